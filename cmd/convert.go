@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+)
+
+const convertUsage = `Usage: vCluster convert <input> <output>
+
+Converts a topology file into another format; the formats are selected by the file extensions:
+.yaml/.yml and .json for the structured format, others for the comma-separated format.
+`
+
+// runConvert translates a topology file, e.g., conf/topology.conf, into another format, e.g., YAML.
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), convertUsage)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	topo := topology.NewTargetTopology(clusterId)
+	if err := topo.LoadTopology(input); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load topology[%s]: %v\n", input, err)
+		return 1
+	}
+
+	if err := topo.SaveTopology(output); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save topology[%s]: %v\n", output, err)
+		return 1
+	}
+
+	fmt.Printf("converted %s to %s\n", input, output)
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+)
+
+// exportOnSignal saves the live cluster into a topology file on each SIGUSR1,
+// so that the state after the actions can be loaded again by --topologyConf.
+func exportOnSignal(handler *target.ClusterHandler, fname string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		for range signals {
+			output := getExportFileName(fname, time.Now())
+			if err := topology.SaveCluster(handler.Snapshot(), output); err != nil {
+				glog.Errorf("failed to export cluster to [%s]: %v", output, err)
+				continue
+			}
+			glog.V(1).Infof("exported cluster to [%s]", output)
+		}
+	}()
+
+	glog.V(1).Infof("send SIGUSR1 to process[%d] to export cluster to [%s]", os.Getpid(), fname)
+}
+
+// getExportFileName adds the time before the extension, so that every export is kept,
+// e.g., topology.conf --> topology.20171025-163000.conf
+func getExportFileName(fname string, now time.Time) string {
+	ext := filepath.Ext(fname)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(fname, ext), now.Format("20060102-150405"), ext)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+)
+
+const genUsage = `Usage: vCluster gen [--seed <seed>] <conf> <output>
+
+Generates a topology file from the templates and their replicas defined in a YAML/JSON file,
+e.g., conf/generator.yaml; the format of the output is selected by the file extension.
+`
+
+// runGen generates a large topology from a few templates.
+func runGen(args []string) int {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	seed := flags.Int64("seed", 0, "seed of the random usage, instead of the one in the conf")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), genUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	conf, err := topology.NewGeneratorConf(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load generator conf[%s]: %v\n", input, err)
+		return 1
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			conf.Seed = *seed
+		}
+	})
+
+	topo, err := conf.Generate(clusterId)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate topology: %v\n", err)
+		return 1
+	}
+
+	if err := topo.SaveTopology(output); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save topology[%s]: %v\n", output, err)
+		return 1
+	}
+
+	fmt.Printf("generated %s from %s: %d nodes, %d vnodes, %d pods\n", output, input,
+		len(topo.NodeTemplateMap), len(topo.VNodeTemplateMap), len(topo.PodTemplateMap))
+	return 0
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/golang/glog"
+	"os"
+	"time"
 
 	"github.com/turbonomic/virtualCluster/pkg/action"
 	"github.com/turbonomic/virtualCluster/pkg/discovery"
@@ -20,29 +23,102 @@ var (
 	targetConf   string
 	opsMgrConf   string
 	topologyConf string
-	stitchType   stitching.StitchingPropertyType = "IP"
-	clusterName  string                          = "clusterName-1"
-	clusterId    string                          = "clusterId-1"
+	stitchType   string
+	proxyVM      bool
+	clusterName  string = "clusterName-1"
+	clusterId    string = "clusterId-1"
+
+	cpuOvercommit float64 = 1.0
+	memOvercommit float64 = 1.0
+
+	journalFile   string
+	replayJournal bool
+	faultConf     string
+	timeouts      string
+	strict        bool
+	exportConf    string
+	generatorConf string
+	traceFile     string
+	traceMode     string
+	traceSpeed    float64
+
+	reloadInterval      time.Duration
+	incrementalInterval time.Duration
+	performanceInterval time.Duration
+	sampleWindow        time.Duration
+	targetIdleTimeout   time.Duration
 )
 
 func getFlags() {
 	flag.StringVar(&opsMgrConf, "turboConf", "./conf/turbo.json", "configuration file of OpsMgr")
 	flag.StringVar(&targetConf, "targetConf", "./conf/target.json", "configuration file of target")
-	flag.StringVar(&topologyConf, "topologyConf", "./conf/topology.conf", "topology definition of the target")
+	flag.StringVar(&topologyConf, "topologyConf", "./conf/topology.conf", "topology definition of the target, in the comma-separated format or .yaml/.json")
+	flag.StringVar(&generatorConf, "generatorConf", "", "templates to generate the topology in memory, instead of --topologyConf; disabled if empty")
 	flag.StringVar(&clusterName, "clusterName", "clusterName-1", "virtual cluster Name")
 	flag.StringVar(&clusterId, "clusterId", "clusterId-1", "virtual cluster Id")
+	flag.Float64Var(&cpuOvercommit, "cpuOvercommit", 1.0, "ratio by which host CPU can be over-committed when moving entities")
+	flag.Float64Var(&memOvercommit, "memOvercommit", 1.0, "ratio by which host memory can be over-committed when moving entities")
+	flag.StringVar(&journalFile, "journal", "", "file to record the executed actions; disabled if empty")
+	flag.BoolVar(&replayJournal, "replayJournal", false, "replay the actions in the journal on top of the topology at startup")
+	flag.StringVar(&faultConf, "faultConf", "", "configuration file of latency and failures injected into actions; disabled if empty")
+	flag.BoolVar(&strict, "strict", false, "refuse to start if the topology has any error, or any entry of --replayJournal fails; reject a target whose topology has any error")
+	flag.StringVar(&exportConf, "exportConf", "", "topology file to save the live cluster into on SIGUSR1, with the time added before the extension; disabled if empty")
+	flag.DurationVar(&reloadInterval, "reloadInterval", 0, "interval to check the topology file, which is reloaded without restart when it is changed; disabled if 0")
+	flag.DurationVar(&incrementalInterval, "incrementalInterval", 0, "interval of the incremental discovery, which sends only the changed and removed entities, at least 1m; disabled if 0")
+	flag.DurationVar(&performanceInterval, "performanceInterval", 0, "interval of the performance discovery, which sends the average and peak usage in --sampleWindow, at least 1m; disabled if 0")
+	flag.DurationVar(&targetIdleTimeout, "targetIdleTimeout", 0, "the cluster of a target added from the server, neither discovered nor acted on within it, is dropped; disabled if 0")
+	flag.DurationVar(&sampleWindow, "sampleWindow", 10*time.Minute, "window of the usage history, sampled in each performance discovery, for its average and peak usage")
+	flag.StringVar(&traceFile, "traceFile", "", "usage of the containers to replay in discovery, in CSV or .yaml/.json; disabled if empty")
+	flag.StringVar(&traceMode, "traceMode", target.TraceRealtime, "how the trace is replayed: realtime, accelerated (by --traceSpeed), or step (one time of the trace per full discovery)")
+	flag.Float64Var(&traceSpeed, "traceSpeed", 60, "speed of the accelerated trace, e.g., 60 replays one hour of the trace in one minute")
+	flag.StringVar(&stitchType, "stitchType", "", "property to stitch the pods to the VMs: IP or UUID; the stitchType of --targetConf, or IP if empty")
+	flag.BoolVar(&proxyVM, "proxyVM", false, "emit the vnodes as proxy VMs, to be replaced by the VMs of the same IP or UUID discovered by another probe; or set proxyVM in --targetConf")
+	flag.StringVar(&timeouts, "actionTimeouts", "", "timeout of action items per action type, e.g., movePod=30s,default=10m")
 
 	//flag.Set("alsologtostderr", "true")
 	flag.Parse()
 }
 
+// newClusterBuilder loads the topology file, or generates the topology by --generatorConf if it is set.
+func newClusterBuilder(clusterId, clusterName, topoConf string) (*topology.ClusterBuilder, error) {
+	if generatorConf == "" {
+		builder := topology.NewClusterBuilder(clusterId, clusterName, topoConf)
+		if builder == nil {
+			return nil, fmt.Errorf("failed to create a cluster builder[%s]", topoConf)
+		}
+		return builder, nil
+	}
+
+	conf, err := topology.NewGeneratorConf(generatorConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load generator conf[%s]: %v", generatorConf, err)
+	}
+	topo, err := conf.Generate(clusterId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate topology by generator conf[%s]: %v", generatorConf, err)
+	}
+	return topology.NewClusterBuilderfromTopology(clusterId, clusterName, topo), nil
+}
+
 func buildCluster(clusterId, clusterName, topoConf string) *target.Cluster {
-	builder := topology.NewClusterBuilder(clusterId, clusterName, topoConf)
-	if builder == nil {
-		err := fmt.Errorf("failed to create a cluster builder[%s]", topoConf)
+	builder, err := newClusterBuilder(clusterId, clusterName, topoConf)
+	if err != nil {
 		glog.Error(err.Error())
 		return nil
 	}
+	return generateCluster(builder, topoConf)
+}
+
+func generateCluster(builder *topology.ClusterBuilder, topoConf string) *target.Cluster {
+	if diagnostics := builder.Validate(); len(diagnostics) > 0 {
+		for _, diagnostic := range diagnostics {
+			glog.Warningf("topology: %v", diagnostic)
+		}
+		if strict {
+			glog.Errorf("topology[%s] has %d errors, refuse to start in strict mode.", topoConf, len(diagnostics))
+			return nil
+		}
+	}
 
 	cluster, err := builder.GenerateCluster()
 	if err != nil {
@@ -56,7 +132,8 @@ func buildCluster(clusterId, clusterName, topoConf string) *target.Cluster {
 	return cluster
 }
 
-func buildClusterHandler(topoConf string) (*target.ClusterHandler, error) {
+// buildClusterHandler builds the cluster; the vnodes are proxy VMs stitched by proxyType, disabled if empty.
+func buildClusterHandler(topoConf string, proxyType stitching.StitchingPropertyType) (*target.ClusterHandler, error) {
 	cluster := buildCluster(clusterId, clusterName, topoConf)
 	if cluster == nil {
 		err := fmt.Errorf("failed to build cluster[%s]", topoConf)
@@ -64,38 +141,211 @@ func buildClusterHandler(topoConf string) (*target.ClusterHandler, error) {
 		return nil, err
 	}
 
+	if traceFile != "" {
+		if err := setTrace(cluster); err != nil {
+			return nil, err
+		}
+	}
+	return newClusterHandler(cluster, proxyType)
+}
+
+// buildTargetClusterHandler builds the cluster of a target added from the server, by the fields of its account;
+// the topology of the probe if topoConf is empty.
+func buildTargetClusterHandler(account *discovery.TargetAccount, topoConf string) (*target.ClusterHandler, error) {
+	var cluster *target.Cluster
+	if topoConf == "" {
+		cluster = buildCluster(account.ClusterId, account.ClusterName, topologyConf)
+	} else if builder := topology.NewClusterBuilder(account.ClusterId, account.ClusterName, topoConf); builder != nil {
+		cluster = generateCluster(builder, topoConf)
+	}
+	if cluster == nil {
+		err := fmt.Errorf("failed to build cluster[%s] of target[%s]", topoConf, account.Address)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	if err := cluster.SetProfileSpeed(account.ProfileSpeed); err != nil {
+		return nil, err
+	}
+	return newClusterHandler(cluster, account.StitchType)
+}
+
+// newClusterHandler returns the handler of the cluster; the vnodes are proxy VMs stitched by proxyType, disabled if empty.
+func newClusterHandler(cluster *target.Cluster, proxyType stitching.StitchingPropertyType) (*target.ClusterHandler, error) {
+	if err := cluster.SetProxyVM(proxyType); err != nil {
+		return nil, err
+	}
+
 	handler := target.NewClusterHandler(cluster)
+	handler.SetOvercommitRatio(cpuOvercommit, memOvercommit)
+	if performanceInterval > 0 {
+		sampler, err := target.NewUsageSampler(sampleWindow)
+		if err != nil {
+			return nil, err
+		}
+		handler.SetSampler(sampler)
+	}
 	return handler, nil
 }
 
-func buildProbe(pType stitching.StitchingPropertyType, targetConf, topoConf string, stop chan struct{}) (*probe.ProbeBuilder, error) {
+func setTrace(cluster *target.Cluster) error {
+	trace, err := topology.LoadTrace(traceFile)
+	if err != nil {
+		return err
+	}
 
-	//1. generate the target Cluster Handler
-	clusterHandler, err := buildClusterHandler(topologyConf)
+	player, err := target.NewTracePlayer(trace, traceMode, traceSpeed)
+	if err == nil {
+		err = cluster.SetTrace(player)
+	}
 	if err != nil {
-		err := fmt.Errorf("failed to build cluster handler for [%s]", topoConf)
+		err = fmt.Errorf("failed to replay trace[%s]: %v", traceFile, err)
 		glog.Error(err.Error())
+		return err
+	}
+	glog.V(2).Infof("replay trace[%s] in %s mode", traceFile, traceMode)
+	return nil
+}
+
+// newActionHandler returns the action handler of a cluster, with the timeouts and the fault injection; the actions
+// in the journal file are replayed before the faults are injected, so that the recorded state is reproduced.
+func newActionHandler(clusterHandler *target.ClusterHandler, stop chan struct{}, replayFile string) (*action.ActionHandler, error) {
+	handler := action.NewActionHandler(clusterHandler, stop)
+	actionTimeouts, err := action.ParseActionTimeouts(timeouts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse action timeouts: %v", err)
+	}
+	handler.SetTimeouts(actionTimeouts)
+
+	if replayFile != "" {
+		err := handler.Replay(replayFile)
+		var replayErr *action.ReplayError
+		if errors.As(err, &replayErr) && !strict {
+			glog.Warningf("%v; the cluster starts with the replayed entries.", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to replay journal[%s]: %v", replayFile, err)
+		}
+	}
+
+	if faultConf != "" {
+		conf, err := action.NewFaultConf(faultConf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load fault conf:%v", err.Error())
+		}
+		handler.SetFaultInjection(conf)
+	}
+	return handler, nil
+}
+
+// buildActionHandler returns the action handler of the cluster of the probe, which also keeps the journal.
+func buildActionHandler(clusterHandler *target.ClusterHandler, stop chan struct{}) (*action.ActionHandler, error) {
+	replayFile := ""
+	if journalFile != "" && replayJournal {
+		replayFile = journalFile
+	}
+	handler, err := newActionHandler(clusterHandler, stop, replayFile)
+	if err != nil {
 		return nil, err
 	}
 
-	//2. generate clients and handlers
+	if journalFile == "" {
+		return handler, nil
+	}
+
+	journal, err := action.NewJournal(journalFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal[%s]: %v", journalFile, err)
+	}
+	handler.SetJournal(journal)
+
+	return handler, nil
+}
+
+// getStitchType returns the stitching type of --stitchType, or of the target conf; IP if neither is set.
+func getStitchType(config *discovery.TargetConf) (stitching.StitchingPropertyType, error) {
+	pType := stitchType
+	if pType == "" {
+		pType = config.StitchType
+	}
+	if pType == "" {
+		return stitching.IP, nil
+	}
+	return stitching.ParseStitchingPropertyType(pType)
+}
+
+// buildProbe returns the probe builder, and the discovery client to register as the incremental and performance discovery.
+func buildProbe(targetConf, topoConf string, stop chan struct{}) (*probe.ProbeBuilder, *discovery.DiscoveryClient, error) {
+
+	//0. load the target conf, and the stitching type
 	config, err := discovery.NewTargetConf(targetConf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load json conf:%v", err.Error())
+		return nil, nil, fmt.Errorf("failed to load json conf:%v", err.Error())
+	}
+	pType, err := getStitchType(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyType := stitching.StitchingPropertyType("")
+	if proxyVM || config.ProxyVM {
+		proxyType = pType
+		glog.V(2).Infof("the vnodes are proxy VMs stitched by %v", pType)
 	}
 
+	//1. generate the target Cluster Handler
+	clusterHandler, err := buildClusterHandler(topologyConf, proxyType)
+	if err != nil {
+		err := fmt.Errorf("failed to build cluster handler for [%s]", topoConf)
+		glog.Error(err.Error())
+		return nil, nil, err
+	}
+	if exportConf != "" {
+		exportOnSignal(clusterHandler, exportConf)
+	}
+
+	//2. generate clients and handlers
 	regClient := registration.NewRegClient(pType)
 	discoveryClient := discovery.NewDiscoveryClient(config, clusterHandler)
-	actionHandler := action.NewActionHandler(clusterHandler, stop)
+	if generatorConf == "" {
+		discoveryClient.SetTopologyConf(topoConf)
+	}
+	discoveryClient.SetStrict(strict)
+	discoveryClient.SetStitchType(pType)
+	if err := discoveryClient.SetIncrementalInterval(incrementalInterval); err != nil {
+		return nil, nil, err
+	}
+	if err := discoveryClient.SetPerformanceInterval(performanceInterval); err != nil {
+		return nil, nil, err
+	}
+	if err := discoveryClient.SetTargetIdleTimeout(targetIdleTimeout); err != nil {
+		return nil, nil, err
+	}
+	discoveryClient.SetClusterHandlerFactory(buildTargetClusterHandler)
+	actionHandler, err := buildActionHandler(clusterHandler, stop)
+	if err != nil {
+		return nil, nil, err
+	}
+	undoOnSignal(actionHandler)
+	if reloadInterval > 0 {
+		watchTopology(actionHandler, clusterHandler, topoConf, reloadInterval, stop)
+	}
+	// the actions of the targets added from the server are executed on their own clusters
+	dispatcher := action.NewActionDispatcher(actionHandler, discoveryClient.GetClusterHandler,
+		func(cluster *target.ClusterHandler) (*action.ActionHandler, error) {
+			return newActionHandler(cluster, stop, "")
+		})
+	discoveryClient.SetEvictionListener(dispatcher.RemoveCluster)
 
 	builder := probe.NewProbeBuilder(config.TargetType, config.ProbeCategory, config.ProbeUICategory).
 		RegisteredBy(regClient).
 		WithActionPolicies(regClient).
 		WithEntityMetadata(regClient).
+		WithDiscoveryOptions(
+			probe.IncrementalRediscoveryIntervalSecondsOption(discoveryClient.GetIncrementalRediscoveryIntervalSeconds()),
+			probe.PerformanceRediscoveryIntervalSecondsOption(discoveryClient.GetPerformanceRediscoveryIntervalSeconds())).
 		DiscoversTarget(config.Address, discoveryClient).
-		ExecutesActionsBy(actionHandler)
+		ExecutesActionsBy(dispatcher)
 
-	return builder, nil
+	return builder, discoveryClient, nil
 }
 
 func createTapService() (*service.TAPService, error) {
@@ -105,7 +355,7 @@ func createTapService() (*service.TAPService, error) {
 	}
 
 	stop := make(chan struct{})
-	probeBuilder, err := buildProbe(stitchType, targetConf, topologyConf, stop)
+	probeBuilder, discoveryClient, err := buildProbe(targetConf, topologyConf, stop)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create probe: %v", err)
 	}
@@ -119,15 +369,38 @@ func createTapService() (*service.TAPService, error) {
 		return nil, fmt.Errorf("error when creating TapService: %v", err.Error())
 	}
 
+	// the probe builder only registers the full discovery
+	if incrementalInterval > 0 {
+		tapService.DiscoveryClient.IIncrementalDiscovery = discoveryClient
+	}
+	if performanceInterval > 0 {
+		tapService.DiscoveryClient.IPerformanceDiscovery = discoveryClient
+	}
+
 	return tapService, nil
 }
 
+// subcommands run instead of the probe, e.g., "vCluster convert <input> <output>"
+var subcommands = map[string]func(args []string) int{
+	"convert":  runConvert,
+	"gen":      runGen,
+	"validate": runValidate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, exist := subcommands[os.Args[1]]; exist {
+			// the subcommands have their own flags, the logs use the default settings
+			flag.CommandLine.Parse(nil)
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	getFlags()
 
 	tap, err := createTapService()
 	if err != nil {
-		glog.Errorf("failed to create tapServier: %v", err)
+		glog.Fatalf("failed to create tapServier: %v", err)
 	}
 
 	tap.ConnectToTurbo()
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/golang/glog"
+	"os"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/action"
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// watchTopology checks the topology file every interval, and when it is changed, applies the change
+// between the two versions of the file to the live cluster, without restarting the probe; the reload is executed
+// by the action handler, between the actions.
+func watchTopology(handler *action.ActionHandler, cluster *target.ClusterHandler, fname string, interval time.Duration,
+	stop chan struct{}) {
+	if generatorConf != "" {
+		glog.Warningf("topology is generated from [%s], it will not be reloaded.", generatorConf)
+		return
+	}
+
+	last, err := os.Stat(fname)
+	if err != nil {
+		glog.Errorf("failed to watch topology[%s]: %v", fname, err)
+		return
+	}
+	// the cluster built from the current version of the file, before any action
+	base := cluster.Snapshot()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(fname)
+			if err != nil {
+				glog.Errorf("failed to check topology[%s]: %v", fname, err)
+				continue
+			}
+			if info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			updated := buildCluster(clusterId, clusterName, fname)
+			if updated == nil {
+				glog.Errorf("failed to reload topology[%s], the cluster is not changed.", fname)
+				continue
+			}
+			diff, err := handler.Reload(fname, base, updated)
+			if err != nil {
+				glog.Errorf("failed to reload topology[%s]: %v", fname, err)
+				continue
+			}
+			base = updated
+			glog.V(1).Infof("reloaded topology[%s]: %v", fname, diff)
+		}
+	}()
+
+	glog.V(1).Infof("watching topology[%s] every %v", fname, interval)
+}
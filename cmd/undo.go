@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/golang/glog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/turbonomic/virtualCluster/pkg/action"
+)
+
+// undoOnSignal undoes the last executed action on each SIGUSR2; the undo is recorded in the journal if there is one.
+func undoOnSignal(handler *action.ActionHandler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+
+	go func() {
+		for range signals {
+			if err := handler.Undo(1); err != nil {
+				glog.Errorf("failed to undo the last action: %v", err)
+				continue
+			}
+			glog.V(1).Infof("undone the last action")
+		}
+	}()
+
+	glog.V(1).Infof("send SIGUSR2 to process[%d] to undo the last action", os.Getpid())
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+)
+
+const validateUsage = `Usage: vCluster validate <topology>
+
+Checks a topology file, and prints all the problems with their positions as <file>:<line>: <problem>.
+Exits with 1 if there is any problem.
+`
+
+// runValidate checks a topology file, e.g., before running the probe with --strict.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), validateUsage)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	fname := flags.Arg(0)
+
+	// the problems are printed below, instead of the error logs
+	flag.Set("stderrthreshold", "FATAL")
+
+	topo := topology.NewTargetTopology(clusterId)
+	err := topo.LoadTopology(fname)
+
+	diagnostics := topo.Validate()
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load topology[%s]: %v\n", fname, err)
+		return 1
+	}
+	if len(diagnostics) > 0 {
+		fmt.Fprintf(os.Stderr, "topology[%s] has %d problems.\n", fname, len(diagnostics))
+		return 1
+	}
+
+	fmt.Printf("topology[%s] is valid.\n", fname)
+	return 0
+}
@@ -5,4 +5,5 @@ go 1.14
 require (
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/turbonomic/turbo-go-sdk v0.0.0-20220203041342-e83a77d10cb6
+	gopkg.in/yaml.v3 v3.0.1
 )
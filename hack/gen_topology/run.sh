@@ -1,3 +0,0 @@
-#!/bin/bash
-
-python gen_topo.py template.json topology.conf
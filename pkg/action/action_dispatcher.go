@@ -0,0 +1,88 @@
+package action
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// ClusterLookup returns the ClusterHandler of the target of the account values.
+type ClusterLookup func(accountValues []*proto.AccountValue) (*target.ClusterHandler, error)
+
+// ActionHandlerFactory builds the ActionHandler of the cluster of a target.
+type ActionHandlerFactory func(cluster *target.ClusterHandler) (*ActionHandler, error)
+
+// ActionDispatcher executes the actions of each target by the ActionHandler of the cluster of the target,
+// built when the first action of the cluster comes.
+type ActionDispatcher struct {
+	defaultHandler *ActionHandler
+	lookup         ClusterLookup
+	factory        ActionHandlerFactory
+
+	// key=the cluster of the ActionHandler
+	handlers map[*target.ClusterHandler]*ActionHandler
+	mux      sync.Mutex
+}
+
+func NewActionDispatcher(defaultHandler *ActionHandler, lookup ClusterLookup, factory ActionHandlerFactory) *ActionDispatcher {
+	return &ActionDispatcher{
+		defaultHandler: defaultHandler,
+		lookup:         lookup,
+		factory:        factory,
+		handlers: map[*target.ClusterHandler]*ActionHandler{
+			defaultHandler.cluster: defaultHandler,
+		},
+	}
+}
+
+func (d *ActionDispatcher) getActionHandler(accountValues []*proto.AccountValue) (*ActionHandler, error) {
+	cluster, err := d.lookup(accountValues)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if handler, exist := d.handlers[cluster]; exist {
+		return handler, nil
+	}
+
+	handler, err := d.factory(cluster)
+	if err != nil {
+		err = fmt.Errorf("failed to build action handler for cluster[%s]: %v", cluster.String(), err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	d.handlers[cluster] = handler
+	return handler, nil
+}
+
+// RemoveCluster drops the ActionHandler of the cluster, e.g., when its target is removed;
+// the handler of the default cluster is kept.
+func (d *ActionDispatcher) RemoveCluster(cluster *target.ClusterHandler) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if cluster != d.defaultHandler.cluster {
+		delete(d.handlers, cluster)
+	}
+}
+
+// ExecuteAction executes the action on the cluster of the target of the account values.
+func (d *ActionDispatcher) ExecuteAction(
+	actionDTO *proto.ActionExecutionDTO,
+	accountValue []*proto.AccountValue,
+	progressTracker sdkprobe.ActionProgressTracker) (*proto.ActionResult, error) {
+
+	handler, err := d.getActionHandler(accountValue)
+	if err != nil {
+		msg := fmt.Sprintf("Action failed: %v", err.Error())
+		glog.Error(msg)
+		return d.defaultHandler.failedResult(msg), nil
+	}
+	return handler.ExecuteAction(actionDTO, accountValue, progressTracker)
+}
@@ -1,8 +1,11 @@
 package action
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"sync"
 	"time"
 
 	"github.com/turbonomic/virtualCluster/pkg/action/executor"
@@ -16,6 +19,18 @@ type ActionHandler struct {
 	cluster         *target.ClusterHandler
 	actionExecutors map[TurboActionType]TurboExecutor
 	stop            chan struct{}
+
+	// timeout of each action item; key=TurboActionType, or ActionUnknown for the default
+	timeouts map[TurboActionType]time.Duration
+
+	// executed actions are recorded in the journal if it is set
+	journal *Journal
+
+	// actions on disjoint entities are executed in parallel
+	locker *entityLocker
+
+	// protects the executors, timeouts and journal
+	mux sync.RWMutex
 }
 
 func NewActionHandler(h *target.ClusterHandler, stop chan struct{}) *ActionHandler {
@@ -25,6 +40,8 @@ func NewActionHandler(h *target.ClusterHandler, stop chan struct{}) *ActionHandl
 		cluster:         h,
 		stop:            stop,
 		actionExecutors: executors,
+		timeouts:        map[TurboActionType]time.Duration{ActionUnknown: defaultActionTimeout},
+		locker:          newEntityLocker(),
 	}
 
 	handler.registerExecutors()
@@ -53,8 +70,91 @@ func (h *ActionHandler) registerExecutors() {
 	vmMover := executor.NewVirtualMachineMover(h.cluster)
 	h.actionExecutors[ActionMoveVM] = vmMover
 
-	vmResizer := executor.NewVirtualMachineMover(h.cluster)
+	vmResizer := executor.NewVMResizer(h.cluster)
 	h.actionExecutors[ActionResizeVM] = vmResizer
+
+	podProvisioner := executor.NewPodProvisioner(h.cluster)
+	h.actionExecutors[ActionProvisionPod] = podProvisioner
+
+	vmProvisioner := executor.NewVirtualMachineProvisioner(h.cluster)
+	h.actionExecutors[ActionProvisionVM] = vmProvisioner
+
+	podSuspender := executor.NewPodSuspender(h.cluster)
+	h.actionExecutors[ActionSuspendPod] = podSuspender
+
+	vmSuspender := executor.NewVirtualMachineSuspender(h.cluster)
+	h.actionExecutors[ActionSuspendVM] = vmSuspender
+
+	specResizer := executor.NewContainerSpecResizer(h.cluster)
+	h.actionExecutors[ActionResizeContainerSpec] = specResizer
+
+	replicaProvisioner := executor.NewReplicaProvisioner(h.cluster)
+	h.actionExecutors[ActionProvisionController] = replicaProvisioner
+
+	replicaSuspender := executor.NewReplicaSuspender(h.cluster)
+	h.actionExecutors[ActionSuspendController] = replicaSuspender
+
+	storageMover := executor.NewVirtualMachineStorageMover(h.cluster)
+	h.actionExecutors[ActionMoveVMStorage] = storageMover
+}
+
+// SetFaultInjection wraps the executors to inject latency and failures; the faults of an action are drawn from
+// the seed of the conf and the UUID of the action.
+func (h *ActionHandler) SetFaultInjection(conf *FaultConf) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for atype, executor := range h.actionExecutors {
+		fault := conf.getFault(atype)
+		if fault == nil {
+			continue
+		}
+
+		h.actionExecutors[atype] = newFaultInjector(executor, fault, conf.Seed)
+		glog.V(2).Infof("inject fault for action[%v]: %+v", atype, fault)
+	}
+}
+
+// SetTimeouts sets the timeout of action items per action type; ActionUnknown sets the default.
+func (h *ActionHandler) SetTimeouts(timeouts map[TurboActionType]time.Duration) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for atype, timeout := range timeouts {
+		h.timeouts[atype] = timeout
+		glog.V(2).Infof("timeout of action[%v]: %v", atype, timeout)
+	}
+}
+
+func (h *ActionHandler) getTimeout(atype TurboActionType) time.Duration {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if timeout, exist := h.timeouts[atype]; exist {
+		return timeout
+	}
+	return h.timeouts[ActionUnknown]
+}
+
+// getActionTimeout returns the sum of the timeouts of the action items.
+func (h *ActionHandler) getActionTimeout(actionTypes []TurboActionType) time.Duration {
+	var result time.Duration
+	for _, atype := range actionTypes {
+		result += h.getTimeout(atype)
+	}
+	return result
+}
+
+// the context of actions is canceled when the probe stops
+func (h *ActionHandler) newContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-h.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
 
 func (h *ActionHandler) goodResult(msg string) *proto.ActionResult {
@@ -89,39 +189,53 @@ func (h *ActionHandler) failedResult(msg string) *proto.ActionResult {
 	}
 }
 
+// ExecuteAction executes all the action items in order; if any of them fails,
+// the affected entities are rolled back to the state before the action.
+// Actions on disjoint entities are executed in parallel.
 func (h *ActionHandler) ExecuteAction(
 	actionDTO *proto.ActionExecutionDTO,
 	accountValue []*proto.AccountValue,
 	progressTracker sdkprobe.ActionProgressTracker) (*proto.ActionResult, error) {
 
 	actionItems := actionDTO.GetActionItem()
-	action := actionItems[0]
-
-	glog.V(3).Infof("action:%+++v", action)
-	actionType, err := getActionType(action)
+	actionTypes, executors, err := h.getExecutors(actionItems)
 	if err != nil {
-		msg := fmt.Sprintf("failed to get Action Type:%v", err.Error())
+		msg := err.Error()
 		glog.Error(msg)
 		result := h.failedResult(msg)
 		return result, nil
 	}
 
-	executor, exist := h.actionExecutors[actionType]
-	if !exist {
-		msg := fmt.Sprintf("action type [%v] is not supported", actionType)
+	// the locks are waited for within the timeouts of the action items
+	ctx, cancel := h.newContext()
+	defer cancel()
+	lockCtx, cancelLock := context.WithTimeout(ctx, h.getActionTimeout(actionTypes))
+	defer cancelLock()
+
+	unlock, err := h.lockEntities(lockCtx, actionItems, actionTypes)
+	if err != nil {
+		msg := fmt.Sprintf("Action timed out: waiting for the affected entities: %v", err)
 		glog.Error(msg)
 		result := h.failedResult(msg)
 		return result, nil
 	}
+	defer unlock()
 
-	// here progressTracker is used to keep alive; executor won't really use it.
-	stop := make(chan struct{})
-	defer close(stop)
-	go keepAlive(progressTracker, stop)
+	keeper := newProgressKeeper(progressTracker, len(actionItems))
+	stopKeepAlive := keeper.keepAlive()
+	defer stopKeepAlive()
 
-	err = executor.Execute(action, progressTracker)
-	if err != nil {
+	if err := h.executeItems(ctx, actionItems, actionTypes, executors, keeper, h.getJournal()); err != nil {
 		msg := fmt.Sprintf("Action failed: %v", err.Error())
+		var reason *target.AdmissionError
+		var placement *target.PlacementError
+		if errors.As(err, &reason) {
+			msg = fmt.Sprintf("Action rejected: %v", reason.Error())
+		} else if errors.As(err, &placement) {
+			msg = fmt.Sprintf("Action rejected: %v", placement.Error())
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			msg = fmt.Sprintf("Action timed out: %v", err.Error())
+		}
 		glog.Error(msg)
 		result := h.failedResult(msg)
 		return result, nil
@@ -131,6 +245,301 @@ func (h *ActionHandler) ExecuteAction(
 	return result, nil
 }
 
+// get the executors for all the items before executing any of them
+func (h *ActionHandler) getExecutors(actionItems []*proto.ActionItemDTO) ([]TurboActionType, []TurboExecutor, error) {
+	if len(actionItems) < 1 {
+		return nil, nil, fmt.Errorf("action has no action item")
+	}
+
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	actionTypes := make([]TurboActionType, len(actionItems))
+	executors := make([]TurboExecutor, len(actionItems))
+	for i, action := range actionItems {
+		glog.V(3).Infof("action[%d]:%+++v", i, action)
+		actionType, err := getActionType(action)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get Action Type:%v", err.Error())
+		}
+
+		executor, exist := h.actionExecutors[actionType]
+		if !exist {
+			return nil, nil, fmt.Errorf("action type [%v] is not supported", actionType)
+		}
+		actionTypes[i] = actionType
+		executors[i] = executor
+	}
+
+	return actionTypes, executors, nil
+}
+
+// lockEntities locks the entities affected by the action items, and returns the function to unlock them; or the
+// error of the context if it is done before the entities are locked.
+// Suspending a VM may evict its Pods to any VNode, and provisioning a replica may place it on any VNode,
+// so they lock the whole cluster.
+func (h *ActionHandler) lockEntities(ctx context.Context, actionItems []*proto.ActionItemDTO,
+	actionTypes []TurboActionType) (func(), error) {
+	for _, atype := range actionTypes {
+		if atype == ActionSuspendVM || atype == ActionProvisionController {
+			if err := h.locker.lockAll(ctx); err != nil {
+				return nil, err
+			}
+			return h.locker.unlockAll, nil
+		}
+	}
+
+	for {
+		ids := h.getAffectedEntities(actionItems)
+		if err := h.locker.lock(ctx, ids); err != nil {
+			return nil, err
+		}
+
+		// the providers may have been changed by other actions before they are locked
+		if containsAll(ids, h.getAffectedEntities(actionItems)) {
+			return func() { h.locker.unlock(ids) }, nil
+		}
+		h.locker.unlock(ids)
+	}
+}
+
+func (h *ActionHandler) getAffectedEntities(actionItems []*proto.ActionItemDTO) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, action := range actionItems {
+		item := newJournalItem(action)
+		ids := h.cluster.GetAffectedEntities(item.EntityId)
+		if item.HostId != "" {
+			ids = append(ids, item.HostId)
+		}
+
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+	return result
+}
+
+// getSnapshotEntities returns the entities to snapshot before the action items, for rollback and undo: the
+// affected entities, and the ones the Pods of a suspended VM may be evicted to.
+func (h *ActionHandler) getSnapshotEntities(actionItems []*proto.ActionItemDTO, actionTypes []TurboActionType) []string {
+	result := h.getAffectedEntities(actionItems)
+	for i, action := range actionItems {
+		if actionTypes[i] == ActionSuspendVM {
+			result = append(result, h.cluster.GetEvictionEntities(action.GetTargetSE().GetId())...)
+		}
+	}
+	return result
+}
+
+func containsAll(ids, others []string) bool {
+	set := make(map[string]bool)
+	for _, id := range ids {
+		set[id] = true
+	}
+	for _, id := range others {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// executeItems executes the items in order, and rolls back all of them if any fails.
+// A successful action is recorded in history for undo by the snapshot of the entities it affects,
+// and in the journal if there is one.
+func (h *ActionHandler) executeItems(ctx context.Context, actionItems []*proto.ActionItemDTO,
+	actionTypes []TurboActionType, executors []TurboExecutor, keeper *progressKeeper, journal *Journal) error {
+	// the states of the entities are only journaled
+	var items []*JournalItem
+	var ids []string
+	var before []*target.EntityState
+	if journal != nil {
+		for _, action := range actionItems {
+			items = append(items, newJournalItem(action))
+		}
+		ids = getEntityIds(items)
+		before = h.cluster.GetEntityStates(ids)
+	}
+
+	affected := h.cluster.SnapshotEntities(h.getSnapshotEntities(actionItems, actionTypes))
+	for i, action := range actionItems {
+		timeout := h.getTimeout(actionTypes[i])
+		itemCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := executors[i].Execute(itemCtx, action, keeper)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("%w after %v", err, timeout)
+			}
+			h.cluster.RestoreEntities(affected)
+			if len(actionItems) > 1 {
+				err = fmt.Errorf("%w (action item %d of %d, rolled back)", err, i+1, len(actionItems))
+			}
+			return err
+		}
+		keeper.itemDone()
+	}
+	h.cluster.RecordHistory(affected)
+
+	if journal != nil {
+		entry := &JournalEntry{
+			Time:   time.Now(),
+			Items:  items,
+			Before: before,
+			After:  h.cluster.GetEntityStates(ids),
+		}
+		if err := journal.Write(entry); err != nil {
+			glog.Errorf("failed to write action to journal: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// SetJournal sets the journal to record the executed actions.
+func (h *ActionHandler) SetJournal(journal *Journal) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.journal = journal
+}
+
+func (h *ActionHandler) getJournal() *Journal {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.journal
+}
+
+// Undo restores the cluster to the state before the last n actions.
+func (h *ActionHandler) Undo(n int) error {
+	if err := h.locker.lockAll(context.Background()); err != nil {
+		return err
+	}
+	defer h.locker.unlockAll()
+
+	if err := h.cluster.Undo(n); err != nil {
+		return err
+	}
+
+	if journal := h.getJournal(); journal != nil {
+		entry := &JournalEntry{
+			Time: time.Now(),
+			Undo: n,
+		}
+		if err := journal.Write(entry); err != nil {
+			glog.Errorf("failed to write undo to journal: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Reload applies the change of the topology file, from old to updated, on the cluster, see ClusterHandler.Reload().
+// It waits for the running actions, so that none of them restores the entities removed by the reload; the reload
+// is recorded in the journal if there is one.
+func (h *ActionHandler) Reload(fname string, old, updated *target.Cluster) (*target.TopologyDiff, error) {
+	if err := h.locker.lockAll(context.Background()); err != nil {
+		return nil, err
+	}
+	defer h.locker.unlockAll()
+
+	diff := h.cluster.Reload(old, updated)
+
+	if journal := h.getJournal(); journal != nil {
+		entry := &JournalEntry{
+			Time:   time.Now(),
+			Reload: fname,
+		}
+		if err := journal.Write(entry); err != nil {
+			glog.Errorf("failed to write reload to journal: %v", err)
+		}
+	}
+
+	return diff, nil
+}
+
+// ReplayError tells that some entries of a journal failed to replay; the other entries are replayed, unless the
+// replay is stopped at a reload of the topology.
+type ReplayError struct {
+	Journal string
+	Failed  int
+	Total   int
+	// the reloaded topology file, if the replay is stopped at its reload
+	Reload string
+}
+
+func (e *ReplayError) Error() string {
+	msg := fmt.Sprintf("%d of %d entries of journal[%s] failed to replay", e.Failed, e.Total, e.Journal)
+	if e.Reload != "" {
+		msg += fmt.Sprintf(", stopped at the reload of topology[%s]", e.Reload)
+	}
+	return msg
+}
+
+// Replay executes the actions recorded in a journal file; failed actions are skipped, and reported by a
+// *ReplayError after all the entries are replayed. Replayed actions are not written to the journal again.
+// The replay stops at the first reload of the topology: the entries after it were executed on a version of the
+// topology file which the journal does not keep, so they are reported as failed.
+func (h *ActionHandler) Replay(fname string) error {
+	entries, err := LoadJournal(fname)
+	if err != nil {
+		return err
+	}
+
+	if err := h.locker.lockAll(context.Background()); err != nil {
+		return err
+	}
+	defer h.locker.unlockAll()
+
+	failed := 0
+	for i, entry := range entries {
+		if entry.Reload != "" {
+			glog.Errorf("stop replaying journal[%s] at entry %d: topology[%s] was reloaded", fname, i+1, entry.Reload)
+			failed += len(entries) - i
+			return &ReplayError{Journal: fname, Failed: failed, Total: len(entries), Reload: entry.Reload}
+		}
+		if err := h.replayEntry(entry); err != nil {
+			glog.Errorf("failed to replay journal[%s] entry %d: %v", fname, i+1, err)
+			failed++
+		}
+	}
+
+	glog.V(2).Infof("replayed %d entries of journal[%s], %d failed.", len(entries), fname, failed)
+	if failed > 0 {
+		return &ReplayError{Journal: fname, Failed: failed, Total: len(entries)}
+	}
+	return nil
+}
+
+func (h *ActionHandler) replayEntry(entry *JournalEntry) error {
+	if entry.Undo > 0 {
+		return h.cluster.Undo(entry.Undo)
+	}
+
+	var actionItems []*proto.ActionItemDTO
+	for _, item := range entry.Items {
+		action, err := item.ActionItemDTO()
+		if err != nil {
+			return err
+		}
+		actionItems = append(actionItems, action)
+	}
+
+	actionTypes, executors, err := h.getExecutors(actionItems)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := h.newContext()
+	defer cancel()
+
+	keeper := newProgressKeeper(&nopTracker{}, len(actionItems))
+	return h.executeItems(ctx, actionItems, actionTypes, executors, keeper, nil)
+}
+
 func getActionType(action *proto.ActionItemDTO) (TurboActionType, error) {
 	atype := action.GetActionType()
 	object := action.GetTargetSE()
@@ -144,13 +553,22 @@ func getActionType(action *proto.ActionItemDTO) (TurboActionType, error) {
 	switch atype {
 	case proto.ActionItemDTO_MOVE:
 		glog.V(4).Infof("[%v] [%v]", atype, objectType)
-		// only support move Pod, and Virtual Machine
+		// only support move Pod, and Virtual Machine to a Node or a Storage
 		switch objectType {
 		case proto.EntityDTO_CONTAINER_POD:
 			return ActionMovePod, nil
 		case proto.EntityDTO_VIRTUAL_MACHINE:
+			if action.GetNewSE().GetEntityType() == proto.EntityDTO_STORAGE {
+				return ActionMoveVMStorage, nil
+			}
 			return ActionMoveVM, nil
 		}
+	case proto.ActionItemDTO_CHANGE:
+		// storage move of a Virtual Machine
+		glog.V(4).Infof("[%v] [%v]", atype, objectType)
+		if objectType == proto.EntityDTO_VIRTUAL_MACHINE {
+			return ActionMoveVMStorage, nil
+		}
 	case proto.ActionItemDTO_RIGHT_SIZE:
 		// Other three RESIZEs are deprecated:
 		// ActionItemDTO_RESIZE, ActionItemDTO_RESIZE_FOR_EFFICIENCY, ActionItemDTO_RESIZE_FOR_PERFORMANCE
@@ -160,34 +578,31 @@ func getActionType(action *proto.ActionItemDTO) (TurboActionType, error) {
 			return ActionResizeContainer, nil
 		case proto.EntityDTO_VIRTUAL_MACHINE:
 			return ActionResizeVM, nil
+		case proto.EntityDTO_CONTAINER_SPEC:
+			return ActionResizeContainerSpec, nil
+		}
+	case proto.ActionItemDTO_PROVISION:
+		glog.V(4).Infof("[%v] [%v]", atype, objectType)
+		switch objectType {
+		case proto.EntityDTO_CONTAINER_POD:
+			return ActionProvisionPod, nil
+		case proto.EntityDTO_VIRTUAL_MACHINE:
+			return ActionProvisionVM, nil
+		case proto.EntityDTO_WORKLOAD_CONTROLLER:
+			return ActionProvisionController, nil
+		}
+	case proto.ActionItemDTO_SUSPEND:
+		glog.V(4).Infof("[%v] [%v]", atype, objectType)
+		switch objectType {
+		case proto.EntityDTO_CONTAINER_POD:
+			return ActionSuspendPod, nil
+		case proto.EntityDTO_VIRTUAL_MACHINE:
+			return ActionSuspendVM, nil
+		case proto.EntityDTO_WORKLOAD_CONTROLLER:
+			return ActionSuspendController, nil
 		}
 	}
 
 	err := fmt.Errorf("Action [%v-%v] is not supported.", atype, objectType)
 	return ActionUnknown, err
 }
-
-func keepAlive(tracker sdkprobe.ActionProgressTracker, stop chan struct{}) {
-	//TODO: add timeout
-	go func() {
-		var progress int32 = 0
-		state := proto.ActionResponseState_IN_PROGRESS
-
-		for {
-			progress = progress + 1
-			if progress > 99 {
-				progress = 99
-			}
-
-			tracker.UpdateProgress(state, "in progress", progress)
-
-			t := time.NewTimer(time.Second * 3)
-			select {
-			case <-stop:
-				glog.V(3).Infof("action keepAlive goroutine exit.")
-				return
-			case <-t.C:
-			}
-		}
-	}()
-}
@@ -0,0 +1,665 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type mockTracker struct {
+	progress []int32
+}
+
+func (t *mockTracker) UpdateProgress(state proto.ActionResponseState, description string, progress int32) {
+	t.progress = append(t.progress, progress)
+}
+
+func newTestActionHandler(t *testing.T) *ActionHandler {
+	return newTestActionHandlerFrom(t, testutil.MakeTestPath("conf/topology.conf"))
+}
+
+func newTestActionHandlerFrom(t *testing.T, fname string) *ActionHandler {
+	builder := topology.NewClusterBuilder("clusterId-1", "testCluster", fname)
+	if builder == nil {
+		t.Fatalf("load topology failed: %s", fname)
+	}
+
+	cluster, err := builder.GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to generate cluster: %v", err)
+	}
+
+	return NewActionHandler(target.NewClusterHandler(cluster), make(chan struct{}))
+}
+
+func newEntity(etype proto.EntityDTO_EntityType, id string) *proto.EntityDTO {
+	return &proto.EntityDTO{
+		EntityType: &etype,
+		Id:         &id,
+	}
+}
+
+func newMoveItem(etype, hostType proto.EntityDTO_EntityType, id, hostId string) *proto.ActionItemDTO {
+	atype := proto.ActionItemDTO_MOVE
+	uuid := "action-" + id
+	return &proto.ActionItemDTO{
+		ActionType: &atype,
+		Uuid:       &uuid,
+		TargetSE:   newEntity(etype, id),
+		NewSE:      newEntity(hostType, hostId),
+	}
+}
+
+// get the provider of an entity from the discovered DTOs
+func getProvider(t *testing.T, h *ActionHandler, id string) string {
+	dtos, err := h.cluster.GenerateClusterDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+
+	for _, dto := range dtos {
+		if dto.GetId() == id {
+			return dto.GetCommoditiesBought()[0].GetProviderId()
+		}
+	}
+	return ""
+}
+
+func TestActionHandler_ExecuteAction(t *testing.T) {
+	h := newTestActionHandler(t)
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	pm := proto.EntityDTO_PHYSICAL_MACHINE
+
+	actionDTO := &proto.ActionExecutionDTO{
+		ActionItem: []*proto.ActionItemDTO{
+			newMoveItem(pod, vm, "pod-1", "vnode-2"),
+			newMoveItem(vm, pm, "vnode-2", "node-1"),
+		},
+	}
+
+	tracker := &mockTracker{}
+	result, _ := h.ExecuteAction(actionDTO, nil, tracker)
+	if state := result.GetResponse().GetActionResponseState(); state != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("action failed: %v", result.GetResponse().GetResponseDescription())
+	}
+
+	if p := getProvider(t, h, "pod-1"); p != "vnode-2" {
+		t.Errorf("pod-1 should be moved to vnode-2, but on %s", p)
+	}
+	if p := getProvider(t, h, "vnode-2"); p != "node-1" {
+		t.Errorf("vnode-2 should be moved to node-1, but on %s", p)
+	}
+
+	// progress reflects the completion of the first item
+	found := false
+	for _, p := range tracker.progress {
+		if p == 50 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("progress of the first item is not reported: %v", tracker.progress)
+	}
+}
+
+func TestActionHandler_ExecuteActionRollback(t *testing.T) {
+	h := newTestActionHandler(t)
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+
+	actionDTO := &proto.ActionExecutionDTO{
+		ActionItem: []*proto.ActionItemDTO{
+			newMoveItem(pod, vm, "pod-1", "vnode-2"),
+			newMoveItem(pod, vm, "pod-x", "vnode-2"),
+		},
+	}
+
+	result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+	response := result.GetResponse()
+	if state := response.GetActionResponseState(); state != proto.ActionResponseState_FAILED {
+		t.Fatalf("action should fail, but got: %v", state)
+	}
+	if !strings.Contains(response.GetResponseDescription(), "2 of 2") {
+		t.Errorf("failed item is not reported: %s", response.GetResponseDescription())
+	}
+
+	if p := getProvider(t, h, "pod-1"); p != "vnode-1" {
+		t.Errorf("move of pod-1 should be rolled back, but it is on %s", p)
+	}
+}
+
+func TestActionHandler_JournalReplay(t *testing.T) {
+	fname := filepath.Join(testutil.TempDir(t), "journal.json")
+	journal, err := NewJournal(fname)
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	defer journal.Close()
+
+	h := newTestActionHandler(t)
+	h.SetJournal(journal)
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	pm := proto.EntityDTO_PHYSICAL_MACHINE
+
+	for _, item := range []*proto.ActionItemDTO{
+		newMoveItem(pod, vm, "pod-1", "vnode-2"),
+		newMoveItem(pod, vm, "pod-3", "vnode-1"),
+		newMoveItem(vm, pm, "vnode-2", "node-1"),
+	} {
+		actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{item}}
+		result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+		if result.GetResponse().GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+			t.Fatalf("action failed: %v", result.GetResponse().GetResponseDescription())
+		}
+	}
+
+	if err := h.Undo(1); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if p := getProvider(t, h, "vnode-2"); p != "node-2" {
+		t.Errorf("move of vnode-2 should be undone, but it is on %s", p)
+	}
+
+	entries, err := LoadJournal(fname)
+	if err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(entries) != 4 || entries[3].Undo != 1 {
+		t.Fatalf("journal should have 3 actions and 1 undo, got %d entries", len(entries))
+	}
+	if before := entries[0].Before; len(before) != 2 || before[0].Provider != "vnode-1" {
+		t.Errorf("wrong before state of pod-1: %+v", before)
+	}
+	if after := entries[0].After; len(after) != 2 || after[0].Provider != "vnode-2" {
+		t.Errorf("wrong after state of pod-1: %+v", after)
+	}
+
+	// replay on a new cluster reproduces the same state
+	h2 := newTestActionHandler(t)
+	if err := h2.Replay(fname); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	expected := map[string]string{"pod-1": "vnode-2", "pod-3": "vnode-1", "vnode-2": "node-2"}
+	for id, host := range expected {
+		if p := getProvider(t, h2, id); p != host {
+			t.Errorf("%s should be on %s after replay, but on %s", id, host, p)
+		}
+	}
+
+	// a failed entry is skipped, and reported after the others are replayed
+	failed := &JournalEntry{Time: time.Now(), Items: []*JournalItem{newJournalItem(newMoveItem(pod, vm, "pod-x", "vnode-2"))}}
+	if err := journal.Write(failed); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+	h3 := newTestActionHandler(t)
+	var replayErr *ReplayError
+	if err := h3.Replay(fname); !errors.As(err, &replayErr) || replayErr.Failed != 1 || replayErr.Total != 5 {
+		t.Errorf("replay should report 1 of 5 entries failed, but got %v", err)
+	}
+	if p := getProvider(t, h3, "pod-1"); p != "vnode-2" {
+		t.Errorf("pod-1 should be on vnode-2 after replay, but on %s", p)
+	}
+}
+
+func TestActionHandler_ReloadJournal(t *testing.T) {
+	fname := filepath.Join(testutil.TempDir(t), "journal.json")
+	journal, err := NewJournal(fname)
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	defer journal.Close()
+
+	h := newTestActionHandler(t)
+	h.SetJournal(journal)
+	base := h.cluster.Snapshot()
+
+	// the reload waits for the running actions
+	ids := []string{"pod-1", "vnode-1"}
+	if err := h.locker.lock(context.Background(), ids); err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := h.Reload("topology.conf", base, base)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatalf("reload should wait for the running actions")
+	case <-time.After(20 * time.Millisecond):
+	}
+	h.locker.unlock(ids)
+	if err := <-done; err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{newMoveItem(pod, vm, "pod-1", "vnode-2")}}
+	if result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{}); result.GetResponse().GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("action failed: %v", result.GetResponse().GetResponseDescription())
+	}
+
+	entries, err := LoadJournal(fname)
+	if err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Reload != "topology.conf" {
+		t.Fatalf("journal should have 1 reload and 1 action, got %+v", entries)
+	}
+
+	// the replay stops at the reload
+	h2 := newTestActionHandler(t)
+	var replayErr *ReplayError
+	if err := h2.Replay(fname); !errors.As(err, &replayErr) || replayErr.Failed != 2 || replayErr.Reload != "topology.conf" {
+		t.Errorf("replay should stop at the reload, but got %v", err)
+	}
+	if p := getProvider(t, h2, "pod-1"); p != "vnode-1" {
+		t.Errorf("action after the reload should not be replayed, but pod-1 is on %s", p)
+	}
+}
+
+func TestActionHandler_UndoSuspendVM(t *testing.T) {
+	h := newTestActionHandler(t)
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	pm := proto.EntityDTO_PHYSICAL_MACHINE
+
+	suspend := proto.ActionItemDTO_SUSPEND
+	uuid := "action-vnode-2"
+	for _, item := range []*proto.ActionItemDTO{
+		newMoveItem(pod, vm, "pod-1", "vnode-2"),
+		{ActionType: &suspend, Uuid: &uuid, TargetSE: newEntity(vm, "vnode-2")},
+		newMoveItem(vm, pm, "vnode-1", "node-2"),
+	} {
+		actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{item}}
+		result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+		if result.GetResponse().GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+			t.Fatalf("action failed: %v", result.GetResponse().GetResponseDescription())
+		}
+	}
+
+	// undo the suspension restores the evicted pods, and keeps the move of pod-1 before it
+	if err := h.Undo(2); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	expected := map[string]string{"pod-1": "vnode-2", "pod-3": "vnode-2", "vnode-1": "node-1", "vnode-2": "node-2"}
+	for id, host := range expected {
+		if p := getProvider(t, h, id); p != host {
+			t.Errorf("%s should be on %s after undo, but on %s", id, host, p)
+		}
+	}
+	if pods := h.cluster.GetEntityStates([]string{"pod-2", "pod-3"}); len(pods) != 2 || pods[0].Provider != "vnode-1" {
+		t.Errorf("wrong pods after undo: %+v", pods)
+	}
+}
+
+func TestActionHandler_ExecuteActionTimeout(t *testing.T) {
+	h := newTestActionHandler(t)
+	h.SetFaultInjection(&FaultConf{
+		Actions: map[string]*ActionFault{
+			string(ActionMoveVM): {Hang: true},
+		},
+	})
+	h.SetTimeouts(map[TurboActionType]time.Duration{ActionMoveVM: 50 * time.Millisecond})
+
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	pm := proto.EntityDTO_PHYSICAL_MACHINE
+
+	actionDTO := &proto.ActionExecutionDTO{
+		ActionItem: []*proto.ActionItemDTO{
+			newMoveItem(pod, vm, "pod-1", "vnode-2"),
+			newMoveItem(vm, pm, "vnode-2", "node-1"),
+		},
+	}
+
+	result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+	response := result.GetResponse()
+	if state := response.GetActionResponseState(); state != proto.ActionResponseState_FAILED {
+		t.Fatalf("action should fail, but got: %v", state)
+	}
+	if !strings.Contains(response.GetResponseDescription(), "timed out") {
+		t.Errorf("timeout is not reported: %s", response.GetResponseDescription())
+	}
+
+	if p := getProvider(t, h, "pod-1"); p != "vnode-1" {
+		t.Errorf("move of pod-1 should be rolled back, but it is on %s", p)
+	}
+	if p := getProvider(t, h, "vnode-2"); p != "node-2" {
+		t.Errorf("vnode-2 should not be moved, but it is on %s", p)
+	}
+}
+
+func TestActionHandler_ExecuteActionLockTimeout(t *testing.T) {
+	h := newTestActionHandler(t)
+	h.SetTimeouts(map[TurboActionType]time.Duration{ActionMovePod: 50 * time.Millisecond})
+
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	actionDTO := &proto.ActionExecutionDTO{
+		ActionItem: []*proto.ActionItemDTO{newMoveItem(pod, vm, "pod-1", "vnode-2")},
+	}
+
+	// the locks are given up within the timeout of the action
+	if err := h.locker.lockAll(context.Background()); err != nil {
+		t.Fatalf("failed to lock the cluster: %v", err)
+	}
+	result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+	response := result.GetResponse()
+	if state := response.GetActionResponseState(); state != proto.ActionResponseState_FAILED ||
+		!strings.Contains(response.GetResponseDescription(), "timed out") {
+		t.Errorf("action should time out waiting for the locks, but got: %v %s", state, response.GetResponseDescription())
+	}
+	if p := getProvider(t, h, "pod-1"); p != "vnode-1" {
+		t.Errorf("pod-1 should not be moved, but it is on %s", p)
+	}
+
+	h.locker.unlockAll()
+	result, _ = h.ExecuteAction(actionDTO, nil, &mockTracker{})
+	if state := result.GetResponse().GetActionResponseState(); state != proto.ActionResponseState_SUCCEEDED {
+		t.Errorf("move failed after the cluster is unlocked: %v", result.GetResponse().GetResponseDescription())
+	}
+}
+
+func newResizeItem(id string, ctype proto.CommodityDTO_CommodityType, capacity float64) *proto.ActionItemDTO {
+	atype := proto.ActionItemDTO_RIGHT_SIZE
+	uuid := "action-" + id
+	return &proto.ActionItemDTO{
+		ActionType: &atype,
+		Uuid:       &uuid,
+		TargetSE:   newEntity(proto.EntityDTO_CONTAINER, id),
+		NewComm: &proto.CommodityDTO{
+			CommodityType: &ctype,
+			Capacity:      &capacity,
+		},
+	}
+}
+
+func TestActionHandler_ExecuteActionParallel(t *testing.T) {
+	h := newTestActionHandler(t)
+	h.SetFaultInjection(&FaultConf{
+		Actions: map[string]*ActionFault{
+			string(ActionMoveVM): {Hang: true},
+		},
+	})
+	h.SetTimeouts(map[TurboActionType]time.Duration{ActionMoveVM: time.Second})
+
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	pm := proto.EntityDTO_PHYSICAL_MACHINE
+
+	moveDone := make(chan *proto.ActionResult)
+	go func() {
+		actionDTO := &proto.ActionExecutionDTO{
+			ActionItem: []*proto.ActionItemDTO{newMoveItem(vm, pm, "vnode-1", "node-2")},
+		}
+		result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+		moveDone <- result
+	}()
+
+	// the resize of a container on the other VNode is not blocked by the hanging move
+	actionDTO := &proto.ActionExecutionDTO{
+		ActionItem: []*proto.ActionItemDTO{newResizeItem("containerC-pod-3", proto.CommodityDTO_VCPU, 250)},
+	}
+	result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+	if state := result.GetResponse().GetActionResponseState(); state != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("resize failed: %v", result.GetResponse().GetResponseDescription())
+	}
+
+	select {
+	case <-moveDone:
+		t.Fatalf("resize should not wait for the move")
+	default:
+	}
+
+	result = <-moveDone
+	if state := result.GetResponse().GetActionResponseState(); state != proto.ActionResponseState_FAILED {
+		t.Fatalf("move should time out, but got: %v", state)
+	}
+
+	// rollback of the move keeps the resize
+	if p := getProvider(t, h, "vnode-1"); p != "node-1" {
+		t.Errorf("move of vnode-1 should be rolled back, but it is on %s", p)
+	}
+	states := h.cluster.GetEntityStates([]string{"containerC-pod-3"})
+	if len(states) != 1 || states[0].CPU.Capacity != 250 {
+		t.Errorf("resize of container should be kept: %+v", states)
+	}
+}
+
+func TestActionHandler_ExecuteActionConcurrent(t *testing.T) {
+	h := newTestActionHandler(t)
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"pod-1", "pod-2", "pod-3"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				host := []string{"vnode-1", "vnode-2"}[i%2]
+				actionDTO := &proto.ActionExecutionDTO{
+					ActionItem: []*proto.ActionItemDTO{newMoveItem(pod, vm, id, host)},
+				}
+				result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+				if state := result.GetResponse().GetActionResponseState(); state != proto.ActionResponseState_SUCCEEDED {
+					t.Errorf("move of %s failed: %v", id, result.GetResponse().GetResponseDescription())
+				}
+			}
+		}(id)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if _, err := h.cluster.GenerateClusterDTOs(); err != nil {
+				t.Errorf("discovery failed: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	// the last move of every Pod is to vnode-2
+	for _, id := range []string{"pod-1", "pod-2", "pod-3"} {
+		if p := getProvider(t, h, id); p != "vnode-2" {
+			t.Errorf("%s should be on vnode-2, but on %s", id, p)
+		}
+	}
+}
+
+func TestParseActionTimeouts(t *testing.T) {
+	timeouts, err := ParseActionTimeouts("movePod=30s, default=5m")
+	if err != nil {
+		t.Fatalf("failed to parse timeouts: %v", err)
+	}
+	if timeouts[ActionMovePod] != 30*time.Second || timeouts[ActionUnknown] != 5*time.Minute {
+		t.Errorf("wrong timeouts: %v", timeouts)
+	}
+
+	for _, value := range []string{"movePod", "movePod=abc", "movePod=-1s", "movepod=30s", "unknown=1m"} {
+		if _, err := ParseActionTimeouts(value); err == nil {
+			t.Errorf("parse [%s] should fail", value)
+		}
+	}
+}
+
+func newControllerItem(atype proto.ActionItemDTO_ActionType, id string) *proto.ActionItemDTO {
+	uuid := "action-" + id
+	return &proto.ActionItemDTO{
+		ActionType: &atype,
+		Uuid:       &uuid,
+		TargetSE:   newEntity(proto.EntityDTO_WORKLOAD_CONTROLLER, id),
+	}
+}
+
+func TestActionHandler_ExecuteControllerActions(t *testing.T) {
+	h := newTestActionHandlerFrom(t, testutil.MakeTestPath("conf/controller.topology.conf"))
+	execute := func(item *proto.ActionItemDTO) *proto.ActionResponse {
+		actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{item}}
+		result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+		return result.GetResponse()
+	}
+
+	resize := newResizeItem("frontend/containerA", proto.CommodityDTO_VCPU, 250)
+	resize.TargetSE = newEntity(proto.EntityDTO_CONTAINER_SPEC, "frontend/containerA")
+	if response := execute(resize); response.GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("resize containerSpec failed: %v", response.GetResponseDescription())
+	}
+	dtos, err := h.cluster.GenerateClusterDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+	for _, dto := range dtos {
+		if dto.GetEntityType() != proto.EntityDTO_CONTAINER || !strings.HasPrefix(dto.GetId(), "containerA-") {
+			continue
+		}
+		for _, comm := range dto.GetCommoditiesSold() {
+			if comm.GetCommodityType() == proto.CommodityDTO_VCPU && comm.GetCapacity() != 250 {
+				t.Errorf("%s should be resized to 250, but got %v", dto.GetId(), comm.GetCapacity())
+			}
+		}
+	}
+
+	// the CPU limit quota of namespace web takes only one more replica of frontend
+	if response := execute(newControllerItem(proto.ActionItemDTO_PROVISION, "frontend")); response.GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("provision replica failed: %v", response.GetResponseDescription())
+	}
+	response := execute(newControllerItem(proto.ActionItemDTO_PROVISION, "frontend"))
+	if !strings.HasPrefix(response.GetResponseDescription(), "Action rejected") {
+		t.Errorf("provision replica beyond the quota should be rejected, but got: %v", response.GetResponseDescription())
+	}
+
+	if response := execute(newControllerItem(proto.ActionItemDTO_SUSPEND, "db")); response.GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("suspend replica failed: %v", response.GetResponseDescription())
+	}
+	if response := execute(newControllerItem(proto.ActionItemDTO_SUSPEND, "db")); response.GetActionResponseState() != proto.ActionResponseState_FAILED {
+		t.Errorf("suspend the last replica should fail")
+	}
+	if p := getProvider(t, h, "pod-3"); p != "vnode-1" {
+		t.Errorf("pod-3 should be kept on vnode-1, but on %s", p)
+	}
+}
+
+func TestActionHandler_ExecuteStorageActions(t *testing.T) {
+	h := newTestActionHandlerFrom(t, testutil.MakeTestPath("conf/storage.topology.conf"))
+	execute := func(item *proto.ActionItemDTO) *proto.ActionResponse {
+		actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{item}}
+		result, _ := h.ExecuteAction(actionDTO, nil, &mockTracker{})
+		return result.GetResponse()
+	}
+	// the storage is the second provider of a vnode, after its node
+	getStorage := func(id string) string {
+		dtos, err := h.cluster.GenerateClusterDTOs()
+		if err != nil {
+			t.Fatalf("failed to generate DTOs: %v", err)
+		}
+		for _, dto := range dtos {
+			if dto.GetId() == id && len(dto.GetCommoditiesBought()) > 1 {
+				return dto.GetCommoditiesBought()[1].GetProviderId()
+			}
+		}
+		return ""
+	}
+
+	move := newMoveItem(proto.EntityDTO_VIRTUAL_MACHINE, proto.EntityDTO_STORAGE, "vnode-1", "storage-2")
+	if response := execute(move); response.GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("move storage of vnode-1 failed: %v", response.GetResponseDescription())
+	}
+	if s := getStorage("vnode-1"); s != "storage-2" {
+		t.Errorf("vnode-1 should be on storage-2, but on %s", s)
+	}
+
+	// storage-2 has no room for the disk of vnode-2
+	change := newMoveItem(proto.EntityDTO_VIRTUAL_MACHINE, proto.EntityDTO_STORAGE, "vnode-2", "storage-2")
+	atype := proto.ActionItemDTO_CHANGE
+	change.ActionType = &atype
+	response := execute(change)
+	if !strings.HasPrefix(response.GetResponseDescription(), "Action rejected") {
+		t.Errorf("move storage beyond the capacity should be rejected, but got: %v", response.GetResponseDescription())
+	}
+
+	resize := newResizeItem("vnode-2", proto.CommodityDTO_VSTORAGE, 30720)
+	resize.TargetSE = newEntity(proto.EntityDTO_VIRTUAL_MACHINE, "vnode-2")
+	if response := execute(resize); response.GetActionResponseState() != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("resize disk of vnode-2 failed: %v", response.GetResponseDescription())
+	}
+
+	// the volumes of pod-4 take 10240
+	resize = newResizeItem("vnode-2", proto.CommodityDTO_VSTORAGE, 8192)
+	resize.TargetSE = newEntity(proto.EntityDTO_VIRTUAL_MACHINE, "vnode-2")
+	if response := execute(resize); response.GetActionResponseState() != proto.ActionResponseState_FAILED {
+		t.Errorf("resize disk below the volumes should fail")
+	}
+}
+
+func TestActionDispatcher_ExecuteAction(t *testing.T) {
+	defaultHandler := newTestActionHandler(t)
+	other := newTestActionHandler(t).cluster
+	lookup := func(accountValues []*proto.AccountValue) (*target.ClusterHandler, error) {
+		switch accountValues[0].GetStringValue() {
+		case "default":
+			return defaultHandler.cluster, nil
+		case "other":
+			return other, nil
+		}
+		return nil, fmt.Errorf("unknown target")
+	}
+	built := 0
+	d := NewActionDispatcher(defaultHandler, lookup, func(cluster *target.ClusterHandler) (*ActionHandler, error) {
+		built++
+		return NewActionHandler(cluster, make(chan struct{})), nil
+	})
+
+	execute := func(address string, item *proto.ActionItemDTO) proto.ActionResponseState {
+		key := "address"
+		accountValues := []*proto.AccountValue{{Key: &key, StringValue: &address}}
+		actionDTO := &proto.ActionExecutionDTO{ActionItem: []*proto.ActionItemDTO{item}}
+		result, _ := d.ExecuteAction(actionDTO, accountValues, &mockTracker{})
+		return result.GetResponse().GetActionResponseState()
+	}
+
+	pod := proto.EntityDTO_CONTAINER_POD
+	vm := proto.EntityDTO_VIRTUAL_MACHINE
+	if state := execute("other", newMoveItem(pod, vm, "pod-1", "vnode-2")); state != proto.ActionResponseState_SUCCEEDED {
+		t.Fatalf("move pod-1 on the other target failed: %v", state)
+	}
+	if provider := getProvider(t, NewActionHandler(other, nil), "pod-1"); provider != "vnode-2" {
+		t.Errorf("pod-1 should be moved to vnode-2 on the other target, but got %s", provider)
+	}
+	if provider := getProvider(t, defaultHandler, "pod-1"); provider != "vnode-1" {
+		t.Errorf("pod-1 should stay on vnode-1 on the default target, but got %s", provider)
+	}
+
+	if state := execute("other", newMoveItem(pod, vm, "pod-1", "vnode-1")); state != proto.ActionResponseState_SUCCEEDED || built != 1 {
+		t.Errorf("action handler of the other target should be built once, but got %d: %v", built, state)
+	}
+	if state := execute("default", newMoveItem(pod, vm, "pod-3", "vnode-1")); state != proto.ActionResponseState_SUCCEEDED || built != 1 {
+		t.Errorf("default target should be served by the default handler: %v", state)
+	}
+	if state := execute("unknown", newMoveItem(pod, vm, "pod-1", "vnode-2")); state != proto.ActionResponseState_FAILED {
+		t.Errorf("action of an unknown target should fail, but got %v", state)
+	}
+
+	// the handler of a removed cluster is dropped, the default one is kept
+	d.RemoveCluster(other)
+	d.RemoveCluster(defaultHandler.cluster)
+	if len(d.handlers) != 1 || d.handlers[defaultHandler.cluster] != defaultHandler {
+		t.Errorf("only the default handler should be kept, but got %d handlers", len(d.handlers))
+	}
+}
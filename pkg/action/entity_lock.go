@@ -0,0 +1,117 @@
+package action
+
+import (
+	"context"
+	"sync"
+)
+
+// entityLocker makes sure that the actions on the same entities are executed one by one,
+// while the actions on disjoint entities can be executed in parallel.
+// Waiting for the locks gives up when the context is done.
+type entityLocker struct {
+	mux sync.Mutex
+	// closed and replaced whenever a lock is released, to wake up the waiting ones
+	changed chan struct{}
+	locked  map[string]bool
+	// number of the actions holding some entities
+	holders int
+	// the whole cluster is locked, or being waited for; the latter blocks new locks of entities
+	all        bool
+	waitingAll int
+}
+
+func newEntityLocker() *entityLocker {
+	return &entityLocker{
+		changed: make(chan struct{}),
+		locked:  make(map[string]bool),
+	}
+}
+
+// wait waits until ready() is true, and returns with l.mux held; or returns the error of the context, with
+// l.mux released.
+func (l *entityLocker) wait(ctx context.Context, ready func() bool) error {
+	l.mux.Lock()
+	for !ready() {
+		changed := l.changed
+		l.mux.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		l.mux.Lock()
+	}
+	return nil
+}
+
+// notify wakes up the waiting ones; l.mux should be held.
+func (l *entityLocker) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// lock waits until none of the entities is locked, and then locks all of them at once.
+func (l *entityLocker) lock(ctx context.Context, ids []string) error {
+	err := l.wait(ctx, func() bool {
+		return !l.all && l.waitingAll == 0 && !l.isLocked(ids)
+	})
+	if err != nil {
+		return err
+	}
+	defer l.mux.Unlock()
+
+	for _, id := range ids {
+		l.locked[id] = true
+	}
+	l.holders++
+	return nil
+}
+
+func (l *entityLocker) unlock(ids []string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, id := range ids {
+		delete(l.locked, id)
+	}
+	l.holders--
+	l.notify()
+}
+
+func (l *entityLocker) isLocked(ids []string) bool {
+	for _, id := range ids {
+		if l.locked[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// lockAll waits until no entity is locked, and then locks the whole cluster.
+func (l *entityLocker) lockAll(ctx context.Context) error {
+	l.mux.Lock()
+	l.waitingAll++
+	l.mux.Unlock()
+
+	err := l.wait(ctx, func() bool {
+		return !l.all && l.holders == 0
+	})
+	if err != nil {
+		l.mux.Lock()
+		l.waitingAll--
+		l.notify()
+		l.mux.Unlock()
+		return err
+	}
+	defer l.mux.Unlock()
+
+	l.waitingAll--
+	l.all = true
+	return nil
+}
+
+func (l *entityLocker) unlockAll() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.all = false
+	l.notify()
+}
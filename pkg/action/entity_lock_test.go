@@ -0,0 +1,43 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEntityLocker_GiveUp(t *testing.T) {
+	l := newEntityLocker()
+	if err := l.lock(context.Background(), []string{"pod-1", "vnode-1"}); err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.lock(ctx, []string{"vnode-1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lock of a locked entity should give up at the deadline, but got %v", err)
+	}
+	if err := l.lockAll(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lock of the cluster should give up at the deadline, but got %v", err)
+	}
+
+	// the given up lock of the cluster does not block the other entities
+	if err := l.lock(context.Background(), []string{"vnode-2"}); err != nil {
+		t.Fatalf("failed to lock vnode-2: %v", err)
+	}
+	l.unlock([]string{"vnode-2"})
+	l.unlock([]string{"pod-1", "vnode-1"})
+
+	if err := l.lockAll(context.Background()); err != nil {
+		t.Fatalf("failed to lock the cluster: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- l.lock(context.Background(), []string{"vnode-1"})
+	}()
+	l.unlockAll()
+	if err := <-done; err != nil {
+		t.Errorf("lock should be taken after the cluster is unlocked: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkCanceled should be called right before changing the cluster: once the action is canceled
+// or timed out, the executor should return without touching the cluster.
+func checkCanceled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("action is canceled: %w", err)
+	}
+	return nil
+}
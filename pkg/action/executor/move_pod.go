@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/glog"
 
@@ -20,7 +21,7 @@ func NewPodMover(c *target.ClusterHandler) *PodMover {
 	}
 }
 
-func (m *PodMover) Execute(actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+func (m *PodMover) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
 	glog.V(2).Infof("begin to move a Pod.")
 
 	//1. check
@@ -44,9 +45,12 @@ func (m *PodMover) Execute(actionItem *proto.ActionItemDTO, progressTracker sdkp
 	hostId := hostEntity.GetId()
 
 	glog.V(2).Infof("podId: %s, new VNodeId:%s", podId, hostId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
 	err := m.cluster.MovePod(podId, hostId)
 	if err != nil {
-		return fmt.Errorf("move failed: %v", err)
+		return fmt.Errorf("move failed: %w", err)
 	}
 
 	return nil
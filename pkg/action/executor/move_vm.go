@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/glog"
 
@@ -20,7 +21,7 @@ func NewVirtualMachineMover(c *target.ClusterHandler) *VirtualMachineMover {
 	}
 }
 
-func (m *VirtualMachineMover) Execute(actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+func (m *VirtualMachineMover) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
 	glog.V(2).Infof("begin to move a VirtualMachine.")
 
 	//1. check
@@ -44,9 +45,12 @@ func (m *VirtualMachineMover) Execute(actionItem *proto.ActionItemDTO, progressT
 	hostId := hostEntity.GetId()
 
 	glog.V(2).Infof("move vnodeId: %s, new NodeId:%s", vmId, hostId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
 	err := m.cluster.MoveVirtualMachine(vmId, hostId)
 	if err != nil {
-		return fmt.Errorf("move failed: %v", err)
+		return fmt.Errorf("move failed: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// VirtualMachineStorageMover moves the disk of a VirtualMachine to another Storage.
+type VirtualMachineStorageMover struct {
+	cluster *target.ClusterHandler
+}
+
+func NewVirtualMachineStorageMover(c *target.ClusterHandler) *VirtualMachineStorageMover {
+	return &VirtualMachineStorageMover{
+		cluster: c,
+	}
+}
+
+func (m *VirtualMachineStorageMover) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to move the storage of a VirtualMachine.")
+
+	//1. check
+	vmEntity := actionItem.GetTargetSE()
+	if vmEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	storageEntity := actionItem.GetNewSE()
+	if storageEntity == nil {
+		return fmt.Errorf("StorageEntity is empty.")
+	}
+
+	storageType := storageEntity.GetEntityType()
+	if storageType != proto.EntityDTO_STORAGE {
+		return fmt.Errorf("new storage entity is not a Storage: %v", storageType)
+	}
+
+	//2. move
+	vmId := vmEntity.GetId()
+	storageId := storageEntity.GetId()
+
+	glog.V(2).Infof("move vnodeId: %s, new storageId:%s", vmId, storageId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.MoveVirtualMachineStorage(vmId, storageId); err != nil {
+		return fmt.Errorf("move storage failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type PodProvisioner struct {
+	cluster *target.ClusterHandler
+}
+
+func NewPodProvisioner(c *target.ClusterHandler) *PodProvisioner {
+	return &PodProvisioner{
+		cluster: c,
+	}
+}
+
+func (m *PodProvisioner) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to provision a Pod.")
+
+	//1. check
+	podEntity := actionItem.GetTargetSE()
+	if podEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	// the new Pod is placed on the original VNode if no host is specified.
+	hostId := ""
+	if hostEntity := getProvisionHost(actionItem); hostEntity != nil {
+		hostType := hostEntity.GetEntityType()
+		if hostType != proto.EntityDTO_VIRTUAL_MACHINE {
+			return fmt.Errorf("host entity is not a VM: %v", hostType)
+		}
+		hostId = hostEntity.GetId()
+	}
+
+	//2. provision
+	podId := podEntity.GetId()
+	glog.V(2).Infof("podId: %s, VNodeId:%s", podId, hostId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	pod, err := m.cluster.ProvisionPod(podId, hostId)
+	if err != nil {
+		return fmt.Errorf("provision failed: %v", err)
+	}
+
+	glog.V(2).Infof("new Pod[%s] is provisioned on VNode[%s]", pod.Name, pod.ProviderID)
+	return nil
+}
+
+// the host of the new entity: NewSE, or HostedBySE if NewSE is not set.
+func getProvisionHost(actionItem *proto.ActionItemDTO) *proto.EntityDTO {
+	if host := actionItem.GetNewSE(); host != nil {
+		return host
+	}
+
+	return actionItem.GetHostedBySE()
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// ReplicaProvisioner scales a WorkloadController out by one replica.
+type ReplicaProvisioner struct {
+	cluster *target.ClusterHandler
+}
+
+func NewReplicaProvisioner(c *target.ClusterHandler) *ReplicaProvisioner {
+	return &ReplicaProvisioner{
+		cluster: c,
+	}
+}
+
+func (m *ReplicaProvisioner) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to provision a replica.")
+
+	controllerEntity := actionItem.GetTargetSE()
+	if controllerEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	controllerId := controllerEntity.GetId()
+	glog.V(2).Infof("controllerId: %s", controllerId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	pod, err := m.cluster.ProvisionReplica(controllerId)
+	if err != nil {
+		return fmt.Errorf("provision failed: %w", err)
+	}
+
+	glog.V(2).Infof("new replica Pod[%s] is provisioned on VNode[%s]", pod.Name, pod.ProviderID)
+	return nil
+}
@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type VirtualMachineProvisioner struct {
+	cluster *target.ClusterHandler
+}
+
+func NewVirtualMachineProvisioner(c *target.ClusterHandler) *VirtualMachineProvisioner {
+	return &VirtualMachineProvisioner{
+		cluster: c,
+	}
+}
+
+func (m *VirtualMachineProvisioner) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to provision a VirtualMachine.")
+
+	//1. check
+	vmEntity := actionItem.GetTargetSE()
+	if vmEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	// the new VM is placed on the original Node if no host is specified.
+	hostId := ""
+	if hostEntity := getProvisionHost(actionItem); hostEntity != nil {
+		hostType := hostEntity.GetEntityType()
+		if hostType != proto.EntityDTO_PHYSICAL_MACHINE {
+			return fmt.Errorf("host entity is not a PM: %v", hostType)
+		}
+		hostId = hostEntity.GetId()
+	}
+
+	//2. provision
+	vmId := vmEntity.GetId()
+	glog.V(2).Infof("vnodeId: %s, NodeId:%s", vmId, hostId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	vnode, err := m.cluster.ProvisionVirtualMachine(vmId, hostId)
+	if err != nil {
+		return fmt.Errorf("provision failed: %v", err)
+	}
+
+	glog.V(2).Infof("new VNode[%s] is provisioned on Node[%s]", vnode.Name, vnode.ProviderID)
+	return nil
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/glog"
 
@@ -20,7 +21,7 @@ func NewContainerResizer(c *target.ClusterHandler) *ContainerResizer {
 	}
 }
 
-func (m *ContainerResizer) Execute(actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+func (m *ContainerResizer) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
 	glog.V(2).Infof("begin to resize a container.")
 	containerSE := actionItem.GetTargetSE()
 	podSE := actionItem.GetHostedBySE()
@@ -30,8 +31,30 @@ func (m *ContainerResizer) Execute(actionItem *proto.ActionItemDTO, progressTrac
 		podSE.GetDisplayName(),
 		comm)
 
-	cpu := -1.0
-	mem := -1.0
+	cpu, mem, err := getNewCapacity(comm)
+	if err != nil {
+		glog.Errorf("unable to resize container[%s]: %v", containerSE.GetId(), err)
+		return err
+	}
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	err = m.cluster.ResizeContainerCapacity(containerSE.GetId(), cpu, mem)
+	if err != nil {
+		glog.Errorf("Failed to resize container[%s] capacity: %v", containerSE.GetId(), err)
+		return fmt.Errorf("failed to resize container capacity.")
+	}
+
+	glog.V(2).Infof("End of resizing container")
+
+	return nil
+}
+
+// getNewCapacity returns the new capacity of VCPU or VMEM; the other one is -1, which is not changed.
+func getNewCapacity(comm *proto.CommodityDTO) (cpu, mem float64, err error) {
+	cpu = -1.0
+	mem = -1.0
 
 	ctype := comm.GetCommodityType()
 	switch ctype {
@@ -42,23 +65,12 @@ func (m *ContainerResizer) Execute(actionItem *proto.ActionItemDTO, progressTrac
 		cpu = comm.GetCapacity()
 		glog.V(2).Infof("resize vcpu to: %v", cpu)
 	default:
-		glog.Errorf("unable to resize commodity type[%v] for container[%s].", ctype, containerSE.GetId())
-		return fmt.Errorf("unsupported commdity type [%v]", ctype)
+		return cpu, mem, fmt.Errorf("unsupported commdity type [%v]", ctype)
 	}
 
 	if cpu < 0 && mem < 0 {
-		err := fmt.Errorf("wrong new capacity: mem=%.1f, cpu=%.1f", cpu, mem)
-		glog.Error(err)
-		return fmt.Errorf("wrong new capacity.")
+		glog.Errorf("wrong new capacity: mem=%.1f, cpu=%.1f", mem, cpu)
+		return cpu, mem, fmt.Errorf("wrong new capacity.")
 	}
-
-	err := m.cluster.ResizeContainerCapacity(containerSE.GetId(), cpu, mem)
-	if err != nil {
-		glog.Errorf("Failed to resize container[%s] capacity: %v", containerSE.GetId(), err)
-		return fmt.Errorf("failed to resize container capacity.")
-	}
-
-	glog.V(2).Infof("End of resizing container")
-
-	return nil
+	return cpu, mem, nil
 }
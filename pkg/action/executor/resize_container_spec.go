@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// ContainerSpecResizer resizes the containers of a ContainerSpec in all the replicas of its WorkloadController.
+type ContainerSpecResizer struct {
+	cluster *target.ClusterHandler
+}
+
+func NewContainerSpecResizer(c *target.ClusterHandler) *ContainerSpecResizer {
+	return &ContainerSpecResizer{
+		cluster: c,
+	}
+}
+
+func (m *ContainerSpecResizer) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	specSE := actionItem.GetTargetSE()
+	if specSE == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+	comm := actionItem.GetNewComm()
+	glog.V(2).Infof("begin to resize containerSpec[%s]\n comm:%++v", specSE.GetId(), comm)
+
+	cpu, mem, err := getNewCapacity(comm)
+	if err != nil {
+		glog.Errorf("unable to resize containerSpec[%s]: %v", specSE.GetId(), err)
+		return err
+	}
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.ResizeContainerSpec(specSE.GetId(), cpu, mem); err != nil {
+		return fmt.Errorf("resize failed: %w", err)
+	}
+
+	glog.V(2).Infof("End of resizing containerSpec")
+	return nil
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/glog"
 
@@ -20,7 +21,76 @@ func NewVMResizer(c *target.ClusterHandler) *VMResizer {
 	}
 }
 
-func (m *VMResizer) Execute(actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
-	glog.V(2).Infof("begin to move a Pod.")
-	return fmt.Errorf("VirtualMachine Resizer is not implemented.")
+func (m *VMResizer) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to resize a VirtualMachine.")
+	vmEntity := actionItem.GetTargetSE()
+	if vmEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	comm := actionItem.GetNewComm()
+	if comm == nil {
+		return fmt.Errorf("NewComm is empty.")
+	}
+	glog.V(2).Infof("begin to resize VM[%s]\n comm:%++v", vmEntity.GetDisplayName(), comm)
+
+	cpu := -1.0
+	mem := -1.0
+
+	ctype := comm.GetCommodityType()
+	if ctype == proto.CommodityDTO_VSTORAGE {
+		return m.resizeDisk(ctx, vmEntity.GetId(), comm.GetCapacity())
+	}
+
+	switch ctype {
+	case proto.CommodityDTO_VMEM:
+		mem = comm.GetCapacity()
+		glog.V(2).Infof("resize vmem to: %v", mem)
+	case proto.CommodityDTO_VCPU:
+		cpu = comm.GetCapacity()
+		glog.V(2).Infof("resize vcpu to: %v", cpu)
+	default:
+		glog.Errorf("unable to resize commodity type[%v] for VM[%s].", ctype, vmEntity.GetId())
+		return fmt.Errorf("unsupported commdity type [%v]", ctype)
+	}
+
+	if cpu <= 0 && mem <= 0 {
+		err := fmt.Errorf("wrong new capacity: cpu=%.1f, mem=%.1f", cpu, mem)
+		glog.Error(err)
+		return fmt.Errorf("wrong new capacity.")
+	}
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	err := m.cluster.ResizeVirtualMachine(vmEntity.GetId(), cpu, mem)
+	if err != nil {
+		glog.Errorf("Failed to resize VM[%s] capacity: %v", vmEntity.GetId(), err)
+		return fmt.Errorf("failed to resize VM capacity: %v", err)
+	}
+
+	glog.V(2).Infof("End of resizing VM")
+
+	return nil
+}
+
+// resizeDisk changes the VStorage capacity of the VM, in MB
+func (m *VMResizer) resizeDisk(ctx context.Context, vmId string, size float64) error {
+	if size <= 0 {
+		err := fmt.Errorf("wrong new capacity: vstorage=%.1f", size)
+		glog.Error(err)
+		return fmt.Errorf("wrong new capacity.")
+	}
+	glog.V(2).Infof("resize vstorage to: %v", size)
+
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.ResizeVirtualMachineDisk(vmId, size); err != nil {
+		glog.Errorf("Failed to resize VM[%s] disk: %v", vmId, err)
+		return fmt.Errorf("failed to resize VM disk: %w", err)
+	}
+
+	glog.V(2).Infof("End of resizing VM disk")
+	return nil
 }
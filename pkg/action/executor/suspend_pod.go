@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type PodSuspender struct {
+	cluster *target.ClusterHandler
+}
+
+func NewPodSuspender(c *target.ClusterHandler) *PodSuspender {
+	return &PodSuspender{
+		cluster: c,
+	}
+}
+
+func (m *PodSuspender) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to suspend a Pod.")
+
+	podEntity := actionItem.GetTargetSE()
+	if podEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	podId := podEntity.GetId()
+	glog.V(2).Infof("podId: %s", podId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.SuspendPod(podId); err != nil {
+		return fmt.Errorf("suspend failed: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// ReplicaSuspender scales a WorkloadController in by one replica.
+type ReplicaSuspender struct {
+	cluster *target.ClusterHandler
+}
+
+func NewReplicaSuspender(c *target.ClusterHandler) *ReplicaSuspender {
+	return &ReplicaSuspender{
+		cluster: c,
+	}
+}
+
+func (m *ReplicaSuspender) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to suspend a replica.")
+
+	controllerEntity := actionItem.GetTargetSE()
+	if controllerEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	controllerId := controllerEntity.GetId()
+	glog.V(2).Infof("controllerId: %s", controllerId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.SuspendReplica(controllerId); err != nil {
+		return fmt.Errorf("suspend failed: %v", err)
+	}
+
+	return nil
+}
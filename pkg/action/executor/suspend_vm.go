@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type VirtualMachineSuspender struct {
+	cluster *target.ClusterHandler
+}
+
+func NewVirtualMachineSuspender(c *target.ClusterHandler) *VirtualMachineSuspender {
+	return &VirtualMachineSuspender{
+		cluster: c,
+	}
+}
+
+func (m *VirtualMachineSuspender) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	glog.V(2).Infof("begin to suspend a VirtualMachine.")
+
+	vmEntity := actionItem.GetTargetSE()
+	if vmEntity == nil {
+		return fmt.Errorf("TargetSE is empty.")
+	}
+
+	vmId := vmEntity.GetId()
+	glog.V(2).Infof("vnodeId: %s", vmId)
+	if err := checkCanceled(ctx); err != nil {
+		return err
+	}
+	if err := m.cluster.SuspendVirtualMachine(vmId); err != nil {
+		return fmt.Errorf("suspend failed: %v", err)
+	}
+
+	return nil
+}
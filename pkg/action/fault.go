@@ -0,0 +1,161 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+const (
+	// the fault of this key applies to the action types without their own fault
+	defaultFaultKey = "default"
+
+	faultProgressInterval = time.Second
+)
+
+// ActionFault: the latency and failure injected into the execution of an action type.
+type ActionFault struct {
+	// fixed delay before the action is executed
+	DelaySeconds float64
+	// an extra random delay in [0, RandomDelaySeconds)
+	RandomDelaySeconds float64
+
+	// probability of failing the action
+	FailureProbability float64
+	// the progress (0-100) reported during the delay before the action fails
+	FailAtProgress int32
+
+	// the action never returns, until it is canceled or timed out
+	Hang bool
+}
+
+// FaultConf: configuration of fault injection; key of Actions is the TurboActionType, or "default".
+type FaultConf struct {
+	Seed    int64
+	Actions map[string]*ActionFault
+}
+
+func NewFaultConf(path string) (*FaultConf, error) {
+	glog.Infof("[FaultConf] Read configuration from %s\n", path)
+
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		glog.Errorf("failed to read file:%v", err.Error())
+		return nil, err
+	}
+
+	var config FaultConf
+	if err := json.Unmarshal(file, &config); err != nil {
+		msg := fmt.Sprintf("Unmarshall error :%v\n", err)
+		glog.Error(msg)
+		return nil, fmt.Errorf(msg)
+	}
+
+	glog.V(2).Infof("Results: %+v\n", config)
+	return &config, nil
+}
+
+func (c *FaultConf) getFault(atype TurboActionType) *ActionFault {
+	if fault, exist := c.Actions[string(atype)]; exist {
+		return fault
+	}
+
+	return c.Actions[defaultFaultKey]
+}
+
+// newActionRand returns the random source of an action, made from the seed and the UUID of the action; so that
+// the faults of an action do not depend on the order of the actions executed in parallel.
+func newActionRand(seed int64, uuid string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(uuid))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
+// faultInjector wraps a TurboExecutor to delay it, fail it, or hang it.
+type faultInjector struct {
+	executor TurboExecutor
+	fault    *ActionFault
+	seed     int64
+}
+
+func newFaultInjector(executor TurboExecutor, fault *ActionFault, seed int64) *faultInjector {
+	return &faultInjector{
+		executor: executor,
+		fault:    fault,
+		seed:     seed,
+	}
+}
+
+func (f *faultInjector) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	fault := f.fault
+	random := newActionRand(f.seed, actionItem.GetUuid())
+
+	delay := fault.DelaySeconds
+	if fault.RandomDelaySeconds > 0 {
+		delay += fault.RandomDelaySeconds * random.Float64()
+	}
+	failed := fault.FailureProbability > 0 && random.Float64() < fault.FailureProbability
+	glog.V(2).Infof("inject fault for action[%s]: delay=%.1fs, failed=%v, hang=%v",
+		actionItem.GetUuid(), delay, failed, fault.Hang)
+
+	if fault.Hang {
+		progressTracker.UpdateProgress(proto.ActionResponseState_IN_PROGRESS, "in progress", 0)
+		<-ctx.Done()
+		return fmt.Errorf("injected hang is stopped: %w", ctx.Err())
+	}
+
+	if failed {
+		if err := f.sleep(ctx, time.Duration(delay*float64(time.Second)), fault.FailAtProgress, progressTracker); err != nil {
+			return err
+		}
+		return fmt.Errorf("injected failure at progress %d%%", fault.FailAtProgress)
+	}
+
+	if err := f.sleep(ctx, time.Duration(delay*float64(time.Second)), 100, progressTracker); err != nil {
+		return err
+	}
+	return f.executor.Execute(ctx, actionItem, progressTracker)
+}
+
+// sleep for the delay, and report the progress growing to maxProgress evenly.
+func (f *faultInjector) sleep(ctx context.Context, delay time.Duration, maxProgress int32, progressTracker sdkprobe.ActionProgressTracker) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	interval := faultProgressInterval
+	if delay/10 < interval {
+		interval = delay / 10
+	}
+
+	begin := time.Now()
+	for {
+		elapsed := time.Since(begin)
+		if elapsed >= delay {
+			return nil
+		}
+
+		progress := int32(float64(maxProgress) * float64(elapsed) / float64(delay))
+		progressTracker.UpdateProgress(proto.ActionResponseState_IN_PROGRESS, "in progress", progress)
+
+		wait := interval
+		if delay-elapsed < wait {
+			wait = delay - elapsed
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("injected delay is stopped: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
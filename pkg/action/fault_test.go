@@ -0,0 +1,120 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type mockExecutor struct {
+	count int
+}
+
+func (e *mockExecutor) Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error {
+	e.count++
+	return nil
+}
+
+func newTestInjector(fault *ActionFault, seed int64) (*faultInjector, *mockExecutor) {
+	executor := &mockExecutor{}
+	return newFaultInjector(executor, fault, seed), executor
+}
+
+func newTestActionItem(i int) *proto.ActionItemDTO {
+	uuid := fmt.Sprintf("action-%d", i)
+	return &proto.ActionItemDTO{Uuid: &uuid}
+}
+
+func TestFaultInjector_Deterministic(t *testing.T) {
+	fault := &ActionFault{FailureProbability: 0.5}
+
+	// the faults of an action do not depend on the order of the actions
+	run := func(reverse bool) []bool {
+		injector, _ := newTestInjector(fault, 42)
+		result := make([]bool, 20)
+		for j := range result {
+			i := j
+			if reverse {
+				i = len(result) - 1 - j
+			}
+			err := injector.Execute(context.Background(), newTestActionItem(i), &mockTracker{})
+			result[i] = err != nil
+		}
+		return result
+	}
+
+	first := run(false)
+	second := run(true)
+	failed := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("injected failures differ with the same seed: %v Vs. %v", first, second)
+		}
+		if first[i] {
+			failed++
+		}
+	}
+
+	if failed == 0 || failed == len(first) {
+		t.Errorf("failure probability 0.5 should fail some of the actions: %v", first)
+	}
+
+	// another seed makes other faults
+	other, _ := newTestInjector(fault, 7)
+	differ := false
+	for i := range first {
+		err := other.Execute(context.Background(), newTestActionItem(i), &mockTracker{})
+		differ = differ || (err != nil) != first[i]
+	}
+	if !differ {
+		t.Errorf("injected failures should differ with another seed: %v", first)
+	}
+}
+
+func TestFaultInjector_PartialProgress(t *testing.T) {
+	fault := &ActionFault{
+		DelaySeconds:       0.05,
+		FailureProbability: 1.0,
+		FailAtProgress:     60,
+	}
+	injector, executor := newTestInjector(fault, 1)
+
+	tracker := &mockTracker{}
+	if err := injector.Execute(context.Background(), &proto.ActionItemDTO{}, tracker); err == nil {
+		t.Fatalf("action should fail")
+	}
+	if executor.count != 0 {
+		t.Errorf("failed action should not be executed")
+	}
+
+	if len(tracker.progress) < 2 {
+		t.Fatalf("progress should be reported during delay: %v", tracker.progress)
+	}
+	for _, p := range tracker.progress {
+		if p > 60 {
+			t.Errorf("progress should not exceed 60: %v", tracker.progress)
+		}
+	}
+}
+
+func TestFaultInjector_Delay(t *testing.T) {
+	fault := &ActionFault{DelaySeconds: 0.05}
+	injector, executor := newTestInjector(fault, 1)
+
+	tracker := &mockTracker{}
+	if err := injector.Execute(context.Background(), &proto.ActionItemDTO{}, tracker); err != nil {
+		t.Fatalf("action failed: %v", err)
+	}
+	if executor.count != 1 {
+		t.Errorf("action should be executed once: %d", executor.count)
+	}
+
+	for i := 1; i < len(tracker.progress); i++ {
+		if tracker.progress[i] < tracker.progress[i-1] {
+			t.Errorf("progress should not decrease: %v", tracker.progress)
+		}
+	}
+}
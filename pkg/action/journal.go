@@ -0,0 +1,211 @@
+package action
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// JournalItem is the part of an ActionItemDTO needed to execute it again.
+type JournalItem struct {
+	ActionType string
+	EntityType string
+	EntityId   string
+
+	HostType string `json:",omitempty"`
+	HostId   string `json:",omitempty"`
+
+	CommodityType string  `json:",omitempty"`
+	Capacity      float64 `json:",omitempty"`
+}
+
+// JournalEntry is either an executed action, an undo of the last N actions, or a reload of the topology file.
+type JournalEntry struct {
+	Time time.Time
+
+	Items  []*JournalItem        `json:",omitempty"`
+	Before []*target.EntityState `json:",omitempty"`
+	After  []*target.EntityState `json:",omitempty"`
+
+	Undo int `json:",omitempty"`
+	// the reloaded topology file; the entries after it were executed on the reloaded cluster
+	Reload string `json:",omitempty"`
+}
+
+// Journal is an append-only file of JournalEntry, one JSON object per line.
+type Journal struct {
+	fname string
+	file  *os.File
+	mux   sync.Mutex
+}
+
+func NewJournal(fname string) (*Journal, error) {
+	file, err := os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		glog.Errorf("failed to open journal[%s] for write: %v", fname, err)
+		return nil, err
+	}
+
+	return &Journal{
+		fname: fname,
+		file:  file,
+	}, nil
+}
+
+func (j *Journal) Write(entry *JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %v", err)
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		glog.Errorf("failed to write journal[%s]: %v", j.fname, err)
+		return err
+	}
+
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.file.Close()
+}
+
+// LoadJournal reads all the entries of a journal file.
+func LoadJournal(fname string) ([]*JournalEntry, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		glog.Errorf("failed to open journal[%s] for read: %v", fname, err)
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []*JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum += 1
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &JournalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("failed to parse journal[%s/%d]: %v", fname, lineNum, err)
+		}
+		result = append(result, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		glog.Errorf("error while reading journal[%s]: %v", fname, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func newJournalItem(action *proto.ActionItemDTO) *JournalItem {
+	item := &JournalItem{
+		ActionType: action.GetActionType().String(),
+		EntityType: action.GetTargetSE().GetEntityType().String(),
+		EntityId:   action.GetTargetSE().GetId(),
+	}
+
+	host := action.GetNewSE()
+	if host == nil {
+		host = action.GetHostedBySE()
+	}
+	if host != nil {
+		item.HostType = host.GetEntityType().String()
+		item.HostId = host.GetId()
+	}
+
+	if comm := action.GetNewComm(); comm != nil {
+		item.CommodityType = comm.GetCommodityType().String()
+		item.Capacity = comm.GetCapacity()
+	}
+
+	return item
+}
+
+// ActionItemDTO builds an action item which can be handled by the executors.
+func (item *JournalItem) ActionItemDTO() (*proto.ActionItemDTO, error) {
+	atype, exist := proto.ActionItemDTO_ActionType_value[item.ActionType]
+	if !exist {
+		return nil, fmt.Errorf("unknown action type [%s]", item.ActionType)
+	}
+	actionType := proto.ActionItemDTO_ActionType(atype)
+
+	entity, err := newEntityDTO(item.EntityType, item.EntityId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &proto.ActionItemDTO{
+		ActionType: &actionType,
+		Uuid:       &item.EntityId,
+		TargetSE:   entity,
+	}
+
+	if item.HostId != "" {
+		if result.NewSE, err = newEntityDTO(item.HostType, item.HostId); err != nil {
+			return nil, err
+		}
+	}
+
+	if item.CommodityType != "" {
+		ctype, exist := proto.CommodityDTO_CommodityType_value[item.CommodityType]
+		if !exist {
+			return nil, fmt.Errorf("unknown commodity type [%s]", item.CommodityType)
+		}
+		commodityType := proto.CommodityDTO_CommodityType(ctype)
+		capacity := item.Capacity
+		result.NewComm = &proto.CommodityDTO{
+			CommodityType: &commodityType,
+			Capacity:      &capacity,
+		}
+	}
+
+	return result, nil
+}
+
+func newEntityDTO(entityType, id string) (*proto.EntityDTO, error) {
+	value, exist := proto.EntityDTO_EntityType_value[entityType]
+	if !exist {
+		return nil, fmt.Errorf("unknown entity type [%s]", entityType)
+	}
+	etype := proto.EntityDTO_EntityType(value)
+	uuid := id
+
+	return &proto.EntityDTO{
+		EntityType: &etype,
+		Id:         &uuid,
+	}, nil
+}
+
+// the Ids of the entities involved in the action items
+func getEntityIds(items []*JournalItem) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		for _, id := range []string{item.EntityId, item.HostId} {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package action
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+	"time"
+
+	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+const (
+	keepAliveInterval = time.Second * 3
+)
+
+// progressKeeper reports the progress of an action with several action items:
+// progress is increased periodically, but stays within the range of the current item.
+type progressKeeper struct {
+	tracker sdkprobe.ActionProgressTracker
+	total   int
+
+	// also serializes the reports to the tracker, so that the progress never goes back
+	mux      sync.Mutex
+	done     int
+	progress int32
+}
+
+func newProgressKeeper(tracker sdkprobe.ActionProgressTracker, total int) *progressKeeper {
+	if total < 1 {
+		total = 1
+	}
+
+	return &progressKeeper{
+		tracker: tracker,
+		total:   total,
+	}
+}
+
+// the upper bound of progress before current item is done
+func (k *progressKeeper) limit() int32 {
+	limit := int32((k.done+1)*100/k.total) - 1
+	if limit > 99 {
+		limit = 99
+	}
+	return limit
+}
+
+// itemDone is called after an action item is executed successfully.
+func (k *progressKeeper) itemDone() {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.done++
+	k.progress = int32(k.done * 100 / k.total)
+	if k.progress > 99 {
+		k.progress = 99
+	}
+	desc := fmt.Sprintf("%d of %d action items completed", k.done, k.total)
+	k.tracker.UpdateProgress(proto.ActionResponseState_IN_PROGRESS, desc, k.progress)
+}
+
+// UpdateProgress is used by executors to report the progress (0-100) of the current item.
+func (k *progressKeeper) UpdateProgress(state proto.ActionResponseState, description string, progress int32) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	p := int32(k.done*100/k.total) + progress/int32(k.total)
+	if limit := k.limit(); p > limit {
+		p = limit
+	}
+	if p > k.progress {
+		k.progress = p
+	}
+	k.tracker.UpdateProgress(proto.ActionResponseState_IN_PROGRESS, description, k.progress)
+}
+
+func (k *progressKeeper) tick() {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if k.progress < k.limit() {
+		k.progress++
+	}
+	k.tracker.UpdateProgress(proto.ActionResponseState_IN_PROGRESS, "in progress", k.progress)
+}
+
+// keepAlive reports the progress periodically, until the returned function is called.
+func (k *progressKeeper) keepAlive() func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			k.tick()
+
+			t := time.NewTimer(keepAliveInterval)
+			select {
+			case <-stop:
+				t.Stop()
+				glog.V(3).Infof("action keepAlive goroutine exit.")
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// nopTracker is used when there is no action from the server, e.g., replay.
+type nopTracker struct{}
+
+func (t *nopTracker) UpdateProgress(state proto.ActionResponseState, description string, progress int32) {
+}
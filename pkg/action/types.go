@@ -1,20 +1,89 @@
 package action
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	sdkprobe "github.com/turbonomic/turbo-go-sdk/pkg/probe"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
 type TurboActionType string
 
+const (
+	defaultActionTimeout = 10 * time.Minute
+)
+
 const (
 	ActionMovePod         TurboActionType = "movePod"
 	ActionMoveVM          TurboActionType = "moveVirtualMachine"
 	ActionResizeContainer TurboActionType = "resizeContainer"
 	ActionResizeVM        TurboActionType = "resizeVirtualMachine"
-	ActionUnknown         TurboActionType = "unknown"
+	ActionProvisionPod    TurboActionType = "provisionPod"
+	ActionProvisionVM     TurboActionType = "provisionVirtualMachine"
+	ActionSuspendPod      TurboActionType = "suspendPod"
+	ActionSuspendVM       TurboActionType = "suspendVirtualMachine"
+
+	ActionResizeContainerSpec TurboActionType = "resizeContainerSpec"
+	ActionProvisionController TurboActionType = "provisionWorkloadController"
+	ActionSuspendController   TurboActionType = "suspendWorkloadController"
+
+	ActionMoveVMStorage TurboActionType = "moveVirtualMachineStorage"
+
+	ActionUnknown TurboActionType = "unknown"
 )
 
+// the action types that can be executed
+var knownActionTypes = map[TurboActionType]bool{
+	ActionMovePod:             true,
+	ActionMoveVM:              true,
+	ActionResizeContainer:     true,
+	ActionResizeVM:            true,
+	ActionProvisionPod:        true,
+	ActionProvisionVM:         true,
+	ActionSuspendPod:          true,
+	ActionSuspendVM:           true,
+	ActionResizeContainerSpec: true,
+	ActionProvisionController: true,
+	ActionSuspendController:   true,
+	ActionMoveVMStorage:       true,
+}
+
+// TurboExecutor executes an action item; it should return promptly with an error once ctx is done,
+// and should not change the cluster after that.
 type TurboExecutor interface {
-	Execute(actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error
+	Execute(ctx context.Context, actionItem *proto.ActionItemDTO, progressTracker sdkprobe.ActionProgressTracker) error
+}
+
+// ParseActionTimeouts parses the timeouts in the format of "<actionType>=<duration>,...",
+// e.g., "movePod=30s,resizeContainer=1m,default=5m"; the action type should be a TurboActionType or "default".
+func ParseActionTimeouts(value string) (map[TurboActionType]time.Duration, error) {
+	result := make(map[TurboActionType]time.Duration)
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid action timeout [%s]", field)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid duration of action timeout [%s]", field)
+		}
+
+		atype := TurboActionType(strings.TrimSpace(kv[0]))
+		if atype == "default" {
+			atype = ActionUnknown
+		} else if !knownActionTypes[atype] {
+			return nil, fmt.Errorf("unknown action type of action timeout [%s]", field)
+		}
+		result[atype] = timeout
+	}
+
+	return result, nil
 }
@@ -3,7 +3,10 @@ package discovery
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"sync"
+	"time"
 
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
 	"github.com/turbonomic/virtualCluster/pkg/registration"
 	"github.com/turbonomic/virtualCluster/pkg/target"
 
@@ -14,13 +17,79 @@ import (
 type DiscoveryClient struct {
 	targetConfig *TargetConf
 	cluster      *target.ClusterHandler
+
+	// interval of the incremental discovery; not supported if 0
+	incrementalInterval time.Duration
+	// interval of the performance discovery; not supported if 0
+	performanceInterval time.Duration
+
+	// the topology file of the target, checked in validation
+	topologyConf string
+	// all the problems of the topology are critical in validation, see SetStrict()
+	strict bool
+
+	// builds the clusters of the targets added from the server, see SetClusterHandlerFactory()
+	factory ClusterHandlerFactory
+	// the stitching type registered in the supply chain, see SetStitchType()
+	stitchType stitching.StitchingPropertyType
+	// key=target address
+	targets map[string]*targetCluster
+	// the builds of the clusters in flight; key=target address
+	builds map[string]*targetBuild
+	// the clusters of the targets not used within it are dropped, see SetTargetIdleTimeout(); disabled if 0
+	idleTimeout time.Duration
+	// called with the cluster of a dropped target, see SetEvictionListener()
+	evicted func(*target.ClusterHandler)
+	mux     sync.Mutex
 }
 
 func NewDiscoveryClient(targetConfig *TargetConf, handler *target.ClusterHandler) *DiscoveryClient {
 	return &DiscoveryClient{
 		targetConfig: targetConfig,
 		cluster:      handler,
+		targets:      make(map[string]*targetCluster),
+		builds:       make(map[string]*targetBuild),
+		stitchType:   stitching.IP,
+	}
+}
+
+// SetIncrementalInterval sets the interval of the incremental discovery, at least one minute; disabled if 0.
+func (dc *DiscoveryClient) SetIncrementalInterval(interval time.Duration) error {
+	if interval != 0 && interval < time.Minute {
+		err := fmt.Errorf("interval of incremental discovery should be at least 1m: %v", interval)
+		glog.Error(err.Error())
+		return err
+	}
+	dc.incrementalInterval = interval
+	return nil
+}
+
+// SetPerformanceInterval sets the interval of the performance discovery, at least one minute; disabled if 0.
+func (dc *DiscoveryClient) SetPerformanceInterval(interval time.Duration) error {
+	if interval != 0 && interval < time.Minute {
+		err := fmt.Errorf("interval of performance discovery should be at least 1m: %v", interval)
+		glog.Error(err.Error())
+		return err
 	}
+	dc.performanceInterval = interval
+	return nil
+}
+
+// GetIncrementalRediscoveryIntervalSeconds implements IIncrementalDiscoveryMetadata; -1 if not supported.
+func (dc *DiscoveryClient) GetIncrementalRediscoveryIntervalSeconds() int32 {
+	return intervalSeconds(dc.incrementalInterval)
+}
+
+// GetPerformanceRediscoveryIntervalSeconds implements IPerformanceDiscoveryMetadata; -1 if not supported.
+func (dc *DiscoveryClient) GetPerformanceRediscoveryIntervalSeconds() int32 {
+	return intervalSeconds(dc.performanceInterval)
+}
+
+func intervalSeconds(interval time.Duration) int32 {
+	if interval <= 0 {
+		return -1
+	}
+	return int32(interval / time.Second)
 }
 
 func (dc *DiscoveryClient) String() string {
@@ -59,10 +128,20 @@ func (dc *DiscoveryClient) GetAccountValues() *sdkprobe.TurboTargetInfo {
 	return targetInfo
 }
 
+// Validate checks the account values against the target conf, the topology file and the cluster of the target;
+// the problems are sent as ErrorDTOs, and the target is invalid if any of them is critical.
 func (dc *DiscoveryClient) Validate(accountValues []*proto.AccountValue) (*proto.ValidationResponse, error) {
 	glog.V(2).Infof("begin to validating target...")
 
-	return &proto.ValidationResponse{}, nil
+	errorDTOs := dc.validateAccountValues(accountValues)
+	for _, errorDTO := range errorDTOs {
+		glog.Warningf("validation of target[%s]: %v %s", dc.targetConfig.Address, errorDTO.GetSeverity(), errorDTO.GetDescription())
+	}
+
+	glog.V(2).Infof("end of validating target. [%d problems]", len(errorDTOs))
+	return &proto.ValidationResponse{
+		ErrorDTO: errorDTOs,
+	}, nil
 }
 
 func printDTOs(dtos []*proto.EntityDTO) string {
@@ -78,7 +157,11 @@ func printDTOs(dtos []*proto.EntityDTO) string {
 func (dc *DiscoveryClient) Discover(accountValues []*proto.AccountValue) (*proto.DiscoveryResponse, error) {
 	glog.V(2).Infof("begin to discovery target...")
 
-	resultDTOs, err := dc.cluster.GenerateClusterDTOs()
+	cluster, err := dc.GetClusterHandler(accountValues)
+	if err != nil {
+		return nil, err
+	}
+	resultDTOs, err := cluster.DiscoverDTOs()
 	if err != nil {
 		glog.Errorf("failed to generate DTOs: %v", err)
 		resultDTOs = []*proto.EntityDTO{}
@@ -93,3 +176,51 @@ func (dc *DiscoveryClient) Discover(accountValues []*proto.AccountValue) (*proto
 
 	return response, nil
 }
+
+// DiscoverIncremental sends the entities changed since the last discovery, and the removed ones as DELETED.
+func (dc *DiscoveryClient) DiscoverIncremental(accountValues []*proto.AccountValue) (*proto.DiscoveryResponse, error) {
+	glog.V(2).Infof("begin to incremental discovery of target...")
+
+	cluster, err := dc.GetClusterHandler(accountValues)
+	if err != nil {
+		return nil, err
+	}
+	resultDTOs, err := cluster.DiscoverChangedDTOs()
+	if err != nil {
+		glog.Errorf("failed to generate DTOs: %v", err)
+		return nil, err
+	}
+
+	glog.V(2).Infof("end of incremental discovery of target. [%d]", len(resultDTOs))
+	glog.V(3).Infof("DTOs:\n%s", printDTOs(resultDTOs))
+
+	response := &proto.DiscoveryResponse{
+		EntityDTO: resultDTOs,
+	}
+
+	return response, nil
+}
+
+// DiscoverPerformance sends all the entities, with the average and peak usage of the commodities.
+func (dc *DiscoveryClient) DiscoverPerformance(accountValues []*proto.AccountValue) (*proto.DiscoveryResponse, error) {
+	glog.V(2).Infof("begin to performance discovery of target...")
+
+	cluster, err := dc.GetClusterHandler(accountValues)
+	if err != nil {
+		return nil, err
+	}
+	resultDTOs, err := cluster.DiscoverPerformanceDTOs()
+	if err != nil {
+		glog.Errorf("failed to generate DTOs: %v", err)
+		return nil, err
+	}
+
+	glog.V(2).Infof("end of performance discovery of target. [%d]", len(resultDTOs))
+	glog.V(3).Infof("DTOs:\n%s", printDTOs(resultDTOs))
+
+	response := &proto.DiscoveryResponse{
+		EntityDTO: resultDTOs,
+	}
+
+	return response, nil
+}
@@ -52,6 +52,15 @@ func NewStitchingManager(pType StitchingPropertyType) *StitchingManager {
 	}
 }
 
+// ParseStitchingPropertyType parses the stitching type, case-insensitive.
+func ParseStitchingPropertyType(pType string) (StitchingPropertyType, error) {
+	switch result := StitchingPropertyType(strings.ToUpper(pType)); result {
+	case UUID, IP:
+		return result, nil
+	}
+	return "", fmt.Errorf("Wrong stitching type: %v, only [%v, %v] are acceptable", pType, UUID, IP)
+}
+
 func (s *StitchingManager) GetStitchType() StitchingPropertyType {
 	return s.stitchType
 }
@@ -69,6 +78,15 @@ func (s *StitchingManager) GetStitchingValue(nodeName string) (string, error) {
 	return sid, nil
 }
 
+// Store the stitching value of the node: its UUID or IP, by the stitching type.
+func (s *StitchingManager) StoreStitchingValue(nodeName, uuid, ip string) {
+	value := ip
+	if s.stitchType == UUID {
+		value = uuid
+	}
+	s.nodeStitchingIDMap[nodeName] = value
+}
+
 // Build the stitching node property for entity based on the given node name, and purpose.
 //   two purposes: "stitching" and "reconcile".
 //       stitching: is to stitch Pod to the real-VM;
@@ -15,3 +15,34 @@ func TestNewStitchingManager(t *testing.T) {
 		}
 	}
 }
+
+func TestParseStitchingPropertyType(t *testing.T) {
+	for input, expected := range map[string]StitchingPropertyType{"IP": IP, "uuid": UUID, "Uuid": UUID} {
+		if ptype, err := ParseStitchingPropertyType(input); err != nil || ptype != expected {
+			t.Errorf("stitching type %s should be %v, but got %v, %v", input, expected, ptype, err)
+		}
+	}
+	if _, err := ParseStitchingPropertyType("MAC"); err == nil {
+		t.Errorf("stitching type MAC should be invalid")
+	}
+}
+
+func TestStitchingManager_BuildDTOProperty(t *testing.T) {
+	for ptype, expected := range map[StitchingPropertyType][]string{
+		IP:   {proxyVMIP, "10.0.0.1"},
+		UUID: {proxyVMUUID, "vm-1"},
+	} {
+		m := NewStitchingManager(ptype)
+		m.StoreStitchingValue("node-1", "vm-1", "10.0.0.1")
+		property, err := m.BuildDTOProperty("node-1", true)
+		if err != nil {
+			t.Fatalf("failed to build property: %v", err)
+		}
+		if property.GetName() != expected[0] || property.GetValue() != expected[1] {
+			t.Errorf("wrong %v reconcile property: %v", ptype, property)
+		}
+		if _, err := m.BuildDTOProperty("node-2", false); err == nil {
+			t.Errorf("property of unknown node should fail")
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package discovery
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+	"github.com/turbonomic/virtualCluster/pkg/registration"
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+const (
+	presetSuffix = ".topology.conf"
+)
+
+var timeNow = time.Now
+
+// TargetAccount is a target added from the server, with the fields selecting its virtual cluster.
+type TargetAccount struct {
+	Address  string
+	Username string
+	Password string
+
+	// topology file, or name of a preset; the topology of the probe if empty
+	Topology string
+	// the vnodes are proxy VMs stitched by it, which should be the registered stitching type; no stitching if empty
+	StitchType stitching.StitchingPropertyType
+	// the address if empty
+	ClusterId   string
+	ClusterName string
+	// speed of the usage profiles, see Cluster.SetProfileSpeed()
+	ProfileSpeed float64
+}
+
+// NewTargetAccount reads the TargetAccount from the account values of a target.
+func NewTargetAccount(accountValues []*proto.AccountValue) (*TargetAccount, error) {
+	values := make(map[string]string)
+	for _, value := range accountValues {
+		values[value.GetKey()] = strings.TrimSpace(value.GetStringValue())
+	}
+
+	account := &TargetAccount{
+		Address:      values[registration.TargetIdentifierField],
+		Username:     values[registration.Username],
+		Password:     values[registration.Password],
+		Topology:     values[registration.TopologyField],
+		ClusterId:    values[registration.ClusterIdField],
+		ClusterName:  values[registration.ClusterNameField],
+		ProfileSpeed: 1,
+	}
+
+	if pType := values[registration.StitchTypeField]; pType != "" {
+		stitchType, err := stitching.ParseStitchingPropertyType(pType)
+		if err != nil {
+			return nil, err
+		}
+		account.StitchType = stitchType
+	}
+
+	if speed := values[registration.ProfileSpeedField]; speed != "" {
+		f, err := strconv.ParseFloat(speed, 64)
+		if err != nil || f <= 0 {
+			err := fmt.Errorf("speed of usage profiles should be a positive number: %s", speed)
+			glog.Error(err.Error())
+			return nil, err
+		}
+		account.ProfileSpeed = f
+	}
+
+	if account.ClusterId == "" {
+		account.ClusterId = account.Address
+	}
+	if account.ClusterName == "" {
+		account.ClusterName = account.Address
+	}
+	return account, nil
+}
+
+// isDefault tells whether the account is the target of the probe, and selects nothing more than its address,
+// username and password; so that the target is served by the cluster of the probe.
+func (a *TargetAccount) isDefault(conf *TargetConf) bool {
+	return a.Address == conf.Address && a.Topology == "" && a.StitchType == "" && a.ProfileSpeed == 1 &&
+		a.ClusterId == a.Address && a.ClusterName == a.Address
+}
+
+// sameCluster tells whether the two accounts select the same virtual cluster.
+func (a *TargetAccount) sameCluster(b *TargetAccount) bool {
+	return a.Topology == b.Topology && a.StitchType == b.StitchType && a.ProfileSpeed == b.ProfileSpeed &&
+		a.ClusterId == b.ClusterId && a.ClusterName == b.ClusterName
+}
+
+// checkStitchType checks that the account stitches by the stitching type registered in the supply chain, if any.
+func (a *TargetAccount) checkStitchType(registered stitching.StitchingPropertyType) error {
+	if a.StitchType != "" && a.StitchType != registered {
+		return fmt.Errorf("stitchType[%s] of target[%s] is not the registered stitchType[%s]",
+			a.StitchType, a.Address, registered)
+	}
+	return nil
+}
+
+// resolveTopology returns the topology file of the account: the file if it exists, or the preset of the name in
+// presetDir; the default topology if it is empty.
+func (a *TargetAccount) resolveTopology(defaultTopology, presetDir string) string {
+	if a.Topology == "" {
+		return defaultTopology
+	}
+	if _, err := os.Stat(a.Topology); err == nil {
+		return a.Topology
+	}
+
+	preset := filepath.Join(presetDir, a.Topology+presetSuffix)
+	if _, err := os.Stat(preset); err == nil {
+		return preset
+	}
+	return a.Topology
+}
+
+// ClusterHandlerFactory builds the ClusterHandler of a target added from the server, from the topology file of
+// the TargetAccount.
+type ClusterHandlerFactory func(account *TargetAccount, topologyConf string) (*target.ClusterHandler, error)
+
+// the ClusterHandler of a target, the account it is built for, and the last time it is used
+type targetCluster struct {
+	account  *TargetAccount
+	handler  *target.ClusterHandler
+	lastSeen time.Time
+}
+
+// SetClusterHandlerFactory makes each target select its own virtual cluster by its account values, built by
+// the factory; otherwise, all the targets are served by the cluster of the probe.
+func (dc *DiscoveryClient) SetClusterHandlerFactory(factory ClusterHandlerFactory) {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	dc.factory = factory
+}
+
+// SetStitchType sets the stitching type registered in the supply chain, the only one a target can select; IP by default.
+func (dc *DiscoveryClient) SetStitchType(pType stitching.StitchingPropertyType) {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	dc.stitchType = pType
+}
+
+// SetTargetIdleTimeout sets the timeout after which the cluster of a target neither discovered nor acted on is
+// dropped, as the target is taken as removed from the server; disabled if 0.
+func (dc *DiscoveryClient) SetTargetIdleTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		err := fmt.Errorf("idle timeout of targets should not be negative: %v", timeout)
+		glog.Error(err.Error())
+		return err
+	}
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	dc.idleTimeout = timeout
+	return nil
+}
+
+// SetEvictionListener sets the function called with the ClusterHandler of a target when it is dropped, because the
+// target is removed or its account is changed; e.g., to drop the ActionHandler of the cluster.
+func (dc *DiscoveryClient) SetEvictionListener(listener func(*target.ClusterHandler)) {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	dc.evicted = listener
+}
+
+// evictTarget drops the cluster of the target of the address, if any.
+func (dc *DiscoveryClient) evictTarget(address, reason string) {
+	c, exist := dc.targets[address]
+	if !exist {
+		return
+	}
+	delete(dc.targets, address)
+	glog.V(2).Infof("dropped cluster[%s] of target[%s]: %s", c.account.ClusterId, address, reason)
+	if dc.evicted != nil {
+		dc.evicted(c.handler)
+	}
+}
+
+// evictIdleTargets drops the clusters of the targets not used within the idle timeout, if any; the server does not
+// tell the probe when a target is removed.
+func (dc *DiscoveryClient) evictIdleTargets(now time.Time) {
+	if dc.idleTimeout == 0 {
+		return
+	}
+	for address, c := range dc.targets {
+		if now.Sub(c.lastSeen) > dc.idleTimeout {
+			dc.evictTarget(address, fmt.Sprintf("not used since %v", c.lastSeen.Format(time.RFC3339)))
+		}
+	}
+}
+
+// GetClusterHandler returns the ClusterHandler of the target of the account values, and builds it if the target
+// is new or its account has been changed. The target of the probe is served by the cluster of the probe, unless
+// its account selects another cluster. The clusters of the targets not used within the idle timeout are dropped.
+// The cluster is built without holding the lock, once for all the concurrent calls of the same target.
+func (dc *DiscoveryClient) GetClusterHandler(accountValues []*proto.AccountValue) (*target.ClusterHandler, error) {
+	factory := dc.getFactory()
+	if factory == nil {
+		return dc.cluster, nil
+	}
+
+	account, err := NewTargetAccount(accountValues)
+	if err != nil {
+		return nil, err
+	}
+	if err := account.checkStitchType(dc.getStitchType()); err != nil {
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	for {
+		handler, build, isNew := dc.lookupTarget(account)
+		if handler != nil {
+			return handler, nil
+		}
+		if isNew {
+			return dc.buildTarget(factory, build)
+		}
+
+		// look up again after the build of another call: it may be of another account
+		<-build.done
+		if build.err != nil && build.account.sameCluster(account) {
+			return nil, build.err
+		}
+	}
+}
+
+// the build of the cluster of a target; done is closed when the handler or the error is set
+type targetBuild struct {
+	account *TargetAccount
+	handler *target.ClusterHandler
+	err     error
+	done    chan struct{}
+}
+
+// lookupTarget returns the cluster of the account if it is built; otherwise, the build of its target in flight,
+// or a new one to be run by the caller.
+func (dc *DiscoveryClient) lookupTarget(account *TargetAccount) (*target.ClusterHandler, *targetBuild, bool) {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+
+	now := timeNow()
+	dc.evictIdleTargets(now)
+	if account.isDefault(dc.targetConfig) {
+		dc.evictTarget(account.Address, "served by the cluster of the probe")
+		return dc.cluster, nil, false
+	}
+
+	if c, exist := dc.targets[account.Address]; exist {
+		if c.account.sameCluster(account) {
+			c.lastSeen = now
+			return c.handler, nil, false
+		}
+	}
+	if build, exist := dc.builds[account.Address]; exist {
+		return nil, build, false
+	}
+
+	dc.evictTarget(account.Address, "the account is changed")
+	build := &targetBuild{account: account, done: make(chan struct{})}
+	dc.builds[account.Address] = build
+	return nil, build, true
+}
+
+// buildTarget runs the build of the cluster by the factory, and keeps the cluster if it is built.
+func (dc *DiscoveryClient) buildTarget(factory ClusterHandlerFactory, build *targetBuild) (*target.ClusterHandler, error) {
+	account := build.account
+	fname := account.resolveTopology(dc.topologyConf, filepath.Dir(dc.topologyConf))
+	handler, err := factory(account, fname)
+	if err != nil {
+		err = fmt.Errorf("failed to build cluster of target[%s] from topology[%s]: %v", account.Address, fname, err)
+		glog.Error(err.Error())
+	} else {
+		glog.V(2).Infof("built cluster[%s] of target[%s] from topology[%s]", account.ClusterId, account.Address, fname)
+	}
+
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	delete(dc.builds, account.Address)
+	if err == nil {
+		dc.targets[account.Address] = &targetCluster{account: account, handler: handler, lastSeen: timeNow()}
+	}
+	build.handler, build.err = handler, err
+	close(build.done)
+	return handler, err
+}
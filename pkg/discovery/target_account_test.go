@@ -0,0 +1,212 @@
+package discovery
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+	"github.com/turbonomic/virtualCluster/pkg/registration"
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func withFields(accountValues []*proto.AccountValue, fields map[string]string) []*proto.AccountValue {
+	for key, value := range fields {
+		key, value := key, value
+		accountValues = append(accountValues, &proto.AccountValue{Key: &key, StringValue: &value})
+	}
+	return accountValues
+}
+
+func TestNewTargetAccount(t *testing.T) {
+	account, err := NewTargetAccount(withFields(newAccountValues("my.vCluster", "user", "secret"), map[string]string{
+		registration.StitchTypeField:   "uuid",
+		registration.ProfileSpeedField: "24",
+		registration.ClusterNameField:  "web",
+	}))
+	if err != nil {
+		t.Fatalf("failed to read account: %v", err)
+	}
+	if account.StitchType != stitching.UUID || account.ProfileSpeed != 24 || account.ClusterName != "web" ||
+		account.ClusterId != "my.vCluster" {
+		t.Errorf("wrong account: %+v", account)
+	}
+
+	for field, value := range map[string]string{
+		registration.StitchTypeField:   "MAC",
+		registration.ProfileSpeedField: "-1",
+	} {
+		values := withFields(newAccountValues("my.vCluster", "user", "secret"), map[string]string{field: value})
+		if _, err := NewTargetAccount(values); err == nil {
+			t.Errorf("%s=%s should be invalid", field, value)
+		}
+	}
+}
+
+func TestDiscoveryClient_GetClusterHandler(t *testing.T) {
+	conf := &TargetConf{Address: "my.vCluster", Username: "user", Password: "secret"}
+	defaultHandler := target.NewClusterHandler(target.NewCluster("default", "default"))
+	dc := NewDiscoveryClient(conf, defaultHandler)
+	dc.SetTopologyConf(testutil.MakeTestPath("conf/topology.conf"))
+
+	var built []string
+	dc.SetClusterHandlerFactory(func(account *TargetAccount, fname string) (*target.ClusterHandler, error) {
+		built = append(built, fmt.Sprintf("%s:%s", account.ClusterId, fname))
+		builder := topology.NewClusterBuilder(account.ClusterId, account.ClusterName, fname)
+		if builder == nil {
+			return nil, fmt.Errorf("failed to load topology[%s]", fname)
+		}
+		cluster, err := builder.GenerateCluster()
+		if err != nil {
+			return nil, err
+		}
+		return target.NewClusterHandler(cluster), nil
+	})
+
+	if h, err := dc.GetClusterHandler(newAccountValues("my.vCluster", "user", "secret")); err != nil || h != defaultHandler {
+		t.Errorf("target of the probe should be served by the default cluster: %v", err)
+	}
+
+	other := newAccountValues("other.vCluster", "user", "secret")
+	h1, err := dc.GetClusterHandler(other)
+	if err != nil || h1 == defaultHandler {
+		t.Fatalf("another target should have its own cluster: %v", err)
+	}
+	if h2, _ := dc.GetClusterHandler(other); h2 != h1 || len(built) != 1 {
+		t.Errorf("cluster of a target should be built once, but got %v", built)
+	}
+
+	// the preset in the directory of the topology of the probe
+	preset := withFields(other, map[string]string{registration.TopologyField: "storage", registration.ClusterIdField: "c2"})
+	h3, err := dc.GetClusterHandler(preset)
+	if err != nil || h3 == h1 {
+		t.Fatalf("cluster should be rebuilt when the account is changed: %v", err)
+	}
+	if expected := "c2:" + testutil.MakeTestPath("conf/storage.topology.conf"); built[1] != expected {
+		t.Errorf("preset storage should be %s, but got %s", expected, built[1])
+	}
+
+	missing := withFields(newAccountValues("third.vCluster", "user", "secret"), map[string]string{registration.TopologyField: "missing"})
+	if _, err := dc.GetClusterHandler(missing); err == nil {
+		t.Errorf("target of a missing topology should fail")
+	}
+	uuid := withFields(newAccountValues("third.vCluster", "user", "secret"), map[string]string{registration.StitchTypeField: "UUID"})
+	if _, err := dc.GetClusterHandler(uuid); err == nil || len(built) != 3 {
+		t.Errorf("target of another stitchType than the registered one should fail: %v", err)
+	}
+	response, _ := dc.Validate(missing)
+	if errorDTOs := response.GetErrorDTO(); len(errorDTOs) != 1 || errorDTOs[0].GetSeverity() != proto.ErrorDTO_CRITICAL {
+		t.Errorf("validation of a missing topology should be critical, but got %v", errorDTOs)
+	}
+	if response, _ := dc.Validate(preset); len(response.GetErrorDTO()) > 0 {
+		t.Errorf("another target should be valid, but got %v", response.GetErrorDTO())
+	}
+	// the validation does not build the cluster of a target
+	if response, _ := dc.Validate(newAccountValues("fourth.vCluster", "user", "secret")); len(response.GetErrorDTO()) > 0 ||
+		len(built) != 3 || dc.targets["fourth.vCluster"] != nil {
+		t.Errorf("validation should not build the cluster, but got %v: %v", built, response.GetErrorDTO())
+	}
+}
+
+func TestDiscoveryClient_EvictTargets(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	conf := &TargetConf{Address: "my.vCluster", Username: "user", Password: "secret"}
+	dc := NewDiscoveryClient(conf, target.NewClusterHandler(target.NewCluster("default", "default")))
+	dc.SetClusterHandlerFactory(func(account *TargetAccount, fname string) (*target.ClusterHandler, error) {
+		return target.NewClusterHandler(target.NewCluster(account.ClusterId, account.ClusterName)), nil
+	})
+	var evicted []*target.ClusterHandler
+	dc.SetEvictionListener(func(h *target.ClusterHandler) {
+		evicted = append(evicted, h)
+	})
+
+	first := newAccountValues("first.vCluster", "user", "secret")
+	second := newAccountValues("second.vCluster", "user", "secret")
+	h1, _ := dc.GetClusterHandler(first)
+	h2, _ := dc.GetClusterHandler(second)
+
+	// the cluster is dropped when the account is changed
+	if h, _ := dc.GetClusterHandler(withFields(first, map[string]string{registration.ClusterIdField: "c1"})); h == h1 ||
+		len(evicted) != 1 || evicted[0] != h1 {
+		t.Errorf("cluster of the changed account should be dropped, but got %v", evicted)
+	}
+
+	// the cluster of a target not used for a while is kept by default
+	now = now.Add(24 * time.Hour)
+	dc.GetClusterHandler(second)
+	if len(dc.targets) != 2 || len(evicted) != 1 {
+		t.Errorf("clusters should not be dropped without idle timeout, but got %v", dc.targets)
+	}
+
+	// and dropped after the idle timeout
+	dc.SetTargetIdleTimeout(time.Hour)
+	now = now.Add(time.Hour / 2)
+	dc.GetClusterHandler(second)
+	now = now.Add(time.Hour/2 + time.Minute)
+	dc.GetClusterHandler(second)
+	if len(dc.targets) != 1 || dc.targets["second.vCluster"].handler != h2 || len(evicted) != 2 {
+		t.Errorf("only the cluster of second.vCluster should be kept, but got %v", dc.targets)
+	}
+
+	// the cluster is dropped when the target is served by the cluster of the probe
+	dc.GetClusterHandler(newAccountValues("my.vCluster", "user", "secret"))
+	dc.GetClusterHandler(withFields(newAccountValues("my.vCluster", "user", "secret"), map[string]string{registration.ClusterIdField: "c3"}))
+	dc.GetClusterHandler(newAccountValues("my.vCluster", "user", "secret"))
+	if _, exist := dc.targets["my.vCluster"]; exist || len(evicted) != 3 {
+		t.Errorf("cluster of the target of the probe should be dropped, but got %d evicted", len(evicted))
+	}
+}
+
+func TestDiscoveryClient_BuildTargetOnce(t *testing.T) {
+	conf := &TargetConf{Address: "my.vCluster", Username: "user", Password: "secret"}
+	dc := NewDiscoveryClient(conf, target.NewClusterHandler(target.NewCluster("default", "default")))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mux sync.Mutex
+	built := make(map[string]int)
+	dc.SetClusterHandlerFactory(func(account *TargetAccount, fname string) (*target.ClusterHandler, error) {
+		mux.Lock()
+		built[account.Address]++
+		mux.Unlock()
+		if account.Address == "slow.vCluster" {
+			close(started)
+			<-release
+		}
+		return target.NewClusterHandler(target.NewCluster(account.ClusterId, account.ClusterName)), nil
+	})
+
+	slow := newAccountValues("slow.vCluster", "user", "secret")
+	results := make(chan *target.ClusterHandler, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			h, _ := dc.GetClusterHandler(slow)
+			results <- h
+		}()
+	}
+
+	// another target is built while the cluster of slow.vCluster is being built
+	<-started
+	if h, err := dc.GetClusterHandler(newAccountValues("fast.vCluster", "user", "secret")); err != nil || h == nil {
+		t.Errorf("another target should be built during the build of slow.vCluster: %v", err)
+	}
+	close(release)
+
+	first := <-results
+	for i := 1; i < 3; i++ {
+		if h := <-results; h == nil || h != first {
+			t.Errorf("concurrent calls of a target should get the same cluster")
+		}
+	}
+	if built["slow.vCluster"] != 1 || built["fast.vCluster"] != 1 {
+		t.Errorf("cluster of each target should be built once, but got %v", built)
+	}
+}
@@ -15,6 +15,11 @@ type TargetConf struct {
 	ProbeCategory   string
 	TargetType      string
 	ProbeUICategory string
+
+	// the property to stitch the pods to the VMs: IP or UUID; IP if empty
+	StitchType string
+	// emit the VNodes as proxy VMs, to be replaced by the VMs discovered by another probe
+	ProxyVM bool
 }
 
 // Create a new ExampleClientConf from file. Other fields have default values and can be overridden.
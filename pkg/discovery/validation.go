@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+	"github.com/turbonomic/virtualCluster/pkg/topology"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// SetTopologyConf sets the topology file of the target, checked by Validate; not checked if empty.
+func (dc *DiscoveryClient) SetTopologyConf(fname string) {
+	dc.topologyConf = fname
+}
+
+// SetStrict makes all the problems of the topology critical in validation, so the target is rejected;
+// by default only the unknown references and duplicates are.
+func (dc *DiscoveryClient) SetStrict(strict bool) {
+	dc.strict = strict
+}
+
+func newErrorDTO(severity proto.ErrorDTO_ErrorSeverity, format string, args ...interface{}) *proto.ErrorDTO {
+	description := fmt.Sprintf(format, args...)
+	return &proto.ErrorDTO{
+		Severity:    &severity,
+		Description: &description,
+	}
+}
+
+// validateAccountValues checks the username and password against the target conf, the address unless each
+// target has its own cluster, and the stitchType against the registered one; then the topology file of the target.
+// The cluster of the target is not kept, it is built by the discovery.
+func (dc *DiscoveryClient) validateAccountValues(accountValues []*proto.AccountValue) []*proto.ErrorDTO {
+	account, err := NewTargetAccount(accountValues)
+	if err != nil {
+		return []*proto.ErrorDTO{newErrorDTO(proto.ErrorDTO_CRITICAL, "invalid account of the target: %v", err)}
+	}
+
+	var result []*proto.ErrorDTO
+	conf := dc.targetConfig
+	address := account.Address
+	if address == "" {
+		result = append(result, newErrorDTO(proto.ErrorDTO_CRITICAL, "address of the target is missing"))
+	} else if address != conf.Address && dc.getFactory() == nil {
+		result = append(result, newErrorDTO(proto.ErrorDTO_CRITICAL,
+			"unknown target address[%s], this probe serves [%s]", address, conf.Address))
+	}
+
+	if account.Username != conf.Username || account.Password != conf.Password {
+		result = append(result, newErrorDTO(proto.ErrorDTO_CRITICAL,
+			"wrong username or password of target[%s]", address))
+	}
+	if err := account.checkStitchType(dc.getStitchType()); err != nil {
+		result = append(result, newErrorDTO(proto.ErrorDTO_CRITICAL, "%v", err))
+	}
+	if len(result) > 0 {
+		return result
+	}
+
+	fname := account.resolveTopology(dc.topologyConf, filepath.Dir(dc.topologyConf))
+	return validateTopology(account, fname, dc.strict)
+}
+
+func (dc *DiscoveryClient) getFactory() ClusterHandlerFactory {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	return dc.factory
+}
+
+func (dc *DiscoveryClient) getStitchType() stitching.StitchingPropertyType {
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+	return dc.stitchType
+}
+
+// validateTopology checks that the topology file exists, is loaded and builds the cluster of the account without
+// error; the critical problems found by the topology validation are critical, the others are warnings unless strict.
+func validateTopology(account *TargetAccount, fname string, strict bool) []*proto.ErrorDTO {
+	if fname == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(fname); err != nil {
+		return []*proto.ErrorDTO{newErrorDTO(proto.ErrorDTO_CRITICAL, "topology[%s] of the target is not found: %v", fname, err)}
+	}
+
+	topo := topology.NewTargetTopology(account.ClusterId)
+	if err := topo.LoadTopology(fname); err != nil {
+		return []*proto.ErrorDTO{newErrorDTO(proto.ErrorDTO_CRITICAL, "failed to load topology[%s]: %v", fname, err)}
+	}
+
+	var result []*proto.ErrorDTO
+	for _, diagnostic := range topo.Validate() {
+		severity := proto.ErrorDTO_WARNING
+		if diagnostic.Critical || strict {
+			severity = proto.ErrorDTO_CRITICAL
+		}
+		result = append(result, newErrorDTO(severity, "topology: %v", diagnostic))
+	}
+
+	builder := topology.NewClusterBuilderfromTopology(account.ClusterId, account.ClusterName, topo)
+	if _, err := builder.GenerateCluster(); err != nil {
+		result = append(result, newErrorDTO(proto.ErrorDTO_CRITICAL, "failed to build cluster from topology[%s]: %v", fname, err))
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/registration"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func newAccountValues(address, username, password string) []*proto.AccountValue {
+	var result []*proto.AccountValue
+	for key, value := range map[string]string{
+		registration.TargetIdentifierField: address,
+		registration.Username:              username,
+		registration.Password:              password,
+	} {
+		key, value := key, value
+		result = append(result, &proto.AccountValue{Key: &key, StringValue: &value})
+	}
+	return result
+}
+
+func TestDiscoveryClient_Validate(t *testing.T) {
+	dir := testutil.TempDir(t)
+	invalid := filepath.Join(dir, "invalid.conf")
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1, pod-x
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+`
+	if err := ioutil.WriteFile(invalid, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	// containerA uses more CPU than its limit
+	overused := filepath.Join(dir, "overused.conf")
+	content = `container, containerA, 200, 100, 250, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+`
+	if err := ioutil.WriteFile(overused, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	broken := filepath.Join(dir, "broken.conf")
+	if err := ioutil.WriteFile(broken, []byte("container, containerA, 200, abc\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	conf := &TargetConf{Address: "my.vCluster", Username: "user", Password: "secret"}
+	tests := []struct {
+		name     string
+		topology string
+		strict   bool
+		values   []*proto.AccountValue
+		expected []string
+	}{
+		{"valid", testutil.MakeTestPath("conf/topology.conf"), false, newAccountValues("my.vCluster", "user", "secret"), nil},
+		{"no topology", "", false, newAccountValues("my.vCluster", "user", "secret"), nil},
+		{"wrong address", "", false, newAccountValues("other.vCluster", "user", "secret"),
+			[]string{"CRITICAL unknown target address[other.vCluster]"}},
+		{"no address", "", false, newAccountValues("", "user", "secret"),
+			[]string{"CRITICAL address of the target is missing"}},
+		{"wrong password", "", false, newAccountValues("my.vCluster", "user", "wrong"),
+			[]string{"CRITICAL wrong username or password"}},
+		{"other stitchType", "", false, withFields(newAccountValues("my.vCluster", "user", "secret"),
+			map[string]string{registration.StitchTypeField: "UUID"}),
+			[]string{"CRITICAL stitchType[UUID] of target[my.vCluster] is not the registered stitchType[IP]"}},
+		{"registered stitchType", "", false, withFields(newAccountValues("my.vCluster", "user", "secret"),
+			map[string]string{registration.StitchTypeField: "IP"}), nil},
+		{"missing topology", filepath.Join(dir, "missing.conf"), false, newAccountValues("my.vCluster", "user", "secret"),
+			[]string{"CRITICAL topology[" + filepath.Join(dir, "missing.conf") + "] of the target is not found"}},
+		{"broken topology", broken, false, newAccountValues("my.vCluster", "user", "secret"),
+			[]string{"CRITICAL failed to load topology"}},
+		{"invalid topology", invalid, false, newAccountValues("my.vCluster", "user", "secret"),
+			[]string{"CRITICAL topology: " + invalid + ":3:"}},
+		{"overused topology", overused, false, newAccountValues("my.vCluster", "user", "secret"),
+			[]string{"WARNING topology: " + overused + ":1:"}},
+		{"overused topology in strict mode", overused, true, newAccountValues("my.vCluster", "user", "secret"),
+			[]string{"CRITICAL topology: " + overused + ":1:"}},
+	}
+
+	for _, test := range tests {
+		dc := NewDiscoveryClient(conf, nil)
+		dc.SetTopologyConf(test.topology)
+		dc.SetStrict(test.strict)
+		response, err := dc.Validate(test.values)
+		if err != nil {
+			t.Fatalf("%s: failed to validate: %v", test.name, err)
+		}
+
+		errorDTOs := response.GetErrorDTO()
+		if len(errorDTOs) != len(test.expected) {
+			t.Errorf("%s: expected %d errors, but got %v", test.name, len(test.expected), errorDTOs)
+			continue
+		}
+		for i, prefix := range test.expected {
+			msg := errorDTOs[i].GetSeverity().String() + " " + errorDTOs[i].GetDescription()
+			if !strings.HasPrefix(msg, prefix) {
+				t.Errorf("%s: error %d should start with [%s], but got [%s]", test.name, i, prefix, msg)
+			}
+		}
+	}
+}
@@ -11,6 +11,13 @@ const (
 	TargetIdentifierField string = "targetIdentifier"
 	Username              string = "username"
 	Password              string = "password"
+
+	// optional fields to select the virtual cluster of the target; the settings of the probe if empty
+	TopologyField     string = "topology"
+	StitchTypeField   string = "stitchType"
+	ClusterIdField    string = "clusterId"
+	ClusterNameField  string = "clusterName"
+	ProfileSpeedField string = "profileSpeed"
 )
 
 type DemoRegClient struct {
@@ -51,6 +58,30 @@ func (rClient *DemoRegClient) GetAccountDefinition() []*proto.AccountDefEntry {
 		"Password of the target cluster master", ".*", false, true).Create()
 	acctDefProps = append(acctDefProps, passwordAcctDefEntry)
 
+	// the virtual cluster of the target
+	topologyAcctDefEntry := builder.NewAccountDefEntryBuilder(TopologyField, "Topology",
+		"Topology file of the virtual cluster, or the name of a preset <name>.topology.conf; the topology of the probe if empty",
+		".*", false, false).Create()
+	acctDefProps = append(acctDefProps, topologyAcctDefEntry)
+
+	stitchTypeAcctDefEntry := builder.NewAccountDefEntryBuilder(StitchTypeField, "Stitching Type",
+		"Stitch the vnodes as proxy VMs to the VMs of the same IP or UUID; no stitching if empty",
+		"^$|^[iI][pP]$|^[uU][uU][iI][dD]$", false, false).Create()
+	acctDefProps = append(acctDefProps, stitchTypeAcctDefEntry)
+
+	clusterIdAcctDefEntry := builder.NewAccountDefEntryBuilder(ClusterIdField, "Cluster Id",
+		"Id of the virtual cluster; the address if empty", ".*", false, false).Create()
+	acctDefProps = append(acctDefProps, clusterIdAcctDefEntry)
+
+	clusterNameAcctDefEntry := builder.NewAccountDefEntryBuilder(ClusterNameField, "Cluster Name",
+		"Name of the virtual cluster; the address if empty", ".*", false, false).Create()
+	acctDefProps = append(acctDefProps, clusterNameAcctDefEntry)
+
+	profileSpeedAcctDefEntry := builder.NewAccountDefEntryBuilder(ProfileSpeedField, "Usage Profile Speed",
+		"How many times faster than the real time the usage profiles run, e.g., 24 for one day in one hour; 1 if empty",
+		"^$|^[0-9]*\\.?[0-9]+$", false, false).Create()
+	acctDefProps = append(acctDefProps, profileSpeedAcctDefEntry)
+
 	return acctDefProps
 }
 
@@ -105,16 +136,47 @@ func (rClient *DemoRegClient) GetActionPolicy() []*proto.ActionPolicyDTO {
 
 	rClient.addActionPolicy(ab, service, servicePolicy)
 
-	// 5. node: support provision and suspend; not resize; do not set move
+	// 5. node: support provision, suspend, resize and storage move (change); do not set move
 	vnode := proto.EntityDTO_VIRTUAL_MACHINE
 	vnodePolicy := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
 	vnodePolicy[proto.ActionItemDTO_PROVISION] = supported
-	vnodePolicy[proto.ActionItemDTO_RIGHT_SIZE] = notSupported
+	vnodePolicy[proto.ActionItemDTO_RIGHT_SIZE] = supported
+	vnodePolicy[proto.ActionItemDTO_CHANGE] = supported
 	vnodePolicy[proto.ActionItemDTO_SCALE] = notSupported
 	vnodePolicy[proto.ActionItemDTO_SUSPEND] = supported
 
 	rClient.addActionPolicy(ab, vnode, vnodePolicy)
 
+	// 6. workload controller: support provision and suspend of a replica; not move or resize
+	controller := proto.EntityDTO_WORKLOAD_CONTROLLER
+	controllerPolicy := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	controllerPolicy[proto.ActionItemDTO_PROVISION] = supported
+	controllerPolicy[proto.ActionItemDTO_SUSPEND] = supported
+	controllerPolicy[proto.ActionItemDTO_RIGHT_SIZE] = notSupported
+	controllerPolicy[proto.ActionItemDTO_MOVE] = notSupported
+
+	rClient.addActionPolicy(ab, controller, controllerPolicy)
+
+	// 7. container spec: support resize of the containers in all the replicas; all else are not supported
+	spec := proto.EntityDTO_CONTAINER_SPEC
+	specPolicy := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	specPolicy[proto.ActionItemDTO_RIGHT_SIZE] = supported
+	specPolicy[proto.ActionItemDTO_PROVISION] = notSupported
+	specPolicy[proto.ActionItemDTO_MOVE] = notSupported
+	specPolicy[proto.ActionItemDTO_SUSPEND] = notSupported
+
+	rClient.addActionPolicy(ab, spec, specPolicy)
+
+	// 8. storage: only recommend provision and suspend; all else are not supported
+	storage := proto.EntityDTO_STORAGE
+	storagePolicy := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	storagePolicy[proto.ActionItemDTO_PROVISION] = recommend
+	storagePolicy[proto.ActionItemDTO_SUSPEND] = recommend
+	storagePolicy[proto.ActionItemDTO_RIGHT_SIZE] = notSupported
+	storagePolicy[proto.ActionItemDTO_MOVE] = notSupported
+
+	rClient.addActionPolicy(ab, storage, storagePolicy)
+
 	return ab.Create()
 }
 
@@ -135,6 +197,7 @@ func (rclient *DemoRegClient) GetEntityMetadata() []*proto.EntityIdentityMetadat
 	entities := []proto.EntityDTO_EntityType{
 		proto.EntityDTO_SWITCH,
 		proto.EntityDTO_PHYSICAL_MACHINE,
+		proto.EntityDTO_STORAGE,
 		proto.EntityDTO_NAMESPACE,
 		proto.EntityDTO_WORKLOAD_CONTROLLER,
 		proto.EntityDTO_VIRTUAL_MACHINE,
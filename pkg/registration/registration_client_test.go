@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
 )
 
 func xcheck(expected map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability,
@@ -41,12 +43,16 @@ func TestK8sRegistrationClient_GetActionPolicy(t *testing.T) {
 	container := proto.EntityDTO_CONTAINER
 	app := proto.EntityDTO_APPLICATION_COMPONENT
 	service := proto.EntityDTO_SERVICE
+	controller := proto.EntityDTO_WORKLOAD_CONTROLLER
+	spec := proto.EntityDTO_CONTAINER_SPEC
+	storage := proto.EntityDTO_STORAGE
 
 	move := proto.ActionItemDTO_MOVE
 	resize := proto.ActionItemDTO_RIGHT_SIZE
 	provision := proto.ActionItemDTO_PROVISION
 	suspend := proto.ActionItemDTO_SUSPEND
 	scale := proto.ActionItemDTO_SCALE
+	change := proto.ActionItemDTO_CHANGE
 
 	expected_pod := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
 	expected_pod[move] = supported
@@ -73,10 +79,29 @@ func TestK8sRegistrationClient_GetActionPolicy(t *testing.T) {
 	expected_service[suspend] = notSupported
 
 	expected_node := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
-	expected_node[resize] = notSupported
+	expected_node[resize] = supported
 	expected_node[provision] = supported
 	expected_node[suspend] = supported
 	expected_node[scale] = notSupported
+	expected_node[change] = supported
+
+	expected_controller := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	expected_controller[move] = notSupported
+	expected_controller[resize] = notSupported
+	expected_controller[provision] = supported
+	expected_controller[suspend] = supported
+
+	expected_spec := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	expected_spec[move] = notSupported
+	expected_spec[resize] = supported
+	expected_spec[provision] = notSupported
+	expected_spec[suspend] = notSupported
+
+	expected_storage := make(map[proto.ActionItemDTO_ActionType]proto.ActionPolicyDTO_ActionCapability)
+	expected_storage[move] = notSupported
+	expected_storage[resize] = notSupported
+	expected_storage[provision] = recommend
+	expected_storage[suspend] = recommend
 
 	policies := reg.GetActionPolicy()
 
@@ -94,6 +119,12 @@ func TestK8sRegistrationClient_GetActionPolicy(t *testing.T) {
 			expected = expected_node
 		} else if entity == service {
 			expected = expected_service
+		} else if entity == controller {
+			expected = expected_controller
+		} else if entity == spec {
+			expected = expected_spec
+		} else if entity == storage {
+			expected = expected_storage
 		} else {
 			t.Errorf("Unknown entity type: %v", entity)
 		}
@@ -103,3 +134,50 @@ func TestK8sRegistrationClient_GetActionPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestDemoRegClient_GetAccountDefinition(t *testing.T) {
+	entries := NewRegClient("mock").GetAccountDefinition()
+
+	// the fields selecting the virtual cluster are optional
+	expected := []string{TargetIdentifierField, Username, Password,
+		TopologyField, StitchTypeField, ClusterIdField, ClusterNameField, ProfileSpeedField}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d account fields, but got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if name := entry.GetCustomDefinition().GetName(); name != expected[i] {
+			t.Errorf("account field %d should be %s, but got %s", i, expected[i], name)
+		}
+		if i >= 3 && entry.GetMandatory() {
+			t.Errorf("account field %s should be optional", expected[i])
+		}
+	}
+}
+
+func TestDemoRegClient_GetSupplyChainDefinition(t *testing.T) {
+	var pod *proto.TemplateDTO
+	for _, template := range NewRegClient(stitching.IP).GetSupplyChainDefinition() {
+		if template.GetTemplateClass() == proto.EntityDTO_CONTAINER_POD {
+			pod = template
+		}
+	}
+	if pod == nil {
+		t.Fatalf("supply chain has no pod template")
+	}
+
+	// the pods buy the VMPM_ACCESS commodities of their placement constraints from the VMs
+	found := false
+	for _, bought := range pod.GetCommodityBought() {
+		if bought.GetKey().GetTemplateClass() != proto.EntityDTO_VIRTUAL_MACHINE {
+			continue
+		}
+		for _, comm := range bought.GetValue() {
+			if comm.GetCommodityType() == proto.CommodityDTO_VMPM_ACCESS && comm.GetKey() != "" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("pods should buy keyed VMPM_ACCESS from VMs: %v", pod.GetCommodityBought())
+	}
+}
@@ -29,6 +29,11 @@ var (
 	responseTimeType       = proto.CommodityDTO_RESPONSE_TIME
 	numPodNumConsumersType = proto.CommodityDTO_NUMBER_CONSUMERS
 	vStorageType           = proto.CommodityDTO_VSTORAGE
+	storageAmountType      = proto.CommodityDTO_STORAGE_AMOUNT
+	storageAccessType      = proto.CommodityDTO_STORAGE_ACCESS
+	storageClusterType     = proto.CommodityDTO_STORAGE_CLUSTER
+	dspmAccessType         = proto.CommodityDTO_DSPM_ACCESS
+	datastoreType          = proto.CommodityDTO_DATASTORE
 
 	fakeKey = "fake"
 
@@ -45,6 +50,8 @@ var (
 	vMemRequestTemplateComm        = &proto.TemplateCommodity{CommodityType: &vMemRequestType}
 	numPodNumConsumersTemplateComm = &proto.TemplateCommodity{CommodityType: &numPodNumConsumersType}
 	vStorageTemplateComm           = &proto.TemplateCommodity{CommodityType: &vStorageType}
+	storageAmountTemplateComm      = &proto.TemplateCommodity{CommodityType: &storageAmountType}
+	storageAccessTemplateComm      = &proto.TemplateCommodity{CommodityType: &storageAccessType}
 
 	// Optional TemplateCommodity
 	vCpuRequestTemplateCommOpt      = &proto.TemplateCommodity{CommodityType: &vCpuRequestType, Optional: &commIsOptional}
@@ -73,6 +80,9 @@ var (
 	applicationTemplateCommWithKey      = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &appCommType}
 	transactionTemplateComm             = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &transactionType}
 	responseTimeTemplateComm            = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &responseTimeType}
+	storageClusterTemplateComm          = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &storageClusterType}
+	dspmAccessTemplateComm              = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &dspmAccessType}
+	datastoreTemplateComm               = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &datastoreType}
 
 	// Resold TemplateCommodity with key
 	vCpuLimitQuotaTemplateCommWithKeyResold   = &proto.TemplateCommodity{Key: &fakeKey, CommodityType: &vCpuLimitQuotaType, IsResold: &commIsResold}
@@ -109,6 +119,12 @@ func (f *SupplyChainFactory) createSupplyChain() ([]*proto.TemplateDTO, error) {
 		return nil, err
 	}
 
+	// Storage supply chain template
+	storageSupplyChainNode, err := f.buildStorageSupply()
+	if err != nil {
+		return nil, err
+	}
+
 	// Node supply chain template
 	nodeSupplyChainNode, err := f.buildNodeSupplyBuilder()
 	if err != nil {
@@ -179,6 +195,7 @@ func (f *SupplyChainFactory) createSupplyChain() ([]*proto.TemplateDTO, error) {
 	supplyChainBuilder.Entity(namespaceSupplyChainNode)
 	supplyChainBuilder.Entity(nodeSupplyChainNode)
 	supplyChainBuilder.Entity(pmSupplyChainNode)
+	supplyChainBuilder.Entity(storageSupplyChainNode)
 
 	return supplyChainBuilder.Create()
 }
@@ -236,12 +253,25 @@ func (f *SupplyChainFactory) buildPMSupply() (*proto.TemplateDTO, error) {
 	nodeSupplyChainNodeBuilder = nodeSupplyChainNodeBuilder.
 		Sells(CpuTemplateComm).
 		Sells(MemTemplateComm).
-		Sells(clusterTemplateComm)
+		Sells(clusterTemplateComm).
+		Sells(datastoreTemplateComm) // sells to VMs, key=storage attached
 
 	return nodeSupplyChainNodeBuilder.Create()
 }
 
+func (f *SupplyChainFactory) buildStorageSupply() (*proto.TemplateDTO, error) {
+	storageSupplyChainNodeBuilder := supplychain.NewSupplyChainNodeBuilder(proto.EntityDTO_STORAGE)
+	storageSupplyChainNodeBuilder = storageSupplyChainNodeBuilder.
+		Sells(storageAmountTemplateComm).  // sells to VMs
+		Sells(storageAccessTemplateComm).  // sells to VMs
+		Sells(storageClusterTemplateComm). // sells to VMs
+		Sells(dspmAccessTemplateComm)      // sells to VMs, key=node attached
+
+	return storageSupplyChainNodeBuilder.Create()
+}
+
 func (f *SupplyChainFactory) buildNodeSupplyBuilder() (*proto.TemplateDTO, error) {
+	isProviderOptional := true
 	nodeSupplyChainNodeBuilder := supplychain.NewSupplyChainNodeBuilder(proto.EntityDTO_VIRTUAL_MACHINE)
 
 	nodeSupplyChainNodeBuilder = nodeSupplyChainNodeBuilder.
@@ -251,7 +281,12 @@ func (f *SupplyChainFactory) buildNodeSupplyBuilder() (*proto.TemplateDTO, error
 		Sells(vMemRequestTemplateComm).        // sells to Pods
 		Sells(vmpmAccessTemplateComm).         // sells to Pods
 		Sells(numPodNumConsumersTemplateComm). // sells to Pods
-		Sells(vStorageTemplateComm)            // sells to Pods
+		Sells(vStorageTemplateComm).           // sells to Pods
+		ProviderOpt(proto.EntityDTO_STORAGE, proto.Provider_LAYERED_OVER, &isProviderOptional).
+		Buys(storageAmountTemplateComm). // the disk, if it is on a Storage
+		Buys(storageAccessTemplateComm).
+		Buys(storageClusterTemplateComm).
+		Buys(dspmAccessTemplateComm)
 	// also sells Cluster to Pods
 
 	return nodeSupplyChainNodeBuilder.Create()
@@ -307,6 +342,7 @@ func (f *SupplyChainFactory) buildPodSupplyBuilder() (*proto.TemplateDTO, error)
 		Buys(vMemRequestTemplateComm).
 		Buys(numPodNumConsumersTemplateComm).
 		Buys(vStorageTemplateComm).
+		Buys(vmpmAccessTemplateComm). // the placement constraints of the Pod, key=label/taint
 		ProviderOpt(proto.EntityDTO_WORKLOAD_CONTROLLER, proto.Provider_HOSTING, &isProviderOptional).
 		Buys(vCpuLimitQuotaTemplateCommWithKey).
 		Buys(vMemLimitQuotaTemplateCommWithKey).
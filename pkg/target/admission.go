@@ -0,0 +1,194 @@
+package target
+
+import (
+	"fmt"
+)
+
+const (
+	ResourceCPU       = "CPU"
+	ResourceMemory    = "Memory"
+	ResourceCPUReq    = "CPURequest"
+	ResourceMemoryReq = "MemoryRequest"
+	ResourceVStorage  = "VStorage"
+
+	ResourceStorageAmount = "StorageAmount"
+
+	ResourceCPULimitQuota      = "CPULimitQuota"
+	ResourceMemoryLimitQuota   = "MemoryLimitQuota"
+	ResourceCPURequestQuota    = "CPURequestQuota"
+	ResourceMemoryRequestQuota = "MemoryRequestQuota"
+
+	defaultOvercommitRatio = 1.0
+)
+
+// OvercommitRatio: the capacity of a host can be over-committed by this ratio during admission check.
+type OvercommitRatio struct {
+	CPU    float64
+	Memory float64
+}
+
+func NewOvercommitRatio(cpu, memory float64) *OvercommitRatio {
+	if cpu <= 0 {
+		cpu = defaultOvercommitRatio
+	}
+
+	if memory <= 0 {
+		memory = defaultOvercommitRatio
+	}
+
+	return &OvercommitRatio{
+		CPU:    cpu,
+		Memory: memory,
+	}
+}
+
+// AdmissionError is the reason why an entity cannot be placed on a host.
+type AdmissionError struct {
+	Entity    string
+	Host      string
+	Resource  string
+	Required  float64
+	Available float64
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("not enough %s on [%s] for [%s]: required %.1f, available %.1f",
+		e.Resource, e.Host, e.Entity, e.Required, e.Available)
+}
+
+func (pod *Pod) getRequest() (float64, float64) {
+	cpu := 0.0
+	mem := 0.0
+	for _, container := range pod.Containers {
+		cpu += container.ReqCPU
+		mem += container.ReqMemory
+	}
+
+	return cpu, mem
+}
+
+// check whether the VNode can host the Pod. Pod and VNode usage should be up-to-date.
+func (vnode *VNode) admitPod(pod *Pod, ratio *OvercommitRatio) error {
+	check := func(resource string, required, available float64) error {
+		if required > available {
+			return &AdmissionError{
+				Entity:    pod.Name,
+				Host:      vnode.Name,
+				Resource:  resource,
+				Required:  required,
+				Available: available,
+			}
+		}
+		return nil
+	}
+
+	// 1. used; VNode.Used includes the overhead
+	freeCPU := vnode.CPU.Capacity*ratio.CPU - vnode.CPU.Used
+	if err := check(ResourceCPU, pod.CPU.Used, freeCPU); err != nil {
+		return err
+	}
+
+	freeMem := vnode.Memory.Capacity*ratio.Memory - vnode.Memory.Used
+	if err := check(ResourceMemory, pod.Memory.Used, freeMem); err != nil {
+		return err
+	}
+
+	// 2. request
+	reqCPU := 0.0
+	reqMem := 0.0
+	for _, p := range vnode.Pods {
+		cpu, mem := p.getRequest()
+		reqCPU += cpu
+		reqMem += mem
+	}
+
+	podReqCPU, podReqMem := pod.getRequest()
+	freeCPU = vnode.CPU.Capacity*ratio.CPU - defaultOverheadVMCPU - reqCPU
+	if err := check(ResourceCPUReq, podReqCPU, freeCPU); err != nil {
+		return err
+	}
+
+	freeMem = vnode.Memory.Capacity*ratio.Memory - defaultOverheadVMMem - reqMem
+	if err := check(ResourceMemoryReq, podReqMem, freeMem); err != nil {
+		return err
+	}
+
+	// 3. volumes, on the disk of the VNode; not over-committed
+	if size, _ := pod.getVolumeUsage(); size > 0 {
+		return check(ResourceVStorage, size, vnode.Disk.Capacity-vnode.Disk.Used)
+	}
+	return nil
+}
+
+// check whether the Node can host the VNode. VNode and Node usage should be up-to-date.
+func (node *Node) admitVM(vnode *VNode, ratio *OvercommitRatio) error {
+	// Node.Used includes the overhead
+	freeCPU := node.CPU.Capacity*ratio.CPU - node.CPU.Used
+	if vnode.CPU.Used > freeCPU {
+		return &AdmissionError{
+			Entity:    vnode.Name,
+			Host:      node.Name,
+			Resource:  ResourceCPU,
+			Required:  vnode.CPU.Used,
+			Available: freeCPU,
+		}
+	}
+
+	freeMem := node.Memory.Capacity*ratio.Memory - node.Memory.Used
+	if vnode.Memory.Used > freeMem {
+		return &AdmissionError{
+			Entity:    vnode.Name,
+			Host:      node.Name,
+			Resource:  ResourceMemory,
+			Required:  vnode.Memory.Used,
+			Available: freeMem,
+		}
+	}
+
+	return nil
+}
+
+// check whether the VNode can be resized to the given capacity: its Pods, with the overhead, should still fit
+// in by both usage and request. VNode usage should be up-to-date. A non-positive capacity means no change.
+func (vnode *VNode) admitResize(cpu, memory float64, ratio *OvercommitRatio) error {
+	check := func(resource string, required, available float64) error {
+		if required > available {
+			return &AdmissionError{
+				Entity:    fmt.Sprintf("%d Pods", len(vnode.Pods)),
+				Host:      vnode.Name,
+				Resource:  resource,
+				Required:  required,
+				Available: available,
+			}
+		}
+		return nil
+	}
+
+	reqCPU := defaultOverheadVMCPU * 1.0
+	reqMem := defaultOverheadVMMem * 1.0
+	for _, pod := range vnode.Pods {
+		c, m := pod.getRequest()
+		reqCPU += c
+		reqMem += m
+	}
+
+	if cpu > 0 && cpu < vnode.CPU.Capacity {
+		if err := check(ResourceCPU, vnode.CPU.Used, cpu*ratio.CPU); err != nil {
+			return err
+		}
+		if err := check(ResourceCPUReq, reqCPU, cpu*ratio.CPU); err != nil {
+			return err
+		}
+	}
+
+	if memory > 0 && memory < vnode.Memory.Capacity {
+		if err := check(ResourceMemory, vnode.Memory.Used, memory*ratio.Memory); err != nil {
+			return err
+		}
+		if err := check(ResourceMemoryReq, reqMem, memory*ratio.Memory); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
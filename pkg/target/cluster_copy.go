@@ -0,0 +1,168 @@
+package target
+
+// DeepCopy returns a copy of the Cluster sharing no entity with the original one.
+func (c *Cluster) DeepCopy() *Cluster {
+	result := &Cluster{
+		ObjectMeta:   c.ObjectMeta,
+		start:        c.start,
+		trace:        c.trace,
+		profileSpeed: c.profileSpeed,
+		stitchType:   c.stitchType,
+	}
+
+	pods := make(map[string]*Pod)
+
+	if c.Nodes != nil {
+		result.Nodes = make(map[string]*Node)
+		for k, node := range c.Nodes {
+			result.Nodes[k] = node.deepCopy(pods)
+		}
+	}
+
+	if c.Switches != nil {
+		result.Switches = make(map[string]*Switch)
+		for k, networkswitch := range c.Switches {
+			newSwitch := *networkswitch
+			newSwitch.PMs = make(map[string]*Node)
+			for pk, pm := range networkswitch.PMs {
+				if node, exist := result.Nodes[pm.UUID]; exist {
+					newSwitch.PMs[pk] = node
+				} else {
+					newSwitch.PMs[pk] = pm.deepCopy(pods)
+				}
+			}
+			result.Switches[k] = &newSwitch
+		}
+	}
+
+	for _, service := range c.Services {
+		newService := *service
+		newService.Pods = []*Pod{}
+		for _, pod := range service.Pods {
+			if p, exist := pods[pod.UUID]; exist {
+				newService.Pods = append(newService.Pods, p)
+			} else {
+				newService.Pods = append(newService.Pods, pod.deepCopy())
+			}
+		}
+		result.Services = append(result.Services, &newService)
+	}
+
+	if c.Namespaces != nil {
+		result.Namespaces = make(map[string]*Namespace)
+		for k, ns := range c.Namespaces {
+			newNamespace := *ns
+			result.Namespaces[k] = &newNamespace
+		}
+	}
+
+	if c.Controllers != nil {
+		result.Controllers = make(map[string]*WorkloadController)
+		for k, controller := range c.Controllers {
+			newController := *controller
+			result.Controllers[k] = &newController
+		}
+	}
+
+	if c.Storages != nil {
+		result.Storages = make(map[string]*Storage)
+		for k, s := range c.Storages {
+			newStorage := *s
+			newStorage.Nodes = append([]string(nil), s.Nodes...)
+			result.Storages[k] = &newStorage
+		}
+	}
+
+	return result
+}
+
+// pods: the copied Pods, key=pod.UUID
+func (node *Node) deepCopy(pods map[string]*Pod) *Node {
+	result := *node
+	if node.VMs != nil {
+		result.VMs = make(map[string]*VNode)
+		for k, vnode := range node.VMs {
+			result.VMs[k] = vnode.deepCopy(pods)
+		}
+	}
+	return &result
+}
+
+func (vnode *VNode) deepCopy(pods map[string]*Pod) *VNode {
+	result := *vnode
+	result.Labels = copyLabels(vnode.Labels)
+	result.Taints = copyTaints(vnode.Taints)
+	if vnode.Pods != nil {
+		result.Pods = make(map[string]*Pod)
+		for k, pod := range vnode.Pods {
+			p := pod.deepCopy()
+			result.Pods[k] = p
+			pods[p.UUID] = p
+		}
+	}
+	return &result
+}
+
+func (pod *Pod) deepCopy() *Pod {
+	result := *pod
+	pod.copyPlacement(&result)
+	result.Volumes = copyVolumes(pod.Volumes)
+	result.Containers = nil
+	for _, container := range pod.Containers {
+		result.Containers = append(result.Containers, container.deepCopy())
+	}
+	return &result
+}
+
+func (d *Container) deepCopy() *Container {
+	result := *d
+	if d.App != nil {
+		app := *d.App
+		result.App = &app
+	}
+	return &result
+}
+
+// copyPlacement copies the labels and placement constraints of the Pod to the other one
+func (pod *Pod) copyPlacement(other *Pod) {
+	other.Labels = copyLabels(pod.Labels)
+	other.NodeSelector = copyLabels(pod.NodeSelector)
+	other.Affinity = copyLabels(pod.Affinity)
+	other.AntiAffinity = copyLabels(pod.AntiAffinity)
+	other.Tolerations = copyTolerations(pod.Tolerations)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+// copyTaints copies the taints; nil if there is none
+func copyTaints(taints []Taint) []Taint {
+	if len(taints) < 1 {
+		return nil
+	}
+	return append([]Taint(nil), taints...)
+}
+
+// copyVolumes copies the volumes; nil if there is none
+func copyVolumes(volumes []Volume) []Volume {
+	if len(volumes) < 1 {
+		return nil
+	}
+	return append([]Volume(nil), volumes...)
+}
+
+// copyTolerations copies the tolerations; nil if there is none
+func copyTolerations(tolerations []Toleration) []Toleration {
+	if len(tolerations) < 1 {
+		return nil
+	}
+	return append([]Toleration(nil), tolerations...)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"time"
 )
 
 func (c *Cluster) GenerateDTOs() ([]*proto.EntityDTO, error) {
@@ -16,7 +17,7 @@ func (c *Cluster) GenerateDTOs() ([]*proto.EntityDTO, error) {
 	}
 
 	//0. calculate the resource usage
-	c.SetResourceAmount()
+	c.prepareDTOs()
 
 	//1. switch, node, pod, container, app DTOs
 	if c.Switches != nil {
@@ -60,12 +61,24 @@ func (c *Cluster) GenerateDTOs() ([]*proto.EntityDTO, error) {
 	}
 
 	//2. service DTOs
-	if serviceDTOs, err := c.generateServiceDTOs(); err != nil {
+	if serviceDTOs, err := c.generateServiceDTOs(nil); err != nil {
 		glog.Errorf("failed to generate ServiceDTOs:%v", err)
 	} else {
 		result = append(result, serviceDTOs...)
 	}
 
+	//3. namespace DTOs
+	result = append(result, c.generateNamespaceDTOs(nil)...)
+
+	//4. controller and containerSpec DTOs
+	result = append(result, c.generateControllerDTOs(nil)...)
+
+	//5. storage DTOs
+	result = append(result, c.generateStorageDTOs(nil)...)
+
+	//6. stitching properties, and the order of the commodities
+	c.completeDTOs(result)
+
 	glog.V(2).Infof("There are %d DTOs in total.", len(result))
 	if len(result) < 1 {
 		return result, fmt.Errorf("failed to generate valid DTOs.")
@@ -74,7 +87,26 @@ func (c *Cluster) GenerateDTOs() ([]*proto.EntityDTO, error) {
 	return result, nil
 }
 
-func (c *Cluster) generateServiceDTOs() ([]*proto.EntityDTO, error) {
+// prepareDTOs sets the resource usage, the access keys and the proxy VMs, before the DTOs are built.
+func (c *Cluster) prepareDTOs() {
+	c.SetResourceAmount()
+	c.setAccessKeys()
+	if err := c.setProxyVMs(); err != nil {
+		glog.Errorf("failed to set proxy VMs: %v", err)
+	}
+}
+
+// completeDTOs stitches the proxy VMs and the pods to the real VMs, and sorts the commodities bought,
+// so that the DTOs are the same if the cluster is not changed.
+func (c *Cluster) completeDTOs(dtos []*proto.EntityDTO) {
+	if c.stitchType != "" {
+		c.addStitchingProperties(dtos)
+	}
+	sortCommoditiesBought(dtos)
+}
+
+// generateServiceDTOs builds the VirtualApps in the filter.
+func (c *Cluster) generateServiceDTOs(filter entityFilter) ([]*proto.EntityDTO, error) {
 	var result []*proto.EntityDTO
 	if c.Services == nil || len(c.Services) < 1 {
 		glog.Warningf("No services in cluster[%s]", c.Name)
@@ -82,6 +114,9 @@ func (c *Cluster) generateServiceDTOs() ([]*proto.EntityDTO, error) {
 	}
 
 	for _, service := range c.Services {
+		if !filter.has(service.UUID) {
+			continue
+		}
 		serviceDTO, err := service.BuildDTO()
 		if err != nil {
 			e := fmt.Errorf("failed to build serviceDTO for service[%s]: %v", service.Name, err)
@@ -96,6 +131,16 @@ func (c *Cluster) generateServiceDTOs() ([]*proto.EntityDTO, error) {
 	return result, nil
 }
 
+// FindService returns the VirtualApp containing the Pod, or nil.
+func (c *Cluster) FindService(podId string) *VirtualApp {
+	for _, service := range c.Services {
+		if service.HasPod(podId) {
+			return service
+		}
+	}
+	return nil
+}
+
 // 1. set ProviderId for each SE;
 // 2. Generate Application for each pod-container;
 // 3. calculate and set resource usage;
@@ -124,64 +169,112 @@ func (c *Cluster) SetProvider() {
 }
 
 // SetResourceAmount: Set the resource Capacity and Usage
-// Container.Capacity = Container.Limit/Pod.Capacity
+// Container.Capacity = Container.Limit/Pod.Capacity (if no limit)
 // Pod.Capacity = VM.Capacity
 // VM.Capacity = setting
 // PM.Capacity = setting
-// Container.Used = monitored (from topology)
+// Container.Used = monitored (from topology, changed over time by the profile, or replayed from the trace)
 // Pod.Used = sum.container.Used
+// Namespace.Used = sum.Pod.limits/requests
 // VM.Used = monitored = sum.Pod.Used + overhead1
 // PM.Used = monitored = sum.Vm.Used + overhead2
+// VM.Disk.Used = sum.Pod.volumes
+// Storage.Used = sum.VM.Disk.Capacity
 func (c *Cluster) SetResourceAmount() {
+	t := c.usageTime()
 	for _, host := range c.Nodes {
-		hostCPU := 0.0
-		hostMem := 0.0
+		c.setNodeUsage(host, t)
+	}
 
-		for _, vhost := range host.VMs {
-			vhostCPU := 0.0
-			vhostMem := 0.0
+	c.setQuotaUsage()
+	c.setStorageUsage()
+	return
+}
 
-			for _, pod := range vhost.Pods {
-				pod.CPU.Capacity = vhost.CPU.Capacity
-				pod.Memory.Capacity = vhost.Memory.Capacity
+// usageTime is the time of the usage profiles and of the trace, so that the usage of the entities updated together
+// is of the same time.
+type usageTime struct {
+	profile time.Duration
+	trace   time.Duration
+}
 
-				podCPU := 0.0
-				podMem := 0.0
+func (c *Cluster) usageTime() usageTime {
+	elapsed := timeNow().Sub(c.start)
+	t := usageTime{profile: time.Duration(float64(elapsed) * c.profileSpeed)}
+	if c.trace != nil {
+		t.trace = c.trace.position(elapsed)
+	}
+	return t
+}
 
-				for _, container := range pod.Containers {
-					app := container.App
-					app.CPU.Used = container.CPU.Used
-					app.Memory.Used = container.Memory.Used
+// setNodeUsage sets the usage of the Node, and of its VNodes.
+func (c *Cluster) setNodeUsage(host *Node, t usageTime) {
+	hostCPU := 0.0
+	hostMem := 0.0
+	for _, vhost := range host.VMs {
+		c.setVNodeUsage(vhost, t)
+		hostCPU += vhost.CPU.Used
+		hostMem += vhost.Memory.Used
+	}
 
-					podCPU += container.CPU.Used
-					podMem += container.Memory.Used
+	host.CPU.Used = hostCPU + defaultOverheadPMCPU
+	host.Memory.Used = hostMem + defaultOverheadPMMem
+}
 
-					if container.CPU.Capacity < 1 {
-						container.CPU.Capacity = pod.CPU.Capacity
-					}
+// setVNodeUsage sets the usage and the disk usage of the VNode, and the capacity and usage of its Pods;
+// the usage of its Node is not changed.
+func (c *Cluster) setVNodeUsage(vhost *VNode, t usageTime) {
+	vhostCPU := 0.0
+	vhostMem := 0.0
+	vhost.Disk.Used = 0
+	for _, pod := range vhost.Pods {
+		pod.CPU.Capacity = vhost.CPU.Capacity
+		pod.Memory.Capacity = vhost.Memory.Capacity
+		c.setPodUsage(pod, t)
 
-					if container.Memory.Capacity < 1 {
-						container.Memory.Capacity = pod.Memory.Capacity
-					}
-				}
+		vhostCPU += pod.CPU.Used
+		vhostMem += pod.Memory.Used
+		size, _ := pod.getVolumeUsage()
+		vhost.Disk.Used += size
+	}
 
-				pod.CPU.Used = podCPU
-				pod.Memory.Used = podMem
+	vhost.CPU.Used = vhostCPU + defaultOverheadVMCPU
+	vhost.Memory.Used = vhostMem + defaultOverheadVMMem
+}
 
-				vhostCPU += pod.CPU.Used
-				vhostMem += pod.Memory.Used
-			}
+// setPodUsage sets the usage of the Pod, and the capacity and usage of its containers;
+// the usage of its VNode is not changed.
+func (c *Cluster) setPodUsage(pod *Pod, t usageTime) {
+	podCPU := 0.0
+	podMem := 0.0
 
-			vhost.CPU.Used = vhostCPU + defaultOverheadVMCPU
-			vhost.Memory.Used = vhostMem + defaultOverheadVMMem
+	for _, container := range pod.Containers {
+		// container without limit follows the Pod, so that VM resize is propagated
+		if container.CPU.Capacity < 1 || container.inheritCPU {
+			container.CPU.Capacity = pod.CPU.Capacity
+			container.inheritCPU = true
+		}
 
-			hostCPU += vhost.CPU.Used
-			hostMem += vhost.Memory.Used
+		if container.Memory.Capacity < 1 || container.inheritMem {
+			container.Memory.Capacity = pod.Memory.Capacity
+			container.inheritMem = true
 		}
 
-		host.CPU.Used = hostCPU + defaultOverheadPMCPU
-		host.Memory.Used = hostMem + defaultOverheadPMMem
+		container.applyProfile(t.profile)
+		if c.trace != nil {
+			container.applyTrace(c.trace.trace, containerKey(pod, container), t.trace)
+		}
+
+		app := container.App
+		app.CPU.Used = container.CPU.Used
+		app.Memory.Used = container.Memory.Used
+		app.QPS.Used = container.QPS.Used
+		app.ResponseTime.Used = container.ResponseTime.Used
+
+		podCPU += container.CPU.Used
+		podMem += container.Memory.Used
 	}
 
-	return
+	pod.CPU.Used = podCPU
+	pod.Memory.Used = podMem
 }
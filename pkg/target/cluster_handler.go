@@ -1,8 +1,11 @@
 package target
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"net"
+	"sort"
 	"sync"
 
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
@@ -17,15 +20,28 @@ type ClusterHandler struct {
 	nodes      map[string]*Node
 	switches   map[string]*Switch
 
+	overcommit *OvercommitRatio
+
+	// snapshots before the executed actions, for undo
+	history []*EntitySnapshot
+
+	// the DTOs sent by the last discovery, for incremental discovery
+	dirty *dirtyTracker
+	// the usage history of the commodities, for the average and peak; disabled if nil
+	sampler *UsageSampler
+
 	Ready bool
-	mux   sync.Mutex
+	// discovery and snapshots only need the read lock; changes on the cluster need the write lock
+	mux sync.RWMutex
 }
 
 func NewClusterHandler(c *Cluster) *ClusterHandler {
 
 	h := &ClusterHandler{
-		cluster: c,
-		Ready:   false,
+		cluster:    c,
+		overcommit: NewOvercommitRatio(defaultOvercommitRatio, defaultOvercommitRatio),
+		dirty:      newDirtyTracker(),
+		Ready:      false,
 	}
 
 	h.BuildIndex()
@@ -37,6 +53,14 @@ func (h *ClusterHandler) String() string {
 	return fmt.Sprintf("clusterInfo: %s; %s", h.cluster.Name, h.cluster.UUID)
 }
 
+// SetOvercommitRatio sets the ratio by which hosts can be over-committed when moving entities onto them.
+func (h *ClusterHandler) SetOvercommitRatio(cpu, memory float64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.overcommit = NewOvercommitRatio(cpu, memory)
+	glog.V(2).Infof("overcommit ratio: CPU=%.2f, Memory=%.2f", h.overcommit.CPU, h.overcommit.Memory)
+}
+
 func (h *ClusterHandler) BuildIndex() {
 	h.mux.Lock()
 	defer h.mux.Unlock()
@@ -45,42 +69,43 @@ func (h *ClusterHandler) BuildIndex() {
 		return
 	}
 
-	containers := make(map[string]*Container)
-	pods := make(map[string]*Pod)
-	vnodes := make(map[string]*VNode)
-	nodes := make(map[string]*Node)
-	switches := make(map[string]*Switch)
-
-	c := h.cluster
-	for _, host := range c.Nodes {
-		nodes[host.UUID] = host
-
-		for _, vhost := range host.VMs {
-			vnodes[vhost.UUID] = vhost
+	h.buildIndex()
+}
 
-			for _, pod := range vhost.Pods {
-				pods[pod.UUID] = pod
+func (h *ClusterHandler) buildIndex() {
+	index := newClusterIndex(h.cluster)
 
-				for _, container := range pod.Containers {
-					containers[container.UUID] = container
-				}
-			}
-		}
-	}
-
-	h.switches = switches
-	h.nodes = nodes
-	h.vnodes = vnodes
-	h.pods = pods
-	h.containers = containers
+	h.switches = index.switches
+	h.nodes = index.nodes
+	h.vnodes = index.vnodes
+	h.pods = index.pods
+	h.containers = index.containers
 
 	h.Ready = true
 }
 
-func (h *ClusterHandler) GenerateClusterDTOs() ([]*proto.EntityDTO, error) {
+// Snapshot returns a copy of the current cluster, which can be restored later.
+func (h *ClusterHandler) Snapshot() *Cluster {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.cluster.DeepCopy()
+}
+
+// Restore replaces the cluster by a snapshot, and rebuilds the index.
+func (h *ClusterHandler) Restore(snapshot *Cluster) {
 	h.mux.Lock()
 	defer h.mux.Unlock()
-	return h.cluster.GenerateDTOs()
+	h.cluster = snapshot.DeepCopy()
+	h.buildIndex()
+	h.clearHistory()
+	h.dirty.markAll()
+	glog.V(2).Infof("cluster[%s] is restored from snapshot.", h.cluster.Name)
+}
+
+// GenerateClusterDTOs builds the DTOs from a snapshot of the cluster,
+// so that the actions are only blocked while the snapshot is being taken.
+func (h *ClusterHandler) GenerateClusterDTOs() ([]*proto.EntityDTO, error) {
+	return h.Snapshot().GenerateDTOs()
 }
 
 func (h *ClusterHandler) MovePod(podId, vnodeId string) error {
@@ -120,6 +145,21 @@ func (h *ClusterHandler) MovePod(podId, vnodeId string) error {
 		glog.Error(err.Error())
 		return err
 	}
+
+	if err := vnode.CheckPlacement(pod); err != nil {
+		err := fmt.Errorf("MovePod failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	t := h.cluster.usageTime()
+	h.cluster.setPodUsage(pod, t)
+	h.cluster.setVNodeUsage(vnode, t)
+	if err := vnode.admitPod(pod, h.overcommit); err != nil {
+		err := fmt.Errorf("MovePod failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
 	if err := oldVnode.DeletePod(podId); err != nil {
 		err := fmt.Errorf("MovePod failed. %v", err)
 		glog.Error(err.Error())
@@ -132,6 +172,7 @@ func (h *ClusterHandler) MovePod(podId, vnodeId string) error {
 		glog.Error(err.Error())
 		return err
 	}
+	h.dirty.mark(pod.UUID, oldVnode.UUID, vnode.UUID)
 
 	glog.V(2).Infof("Successed: move pod[%s] from vnode[%s] to vnode[%s]", pod.Name, oldVnode.Name, vnode.Name)
 	glog.V(2).Infof("oldVnode pods: %s", oldVnode.GetPodNames())
@@ -156,11 +197,111 @@ func (h *ClusterHandler) ResizeContainerCapacity(containerId string, cpu, memory
 		return err
 	}
 
+	if pod, exist := h.pods[container.ProviderID]; exist {
+		if ns, exist := h.cluster.Namespaces[pod.Namespace]; exist {
+			h.cluster.setNamespaceUsage(pod.Namespace)
+			if err := ns.admit(container.Name, container.getResizeQuotaUsage(cpu, memory)); err != nil {
+				err := fmt.Errorf("ResizeContainerCapacity failed. %w", err)
+				glog.Error(err.Error())
+				return err
+			}
+		}
+	}
+
 	container.SetCapacity(cpu, memory)
+	h.dirty.mark(container.UUID)
 
 	return nil
 }
 
+// ResizeContainerSpec resizes the containers of a ContainerSpec in all the replicas of its WorkloadController;
+// either all of them are resized, or none of them.
+func (h *ClusterHandler) ResizeContainerSpec(specId string, cpu, memory float64) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	containers, err := h.getSpecContainers(specId)
+	if err != nil {
+		err := fmt.Errorf("ResizeContainerSpec failed. %v", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	// the quotas are checked against the sum of the changes of all the containers
+	required := make(map[string][]float64)
+	for _, container := range containers {
+		pod := h.pods[container.ProviderID]
+		if _, exist := h.cluster.Namespaces[pod.Namespace]; !exist {
+			continue
+		}
+		if required[pod.Namespace] == nil {
+			required[pod.Namespace] = make([]float64, len(quotaTypes))
+		}
+		for i, v := range container.getResizeQuotaUsage(cpu, memory) {
+			required[pod.Namespace][i] += v
+		}
+	}
+	for nsId, usage := range required {
+		h.cluster.setNamespaceUsage(nsId)
+		if err := h.cluster.Namespaces[nsId].admit(specId, usage); err != nil {
+			err := fmt.Errorf("ResizeContainerSpec failed. %w", err)
+			glog.Error(err.Error())
+			return err
+		}
+	}
+
+	for _, container := range containers {
+		container.SetCapacity(cpu, memory)
+		h.dirty.mark(container.UUID)
+	}
+
+	glog.V(2).Infof("Successed: resize %d containers of containerSpec[%s]", len(containers), specId)
+	return nil
+}
+
+// getSpecContainers returns the containers of a ContainerSpec in the replicas of its WorkloadController
+func (h *ClusterHandler) getSpecContainers(specId string) ([]*Container, error) {
+	controllerId, name, err := ParseContainerSpecId(specId)
+	if err != nil {
+		return nil, err
+	}
+	if _, exist := h.cluster.Controllers[controllerId]; !exist {
+		return nil, fmt.Errorf("WorkloadController[%s] is not found", controllerId)
+	}
+
+	var result []*Container
+	for _, pod := range h.getReplicas(controllerId) {
+		for _, container := range pod.Containers {
+			if pod.getContainerName(container) == name {
+				result = append(result, container)
+			}
+		}
+	}
+	if len(result) < 1 {
+		return nil, fmt.Errorf("ContainerSpec[%s] is not found", specId)
+	}
+	return result, nil
+}
+
+// getReplicas returns the Pods of the WorkloadController, sorted by UUID
+func (h *ClusterHandler) getReplicas(controllerId string) []*Pod {
+	var result []*Pod
+	for _, pod := range h.pods {
+		if pod.Controller == controllerId {
+			result = append(result, pod)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UUID < result[j].UUID
+	})
+	return result
+}
+
 func (h *ClusterHandler) MoveVirtualMachine(vnodeId, nodeId string) error {
 	h.mux.Lock()
 	defer h.mux.Unlock()
@@ -198,6 +339,21 @@ func (h *ClusterHandler) MoveVirtualMachine(vnodeId, nodeId string) error {
 		glog.Error(err.Error())
 		return err
 	}
+
+	if err := h.checkStorage(vnode, node); err != nil {
+		err := fmt.Errorf("MoveVM failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	t := h.cluster.usageTime()
+	h.cluster.setVNodeUsage(vnode, t)
+	h.cluster.setNodeUsage(node, t)
+	if err := node.admitVM(vnode, h.overcommit); err != nil {
+		err := fmt.Errorf("MoveVM failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
 	if err := oldNode.DeleteVM(vnodeId); err != nil {
 		err := fmt.Errorf("MovePod failed. %v", err)
 		glog.Error(err.Error())
@@ -210,6 +366,7 @@ func (h *ClusterHandler) MoveVirtualMachine(vnodeId, nodeId string) error {
 		glog.Error(err.Error())
 		return err
 	}
+	h.dirty.mark(vnode.UUID, oldNode.UUID, node.UUID)
 
 	glog.V(2).Infof("Successed: move vnode[%s] from node[%s] to node[%s]", vnode.Name, oldNode.Name, node.Name)
 	glog.V(2).Infof("old Node has vnodes: %s", oldNode.GetVMNames())
@@ -217,3 +374,633 @@ func (h *ClusterHandler) MoveVirtualMachine(vnodeId, nodeId string) error {
 
 	return nil
 }
+
+// ResizeVirtualMachine changes the VCPU/VMEM capacity of a VNode; a non-positive value means no change.
+// The increased capacity should be available on the hosting Node.
+func (h *ClusterHandler) ResizeVirtualMachine(vnodeId string, cpu, memory float64) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("ResizeVM failed. VirtualMachine[%s] is not found.", vnodeId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	node, exist := h.nodes[vnode.ProviderID]
+	if !exist {
+		err := fmt.Errorf("ResizeVM failed. Cannot found hosting Node[%s] of VM[%s].", vnode.ProviderID, vnode.Name)
+		glog.Error(err.Error())
+		return err
+	}
+
+	// make sure the usage of the Node, and of the VNode on it, is up-to-date
+	t := h.cluster.usageTime()
+	h.cluster.setNodeUsage(node, t)
+
+	if err := vnode.admitResize(cpu, memory, h.overcommit); err != nil {
+		err := fmt.Errorf("ResizeVM failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if cpu > vnode.CPU.Capacity {
+		remain := node.CPU.Capacity - node.CPU.Used
+		if cpu-vnode.CPU.Capacity > remain {
+			err := fmt.Errorf("ResizeVM failed. Node[%s] has not enough CPU for VM[%s]: %.1f Vs. %.1f",
+				node.Name, vnode.Name, cpu-vnode.CPU.Capacity, remain)
+			glog.Error(err.Error())
+			return err
+		}
+	}
+
+	if memory > vnode.Memory.Capacity {
+		remain := node.Memory.Capacity - node.Memory.Used
+		if memory-vnode.Memory.Capacity > remain {
+			err := fmt.Errorf("ResizeVM failed. Node[%s] has not enough Memory for VM[%s]: %.1f Vs. %.1f",
+				node.Name, vnode.Name, memory-vnode.Memory.Capacity, remain)
+			glog.Error(err.Error())
+			return err
+		}
+	}
+
+	vnode.SetCapacity(cpu, memory)
+	h.dirty.mark(vnode.UUID)
+
+	// propagate the new capacity to the Pods
+	h.cluster.setVNodeUsage(vnode, t)
+
+	glog.V(2).Infof("Successed: resize vnode[%s] to cpu=%.1f, mem=%.1f", vnode.Name, vnode.CPU.Capacity, vnode.Memory.Capacity)
+	return nil
+}
+
+// checkStorage checks that the Storage of the disk of the VNode is attached to the Node.
+// The error is a *PlacementError.
+func (h *ClusterHandler) checkStorage(vnode *VNode, node *Node) error {
+	if vnode.Storage == "" {
+		return nil
+	}
+	storage, exist := h.cluster.Storages[vnode.Storage]
+	if !exist || !storage.hasNode(node.UUID) {
+		return &PlacementError{
+			Entity: vnode.Name,
+			Host:   node.Name,
+			Reason: fmt.Sprintf("storage[%s] of the disk is not attached", vnode.Storage),
+		}
+	}
+	return nil
+}
+
+// MoveVirtualMachineStorage moves the disk of a VNode to another Storage, which should be attached to the
+// Node of the VNode, and have room for the disk.
+func (h *ClusterHandler) MoveVirtualMachineStorage(vnodeId, storageId string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("MoveVMStorage failed. VirtualMachine[%s] is not found", vnodeId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if vnode.Storage == storageId {
+		msg := fmt.Sprintf("MoveVMStorage aborted. VM[%s][%s] is already on storage[%s].", vnode.Name, vnodeId, storageId)
+		glog.Warning(msg)
+		return nil
+	}
+
+	storage, exist := h.cluster.Storages[storageId]
+	if !exist {
+		err := fmt.Errorf("MoveVMStorage failed. Storage[%s] is not found", storageId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if !storage.hasNode(vnode.ProviderID) {
+		err := fmt.Errorf("MoveVMStorage failed. %w", &PlacementError{
+			Entity: vnode.Name,
+			Host:   storage.Name,
+			Reason: fmt.Sprintf("node[%s] of the VM is not attached", vnode.ProviderID),
+		})
+		glog.Error(err.Error())
+		return err
+	}
+
+	h.cluster.setStorageUsageOf(storage)
+	if err := storage.admitDisk(vnode, vnode.Disk.Capacity); err != nil {
+		err := fmt.Errorf("MoveVMStorage failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	oldStorage := vnode.Storage
+	vnode.Storage = storage.UUID
+	h.cluster.setStorageUsageOf(storage)
+	if old, exist := h.cluster.Storages[oldStorage]; exist {
+		h.cluster.setStorageUsageOf(old)
+	}
+	h.dirty.mark(vnode.UUID, oldStorage, storage.UUID)
+
+	glog.V(2).Infof("Successed: move disk of vnode[%s] from storage[%s] to storage[%s]", vnode.Name, oldStorage, storage.Name)
+	return nil
+}
+
+// ResizeVirtualMachineDisk changes the VSTORAGE capacity of a VNode; the disk should hold the volumes of
+// its Pods, and the increased capacity should be available on its Storage.
+func (h *ClusterHandler) ResizeVirtualMachineDisk(vnodeId string, size float64) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("ResizeVMDisk failed. VirtualMachine[%s] is not found.", vnodeId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	h.cluster.setVNodeUsage(vnode, h.cluster.usageTime())
+	if size < vnode.Disk.Used {
+		err := fmt.Errorf("ResizeVMDisk failed. The volumes on VM[%s] need more disk: %.1f Vs. %.1f",
+			vnode.Name, vnode.Disk.Used, size)
+		glog.Error(err.Error())
+		return err
+	}
+
+	storage, exist := h.cluster.Storages[vnode.Storage]
+	if exist && size > vnode.Disk.Capacity {
+		h.cluster.setStorageUsageOf(storage)
+		if err := storage.admitDisk(vnode, size-vnode.Disk.Capacity); err != nil {
+			err := fmt.Errorf("ResizeVMDisk failed. %w", err)
+			glog.Error(err.Error())
+			return err
+		}
+	}
+
+	vnode.Disk.Capacity = size
+	if exist {
+		h.cluster.setStorageUsageOf(storage)
+	}
+	h.dirty.mark(vnode.UUID, vnode.Storage)
+
+	glog.V(2).Infof("Successed: resize disk of vnode[%s] to %.1f MB", vnode.Name, vnode.Disk.Capacity)
+	return nil
+}
+
+// ProvisionPod clones a Pod onto the given VNode, and adds the new Pod to the VirtualApp of the original Pod.
+func (h *ClusterHandler) ProvisionPod(podId, vnodeId string) (*Pod, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	pod, exist := h.pods[podId]
+	if !exist {
+		err := fmt.Errorf("ProvisionPod failed. Pod[%s] is not found", podId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	if vnodeId == "" {
+		vnodeId = pod.ProviderID
+	}
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("ProvisionPod failed. VNode[%s] is not found", vnodeId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	newPod, err := h.provisionPod(pod, vnode)
+	if err != nil {
+		err := fmt.Errorf("ProvisionPod failed. %w", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	return newPod, nil
+}
+
+// provisionPod clones the Pod onto the VNode, if the clone meets its placement constraints and the quotas.
+func (h *ClusterHandler) provisionPod(pod *Pod, vnode *VNode) (*Pod, error) {
+	newId := h.generateId(pod.UUID, func(id string) bool {
+		_, exist := h.pods[id]
+		return exist
+	})
+	newPod := pod.Clone(newId, newId)
+	if err := vnode.CheckPlacement(newPod); err != nil {
+		return nil, err
+	}
+	if ns, exist := h.cluster.Namespaces[newPod.Namespace]; exist {
+		h.cluster.setNamespaceUsage(newPod.Namespace)
+		if err := ns.admit(newPod.Name, newPod.getQuotaUsage()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := vnode.AddPod(newPod); err != nil {
+		return nil, err
+	}
+
+	if service := h.cluster.FindService(pod.UUID); service != nil {
+		service.AddPod(newPod)
+	}
+
+	h.pods[newPod.UUID] = newPod
+	for _, container := range newPod.Containers {
+		h.containers[container.UUID] = container
+	}
+	h.markPod(newPod)
+
+	glog.V(2).Infof("Successed: provision pod[%s] from pod[%s] on vnode[%s]", newPod.Name, pod.Name, vnode.Name)
+	glog.V(2).Infof("vnode pods: %s", vnode.GetPodNames())
+	return newPod, nil
+}
+
+// ProvisionReplica adds a replica to the WorkloadController by cloning its first Pod onto the VNode with
+// the most free CPU, among the ones meeting the placement constraints of the Pod.
+func (h *ClusterHandler) ProvisionReplica(controllerId string) (*Pod, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	if _, exist := h.cluster.Controllers[controllerId]; !exist {
+		err := fmt.Errorf("ProvisionReplica failed. WorkloadController[%s] is not found", controllerId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	replicas := h.getReplicas(controllerId)
+	if len(replicas) < 1 {
+		err := fmt.Errorf("ProvisionReplica failed. WorkloadController[%s] has no replica to clone", controllerId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	t := h.cluster.usageTime()
+	var hosts []*VNode
+	for _, host := range h.vnodes {
+		h.cluster.setVNodeUsage(host, t)
+		hosts = append(hosts, host)
+	}
+	sortByFreeCPU(hosts)
+
+	var err error
+	for _, host := range hosts {
+		var newPod *Pod
+		newPod, err = h.provisionPod(replicas[0], host)
+		if err == nil {
+			return newPod, nil
+		}
+		// only the placement depends on the VNode
+		var placement *PlacementError
+		if !errors.As(err, &placement) {
+			break
+		}
+	}
+
+	err = fmt.Errorf("ProvisionReplica failed. %w", err)
+	glog.Error(err.Error())
+	return nil, err
+}
+
+// ProvisionVirtualMachine clones an empty VNode onto the given Node.
+func (h *ClusterHandler) ProvisionVirtualMachine(vnodeId, nodeId string) (*VNode, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("ProvisionVM failed. VirtualMachine[%s] is not found", vnodeId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	if nodeId == "" {
+		nodeId = vnode.ProviderID
+	}
+	node, exist := h.nodes[nodeId]
+	if !exist {
+		err := fmt.Errorf("ProvisionVM failed. Node[%s] is not found", nodeId)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	newId := h.generateId(vnode.UUID, func(id string) bool {
+		_, exist := h.vnodes[id]
+		return exist
+	})
+	newVNode := vnode.Clone(newId, newId, h.generateVNodeIP(vnode.IP))
+
+	// the disk of the new VNode is on the same Storage
+	if err := h.checkStorage(newVNode, node); err != nil {
+		err := fmt.Errorf("ProvisionVM failed. %w", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	if storage, exist := h.cluster.Storages[newVNode.Storage]; exist {
+		h.cluster.setStorageUsageOf(storage)
+		if err := storage.admitDisk(newVNode, newVNode.Disk.Capacity); err != nil {
+			err := fmt.Errorf("ProvisionVM failed. %w", err)
+			glog.Error(err.Error())
+			return nil, err
+		}
+	}
+
+	if err := node.AddVM(newVNode); err != nil {
+		err := fmt.Errorf("ProvisionVM failed. %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	h.vnodes[newVNode.UUID] = newVNode
+	h.dirty.mark(newVNode.UUID, node.UUID, newVNode.Storage)
+
+	glog.V(2).Infof("Successed: provision vnode[%s] from vnode[%s] on node[%s]", newVNode.Name, vnode.Name, node.Name)
+	glog.V(2).Infof("node vnodes: %s", node.GetVMNames())
+	return newVNode, nil
+}
+
+// SuspendPod removes a Pod from its VNode and its VirtualApp.
+func (h *ClusterHandler) SuspendPod(podId string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	pod, exist := h.pods[podId]
+	if !exist {
+		err := fmt.Errorf("SuspendPod failed. Pod[%s] is not found", podId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if err := h.suspendPod(pod); err != nil {
+		err := fmt.Errorf("SuspendPod failed. %v", err)
+		glog.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (h *ClusterHandler) suspendPod(pod *Pod) error {
+	vnode, exist := h.vnodes[pod.ProviderID]
+	if !exist {
+		return fmt.Errorf("Cannot found VNode[%s] of Pod[%s].", pod.ProviderID, pod.Name)
+	}
+
+	h.markPod(pod)
+	if err := vnode.DeletePod(pod.UUID); err != nil {
+		return err
+	}
+
+	if service := h.cluster.FindService(pod.UUID); service != nil {
+		service.DeletePod(pod.UUID)
+	}
+
+	delete(h.pods, pod.UUID)
+	for _, container := range pod.Containers {
+		delete(h.containers, container.UUID)
+	}
+
+	glog.V(2).Infof("Successed: suspend pod[%s] on vnode[%s]", pod.Name, vnode.Name)
+	glog.V(2).Infof("vnode pods: %s", vnode.GetPodNames())
+	return nil
+}
+
+// SuspendReplica removes the last replica, by UUID, of the WorkloadController; the last replica is kept.
+func (h *ClusterHandler) SuspendReplica(controllerId string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	if _, exist := h.cluster.Controllers[controllerId]; !exist {
+		err := fmt.Errorf("SuspendReplica failed. WorkloadController[%s] is not found", controllerId)
+		glog.Error(err.Error())
+		return err
+	}
+	replicas := h.getReplicas(controllerId)
+	if len(replicas) < 2 {
+		err := fmt.Errorf("SuspendReplica failed. WorkloadController[%s] has only %d replica", controllerId, len(replicas))
+		glog.Error(err.Error())
+		return err
+	}
+
+	if err := h.suspendPod(replicas[len(replicas)-1]); err != nil {
+		err := fmt.Errorf("SuspendReplica failed. %v", err)
+		glog.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// SuspendVirtualMachine removes a VNode from its Node; Pods on the VNode are evicted to other VNodes first.
+func (h *ClusterHandler) SuspendVirtualMachine(vnodeId string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.Ready {
+		err := fmt.Errorf("ClusterHandler is not ready.")
+		glog.Error(err.Error())
+		return err
+	}
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		err := fmt.Errorf("SuspendVM failed. VirtualMachine[%s] is not found", vnodeId)
+		glog.Error(err.Error())
+		return err
+	}
+
+	node, exist := h.nodes[vnode.ProviderID]
+	if !exist {
+		err := fmt.Errorf("SuspendVM failed. Cannot found Node[%s] of VM[%s].", vnode.ProviderID, vnode.Name)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if err := h.evictPods(vnode); err != nil {
+		err := fmt.Errorf("SuspendVM failed. %w", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	if err := node.DeleteVM(vnodeId); err != nil {
+		err := fmt.Errorf("SuspendVM failed. %v", err)
+		glog.Error(err.Error())
+		return err
+	}
+	delete(h.vnodes, vnodeId)
+	h.dirty.mark(vnodeId, node.UUID, vnode.Storage)
+
+	glog.V(2).Infof("Successed: suspend vnode[%s] on node[%s]", vnode.Name, node.Name)
+	glog.V(2).Infof("node vnodes: %s", node.GetVMNames())
+	return nil
+}
+
+// move all the Pods of the VNode to the other VNodes, the one with most free CPU first,
+// among the ones meeting the placement constraints of the Pod.
+// Either all the Pods are evicted, or none: the evicted ones are moved back if any Pod cannot be placed.
+func (h *ClusterHandler) evictPods(vnode *VNode) error {
+	if len(vnode.Pods) < 1 {
+		return nil
+	}
+
+	t := h.cluster.usageTime()
+	h.cluster.setVNodeUsage(vnode, t)
+	var hosts []*VNode
+	for _, host := range h.vnodes {
+		if host.UUID != vnode.UUID {
+			h.cluster.setVNodeUsage(host, t)
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) < 1 {
+		return fmt.Errorf("no other VNode to host the %d Pods of VNode[%s]", len(vnode.Pods), vnode.Name)
+	}
+
+	podIds := make([]string, 0, len(vnode.Pods))
+	for podId := range vnode.Pods {
+		podIds = append(podIds, podId)
+	}
+	sort.Strings(podIds)
+
+	var evicted []*Pod
+	for _, podId := range podIds {
+		pod := vnode.Pods[podId]
+		host, err := h.selectEvictionHost(pod, hosts)
+		if err == nil {
+			if err = vnode.DeletePod(podId); err == nil {
+				err = host.AddPod(pod)
+			}
+		}
+		if err != nil {
+			h.returnPods(vnode, evicted)
+			return fmt.Errorf("no other VNode can host Pod[%s] of VNode[%s]: %w", pod.Name, vnode.Name, err)
+		}
+
+		evicted = append(evicted, pod)
+		h.cluster.setVNodeUsage(host, t)
+		h.dirty.mark(pod.UUID, vnode.UUID, host.UUID)
+		glog.V(2).Infof("evict pod[%s] from vnode[%s] to vnode[%s]", pod.Name, vnode.Name, host.Name)
+	}
+
+	return nil
+}
+
+// selectEvictionHost returns the host with most free CPU that meets the placement constraints of the Pod, and
+// passes the admission checks of MovePod. The usage of the hosts should be up-to-date.
+func (h *ClusterHandler) selectEvictionHost(pod *Pod, hosts []*VNode) (*VNode, error) {
+	sortByFreeCPU(hosts)
+
+	var admitErr error
+	for _, host := range hosts {
+		if host.CheckPlacement(pod) != nil {
+			continue
+		}
+		if err := host.admitPod(pod, h.overcommit); err != nil {
+			admitErr = err
+			continue
+		}
+		return host, nil
+	}
+
+	if admitErr != nil {
+		return nil, admitErr
+	}
+	return nil, fmt.Errorf("not placeable by its placement constraints")
+}
+
+// returnPods moves the evicted Pods back to the VNode.
+func (h *ClusterHandler) returnPods(vnode *VNode, pods []*Pod) {
+	t := h.cluster.usageTime()
+	for _, pod := range pods {
+		if host, exist := h.vnodes[pod.ProviderID]; exist {
+			host.DeletePod(pod.UUID)
+			h.cluster.setVNodeUsage(host, t)
+		}
+		vnode.AddPod(pod)
+	}
+	h.cluster.setVNodeUsage(vnode, t)
+}
+
+// sortByFreeCPU sorts the VNodes by their free CPU, the most first; the usage should be up-to-date.
+func sortByFreeCPU(hosts []*VNode) {
+	free := func(v *VNode) float64 {
+		return v.CPU.Capacity - v.CPU.Used
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if free(hosts[i]) != free(hosts[j]) {
+			return free(hosts[i]) > free(hosts[j])
+		}
+		return hosts[i].UUID < hosts[j].UUID
+	})
+}
+
+// generate an unused Id based on the Id of the original entity
+func (h *ClusterHandler) generateId(base string, exist func(string) bool) string {
+	for i := 1; ; i++ {
+		id := fmt.Sprintf("%s-c%d", base, i)
+		if !exist(id) {
+			return id
+		}
+	}
+}
+
+// generate an unused IP next to the IP of the original VNode
+func (h *ClusterHandler) generateVNodeIP(base string) string {
+	ip := net.ParseIP(base).To4()
+	if ip == nil {
+		glog.Warningf("cannot generate a new IP from [%s]", base)
+		return base
+	}
+
+	used := make(map[string]bool)
+	for _, vnode := range h.vnodes {
+		used[vnode.IP] = true
+	}
+
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	for i := 0; i < 256; i++ {
+		result[3]++
+		if !used[result.String()] {
+			return result.String()
+		}
+	}
+
+	glog.Warningf("cannot generate a new IP from [%s]", base)
+	return base
+}
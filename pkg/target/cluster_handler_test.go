@@ -0,0 +1,456 @@
+package target
+
+import (
+	"errors"
+	"testing"
+)
+
+// node-1 hosts vnode-1 (with pod-1, pod-2) and vnode-2 (with pod-3)
+// node-2 is empty
+func newTestCluster() *Cluster {
+	cluster := NewCluster("testCluster", "cluster-1")
+
+	newContainer := func(name string, cpu, mem float64) *Container {
+		c := NewContainer(name, name)
+		c.CPU = Resource{Capacity: cpu, Used: 100}
+		c.Memory = Resource{Capacity: mem, Used: 100 * 1024}
+		c.ReqCPU = 50
+		c.ReqMemory = 50 * 1024
+		return c
+	}
+
+	newPod := func(name string, containers ...*Container) *Pod {
+		pod := NewPod(name, name)
+		pod.Containers = containers
+		return pod
+	}
+
+	newVNode := func(name string, pods ...*Pod) *VNode {
+		vnode := NewVNode(name, name)
+		vnode.CPU.Capacity = 2000
+		vnode.Memory.Capacity = 2048 * 1024
+		vnode.ClusterId = cluster.UUID
+		vnode.Pods = make(map[string]*Pod)
+		for _, pod := range pods {
+			vnode.Pods[pod.UUID] = pod
+		}
+		return vnode
+	}
+
+	newNode := func(name string, vnodes ...*VNode) *Node {
+		node := NewNode(name, name)
+		node.CPU.Capacity = 5000
+		node.Memory.Capacity = 8192 * 1024
+		node.ClusterId = cluster.UUID
+		node.VMs = make(map[string]*VNode)
+		for _, vnode := range vnodes {
+			node.VMs[vnode.UUID] = vnode
+		}
+		return node
+	}
+
+	pod1 := newPod("pod-1", newContainer("container-1", 500, 512*1024))
+	pod2 := newPod("pod-2", newContainer("container-2", 0, 0))
+	pod3 := newPod("pod-3", newContainer("container-3", 500, 512*1024))
+
+	vnode1 := newVNode("vnode-1", pod1, pod2)
+	vnode2 := newVNode("vnode-2", pod3)
+
+	node1 := newNode("node-1", vnode1, vnode2)
+	node2 := newNode("node-2")
+
+	cluster.Nodes = map[string]*Node{
+		node1.UUID: node1,
+		node2.UUID: node2,
+	}
+
+	service := NewVirtualApp("service-1", "service-1")
+	service.Pods = []*Pod{pod1, pod2, pod3}
+	cluster.Services = []*VirtualApp{service}
+
+	cluster.CompleteBuild()
+	return cluster
+}
+
+func TestClusterHandler_ResizeVirtualMachine(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	if err := h.ResizeVirtualMachine("vnode-1", 3000, -1); err != nil {
+		t.Fatalf("resize vnode-1 failed: %v", err)
+	}
+
+	vnode := h.vnodes["vnode-1"]
+	if vnode.CPU.Capacity != 3000 {
+		t.Errorf("vnode-1 CPU capacity is %.1f, expected 3000", vnode.CPU.Capacity)
+	}
+	if vnode.Memory.Capacity != 2048*1024 {
+		t.Errorf("vnode-1 Memory capacity should not change: %.1f", vnode.Memory.Capacity)
+	}
+
+	// new capacity is propagated to pods, and to containers without limit
+	pod := h.pods["pod-2"]
+	if pod.CPU.Capacity != 3000 {
+		t.Errorf("pod-2 CPU capacity is %.1f, expected 3000", pod.CPU.Capacity)
+	}
+	if c := pod.Containers[0]; c.CPU.Capacity != 3000 {
+		t.Errorf("container-2 CPU capacity is %.1f, expected 3000", c.CPU.Capacity)
+	}
+	if c := h.pods["pod-1"].Containers[0]; c.CPU.Capacity != 500 {
+		t.Errorf("container-1 CPU limit should not change: %.1f", c.CPU.Capacity)
+	}
+
+	// node-1 has 5000 CPU, and less than 4000 remaining
+	if err := h.ResizeVirtualMachine("vnode-1", 8000, -1); err == nil {
+		t.Errorf("resize vnode-1 beyond node-1 capacity should fail")
+	}
+	if vnode.CPU.Capacity != 3000 {
+		t.Errorf("vnode-1 CPU capacity changed by a failed resize: %.1f", vnode.CPU.Capacity)
+	}
+
+	if err := h.ResizeVirtualMachine("vnode-x", 3000, -1); err == nil {
+		t.Errorf("resize a non-existing vnode should fail")
+	}
+
+	// pods on vnode-1 use 200 CPU, plus 50 overhead
+	var reason *AdmissionError
+	if err := h.ResizeVirtualMachine("vnode-1", 200, -1); !errors.As(err, &reason) || reason.Resource != ResourceCPU {
+		t.Errorf("shrink vnode-1 below the usage of its pods should fail: %v", err)
+	}
+	// pods on vnode-1 request 100 MB memory, plus 50 MB overhead; usage is 250 MB
+	if err := h.ResizeVirtualMachine("vnode-1", -1, 240*1024); !errors.As(err, &reason) || reason.Resource != ResourceMemory {
+		t.Errorf("shrink vnode-1 below the memory usage of its pods should fail: %v", err)
+	}
+	if vnode.CPU.Capacity != 3000 || vnode.Memory.Capacity != 2048*1024 {
+		t.Errorf("vnode-1 capacity changed by a failed shrink: %.1f, %.1f", vnode.CPU.Capacity, vnode.Memory.Capacity)
+	}
+
+	if err := h.ResizeVirtualMachine("vnode-1", 1000, 1024*1024); err != nil {
+		t.Errorf("shrink vnode-1 failed: %v", err)
+	}
+}
+
+func TestClusterHandler_ProvisionPod(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	pod, err := h.ProvisionPod("pod-1", "vnode-2")
+	if err != nil {
+		t.Fatalf("provision pod-1 failed: %v", err)
+	}
+
+	if pod.UUID == "pod-1" || pod.ProviderID != "vnode-2" {
+		t.Errorf("wrong new pod: %s on %s", pod.UUID, pod.ProviderID)
+	}
+	if _, exist := h.vnodes["vnode-2"].Pods[pod.UUID]; !exist {
+		t.Errorf("new pod[%s] is not on vnode-2", pod.UUID)
+	}
+	if _, exist := h.pods[pod.UUID]; !exist {
+		t.Errorf("new pod[%s] is not indexed", pod.UUID)
+	}
+
+	if len(pod.Containers) != 1 {
+		t.Fatalf("new pod should have 1 container, got %d", len(pod.Containers))
+	}
+	container := pod.Containers[0]
+	if container.UUID == "container-1" || container.App == nil || container.App.UUID == "app-container-1" {
+		t.Errorf("container and app should have new UUIDs: %s", container.UUID)
+	}
+	if _, exist := h.containers[container.UUID]; !exist {
+		t.Errorf("new container[%s] is not indexed", container.UUID)
+	}
+
+	if !h.cluster.Services[0].HasPod(pod.UUID) {
+		t.Errorf("new pod[%s] is not added to service-1", pod.UUID)
+	}
+
+	// a second clone gets a different Id, and stays on the original vnode
+	pod2, err := h.ProvisionPod("pod-1", "")
+	if err != nil {
+		t.Fatalf("provision pod-1 again failed: %v", err)
+	}
+	if pod2.UUID == pod.UUID || pod2.ProviderID != "vnode-1" {
+		t.Errorf("wrong second pod: %s on %s", pod2.UUID, pod2.ProviderID)
+	}
+}
+
+func TestClusterHandler_ProvisionVirtualMachine(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.vnodes["vnode-1"].IP = "10.0.0.1"
+	h.vnodes["vnode-2"].IP = "10.0.0.2"
+
+	vnode, err := h.ProvisionVirtualMachine("vnode-1", "node-2")
+	if err != nil {
+		t.Fatalf("provision vnode-1 failed: %v", err)
+	}
+
+	if vnode.ProviderID != "node-2" || len(vnode.Pods) != 0 {
+		t.Errorf("wrong new vnode: %s on %s with %d pods", vnode.UUID, vnode.ProviderID, len(vnode.Pods))
+	}
+	if vnode.CPU.Capacity != 2000 {
+		t.Errorf("new vnode CPU capacity is %.1f, expected 2000", vnode.CPU.Capacity)
+	}
+	if vnode.IP != "10.0.0.3" {
+		t.Errorf("new vnode IP is %s, expected 10.0.0.3", vnode.IP)
+	}
+	if _, exist := h.vnodes[vnode.UUID]; !exist {
+		t.Errorf("new vnode[%s] is not indexed", vnode.UUID)
+	}
+
+	// the new vnode can host pods
+	if err := h.MovePod("pod-3", vnode.UUID); err != nil {
+		t.Errorf("move pod-3 to the new vnode failed: %v", err)
+	}
+}
+
+func TestClusterHandler_SuspendPod(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	if err := h.SuspendPod("pod-1"); err != nil {
+		t.Fatalf("suspend pod-1 failed: %v", err)
+	}
+
+	if _, exist := h.vnodes["vnode-1"].Pods["pod-1"]; exist {
+		t.Errorf("pod-1 is still on vnode-1")
+	}
+	if _, exist := h.pods["pod-1"]; exist {
+		t.Errorf("pod-1 is still indexed")
+	}
+	if _, exist := h.containers["container-1"]; exist {
+		t.Errorf("container-1 is still indexed")
+	}
+	if h.cluster.Services[0].HasPod("pod-1") {
+		t.Errorf("pod-1 is still in service-1")
+	}
+
+	if err := h.SuspendPod("pod-1"); err == nil {
+		t.Errorf("suspend pod-1 twice should fail")
+	}
+}
+
+func TestClusterHandler_SuspendVirtualMachine(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	if err := h.SuspendVirtualMachine("vnode-1"); err != nil {
+		t.Fatalf("suspend vnode-1 failed: %v", err)
+	}
+
+	if _, exist := h.nodes["node-1"].VMs["vnode-1"]; exist {
+		t.Errorf("vnode-1 is still on node-1")
+	}
+	if _, exist := h.vnodes["vnode-1"]; exist {
+		t.Errorf("vnode-1 is still indexed")
+	}
+
+	// pods are evicted to vnode-2
+	vnode2 := h.vnodes["vnode-2"]
+	for _, podId := range []string{"pod-1", "pod-2", "pod-3"} {
+		if pod, exist := vnode2.Pods[podId]; !exist || pod.ProviderID != "vnode-2" {
+			t.Errorf("%s is not evicted to vnode-2", podId)
+		}
+	}
+
+	// the last vnode cannot evict its pods
+	if err := h.SuspendVirtualMachine("vnode-2"); err == nil {
+		t.Errorf("suspend the last vnode with pods should fail")
+	}
+}
+
+func TestClusterHandler_MovePodAdmission(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.vnodes["vnode-2"].CPU.Capacity = 200
+
+	// vnode-2 free CPU: 200 - 100(pod-3) - 50(overhead) = 50 < 100
+	err := h.MovePod("pod-1", "vnode-2")
+	if err == nil {
+		t.Fatalf("move pod-1 to a small vnode should fail")
+	}
+
+	var reason *AdmissionError
+	if !errors.As(err, &reason) {
+		t.Fatalf("move failure should carry an AdmissionError: %v", err)
+	}
+	if reason.Resource != ResourceCPU || reason.Host != "vnode-2" || reason.Required != 100 || reason.Available != 50 {
+		t.Errorf("wrong admission reason: %+v", reason)
+	}
+	if h.pods["pod-1"].ProviderID != "vnode-1" {
+		t.Errorf("pod-1 should stay on vnode-1")
+	}
+
+	h.SetOvercommitRatio(2.0, -1)
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Errorf("move pod-1 with overcommit failed: %v", err)
+	}
+
+	// only the usage of the Pod and of the destination, before the move, is updated; not of the whole cluster
+	for _, id := range []string{"node-1", "node-2"} {
+		h.nodes[id].CPU.Used = -1
+	}
+	h.vnodes["vnode-2"].CPU.Used = -1
+	if err := h.MovePod("pod-1", "vnode-1"); err != nil {
+		t.Fatalf("move pod-1 back failed: %v", err)
+	}
+	if h.vnodes["vnode-1"].CPU.Used != 150 || h.vnodes["vnode-2"].CPU.Used != -1 || h.nodes["node-1"].CPU.Used != -1 {
+		t.Errorf("only the usage of vnode-1 should be updated: vnode-1=%.1f, vnode-2=%.1f, node-1=%.1f",
+			h.vnodes["vnode-1"].CPU.Used, h.vnodes["vnode-2"].CPU.Used, h.nodes["node-1"].CPU.Used)
+	}
+}
+
+func TestClusterHandler_EvictPodsAdmission(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.vnodes["vnode-2"].CPU.Capacity = 200
+
+	// vnode-2 free CPU: 200 - 100(pod-3) - 50(overhead) = 50 < 100(pod-1)
+	err := h.SuspendVirtualMachine("vnode-1")
+	var reason *AdmissionError
+	if !errors.As(err, &reason) || reason.Resource != ResourceCPU || reason.Host != "vnode-2" {
+		t.Fatalf("evict pod-1 to a small vnode should fail on CPU: %v", err)
+	}
+	if h.pods["pod-1"].ProviderID != "vnode-1" {
+		t.Errorf("pod-1 should stay on vnode-1")
+	}
+
+	h.SetOvercommitRatio(2.0, -1)
+	if err := h.SuspendVirtualMachine("vnode-1"); err != nil {
+		t.Fatalf("suspend vnode-1 with overcommit failed: %v", err)
+	}
+	// usage of vnode-2 is refreshed by the evicted pods
+	if used := h.vnodes["vnode-2"].CPU.Used; used != 350 {
+		t.Errorf("vnode-2 CPU used is %.1f, expected 350", used)
+	}
+}
+
+func TestClusterHandler_EvictPodsRollback(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	// pod-1 is evicted first, then pod-2 cannot be placed on any other vnode
+	h.pods["pod-2"].NodeSelector = map[string]string{"zone": "x"}
+
+	if err := h.SuspendVirtualMachine("vnode-1"); err == nil {
+		t.Fatalf("suspend vnode-1 should fail without a vnode for pod-2")
+	}
+
+	for _, podId := range []string{"pod-1", "pod-2"} {
+		if h.pods[podId].ProviderID != "vnode-1" || h.vnodes["vnode-1"].Pods[podId] == nil {
+			t.Errorf("%s should be moved back to vnode-1", podId)
+		}
+		if _, exist := h.vnodes["vnode-2"].Pods[podId]; exist {
+			t.Errorf("%s should not stay on vnode-2", podId)
+		}
+	}
+	if used := h.vnodes["vnode-2"].CPU.Used; used != 150 {
+		t.Errorf("vnode-2 CPU used is %.1f, expected 150", used)
+	}
+}
+
+func TestClusterHandler_MoveVirtualMachineAdmission(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.nodes["node-2"].Memory.Capacity = 200 * 1024
+
+	err := h.MoveVirtualMachine("vnode-2", "node-2")
+	var reason *AdmissionError
+	if !errors.As(err, &reason) || reason.Resource != ResourceMemory {
+		t.Fatalf("move vnode-2 to a small node should fail on Memory: %v", err)
+	}
+
+	h.nodes["node-2"].Memory.Capacity = 8192 * 1024
+	if err := h.MoveVirtualMachine("vnode-2", "node-2"); err != nil {
+		t.Errorf("move vnode-2 to node-2 failed: %v", err)
+	}
+}
+
+func TestClusterHandler_Undo(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	before := h.SnapshotEntities(append(h.GetAffectedEntities("pod-1"), "vnode-2"))
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod-1 failed: %v", err)
+	}
+	h.RecordHistory(before)
+
+	before = h.SnapshotEntities(h.GetAffectedEntities("vnode-1"))
+	if err := h.ResizeVirtualMachine("vnode-1", 3000, -1); err != nil {
+		t.Fatalf("resize vnode-1 failed: %v", err)
+	}
+	h.RecordHistory(before)
+
+	states := h.GetEntityStates([]string{"pod-1", "vnode-1"})
+	if len(states) != 2 || states[0].Provider != "vnode-2" || states[1].CPU.Capacity != 3000 {
+		t.Fatalf("wrong entity states after actions: %+v, %+v", states[0], states[1])
+	}
+
+	if err := h.Undo(3); err == nil {
+		t.Errorf("undo more actions than history should fail")
+	}
+
+	// only the last action is undone
+	if err := h.Undo(1); err != nil {
+		t.Fatalf("undo 1 action failed: %v", err)
+	}
+	states = h.GetEntityStates([]string{"pod-1", "vnode-1"})
+	if states[0].Provider != "vnode-2" || states[1].CPU.Capacity != 2000 {
+		t.Errorf("wrong entity states after undo 1 action: %+v, %+v", states[0], states[1])
+	}
+
+	if err := h.Undo(1); err != nil {
+		t.Fatalf("undo 1 more action failed: %v", err)
+	}
+	states = h.GetEntityStates([]string{"pod-1", "vnode-1"})
+	if states[0].Provider != "vnode-1" || states[1].CPU.Capacity != 2000 {
+		t.Errorf("wrong entity states after undo: %+v, %+v", states[0], states[1])
+	}
+	if _, exist := h.vnodes["vnode-2"].Pods["pod-1"]; exist {
+		t.Errorf("pod-1 should be removed from vnode-2 by undo")
+	}
+	if err := h.Undo(1); err == nil {
+		t.Errorf("undo with empty history should fail")
+	}
+}
+
+func TestClusterHandler_GetEntityStates(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.nodes["node-1"].CPU.Used = -1
+	h.vnodes["vnode-2"].CPU.Used = -1
+
+	states := h.GetEntityStates([]string{"container-1", "vnode-1", "unknown"})
+	if len(states) != 2 || states[0].CPU.Used != 100 || states[1].CPU.Used != 250 {
+		t.Fatalf("wrong states of container-1 and vnode-1: %+v", states)
+	}
+	// only the usage of the given entities is updated
+	if h.nodes["node-1"].CPU.Used != -1 || h.vnodes["vnode-2"].CPU.Used != -1 {
+		t.Errorf("usage of node-1 and vnode-2 should not be updated")
+	}
+}
+
+func TestClusterHandler_RestoreEntities(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	affected := h.GetAffectedEntities("pod-1")
+	if len(affected) != 3 || affected[0] != "pod-1" || affected[1] != "service-1" || affected[2] != "vnode-1" {
+		t.Fatalf("wrong affected entities of pod-1: %v", affected)
+	}
+
+	snapshot := h.SnapshotEntities(append(affected, "vnode-2"))
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod-1 failed: %v", err)
+	}
+	newPod, err := h.ProvisionPod("pod-1", "vnode-2")
+	if err != nil {
+		t.Fatalf("provision pod-1 failed: %v", err)
+	}
+
+	// the change on the other entities is kept
+	if err := h.ResizeContainerCapacity("container-3", 800, -1); err != nil {
+		t.Fatalf("resize container-3 failed: %v", err)
+	}
+
+	h.RestoreEntities(snapshot)
+
+	states := h.GetEntityStates([]string{"pod-1", newPod.UUID, "container-3"})
+	if len(states) != 2 || states[0].Provider != "vnode-1" {
+		t.Fatalf("pod-1 is not restored, or provisioned pod is not removed: %+v", states)
+	}
+	if states[1].CPU.Capacity != 800 {
+		t.Errorf("resize of container-3 should be kept: %+v", states[1])
+	}
+	if service := h.cluster.FindService(newPod.UUID); service != nil {
+		t.Errorf("provisioned pod should be removed from service[%s]", service.Name)
+	}
+}
@@ -0,0 +1,634 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"reflect"
+	"sort"
+)
+
+// TopologyDiff is the change between two versions of the topology, as applied to the live cluster;
+// the entities are in the format of <kind>[<id>].
+type TopologyDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d *TopologyDiff) IsEmpty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+func (d *TopologyDiff) String() string {
+	return fmt.Sprintf("added: %v; removed: %v; changed: %v", d.Added, d.Removed, d.Changed)
+}
+
+func (d *TopologyDiff) add(list *[]string, kind, id string) {
+	*list = append(*list, fmt.Sprintf("%s[%s]", kind, id))
+}
+
+func (d *TopologyDiff) sort() {
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+}
+
+// clusterIndex is the entities of a Cluster by id
+type clusterIndex struct {
+	containers  map[string]*Container
+	pods        map[string]*Pod
+	vnodes      map[string]*VNode
+	nodes       map[string]*Node
+	switches    map[string]*Switch
+	services    map[string]*VirtualApp
+	namespaces  map[string]*Namespace
+	controllers map[string]*WorkloadController
+	storages    map[string]*Storage
+}
+
+func newClusterIndex(c *Cluster) *clusterIndex {
+	index := &clusterIndex{
+		containers:  make(map[string]*Container),
+		pods:        make(map[string]*Pod),
+		vnodes:      make(map[string]*VNode),
+		nodes:       make(map[string]*Node),
+		switches:    make(map[string]*Switch),
+		services:    make(map[string]*VirtualApp),
+		namespaces:  make(map[string]*Namespace),
+		controllers: make(map[string]*WorkloadController),
+		storages:    make(map[string]*Storage),
+	}
+
+	for _, host := range c.Nodes {
+		index.nodes[host.UUID] = host
+		for _, vhost := range host.VMs {
+			index.vnodes[vhost.UUID] = vhost
+			for _, pod := range vhost.Pods {
+				index.pods[pod.UUID] = pod
+				for _, container := range pod.Containers {
+					index.containers[container.UUID] = container
+				}
+			}
+		}
+	}
+	for _, networkswitch := range c.Switches {
+		index.switches[networkswitch.UUID] = networkswitch
+	}
+	for _, service := range c.Services {
+		index.services[service.UUID] = service
+	}
+	for _, ns := range c.Namespaces {
+		index.namespaces[ns.UUID] = ns
+	}
+	for _, controller := range c.Controllers {
+		index.controllers[controller.UUID] = controller
+	}
+	for _, s := range c.Storages {
+		index.storages[s.UUID] = s
+	}
+	return index
+}
+
+// Reload applies the change of the topology, from old to updated, on the live cluster; old and updated are
+// the clusters built from the two versions of the topology file.
+// The entities added, removed or changed in the topology are added, removed or updated in the live cluster.
+// The others keep the state made by the actions: e.g., a moved Pod stays on its new VNode, unless the topology
+// relocates it or its VNode is removed; a provisioned Pod stays, unless its VNode is removed.
+func (h *ClusterHandler) Reload(old, updated *Cluster) *TopologyDiff {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	r := &reloader{
+		h:       h,
+		old:     newClusterIndex(old),
+		updated: newClusterIndex(updated),
+		diff:    &TopologyDiff{},
+	}
+	r.reloadNodes()
+	r.reloadVNodes()
+	r.reloadPods()
+	r.reloadServices(updated)
+	r.reloadNamespaces()
+	r.reloadControllers()
+	r.reloadSwitches()
+	r.reloadStorages()
+
+	h.cluster.CompleteBuild()
+	h.buildIndex()
+	h.clearHistory()
+
+	r.diff.sort()
+	glog.V(2).Infof("cluster[%s] is reloaded: %v", h.cluster.Name, r.diff)
+	return r.diff
+}
+
+type reloader struct {
+	h       *ClusterHandler
+	old     *clusterIndex
+	updated *clusterIndex
+	diff    *TopologyDiff
+}
+
+// add records the change of the entity in the diff, and marks it changed for the incremental discovery.
+func (r *reloader) add(list *[]string, kind, id string) {
+	r.diff.add(list, kind, id)
+	r.h.dirty.mark(id)
+}
+
+func (r *reloader) reloadNodes() {
+	c := r.h.cluster
+	for id := range r.old.nodes {
+		if _, exist := r.updated.nodes[id]; !exist {
+			// its VNodes are relocated or removed later
+			delete(c.Nodes, id)
+			r.add(&r.diff.Removed, KindNode, id)
+		}
+	}
+
+	for id, node := range r.updated.nodes {
+		oldNode, inOld := r.old.nodes[id]
+		live, inLive := c.Nodes[id]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindNode, id)
+		case !sameNode(oldNode, node) || !inLive:
+			r.add(&r.diff.Changed, KindNode, id)
+		default:
+			continue
+		}
+
+		if !inLive {
+			live = NewNode(node.Name, node.UUID)
+			live.ClusterId = node.ClusterId
+			live.VMs = make(map[string]*VNode)
+			c.Nodes[id] = live
+		}
+		live.CPU.Capacity = node.CPU.Capacity
+		live.Memory.Capacity = node.Memory.Capacity
+		live.NetworkThroughput.Capacity = node.NetworkThroughput.Capacity
+		live.IP = node.IP
+	}
+}
+
+func (r *reloader) reloadVNodes() {
+	c := r.h.cluster
+	vnodes := r.h.vnodes
+	for id := range r.old.vnodes {
+		if _, exist := r.updated.vnodes[id]; !exist {
+			if live, exist := vnodes[id]; exist {
+				if host, exist := c.Nodes[live.ProviderID]; exist {
+					delete(host.VMs, id)
+					r.h.dirty.mark(host.UUID)
+				}
+				r.h.dirty.mark(live.Storage)
+				delete(vnodes, id)
+			}
+			r.add(&r.diff.Removed, KindVNode, id)
+		}
+	}
+
+	for id, vnode := range r.updated.vnodes {
+		oldVNode, inOld := r.old.vnodes[id]
+		live, inLive := vnodes[id]
+		changed := !inOld || !sameVNode(oldVNode, vnode)
+		relocated := inOld && oldVNode.ProviderID != vnode.ProviderID
+		orphaned := inLive && c.Nodes[live.ProviderID] == nil
+		if !changed && !relocated && !orphaned {
+			continue
+		}
+
+		if !inOld {
+			r.add(&r.diff.Added, KindVNode, id)
+		} else {
+			r.add(&r.diff.Changed, KindVNode, id)
+		}
+
+		if !inLive {
+			live = NewVNode(vnode.Name, vnode.UUID)
+			live.ClusterId = vnode.ClusterId
+			live.Pods = make(map[string]*Pod)
+			vnodes[id] = live
+		}
+		if changed {
+			live.CPU.Capacity = vnode.CPU.Capacity
+			live.Memory.Capacity = vnode.Memory.Capacity
+			live.IP = vnode.IP
+			live.Labels = copyLabels(vnode.Labels)
+			live.Taints = copyTaints(vnode.Taints)
+			live.Disk.Capacity = vnode.Disk.Capacity
+			r.h.dirty.mark(live.Storage)
+			live.Storage = vnode.Storage
+		}
+
+		if inLive && live.ProviderID == vnode.ProviderID && !orphaned {
+			continue
+		}
+		if host, exist := c.Nodes[live.ProviderID]; exist && inLive {
+			delete(host.VMs, id)
+			r.h.dirty.mark(host.UUID)
+		}
+		c.Nodes[vnode.ProviderID].VMs[id] = live
+		live.ProviderID = vnode.ProviderID
+	}
+
+	// the VNodes not in the topology, e.g., provisioned, are removed with their Node
+	for id, live := range vnodes {
+		if _, exist := c.Nodes[live.ProviderID]; !exist {
+			delete(vnodes, id)
+			r.add(&r.diff.Removed, KindVNode, id)
+		}
+	}
+}
+
+func (r *reloader) reloadPods() {
+	pods := r.h.pods
+	vnodes := r.h.vnodes
+	for id := range r.old.pods {
+		if _, exist := r.updated.pods[id]; !exist {
+			if live, exist := pods[id]; exist {
+				if vnode, exist := vnodes[live.ProviderID]; exist {
+					delete(vnode.Pods, id)
+					r.h.dirty.mark(vnode.UUID)
+				}
+				r.h.dirty.mark(live.Namespace, live.Controller)
+				delete(pods, id)
+			}
+			r.add(&r.diff.Removed, KindPod, id)
+		}
+	}
+
+	for id, pod := range r.updated.pods {
+		oldPod, inOld := r.old.pods[id]
+		live, inLive := pods[id]
+		changed := !inOld || !samePod(oldPod, pod)
+		relocated := inOld && oldPod.ProviderID != pod.ProviderID
+		orphaned := inLive && vnodes[live.ProviderID] == nil
+		if !changed && !relocated && !orphaned {
+			continue
+		}
+
+		if !inOld {
+			r.add(&r.diff.Added, KindPod, id)
+		} else {
+			r.add(&r.diff.Changed, KindPod, id)
+		}
+
+		if !inLive {
+			live = pod.deepCopy()
+			live.ProviderID = emptyProvider
+			pods[id] = live
+		} else if changed {
+			live.Containers = nil
+			for _, container := range pod.Containers {
+				live.Containers = append(live.Containers, container.deepCopy())
+			}
+			r.h.dirty.mark(live.Namespace, live.Controller)
+			live.Namespace = pod.Namespace
+			live.Controller = pod.Controller
+			live.Volumes = copyVolumes(pod.Volumes)
+			pod.copyPlacement(live)
+		}
+
+		if inLive && live.ProviderID == pod.ProviderID && !orphaned {
+			continue
+		}
+		if vnode, exist := vnodes[live.ProviderID]; exist && inLive {
+			delete(vnode.Pods, id)
+			r.h.dirty.mark(vnode.UUID)
+		}
+		vnodes[pod.ProviderID].Pods[id] = live
+		live.ProviderID = pod.ProviderID
+	}
+
+	// the Pods not in the topology, e.g., provisioned, are removed with their VNode
+	for id, live := range pods {
+		if _, exist := vnodes[live.ProviderID]; !exist {
+			delete(pods, id)
+			r.add(&r.diff.Removed, KindPod, id)
+		}
+	}
+}
+
+// reloadServices keeps the Pods added to a service by actions, unless they are removed;
+// the Pods of the service in the topology are the ones in the updated topology.
+func (r *reloader) reloadServices(updated *Cluster) {
+	c := r.h.cluster
+	live := make(map[string]*VirtualApp)
+	services := []*VirtualApp{}
+	for _, service := range c.Services {
+		if _, exist := r.updated.services[service.UUID]; !exist {
+			if _, exist := r.old.services[service.UUID]; exist {
+				r.add(&r.diff.Removed, KindVirtualApp, service.UUID)
+				continue
+			}
+		}
+		live[service.UUID] = service
+		services = append(services, service)
+	}
+
+	for _, service := range updated.Services {
+		oldService, inOld := r.old.services[service.UUID]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindVirtualApp, service.UUID)
+		case !sameService(oldService, service):
+			r.add(&r.diff.Changed, KindVirtualApp, service.UUID)
+		}
+
+		liveService, exist := live[service.UUID]
+		if !exist {
+			liveService = NewVirtualApp(service.Name, service.UUID)
+			live[service.UUID] = liveService
+			services = append(services, liveService)
+		}
+
+		members := []*Pod{}
+		for _, pod := range liveService.Pods {
+			if inOld && oldService.HasPod(pod.UUID) && !service.HasPod(pod.UUID) {
+				continue
+			}
+			members = append(members, pod)
+		}
+		liveService.Pods = members
+		for _, pod := range service.Pods {
+			if !liveService.HasPod(pod.UUID) {
+				liveService.Pods = append(liveService.Pods, pod)
+			}
+		}
+	}
+
+	// the members are the live Pods
+	for _, service := range services {
+		members := []*Pod{}
+		for _, pod := range service.Pods {
+			if p, exist := r.h.pods[pod.UUID]; exist {
+				members = append(members, p)
+			}
+		}
+		if len(members) != len(service.Pods) {
+			r.h.dirty.mark(service.UUID)
+		}
+		service.Pods = members
+	}
+	c.Services = services
+}
+
+func (r *reloader) reloadNamespaces() {
+	c := r.h.cluster
+	for id := range r.old.namespaces {
+		if _, exist := r.updated.namespaces[id]; !exist {
+			delete(c.Namespaces, id)
+			r.add(&r.diff.Removed, KindNamespace, id)
+		}
+	}
+
+	for id, ns := range r.updated.namespaces {
+		oldNamespace, inOld := r.old.namespaces[id]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindNamespace, id)
+		case !sameNamespace(oldNamespace, ns):
+			r.add(&r.diff.Changed, KindNamespace, id)
+		default:
+			continue
+		}
+
+		live := *ns
+		if c.Namespaces == nil {
+			c.Namespaces = make(map[string]*Namespace)
+		}
+		c.Namespaces[id] = &live
+	}
+
+	// the Pods of the removed Namespaces, e.g., provisioned, are in no Namespace
+	for _, pod := range r.h.pods {
+		if _, exist := c.Namespaces[pod.Namespace]; !exist && pod.Namespace != "" {
+			pod.Namespace = ""
+			r.h.dirty.mark(pod.UUID)
+		}
+	}
+}
+
+func (r *reloader) reloadControllers() {
+	c := r.h.cluster
+	for id := range r.old.controllers {
+		if _, exist := r.updated.controllers[id]; !exist {
+			delete(c.Controllers, id)
+			r.add(&r.diff.Removed, KindController, id)
+		}
+	}
+
+	for id, controller := range r.updated.controllers {
+		oldController, inOld := r.old.controllers[id]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindController, id)
+		case oldController.ControllerType != controller.ControllerType:
+			r.add(&r.diff.Changed, KindController, id)
+		default:
+			continue
+		}
+
+		live := *controller
+		if c.Controllers == nil {
+			c.Controllers = make(map[string]*WorkloadController)
+		}
+		c.Controllers[id] = &live
+	}
+
+	// the replicas of the removed WorkloadControllers, e.g., provisioned, are standalone Pods
+	for _, pod := range r.h.pods {
+		if _, exist := c.Controllers[pod.Controller]; !exist && pod.Controller != "" {
+			pod.Controller = ""
+			r.h.dirty.mark(pod.UUID)
+		}
+	}
+}
+
+func (r *reloader) reloadSwitches() {
+	c := r.h.cluster
+	for id := range r.old.switches {
+		if _, exist := r.updated.switches[id]; !exist {
+			delete(c.Switches, id)
+			r.add(&r.diff.Removed, KindSwitch, id)
+		}
+	}
+
+	for id, networkswitch := range r.updated.switches {
+		oldSwitch, inOld := r.old.switches[id]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindSwitch, id)
+		case !sameSwitch(oldSwitch, networkswitch):
+			r.add(&r.diff.Changed, KindSwitch, id)
+		default:
+			continue
+		}
+
+		// the Nodes buy from the Switch
+		if inOld {
+			for k := range oldSwitch.PMs {
+				r.h.dirty.mark(k)
+			}
+		}
+		live := *networkswitch
+		live.PMs = make(map[string]*Node)
+		for k := range networkswitch.PMs {
+			live.PMs[k] = c.Nodes[k]
+			r.h.dirty.mark(k)
+		}
+		if c.Switches == nil {
+			c.Switches = make(map[string]*Switch)
+		}
+		c.Switches[id] = &live
+	}
+
+	for _, networkswitch := range c.Switches {
+		for k := range networkswitch.PMs {
+			if _, exist := c.Nodes[k]; !exist {
+				delete(networkswitch.PMs, k)
+			}
+		}
+	}
+}
+
+func (r *reloader) reloadStorages() {
+	c := r.h.cluster
+	for id := range r.old.storages {
+		if _, exist := r.updated.storages[id]; !exist {
+			delete(c.Storages, id)
+			r.add(&r.diff.Removed, KindStorage, id)
+		}
+	}
+
+	for id, s := range r.updated.storages {
+		oldStorage, inOld := r.old.storages[id]
+		switch {
+		case !inOld:
+			r.add(&r.diff.Added, KindStorage, id)
+		case !sameStorage(oldStorage, s):
+			r.add(&r.diff.Changed, KindStorage, id)
+		default:
+			continue
+		}
+
+		live := *s
+		live.Nodes = append([]string(nil), s.Nodes...)
+		if c.Storages == nil {
+			c.Storages = make(map[string]*Storage)
+		}
+		c.Storages[id] = &live
+	}
+
+	// the disks on the removed Storages, e.g., of the provisioned VNodes, are on no Storage
+	for _, vnode := range r.h.vnodes {
+		if _, exist := c.Storages[vnode.Storage]; !exist && vnode.Storage != "" {
+			vnode.Storage = ""
+			r.h.dirty.mark(vnode.UUID)
+		}
+	}
+}
+
+func sameNode(a, b *Node) bool {
+	return a.CPU.Capacity == b.CPU.Capacity && a.Memory.Capacity == b.Memory.Capacity &&
+		a.NetworkThroughput.Capacity == b.NetworkThroughput.Capacity && a.IP == b.IP
+}
+
+func sameVNode(a, b *VNode) bool {
+	return a.CPU.Capacity == b.CPU.Capacity && a.Memory.Capacity == b.Memory.Capacity && a.IP == b.IP &&
+		sameLabels(a.Labels, b.Labels) && reflect.DeepEqual(copyTaints(a.Taints), copyTaints(b.Taints)) &&
+		a.Disk.Capacity == b.Disk.Capacity && a.Storage == b.Storage
+}
+
+func samePod(a, b *Pod) bool {
+	if len(a.Containers) != len(b.Containers) {
+		return false
+	}
+	if !reflect.DeepEqual(copyVolumes(a.Volumes), copyVolumes(b.Volumes)) {
+		return false
+	}
+	if a.Namespace != b.Namespace || a.Controller != b.Controller || !sameLabels(a.Labels, b.Labels) || !sameLabels(a.NodeSelector, b.NodeSelector) ||
+		!sameLabels(a.Affinity, b.Affinity) || !sameLabels(a.AntiAffinity, b.AntiAffinity) ||
+		!reflect.DeepEqual(copyTolerations(a.Tolerations), copyTolerations(b.Tolerations)) {
+		return false
+	}
+	for i, c := range a.Containers {
+		if !sameContainer(c, b.Containers[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameContainer compares the settings of the containers in the topology
+func sameContainer(a, b *Container) bool {
+	type settings struct {
+		uuid                                  string
+		limitCPU, limitMemory, reqCPU, reqMem float64
+		cpu, memory, qps, responseTime        float64
+		limitQPS, limitResponseTime           float64
+	}
+	get := func(c *Container) settings {
+		s := settings{
+			uuid:              c.UUID,
+			reqCPU:            c.ReqCPU,
+			reqMem:            c.ReqMemory,
+			limitQPS:          c.QPS.Capacity,
+			limitResponseTime: c.ResponseTime.Capacity,
+		}
+		s.limitCPU, s.limitMemory = c.GetLimits()
+		s.cpu, s.memory, s.qps, s.responseTime = c.GetBaseUsage()
+		return s
+	}
+	return get(a) == get(b) && reflect.DeepEqual(a.Profile, b.Profile)
+}
+
+// sameLabels compares the labels, or the selectors; no label and empty labels are the same
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if value, exist := b[k]; !exist || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sameService(a, b *VirtualApp) bool {
+	if len(a.Pods) != len(b.Pods) {
+		return false
+	}
+	for _, pod := range a.Pods {
+		if !b.HasPod(pod.UUID) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameNamespace(a, b *Namespace) bool {
+	return a.CPULimitQuota.Capacity == b.CPULimitQuota.Capacity &&
+		a.MemoryLimitQuota.Capacity == b.MemoryLimitQuota.Capacity &&
+		a.CPURequestQuota.Capacity == b.CPURequestQuota.Capacity &&
+		a.MemoryRequestQuota.Capacity == b.MemoryRequestQuota.Capacity
+}
+
+func sameStorage(a, b *Storage) bool {
+	return a.StorageAmount.Capacity == b.StorageAmount.Capacity &&
+		a.StorageAccess.Capacity == b.StorageAccess.Capacity &&
+		reflect.DeepEqual(append([]string(nil), a.Nodes...), append([]string(nil), b.Nodes...))
+}
+
+func sameSwitch(a, b *Switch) bool {
+	if a.NetworkThroughput.Capacity != b.NetworkThroughput.Capacity || len(a.PMs) != len(b.PMs) {
+		return false
+	}
+	for k := range a.PMs {
+		if _, exist := b.PMs[k]; !exist {
+			return false
+		}
+	}
+	return true
+}
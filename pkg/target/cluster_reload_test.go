@@ -0,0 +1,133 @@
+package target
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newChangedTestCluster changes the topology of newTestCluster:
+// vnode-1 gets more CPU, pod-2 moves to vnode-2, pod-3 is removed, and node-3 hosts vnode-3 with a new pod-4.
+func newChangedTestCluster() *Cluster {
+	c := newTestCluster()
+	vnode1 := c.Nodes["node-1"].VMs["vnode-1"]
+	vnode2 := c.Nodes["node-1"].VMs["vnode-2"]
+
+	vnode1.CPU.Capacity = 3000
+
+	vnode2.Pods["pod-2"] = vnode1.Pods["pod-2"]
+	delete(vnode1.Pods, "pod-2")
+
+	delete(vnode2.Pods, "pod-3")
+	service := c.Services[0]
+	service.DeletePod("pod-3")
+
+	container := NewContainer("container-4", "container-4")
+	container.CPU = Resource{Capacity: 500, Used: 100}
+	pod := NewPod("pod-4", "pod-4")
+	pod.Containers = []*Container{container}
+	vnode := NewVNode("vnode-3", "vnode-3")
+	vnode.CPU.Capacity = 2000
+	vnode.Pods = map[string]*Pod{pod.UUID: pod}
+	node := NewNode("node-3", "node-3")
+	node.CPU.Capacity = 5000
+	node.VMs = map[string]*VNode{vnode.UUID: vnode}
+	c.Nodes[node.UUID] = node
+	service.AddPod(pod)
+
+	c.CompleteBuild()
+	return c
+}
+
+func podLocations(h *ClusterHandler) map[string]string {
+	result := make(map[string]string)
+	for id, pod := range h.pods {
+		result[id] = pod.ProviderID
+	}
+	return result
+}
+
+func servicePods(h *ClusterHandler) []string {
+	pods := []string{}
+	for _, pod := range h.cluster.Services[0].Pods {
+		pods = append(pods, pod.UUID)
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+func TestClusterHandler_Reload(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod-1 failed: %v", err)
+	}
+	provisioned, err := h.ProvisionPod("pod-3", "")
+	if err != nil {
+		t.Fatalf("provision pod-3 failed: %v", err)
+	}
+
+	diff := h.Reload(newTestCluster(), newChangedTestCluster())
+	expected := &TopologyDiff{
+		Added:   []string{"host[node-3]", "pod[pod-4]", "vhost[vnode-3]"},
+		Removed: []string{"pod[pod-3]"},
+		Changed: []string{"pod[pod-2]", "service[service-1]", "vhost[vnode-1]"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+
+	// pod-1 stays where it is moved by the action, pod-2 is moved by the topology
+	locations := map[string]string{
+		"pod-1":          "vnode-2",
+		"pod-2":          "vnode-2",
+		provisioned.UUID: "vnode-2",
+		"pod-4":          "vnode-3",
+	}
+	if got := podLocations(h); !reflect.DeepEqual(got, locations) {
+		t.Errorf("wrong locations of pods: %v, expected %v", got, locations)
+	}
+	if pods := servicePods(h); !reflect.DeepEqual(pods, []string{"pod-1", "pod-2", provisioned.UUID, "pod-4"}) {
+		t.Errorf("wrong pods of service-1: %v", pods)
+	}
+	if cpu := h.vnodes["vnode-1"].CPU.Capacity; cpu != 3000 {
+		t.Errorf("CPU of vnode-1 should be 3000, but got %v", cpu)
+	}
+	if _, exist := h.containers["container-4"]; !exist {
+		t.Errorf("container-4 is not in the index")
+	}
+	if _, err := h.GenerateClusterDTOs(); err != nil {
+		t.Errorf("failed to generate DTOs after reload: %v", err)
+	}
+
+	// nothing changes if the topology is the same
+	if diff := h.Reload(newChangedTestCluster(), newChangedTestCluster()); !diff.IsEmpty() {
+		t.Errorf("diff of the same topology should be empty, but got %v", diff)
+	}
+
+	// removing vnode-2 relocates pod-1 to vnode-1 of the topology, and removes the provisioned pod
+	updated := newChangedTestCluster()
+	node1 := updated.Nodes["node-1"]
+	node1.VMs["vnode-1"].Pods["pod-2"] = node1.VMs["vnode-2"].Pods["pod-2"]
+	delete(node1.VMs, "vnode-2")
+	updated.CompleteBuild()
+
+	diff = h.Reload(newChangedTestCluster(), updated)
+	expected = &TopologyDiff{
+		Removed: []string{"pod[" + provisioned.UUID + "]", "vhost[vnode-2]"},
+		Changed: []string{"pod[pod-1]", "pod[pod-2]"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	locations = map[string]string{
+		"pod-1": "vnode-1",
+		"pod-2": "vnode-1",
+		"pod-4": "vnode-3",
+	}
+	if got := podLocations(h); !reflect.DeepEqual(got, locations) {
+		t.Errorf("wrong locations of pods: %v, expected %v", got, locations)
+	}
+	if pods := servicePods(h); !reflect.DeepEqual(pods, []string{"pod-1", "pod-2", "pod-4"}) {
+		t.Errorf("wrong pods of service-1: %v", pods)
+	}
+}
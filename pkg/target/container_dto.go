@@ -17,6 +17,12 @@ func (d *Container) Clone(newName, newId string) *Container {
 	result.ReqCPU = d.ReqCPU
 	result.QPS = d.QPS
 	result.ResponseTime = d.ResponseTime
+	result.inheritCPU = d.inheritCPU
+	result.inheritMem = d.inheritMem
+	result.Profile = d.Profile
+	result.base = d.base
+	// the randomWalk of the new Container is drawn from its own Id, from the start
+	result.walk = &randomWalk{}
 
 	//not copy the APP
 	result.App = nil
@@ -56,15 +62,20 @@ func (docker *Container) BuildDTO(pod *Pod) (*proto.EntityDTO, error) {
 	provider := builder.CreateProvider(proto.EntityDTO_CONTAINER_POD, pod.UUID)
 	truep := true
 
-	entity, err := builder.
+	containerBuilder := builder.
 		NewEntityDTOBuilder(proto.EntityDTO_CONTAINER, docker.UUID).
 		DisplayName(docker.Name).
 		Provider(provider).
 		BuysCommodities(bought).
 		SellsCommodities(sold).
 		WithPowerState(proto.EntityDTO_POWERED_ON).
-		ConsumerPolicy(&proto.EntityDTO_ConsumerPolicy{ProviderMustClone: &truep}).
-		Create()
+		ConsumerPolicy(&proto.EntityDTO_ConsumerPolicy{ProviderMustClone: &truep})
+
+	if pod.Controller != "" {
+		containerBuilder.ControlledBy(GetContainerSpecId(pod.Controller, pod.getContainerName(docker)))
+	}
+
+	entity, err := containerBuilder.Create()
 
 	if err != nil {
 		msg := fmt.Errorf("Failed to build EntityDTO for container(%v/%v): %v",
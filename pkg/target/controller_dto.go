@@ -0,0 +1,248 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+	"strings"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// the types of WorkloadController
+const (
+	ControllerDeployment  = "Deployment"
+	ControllerStatefulSet = "StatefulSet"
+	ControllerReplicaSet  = "ReplicaSet"
+)
+
+var ControllerTypes = []string{ControllerDeployment, ControllerStatefulSet, ControllerReplicaSet}
+
+// ParseControllerType returns the type of WorkloadController of the given name, case-insensitive.
+func ParseControllerType(value string) (string, error) {
+	for _, ctype := range ControllerTypes {
+		if strings.EqualFold(ctype, strings.TrimSpace(value)) {
+			return ctype, nil
+		}
+	}
+	return "", fmt.Errorf("invalid controller type '%s', should be one of %v", value, ControllerTypes)
+}
+
+// GetContainerSpecId returns the Id of the ContainerSpec of the containers of the given name in the replicas
+// of a WorkloadController: <controllerId>/<containerName>.
+func GetContainerSpecId(controllerId, name string) string {
+	return controllerId + "/" + name
+}
+
+// ParseContainerSpecId returns the Id of the WorkloadController and the name of the containers of a ContainerSpec.
+func ParseContainerSpecId(id string) (controllerId, name string, err error) {
+	i := strings.LastIndex(id, "/")
+	if i < 1 || i == len(id)-1 {
+		return "", "", fmt.Errorf("invalid ContainerSpec Id '%s', should be <controllerId>/<containerName>", id)
+	}
+	return id[:i], id[i+1:], nil
+}
+
+// getContainerName returns the name of the Container shared by the replicas of the Pod:
+// the container Id is <containerName>-<podId>, see topology.ClusterBuilder.buildPods().
+func (pod *Pod) getContainerName(container *Container) string {
+	return strings.TrimSuffix(container.Name, "-"+pod.UUID)
+}
+
+// getReplicas returns the Pods of each WorkloadController, sorted by UUID; key=controller.UUID
+func (c *Cluster) getReplicas() map[string][]*Pod {
+	result := make(map[string][]*Pod)
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			for _, pod := range vhost.Pods {
+				if _, exist := c.Controllers[pod.Controller]; exist {
+					result[pod.Controller] = append(result[pod.Controller], pod)
+				}
+			}
+		}
+	}
+
+	for _, pods := range result {
+		sort.Slice(pods, func(i, j int) bool {
+			return pods[i].UUID < pods[j].UUID
+		})
+	}
+	return result
+}
+
+// getContainerSpecs returns the containers of the replicas of each ContainerSpec; key=containerName
+func getContainerSpecs(replicas []*Pod) map[string][]*Container {
+	result := make(map[string][]*Container)
+	for _, pod := range replicas {
+		for _, container := range pod.Containers {
+			name := pod.getContainerName(container)
+			result[name] = append(result[name], container)
+		}
+	}
+	return result
+}
+
+func (controller *WorkloadController) createControllerData() *proto.EntityDTO_WorkloadControllerData {
+	result := &proto.EntityDTO_WorkloadControllerData{}
+	switch controller.ControllerType {
+	case ControllerStatefulSet:
+		result.ControllerType = &proto.EntityDTO_WorkloadControllerData_StatefulSetData{
+			StatefulSetData: &proto.EntityDTO_StatefulSetData{},
+		}
+	case ControllerReplicaSet:
+		result.ControllerType = &proto.EntityDTO_WorkloadControllerData_ReplicaSetData{
+			ReplicaSetData: &proto.EntityDTO_ReplicaSetData{},
+		}
+	default:
+		result.ControllerType = &proto.EntityDTO_WorkloadControllerData_DeploymentData{
+			DeploymentData: &proto.EntityDTO_DeploymentData{},
+		}
+	}
+	return result
+}
+
+// BuildDTO builds the WorkloadController owning the ContainerSpecs of its replicas; the quotas bought from
+// the Namespace of the replicas are resold to them, with the given capacities sold by the Namespace.
+func (controller *WorkloadController) BuildDTO(replicas []*Pod, ns *Namespace, capacities []float64) (*proto.EntityDTO, error) {
+	controllerBuilder := builder.
+		NewEntityDTOBuilder(proto.EntityDTO_WORKLOAD_CONTROLLER, controller.UUID).
+		DisplayName(controller.Name).
+		WorkloadControllerData(controller.createControllerData()).
+		WithPowerState(proto.EntityDTO_POWERED_ON)
+
+	specs := getContainerSpecs(replicas)
+	var names []string
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		controllerBuilder.Owns(GetContainerSpecId(controller.UUID, name))
+	}
+
+	if ns != nil {
+		usage := make([]float64, len(quotaTypes))
+		for _, pod := range replicas {
+			for i, used := range pod.getQuotaUsage() {
+				usage[i] += used
+			}
+		}
+
+		var sold []*proto.CommodityDTO
+		for i := range quotaTypes {
+			comm, _ := CreateCapacityUsedCommodity(ns.UUID, &Resource{Capacity: capacities[i], Used: usage[i]}, quotaTypes[i])
+			sold = append(sold, comm)
+		}
+		provider := builder.CreateProvider(proto.EntityDTO_NAMESPACE, ns.UUID)
+		controllerBuilder.
+			SellsCommodities(sold).
+			Provider(provider).
+			BuysCommodities(createQuotaCommoditiesBought(ns.UUID, usage))
+	}
+
+	entity, err := controllerBuilder.Create()
+	if err != nil {
+		msg := fmt.Errorf("Failed to build EntityDTO for controller(%v): %v", controller.Name, err.Error())
+		glog.Error(msg.Error())
+		return nil, msg
+	}
+
+	return entity, nil
+}
+
+// buildContainerSpecDTO builds the ContainerSpec of the containers of the same name in the replicas:
+// the capacity is the largest limit of the containers, and the usage is their average usage.
+func buildContainerSpecDTO(controllerId, name string, containers []*Container) (*proto.EntityDTO, error) {
+	var cpu, memory Resource
+	var reqCPU, reqMemory float64
+	for _, container := range containers {
+		cpu.Capacity = maxFloat(cpu.Capacity, container.CPU.Capacity)
+		memory.Capacity = maxFloat(memory.Capacity, container.Memory.Capacity)
+		reqCPU = maxFloat(reqCPU, container.ReqCPU)
+		reqMemory = maxFloat(reqMemory, container.ReqMemory)
+		cpu.Used += container.CPU.Used / float64(len(containers))
+		memory.Used += container.Memory.Used / float64(len(containers))
+	}
+
+	var sold []*proto.CommodityDTO
+	cpuComm, _ := CreateResourceCommodityResize(&cpu, proto.CommodityDTO_VCPU, true)
+	memComm, _ := CreateResourceCommodityResize(&memory, proto.CommodityDTO_VMEM, true)
+	sold = append(sold, cpuComm, memComm)
+	if reqCPU > 0 {
+		comm, _ := CreateResourceCommodity(&Resource{Capacity: reqCPU}, proto.CommodityDTO_VCPU_REQUEST)
+		sold = append(sold, comm)
+	}
+	if reqMemory > 0 {
+		comm, _ := CreateResourceCommodity(&Resource{Capacity: reqMemory}, proto.CommodityDTO_VMEM_REQUEST)
+		sold = append(sold, comm)
+	}
+
+	id := GetContainerSpecId(controllerId, name)
+	entity, err := builder.
+		NewEntityDTOBuilder(proto.EntityDTO_CONTAINER_SPEC, id).
+		DisplayName(id).
+		SellsCommodities(sold).
+		WithPowerState(proto.EntityDTO_POWERED_ON).
+		Create()
+
+	if err != nil {
+		msg := fmt.Errorf("Failed to build EntityDTO for containerSpec(%v): %v", id, err.Error())
+		glog.Error(msg.Error())
+		return nil, msg
+	}
+
+	return entity, nil
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// generateControllerDTOs builds the WorkloadControllers in the filter, and the ContainerSpecs of their replicas;
+// a WorkloadController without replica has no ContainerSpec.
+func (c *Cluster) generateControllerDTOs(filter entityFilter) []*proto.EntityDTO {
+	var result []*proto.EntityDTO
+	if len(c.Controllers) < 1 {
+		return result
+	}
+
+	cpu, memory := c.getVNodeCapacity()
+	allReplicas := c.getReplicas()
+	for id, controller := range c.Controllers {
+		if !filter.has(id) {
+			continue
+		}
+		replicas := allReplicas[id]
+
+		// the replicas are in the same Namespace, see topology.validator.checkControllers()
+		var ns *Namespace
+		var capacities []float64
+		if len(replicas) > 0 {
+			if n, exist := c.Namespaces[replicas[0].Namespace]; exist {
+				ns = n
+				capacities = ns.getQuotaCapacities(cpu, memory)
+			}
+		}
+
+		dto, err := controller.BuildDTO(replicas, ns, capacities)
+		if err != nil {
+			continue
+		}
+		result = append(result, dto)
+
+		for name, containers := range getContainerSpecs(replicas) {
+			dto, err := buildContainerSpecDTO(id, name, containers)
+			if err != nil {
+				continue
+			}
+			result = append(result, dto)
+		}
+	}
+
+	glog.V(3).Infof("There are %d controllers, and %d controller and containerSpec DTOs.", len(c.Controllers), len(result))
+	return result
+}
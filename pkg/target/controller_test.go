@@ -0,0 +1,179 @@
+package target
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// newTestControllerCluster makes pod-1 and pod-3 of the test cluster the replicas of Deployment deploy-1,
+// each with a container of the spec deploy-1/web
+func newTestControllerCluster() *Cluster {
+	c := newTestCluster()
+	controller := NewWorkloadController("deploy-1", "deploy-1", ControllerDeployment)
+	c.Controllers = map[string]*WorkloadController{controller.UUID: controller}
+	for _, pod := range []*Pod{c.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"], c.Nodes["node-1"].VMs["vnode-2"].Pods["pod-3"]} {
+		pod.Controller = controller.UUID
+		id := "web-" + pod.UUID
+		pod.Containers[0].Name = id
+		pod.Containers[0].UUID = id
+	}
+	return c
+}
+
+func getConnected(dto *proto.EntityDTO, ctype proto.ConnectedEntity_ConnectionType) []string {
+	var result []string
+	for _, connected := range dto.GetConnectedEntities() {
+		if connected.GetConnectionType() == ctype {
+			result = append(result, connected.GetConnectedEntityId())
+		}
+	}
+	return result
+}
+
+func TestParseContainerSpecId(t *testing.T) {
+	controllerId, name, err := ParseContainerSpecId(GetContainerSpecId("deploy-1", "web"))
+	if err != nil || controllerId != "deploy-1" || name != "web" {
+		t.Errorf("wrong ContainerSpec: %s, %s, %v", controllerId, name, err)
+	}
+	for _, id := range []string{"deploy-1", "/web", "deploy-1/"} {
+		if _, _, err := ParseContainerSpecId(id); err == nil {
+			t.Errorf("ContainerSpec Id %s should be invalid", id)
+		}
+	}
+}
+
+func TestCluster_ControllerDTOs(t *testing.T) {
+	dtos, err := newTestControllerCluster().GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+
+	controller := findDTO(dtos, "deploy-1")
+	if controller == nil || controller.GetEntityType() != proto.EntityDTO_WORKLOAD_CONTROLLER ||
+		controller.GetWorkloadControllerData().GetDeploymentData() == nil {
+		t.Fatalf("wrong controller DTO: %v", controller)
+	}
+	if owned := getConnected(controller, proto.ConnectedEntity_OWNS_CONNECTION); !reflect.DeepEqual(owned, []string{"deploy-1/web"}) {
+		t.Errorf("wrong ContainerSpecs of deploy-1: %v", owned)
+	}
+
+	spec := findDTO(dtos, "deploy-1/web")
+	if spec == nil || spec.GetEntityType() != proto.EntityDTO_CONTAINER_SPEC {
+		t.Fatalf("wrong containerSpec DTO: %v", spec)
+	}
+	for _, comm := range spec.GetCommoditiesSold() {
+		if comm.GetCommodityType() == proto.CommodityDTO_VCPU && (comm.GetCapacity() != 500 || comm.GetUsed() != 100) {
+			t.Errorf("wrong VCPU of the containerSpec: %v", comm)
+		}
+	}
+
+	for _, id := range []string{"web-pod-1", "web-pod-3"} {
+		if got := getConnected(findDTO(dtos, id), proto.ConnectedEntity_CONTROLLED_BY_CONNECTION); !reflect.DeepEqual(got, []string{"deploy-1/web"}) {
+			t.Errorf("%s should be controlled by deploy-1/web, but by %v", id, got)
+		}
+	}
+	for id, expected := range map[string][]string{"pod-1": {"deploy-1"}, "pod-2": nil, "pod-3": {"deploy-1"}} {
+		if got := getConnected(findDTO(dtos, id), proto.ConnectedEntity_AGGREGATED_BY_CONNECTION); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s should be aggregated by %v, but by %v", id, expected, got)
+		}
+	}
+}
+
+func TestClusterHandler_ResizeContainerSpec(t *testing.T) {
+	c := newTestControllerCluster()
+	ns := NewNamespace("ns-1", "ns-1")
+	ns.CPULimitQuota.Capacity = 1200
+	c.Namespaces = map[string]*Namespace{ns.UUID: ns}
+	c.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Namespace = ns.UUID
+	c.Nodes["node-1"].VMs["vnode-2"].Pods["pod-3"].Namespace = ns.UUID
+	h := NewClusterHandler(c)
+
+	// each replica takes 200 more; only 200 is available in total
+	var reason *AdmissionError
+	if err := h.ResizeContainerSpec("deploy-1/web", 700, -1); !errors.As(err, &reason) {
+		t.Fatalf("resize beyond the quota should fail with an AdmissionError, but got %v", err)
+	}
+	if reason.Required != 400 || reason.Available != 200 {
+		t.Errorf("wrong admission reason: %+v", reason)
+	}
+
+	if err := h.ResizeContainerSpec("deploy-1/web", 600, -1); err != nil {
+		t.Fatalf("resize within the quota failed: %v", err)
+	}
+	for _, id := range []string{"web-pod-1", "web-pod-3"} {
+		if cpu := h.containers[id].CPU.Capacity; cpu != 600 {
+			t.Errorf("%s should be resized to 600, but got %v", id, cpu)
+		}
+	}
+
+	for _, id := range []string{"deploy-1/db", "deploy-2/web", "web"} {
+		if err := h.ResizeContainerSpec(id, 600, -1); err == nil {
+			t.Errorf("resize unknown containerSpec %s should fail", id)
+		}
+	}
+}
+
+func TestClusterHandler_ScaleController(t *testing.T) {
+	h := NewClusterHandler(newTestControllerCluster())
+
+	// vnode-2 has the most free CPU
+	pod, err := h.ProvisionReplica("deploy-1")
+	if err != nil {
+		t.Fatalf("provision replica failed: %v", err)
+	}
+	if pod.ProviderID != "vnode-2" || pod.Controller != "deploy-1" {
+		t.Errorf("wrong new replica: %+v", pod)
+	}
+	if containers, _ := h.getSpecContainers("deploy-1/web"); len(containers) != 3 {
+		t.Errorf("the new replica should have a container of deploy-1/web, but got %d containers", len(containers))
+	}
+	affected := h.GetAffectedEntities("deploy-1/web")
+	if expected := []string{"deploy-1/web", "web-pod-1", "web-pod-1-c1", "web-pod-3"}; !reflect.DeepEqual(affected, expected) {
+		t.Errorf("wrong affected entities: %v", affected)
+	}
+
+	for _, id := range []string{"pod-3", "pod-1-c1"} {
+		if err := h.SuspendReplica("deploy-1"); err != nil {
+			t.Fatalf("suspend replica failed: %v", err)
+		}
+		if _, exist := h.pods[id]; exist {
+			t.Errorf("%s should be suspended", id)
+		}
+	}
+	if err := h.SuspendReplica("deploy-1"); err == nil {
+		t.Errorf("suspend the last replica should fail")
+	}
+
+	if _, err := h.ProvisionReplica("deploy-x"); err == nil {
+		t.Errorf("provision replica of unknown controller should fail")
+	}
+}
+
+func TestClusterHandler_ReloadControllers(t *testing.T) {
+	h := NewClusterHandler(newTestControllerCluster())
+
+	updated := newTestControllerCluster()
+	updated.Controllers["deploy-1"].ControllerType = ControllerStatefulSet
+	controller := NewWorkloadController("deploy-2", "deploy-2", ControllerReplicaSet)
+	updated.Controllers[controller.UUID] = controller
+	updated.Nodes["node-1"].VMs["vnode-1"].Pods["pod-2"].Controller = controller.UUID
+
+	diff := h.Reload(newTestControllerCluster(), updated)
+	expected := &TopologyDiff{Added: []string{"controller[deploy-2]"}, Changed: []string{"controller[deploy-1]", "pod[pod-2]"}}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	if h.pods["pod-2"].Controller != "deploy-2" || h.cluster.Controllers["deploy-1"].ControllerType != ControllerStatefulSet {
+		t.Errorf("controllers are not reloaded")
+	}
+
+	h.Reload(updated, newTestCluster())
+	for id, pod := range h.pods {
+		if pod.Controller != "" {
+			t.Errorf("%s should have no controller, but %s", id, pod.Controller)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package target
 
 import (
+	"sort"
+
 	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
@@ -61,3 +63,13 @@ func CreateCapacityUsedCommodity(key string, qps *Resource, ctype proto.Commodit
 		Used(qps.Used).
 		Create()
 }
+
+// sortCommoditiesBought sorts the commodities bought of the DTOs by provider, which the builder puts in a random order.
+func sortCommoditiesBought(dtos []*proto.EntityDTO) {
+	for _, dto := range dtos {
+		bought := dto.CommoditiesBought
+		sort.SliceStable(bought, func(i, j int) bool {
+			return bought[i].GetProviderId() < bought[j].GetProviderId()
+		})
+	}
+}
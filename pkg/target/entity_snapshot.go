@@ -0,0 +1,211 @@
+package target
+
+import (
+	"github.com/golang/glog"
+	"sort"
+)
+
+// EntitySnapshot is a copy of some entities of the cluster.
+// Restoring it rolls back the changes on these entities only, and keeps the changes on the other entities.
+type EntitySnapshot struct {
+	containers map[*Container]Container
+	pods       map[*Pod]Pod
+	vnodes     map[*VNode]VNode
+	nodes      map[*Node]Node
+	services   map[*VirtualApp]VirtualApp
+}
+
+// GetAffectedEntities returns the Ids of the entities which may be changed by an action on the given entity:
+// the entity itself, its provider, the VirtualApp of a Pod, and the Storage of a VNode. An action on a ContainerSpec
+// changes its containers in all the replicas, and an action on a WorkloadController changes the replicas, their VirtualApps
+// and the VNodes hosting a new replica.
+func (h *ClusterHandler) GetAffectedEntities(id string) []string {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	result := []string{id}
+	if containers, err := h.getSpecContainers(id); err == nil {
+		for _, container := range containers {
+			result = append(result, container.UUID)
+		}
+		return result
+	}
+	if _, exist := h.cluster.Controllers[id]; exist {
+		for _, pod := range h.getReplicas(id) {
+			result = append(result, pod.UUID)
+			if service := h.cluster.FindService(pod.UUID); service != nil {
+				result = append(result, service.UUID)
+			}
+		}
+		for vnodeId := range h.vnodes {
+			result = append(result, vnodeId)
+		}
+		return result
+	}
+
+	provider := ""
+	if container, exist := h.containers[id]; exist {
+		provider = container.ProviderID
+	} else if pod, exist := h.pods[id]; exist {
+		provider = pod.ProviderID
+		if service := h.cluster.FindService(id); service != nil {
+			result = append(result, service.UUID)
+		}
+	} else if vnode, exist := h.vnodes[id]; exist {
+		provider = vnode.ProviderID
+		if vnode.Storage != "" {
+			result = append(result, vnode.Storage)
+		}
+	}
+
+	if provider != "" && provider != emptyProvider {
+		result = append(result, provider)
+	}
+	return result
+}
+
+// GetEvictionEntities returns the Ids of the entities which may be changed by suspending the VNode, besides
+// the ones of GetAffectedEntities(): its Pods may be evicted to any other VNode.
+func (h *ClusterHandler) GetEvictionEntities(vnodeId string) []string {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	vnode, exist := h.vnodes[vnodeId]
+	if !exist {
+		return nil
+	}
+
+	var result []string
+	for podId := range vnode.Pods {
+		result = append(result, podId)
+	}
+	for id := range h.vnodes {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SnapshotEntities copies the given entities; the Containers and the VirtualApp of a Pod are copied too.
+// Unknown entities are ignored.
+func (h *ClusterHandler) SnapshotEntities(ids []string) *EntitySnapshot {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	s := &EntitySnapshot{
+		containers: make(map[*Container]Container),
+		pods:       make(map[*Pod]Pod),
+		vnodes:     make(map[*VNode]VNode),
+		nodes:      make(map[*Node]Node),
+		services:   make(map[*VirtualApp]VirtualApp),
+	}
+
+	for _, id := range ids {
+		if container, exist := h.containers[id]; exist {
+			s.containers[container] = *container
+		} else if pod, exist := h.pods[id]; exist {
+			s.addPod(pod)
+			if service := h.cluster.FindService(id); service != nil {
+				s.addService(service)
+			}
+		} else if vnode, exist := h.vnodes[id]; exist {
+			s.addVNode(vnode)
+		} else if node, exist := h.nodes[id]; exist {
+			s.addNode(node)
+		} else {
+			for _, service := range h.cluster.Services {
+				if service.UUID == id {
+					s.addService(service)
+				}
+			}
+		}
+	}
+
+	return s
+}
+
+func (s *EntitySnapshot) addPod(pod *Pod) {
+	p := *pod
+	p.Containers = append([]*Container{}, pod.Containers...)
+	s.pods[pod] = p
+
+	for _, container := range pod.Containers {
+		s.containers[container] = *container
+	}
+}
+
+func (s *EntitySnapshot) addVNode(vnode *VNode) {
+	v := *vnode
+	v.Pods = make(map[string]*Pod)
+	for k, pod := range vnode.Pods {
+		v.Pods[k] = pod
+	}
+	s.vnodes[vnode] = v
+}
+
+func (s *EntitySnapshot) addNode(node *Node) {
+	n := *node
+	n.VMs = make(map[string]*VNode)
+	for k, vnode := range node.VMs {
+		n.VMs[k] = vnode
+	}
+	s.nodes[node] = n
+}
+
+func (s *EntitySnapshot) addService(service *VirtualApp) {
+	v := *service
+	v.Pods = append([]*Pod{}, service.Pods...)
+	s.services[service] = v
+}
+
+// RestoreEntities rolls back the entities in the snapshot, and rebuilds the index.
+// Entities created after the snapshot are dropped if they are only reachable from the restored entities.
+func (h *ClusterHandler) RestoreEntities(s *EntitySnapshot) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.markEntities(s)
+	s.restore()
+	h.markEntities(s)
+	h.buildIndex()
+	glog.V(2).Infof("cluster[%s]: %d entities are restored from snapshot.", h.cluster.Name,
+		len(s.containers)+len(s.pods)+len(s.vnodes)+len(s.nodes)+len(s.services))
+}
+
+func (s *EntitySnapshot) restore() {
+	for p, v := range s.containers {
+		*p = v
+	}
+	for p, v := range s.pods {
+		*p = v
+	}
+	for p, v := range s.vnodes {
+		*p = v
+	}
+	for p, v := range s.nodes {
+		*p = v
+	}
+	for p, v := range s.services {
+		*p = v
+	}
+}
+
+// markEntities marks the entities in the snapshot changed, with their providers, and the Namespace, WorkloadController
+// and VirtualApp of the Pods; it is called before and after the snapshot is restored, for the providers of both.
+func (h *ClusterHandler) markEntities(s *EntitySnapshot) {
+	for p := range s.containers {
+		h.dirty.mark(p.UUID, p.ProviderID)
+	}
+	for p := range s.pods {
+		h.markPod(p)
+	}
+	for p := range s.vnodes {
+		h.dirty.mark(p.UUID, p.ProviderID, p.Storage)
+	}
+	for p := range s.nodes {
+		h.dirty.mark(p.UUID)
+	}
+	for p := range s.services {
+		h.dirty.mark(p.UUID)
+	}
+}
@@ -0,0 +1,105 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+)
+
+const (
+	// max number of actions that can be undone; each keeps a copy of the entities it affects
+	defaultMaxHistory = 50
+)
+
+// EntityState is the placement and resource capacity/usage of an entity.
+type EntityState struct {
+	Id       string
+	Kind     string
+	Provider string `json:",omitempty"`
+
+	CPU    Resource
+	Memory Resource
+}
+
+// GetEntityStates returns the state of the given entities; unknown entities are ignored.
+// Only the usage of the given entities is updated, not of the whole cluster.
+func (h *ClusterHandler) GetEntityStates(ids []string) []*EntityState {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	t := h.cluster.usageTime()
+	var result []*EntityState
+	for _, id := range ids {
+		if container, exist := h.containers[id]; exist {
+			if pod, exist := h.pods[container.ProviderID]; exist {
+				h.cluster.setPodUsage(pod, t)
+			}
+			result = append(result, newEntityState(&container.ObjectMeta, container.CPU, container.Memory))
+		} else if pod, exist := h.pods[id]; exist {
+			h.cluster.setPodUsage(pod, t)
+			result = append(result, newEntityState(&pod.ObjectMeta, pod.CPU, pod.Memory))
+		} else if vnode, exist := h.vnodes[id]; exist {
+			h.cluster.setVNodeUsage(vnode, t)
+			result = append(result, newEntityState(&vnode.ObjectMeta, vnode.CPU, vnode.Memory))
+		} else if node, exist := h.nodes[id]; exist {
+			h.cluster.setNodeUsage(node, t)
+			result = append(result, newEntityState(&node.ObjectMeta, node.CPU, node.Memory))
+		}
+	}
+
+	return result
+}
+
+func newEntityState(meta *ObjectMeta, cpu, memory Resource) *EntityState {
+	return &EntityState{
+		Id:       meta.UUID,
+		Kind:     meta.Kind,
+		Provider: meta.ProviderID,
+		CPU:      cpu,
+		Memory:   memory,
+	}
+}
+
+// RecordHistory keeps the snapshot of the entities affected by an action, taken before the action, so that the
+// action can be undone; the snapshots are kept in the order the actions are completed.
+func (h *ClusterHandler) RecordHistory(before *EntitySnapshot) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.history = append(h.history, before)
+	if len(h.history) > defaultMaxHistory {
+		h.history = h.history[len(h.history)-defaultMaxHistory:]
+	}
+}
+
+// Undo rolls back the entities affected by the last n completed actions, the latest first; the changes of the
+// other actions are kept.
+func (h *ClusterHandler) Undo(n int) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if n < 1 || n > len(h.history) {
+		err := fmt.Errorf("Undo failed. Cannot undo %d actions, only %d actions in history.", n, len(h.history))
+		glog.Error(err.Error())
+		return err
+	}
+
+	i := len(h.history) - n
+	for j := len(h.history) - 1; j >= i; j-- {
+		h.markEntities(h.history[j])
+		h.history[j].restore()
+		h.markEntities(h.history[j])
+	}
+	h.history = h.history[:i]
+	h.buildIndex()
+
+	glog.V(2).Infof("Successed: undo %d actions; %d actions left in history.", n, len(h.history))
+	return nil
+}
+
+// clearHistory drops the history, when the entities in it are replaced.
+func (h *ClusterHandler) clearHistory() {
+	if len(h.history) > 0 {
+		glog.V(2).Infof("cluster[%s]: %d actions in history are dropped.", h.cluster.Name, len(h.history))
+	}
+	h.history = nil
+}
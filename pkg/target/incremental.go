@@ -0,0 +1,405 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+	"sync"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// dirtyTracker keeps the entities sent to the server by the last discovery, and the entities changed since, so that
+// an incremental discovery only builds the DTOs of the changed entities, and the DELETED DTOs of the removed ones.
+// The entities are marked changed on the live cluster by the actions, the rollbacks and the reloads; the changes
+// made without them, i.e., the usage set by the profiles and the trace, and the access keys set by the placement of
+// the other entities, are found by comparing the states of the entities with the ones sent, see entityStates().
+type dirtyTracker struct {
+	// the entity type of the entities sent; key=entity UUID
+	sent map[string]proto.EntityDTO_EntityType
+	// the states of the entities sent; key=entity UUID
+	states map[string]string
+
+	// the entities marked changed since the last discovery; all the entities are changed if all is true,
+	// e.g., before the first full discovery
+	marked map[string]bool
+	all    bool
+	mux    sync.Mutex
+}
+
+func newDirtyTracker() *dirtyTracker {
+	return &dirtyTracker{
+		sent:   make(map[string]proto.EntityDTO_EntityType),
+		states: make(map[string]string),
+		marked: make(map[string]bool),
+		all:    true,
+	}
+}
+
+// mark marks the entities changed; an empty Id is ignored.
+func (t *dirtyTracker) mark(ids ...string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, id := range ids {
+		if id != "" {
+			t.marked[id] = true
+		}
+	}
+}
+
+// markAll marks all the entities changed, e.g., when the cluster is replaced.
+func (t *dirtyTracker) markAll() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.marked = make(map[string]bool)
+	t.all = true
+}
+
+// take returns the entities marked changed, and clears the marks.
+func (t *dirtyTracker) take() (map[string]bool, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	marked, all := t.marked, t.all
+	t.marked = make(map[string]bool)
+	t.all = false
+	return marked, all
+}
+
+// restore marks the entities taken by a failed discovery again.
+func (t *dirtyTracker) restore(marked map[string]bool, all bool) {
+	if all {
+		t.markAll()
+		return
+	}
+	for id := range marked {
+		t.mark(id)
+	}
+}
+
+// changes returns the entities marked, and the ones whose states in the cluster are not the ones sent.
+func (t *dirtyTracker) changes(c *Cluster, marked map[string]bool) entityFilter {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	result := make(entityFilter, len(marked))
+	for id := range marked {
+		result[id] = true
+	}
+	for id, state := range c.entityStates() {
+		if t.states[id] != state {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+// reset takes the DTOs of a full discovery on the cluster as the base of the next incremental discovery.
+func (t *dirtyTracker) reset(c *Cluster, dtos []*proto.EntityDTO) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.sent = make(map[string]proto.EntityDTO_EntityType, len(dtos))
+	for _, dto := range dtos {
+		t.sent[dto.GetId()] = dto.GetEntityType()
+	}
+	t.states = c.entityStates()
+}
+
+// update adds the DTOs built by an incremental discovery on the cluster to the ones sent, and returns them with
+// a DELETED DTO for each entity sent but not in the cluster any more.
+func (t *dirtyTracker) update(c *Cluster, dtos []*proto.EntityDTO) []*proto.EntityDTO {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ids := c.entityIds()
+	var removed []string
+	for id := range t.sent {
+		if !ids[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+
+	result := dtos
+	for _, dto := range dtos {
+		t.sent[dto.GetId()] = dto.GetEntityType()
+	}
+	for _, id := range removed {
+		result = append(result, newDeletedDTO(id, t.sent[id]))
+		delete(t.sent, id)
+	}
+	t.states = c.entityStates()
+
+	glog.V(3).Infof("%d entities are changed, %d are removed.", len(dtos), len(removed))
+	return result
+}
+
+func newDeletedDTO(id string, etype proto.EntityDTO_EntityType) *proto.EntityDTO {
+	updateType := proto.UpdateType_DELETED
+	return &proto.EntityDTO{
+		EntityType: &etype,
+		Id:         &id,
+		UpdateType: &updateType,
+	}
+}
+
+// markPod marks the Pod changed, with the entities changed by adding or removing it: its VNode, VirtualApp,
+// Namespace and WorkloadController.
+func (h *ClusterHandler) markPod(pod *Pod) {
+	h.dirty.mark(pod.UUID, pod.ProviderID, pod.Namespace, pod.Controller)
+	if service := h.cluster.FindService(pod.UUID); service != nil {
+		h.dirty.mark(service.UUID)
+	}
+}
+
+// snapshotChanges returns a copy of the cluster, and the entities marked changed on it since the last discovery.
+func (h *ClusterHandler) snapshotChanges() (*Cluster, map[string]bool, bool) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	marked, all := h.dirty.take()
+	return h.cluster.DeepCopy(), marked, all
+}
+
+// DiscoverDTOs builds the DTOs of all the entities, as GenerateClusterDTOs();
+// they are the base of the next incremental discovery.
+func (h *ClusterHandler) DiscoverDTOs() ([]*proto.EntityDTO, error) {
+	c, marked, all := h.snapshotChanges()
+	// the trace is stepped by the full discoveries only
+	if c.trace != nil {
+		c.trace.Next()
+	}
+	dtos, err := c.GenerateDTOs()
+	if err != nil {
+		h.dirty.restore(marked, all)
+		return dtos, err
+	}
+	h.dirty.reset(c, dtos)
+	return dtos, nil
+}
+
+// DiscoverChangedDTOs builds the DTOs of the entities changed since the last discovery, and the DELETED DTOs of
+// the removed entities. All the entities are changed if there has been no full discovery.
+func (h *ClusterHandler) DiscoverChangedDTOs() ([]*proto.EntityDTO, error) {
+	c, marked, all := h.snapshotChanges()
+
+	var dtos []*proto.EntityDTO
+	var err error
+	if all {
+		dtos, err = c.GenerateDTOs()
+	} else {
+		dtos, err = c.generateChangedDTOs(func() entityFilter {
+			return h.dirty.changes(c, marked)
+		})
+	}
+	if err != nil {
+		h.dirty.restore(marked, all)
+		return nil, err
+	}
+	return h.dirty.update(c, dtos), nil
+}
+
+// entityFilter is the set of the entities to build DTOs for; nil is all the entities.
+type entityFilter map[string]bool
+
+func (f entityFilter) has(id string) bool {
+	return f == nil || f[id]
+}
+
+// entityStates returns the states of the entities which change their DTOs without an action: the usage and
+// capacity of the containers and the Pods, the access keys of the Pods and the VNodes, and the total capacity of
+// the VNodes, which is the one of the quotas without limit, keyed by the cluster UUID.
+// The usage and access keys should be set.
+func (c *Cluster) entityStates() map[string]string {
+	result := make(map[string]string)
+	cpu, memory := c.getVNodeCapacity()
+	result[c.UUID] = fmt.Sprint(cpu, memory)
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			result[vhost.UUID] = fmt.Sprint(vhost.accessKeys)
+			for _, pod := range vhost.Pods {
+				result[pod.UUID] = fmt.Sprint(pod.CPU, pod.Memory, pod.accessKeys)
+				for _, container := range pod.Containers {
+					result[container.UUID] = fmt.Sprint(container.CPU, container.Memory, container.QPS, container.ResponseTime)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// entityIds returns the Ids of all the entities with a DTO.
+func (c *Cluster) entityIds() map[string]bool {
+	result := make(map[string]bool)
+	for _, host := range c.Nodes {
+		result[host.UUID] = true
+		for _, vhost := range host.VMs {
+			result[vhost.UUID] = true
+			for _, pod := range vhost.Pods {
+				result[pod.UUID] = true
+				for _, container := range pod.Containers {
+					result[container.UUID] = true
+					if container.App != nil {
+						result[container.App.UUID] = true
+					}
+				}
+			}
+		}
+	}
+	for id := range c.Switches {
+		result[id] = true
+	}
+	for _, service := range c.Services {
+		result[service.UUID] = true
+	}
+	for id := range c.Namespaces {
+		result[id] = true
+	}
+	for id, replicas := range c.getReplicas() {
+		for name := range getContainerSpecs(replicas) {
+			result[GetContainerSpecId(id, name)] = true
+		}
+	}
+	for id := range c.Controllers {
+		result[id] = true
+	}
+	for id := range c.Storages {
+		result[id] = true
+	}
+	return result
+}
+
+// expandChanges adds the entities whose DTOs are changed by the changed entities: a changed container changes its
+// Application, Pod, VNode and Node; a changed Pod changes its containers, VirtualApp, Namespace and WorkloadController;
+// a changed VNode changes its Node and Storage; and a change of the total capacity of the VNodes changes all the
+// Namespaces and WorkloadControllers.
+func (c *Cluster) expandChanges(changed entityFilter) {
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			for _, pod := range vhost.Pods {
+				podChanged := changed[pod.UUID]
+				for _, container := range pod.Containers {
+					appId := ""
+					if container.App != nil {
+						appId = container.App.UUID
+					}
+					if changed[pod.UUID] || changed[container.UUID] || changed[appId] {
+						changed[container.UUID] = true
+						changed[appId] = true
+						podChanged = true
+					}
+				}
+				if !podChanged {
+					continue
+				}
+				changed[pod.UUID] = true
+				changed[pod.Namespace] = true
+				changed[pod.Controller] = true
+				changed[vhost.UUID] = true
+			}
+
+			if changed[vhost.UUID] {
+				changed[host.UUID] = true
+				changed[vhost.Storage] = true
+			}
+		}
+	}
+
+	for _, service := range c.Services {
+		for _, pod := range service.Pods {
+			if changed[pod.UUID] {
+				changed[service.UUID] = true
+			}
+		}
+	}
+
+	if changed[c.UUID] {
+		for id := range c.Namespaces {
+			changed[id] = true
+		}
+		for id := range c.Controllers {
+			changed[id] = true
+		}
+	}
+	delete(changed, "")
+}
+
+// generateChangedDTOs builds the DTOs of the entities changed, as given by changes() after the usage, the access
+// keys and the proxy VMs are set, and of the entities depending on them, see expandChanges().
+func (c *Cluster) generateChangedDTOs(changes func() entityFilter) ([]*proto.EntityDTO, error) {
+	var result []*proto.EntityDTO
+
+	if c.Nodes == nil || len(c.Nodes) < 1 {
+		err := fmt.Errorf("empty cluster[%s/%s].", c.Name, c.UUID)
+		glog.Error(err.Error())
+		return result, err
+	}
+
+	c.prepareDTOs()
+	changed := changes()
+	c.expandChanges(changed)
+
+	// the Nodes are built with their Switch, as GenerateDTOs()
+	hosts := make(map[*Node]*Switch)
+	if c.Switches != nil {
+		for _, networkswitch := range c.Switches {
+			if changed[networkswitch.UUID] {
+				if dto, err := networkswitch.BuildDTO(); err == nil {
+					result = append(result, dto)
+				}
+			}
+			for _, pm := range networkswitch.PMs {
+				hosts[pm] = networkswitch
+			}
+		}
+	} else {
+		for _, host := range c.Nodes {
+			hosts[host] = nil
+		}
+	}
+
+	for host, networkswitch := range hosts {
+		if changed[host.UUID] {
+			if dto, err := host.BuildDTO(networkswitch); err == nil {
+				result = append(result, dto)
+			}
+		}
+		for _, vhost := range host.VMs {
+			if changed[vhost.UUID] {
+				if dto, err := vhost.BuildDTO(host); err == nil {
+					result = append(result, dto)
+				}
+			}
+			for _, pod := range vhost.Pods {
+				if changed[pod.UUID] {
+					if dto, err := pod.BuildDTO(vhost); err == nil {
+						result = append(result, dto)
+					}
+				}
+				for _, container := range pod.Containers {
+					if !changed[container.UUID] {
+						continue
+					}
+					if dto, err := container.BuildDTO(pod); err == nil {
+						result = append(result, dto)
+					}
+					if dto, err := container.BuildAppDTO(pod); err == nil {
+						result = append(result, dto)
+					}
+				}
+			}
+		}
+	}
+
+	if serviceDTOs, err := c.generateServiceDTOs(changed); err != nil {
+		glog.Errorf("failed to generate ServiceDTOs:%v", err)
+	} else {
+		result = append(result, serviceDTOs...)
+	}
+	result = append(result, c.generateNamespaceDTOs(changed)...)
+	result = append(result, c.generateControllerDTOs(changed)...)
+	result = append(result, c.generateStorageDTOs(changed)...)
+
+	c.completeDTOs(result)
+	glog.V(2).Infof("There are %d DTOs of the %d changed entities.", len(result), len(changed))
+	return result, nil
+}
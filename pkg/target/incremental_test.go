@@ -0,0 +1,115 @@
+package target
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func getIds(dtos []*proto.EntityDTO) []string {
+	var ids []string
+	for _, dto := range dtos {
+		ids = append(ids, dto.GetId())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestClusterHandler_DiscoverChangedDTOs(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	// without a full discovery, all the entities are changed
+	dtos, err := h.DiscoverChangedDTOs()
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	all, err := h.DiscoverDTOs()
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	if len(dtos) == 0 || len(dtos) != len(all) {
+		t.Errorf("all %d entities should be changed, but got %d", len(all), len(dtos))
+	}
+
+	if dtos, _ := h.DiscoverChangedDTOs(); len(dtos) > 0 {
+		t.Errorf("no entity should be changed, but got %v", getIds(dtos))
+	}
+
+	if err := h.MovePod("pod-3", "vnode-1"); err != nil {
+		t.Fatalf("failed to move pod-3: %v", err)
+	}
+	dtos, _ = h.DiscoverChangedDTOs()
+	changed := make(map[string]bool)
+	for _, dto := range dtos {
+		changed[dto.GetId()] = true
+	}
+	for _, id := range []string{"pod-3", "vnode-1", "vnode-2"} {
+		if !changed[id] {
+			t.Errorf("%s should be changed after the move, but got %v", id, getIds(dtos))
+		}
+	}
+	for _, id := range []string{"node-2", "pod-1", "container-1"} {
+		if changed[id] {
+			t.Errorf("%s should not be changed: %v", id, getIds(dtos))
+		}
+	}
+	// the changed DTOs are the ones of a full discovery
+	full, _ := h.GenerateClusterDTOs()
+	for _, dto := range dtos {
+		if expected := findDTO(full, dto.GetId()); expected == nil || expected.String() != dto.String() {
+			t.Errorf("wrong DTO of %s: %v", dto.GetId(), dto)
+		}
+	}
+
+	if err := h.SuspendPod("pod-1"); err != nil {
+		t.Fatalf("failed to suspend pod-1: %v", err)
+	}
+	dtos, _ = h.DiscoverChangedDTOs()
+	var deleted []*proto.EntityDTO
+	for _, dto := range dtos {
+		if dto.GetUpdateType() == proto.UpdateType_DELETED {
+			deleted = append(deleted, dto)
+		}
+	}
+	// the pod is deleted with its container and application
+	expected := []string{"app-container-1", "container-1", "pod-1"}
+	if ids := getIds(deleted); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("%v should be deleted, but got %v", expected, ids)
+	}
+	if pod := findDTO(deleted, "pod-1"); pod == nil || pod.GetEntityType() != proto.EntityDTO_CONTAINER_POD {
+		t.Errorf("wrong DELETED DTO of pod-1: %v", pod)
+	}
+
+	if dtos, _ := h.DiscoverChangedDTOs(); len(dtos) > 0 {
+		t.Errorf("no entity should be changed, but got %v", getIds(dtos))
+	}
+}
+
+func TestClusterHandler_DiscoverUsageChanges(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	if _, err := h.DiscoverDTOs(); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+
+	// the usage changed without an action, as by a profile
+	h.containers["container-2"].CPU.Used = 300
+	dtos, err := h.DiscoverChangedDTOs()
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	expected := []string{"app-container-2", "container-2", "node-1", "pod-2", "service-1", "vnode-1"}
+	if ids := getIds(dtos); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("%v should be changed, but got %v", expected, ids)
+	}
+
+	if err := h.ResizeContainerCapacity("container-3", 500, 0); err != nil {
+		t.Fatalf("failed to resize container-3: %v", err)
+	}
+	dtos, _ = h.DiscoverChangedDTOs()
+	expected = []string{"app-container-3", "container-3", "node-1", "pod-3", "service-1", "vnode-2"}
+	if ids := getIds(dtos); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("%v should be changed, but got %v", expected, ids)
+	}
+}
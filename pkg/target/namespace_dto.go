@@ -0,0 +1,214 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// namespace of the Pods without Namespace in ContainerPodData
+const defaultNamespace = "default"
+
+// the commodities and the resources of the quotas, in the order of Namespace.quotas()
+var (
+	quotaTypes = []proto.CommodityDTO_CommodityType{
+		proto.CommodityDTO_VCPU_LIMIT_QUOTA,
+		proto.CommodityDTO_VMEM_LIMIT_QUOTA,
+		proto.CommodityDTO_VCPU_REQUEST_QUOTA,
+		proto.CommodityDTO_VMEM_REQUEST_QUOTA,
+	}
+	quotaResources = []string{ResourceCPULimitQuota, ResourceMemoryLimitQuota, ResourceCPURequestQuota,
+		ResourceMemoryRequestQuota}
+)
+
+func (ns *Namespace) quotas() []*Resource {
+	return []*Resource{&ns.CPULimitQuota, &ns.MemoryLimitQuota, &ns.CPURequestQuota, &ns.MemoryRequestQuota}
+}
+
+// getQuotaUsage returns the limits and requests of the Pod charged to the quotas, in the order of
+// Namespace.quotas(); a container without limit is charged the capacity of the Pod.
+func (pod *Pod) getQuotaUsage() []float64 {
+	cpuLimit := 0.0
+	memLimit := 0.0
+	for _, container := range pod.Containers {
+		cpuLimit += container.CPU.Capacity
+		memLimit += container.Memory.Capacity
+	}
+	cpuReq, memReq := pod.getRequest()
+	return []float64{cpuLimit, memLimit, cpuReq, memReq}
+}
+
+// getResizeQuotaUsage returns the more limits charged to the quotas by resizing the Container, in the order of
+// Namespace.quotas(); a capacity not greater than 0 is not changed.
+func (c *Container) getResizeQuotaUsage(cpu, memory float64) []float64 {
+	result := make([]float64, len(quotaTypes))
+	if cpu > 0 {
+		result[0] = cpu - c.CPU.Capacity
+	}
+	if memory > 0 {
+		result[1] = memory - c.Memory.Capacity
+	}
+	return result
+}
+
+// setQuotaUsage sums the limits and requests of the Pods in each Namespace; the capacity of the containers
+// should be up-to-date.
+func (c *Cluster) setQuotaUsage() {
+	for _, ns := range c.Namespaces {
+		for _, quota := range ns.quotas() {
+			quota.Used = 0
+		}
+	}
+
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			for _, pod := range vhost.Pods {
+				ns, exist := c.Namespaces[pod.Namespace]
+				if !exist {
+					continue
+				}
+				quotas := ns.quotas()
+				for i, used := range pod.getQuotaUsage() {
+					quotas[i].Used += used
+				}
+			}
+		}
+	}
+}
+
+// setNamespaceUsage sets the usage of the quotas of the Namespace of the key only.
+func (c *Cluster) setNamespaceUsage(nsId string) {
+	ns, exist := c.Namespaces[nsId]
+	if !exist {
+		return
+	}
+	quotas := ns.quotas()
+	for _, quota := range quotas {
+		quota.Used = 0
+	}
+
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			for _, pod := range vhost.Pods {
+				if pod.Namespace != nsId {
+					continue
+				}
+				for i, used := range pod.getQuotaUsage() {
+					quotas[i].Used += used
+				}
+			}
+		}
+	}
+}
+
+// admit checks whether the quotas of the Namespace can take the more usage of the entity, in the order of
+// Namespace.quotas(). The usage of the Namespace should be up-to-date.
+func (ns *Namespace) admit(entity string, required []float64) error {
+	for i, quota := range ns.quotas() {
+		if quota.Capacity <= 0 || required[i] <= 0 {
+			continue
+		}
+		if available := quota.Capacity - quota.Used; required[i] > available {
+			return &AdmissionError{
+				Entity:    entity,
+				Host:      ns.Name,
+				Resource:  quotaResources[i],
+				Required:  required[i],
+				Available: available,
+			}
+		}
+	}
+	return nil
+}
+
+// getQuotaCapacities returns the capacities of the quotas sold, in the order of Namespace.quotas();
+// a resource without quota is limited by the given capacity of the cluster.
+func (ns *Namespace) getQuotaCapacities(clusterCPU, clusterMemory float64) []float64 {
+	var result []float64
+	for i, quota := range ns.quotas() {
+		capacity := quota.Capacity
+		if capacity <= 0 {
+			capacity = clusterCPU
+			if i%2 == 1 {
+				capacity = clusterMemory
+			}
+		}
+		result = append(result, capacity)
+	}
+	return result
+}
+
+// BuildDTO builds the Namespace selling the quota commodities.
+func (ns *Namespace) BuildDTO(clusterCPU, clusterMemory float64) (*proto.EntityDTO, error) {
+	var sold []*proto.CommodityDTO
+	capacities := ns.getQuotaCapacities(clusterCPU, clusterMemory)
+	for i, quota := range ns.quotas() {
+		comm, _ := CreateCapacityUsedCommodity(ns.UUID, &Resource{Capacity: capacities[i], Used: quota.Used}, quotaTypes[i])
+		sold = append(sold, comm)
+	}
+
+	entity, err := builder.
+		NewEntityDTOBuilder(proto.EntityDTO_NAMESPACE, ns.UUID).
+		DisplayName(ns.Name).
+		SellsCommodities(sold).
+		WithPowerState(proto.EntityDTO_POWERED_ON).
+		Create()
+
+	if err != nil {
+		msg := fmt.Errorf("Failed to build EntityDTO for namespace(%v): %v", ns.Name, err.Error())
+		glog.Error(msg.Error())
+		return nil, msg
+	}
+
+	return entity, nil
+}
+
+// createQuotaCommoditiesBought creates the quota commodities of the given usage bought from a Namespace,
+// or from a WorkloadController reselling them; the key is the UUID of the Namespace.
+func createQuotaCommoditiesBought(namespace string, usage []float64) []*proto.CommodityDTO {
+	var result []*proto.CommodityDTO
+	for i, used := range usage {
+		comm, _ := builder.NewCommodityDTOBuilder(quotaTypes[i]).
+			Key(namespace).
+			Used(used).
+			Create()
+		result = append(result, comm)
+	}
+	return result
+}
+
+// getVNodeCapacity returns the total capacity of the vnodes, which limits the resources without quota.
+func (c *Cluster) getVNodeCapacity() (cpu, memory float64) {
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			cpu += vhost.CPU.Capacity
+			memory += vhost.Memory.Capacity
+		}
+	}
+	return
+}
+
+// generateNamespaceDTOs builds the Namespaces in the filter.
+func (c *Cluster) generateNamespaceDTOs(filter entityFilter) []*proto.EntityDTO {
+	var result []*proto.EntityDTO
+	if len(c.Namespaces) < 1 {
+		return result
+	}
+
+	cpu, memory := c.getVNodeCapacity()
+	for _, ns := range c.Namespaces {
+		if !filter.has(ns.UUID) {
+			continue
+		}
+		dto, err := ns.BuildDTO(cpu, memory)
+		if err != nil {
+			continue
+		}
+		result = append(result, dto)
+	}
+
+	glog.V(3).Infof("There are %d namespaces, and %d namespaceDTOs.", len(c.Namespaces), len(result))
+	return result
+}
@@ -0,0 +1,146 @@
+package target
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// newTestNamespaceCluster puts pod-1 and pod-3 of the test cluster into namespace ns-1
+func newTestNamespaceCluster(cpuLimitQuota float64) *Cluster {
+	c := newTestCluster()
+	ns := NewNamespace("ns-1", "ns-1")
+	ns.CPULimitQuota.Capacity = cpuLimitQuota
+	c.Namespaces = map[string]*Namespace{ns.UUID: ns}
+	c.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Namespace = ns.UUID
+	c.Nodes["node-1"].VMs["vnode-2"].Pods["pod-3"].Namespace = ns.UUID
+	return c
+}
+
+func findDTO(dtos []*proto.EntityDTO, id string) *proto.EntityDTO {
+	for _, dto := range dtos {
+		if dto.GetId() == id {
+			return dto
+		}
+	}
+	return nil
+}
+
+func TestCluster_NamespaceDTOs(t *testing.T) {
+	dtos, err := newTestNamespaceCluster(1500).GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+
+	ns := findDTO(dtos, "ns-1")
+	if ns == nil || ns.GetEntityType() != proto.EntityDTO_NAMESPACE {
+		t.Fatalf("missing namespace DTO: %v", ns)
+	}
+	sold := make(map[proto.CommodityDTO_CommodityType]*proto.CommodityDTO)
+	for _, comm := range ns.GetCommoditiesSold() {
+		if comm.GetKey() != "ns-1" {
+			t.Errorf("wrong key of %v: %s", comm.GetCommodityType(), comm.GetKey())
+		}
+		sold[comm.GetCommodityType()] = comm
+	}
+	if len(sold) != len(quotaTypes) {
+		t.Fatalf("namespace should sell %d quota commodities, but got %v", len(quotaTypes), sold)
+	}
+	// the limits of pod-1 and pod-3; the CPU of the 2 vnodes without the quota
+	if comm := sold[proto.CommodityDTO_VCPU_LIMIT_QUOTA]; comm.GetCapacity() != 1500 || comm.GetUsed() != 1000 {
+		t.Errorf("wrong CPU limit quota: %v", comm)
+	}
+	if comm := sold[proto.CommodityDTO_VCPU_REQUEST_QUOTA]; comm.GetCapacity() != 4000 || comm.GetUsed() != 100 {
+		t.Errorf("wrong CPU request quota: %v", comm)
+	}
+
+	for id, namespace := range map[string]string{"pod-1": "ns-1", "pod-2": defaultNamespace, "pod-3": "ns-1"} {
+		pod := findDTO(dtos, id)
+		if got := pod.GetContainerPodData().GetNamespace(); got != namespace {
+			t.Errorf("wrong namespace of %s: %s", id, got)
+		}
+
+		var keys []string
+		for _, bought := range pod.GetCommoditiesBought() {
+			if bought.GetProviderType() != proto.EntityDTO_NAMESPACE {
+				continue
+			}
+			if bought.GetProviderId() != "ns-1" {
+				t.Errorf("%s buys from wrong namespace %s", id, bought.GetProviderId())
+			}
+			for _, comm := range bought.GetBought() {
+				keys = append(keys, comm.GetKey())
+			}
+		}
+		expected := []string{"ns-1", "ns-1", "ns-1", "ns-1"}
+		if namespace == defaultNamespace {
+			expected = nil
+		}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("wrong quota commodities bought by %s: %v", id, keys)
+		}
+	}
+}
+
+func TestClusterHandler_NamespaceQuota(t *testing.T) {
+	h := NewClusterHandler(newTestNamespaceCluster(1200))
+
+	_, err := h.ProvisionPod("pod-1", "")
+	var reason *AdmissionError
+	if !errors.As(err, &reason) {
+		t.Fatalf("provision pod-1 beyond the quota should fail with an AdmissionError, but got %v", err)
+	}
+	if reason.Host != "ns-1" || reason.Resource != ResourceCPULimitQuota || reason.Available != 200 {
+		t.Errorf("wrong admission reason: %+v", reason)
+	}
+
+	// pod-2 is in no namespace
+	if _, err := h.ProvisionPod("pod-2", ""); err != nil {
+		t.Errorf("provision pod-2 failed: %v", err)
+	}
+
+	if err := h.ResizeContainerCapacity("container-1", 800, 0); !errors.As(err, &reason) {
+		t.Errorf("resize container-1 beyond the quota should fail with an AdmissionError, but got %v", err)
+	}
+	if err := h.ResizeContainerCapacity("container-1", 700, 0); err != nil {
+		t.Errorf("resize container-1 within the quota failed: %v", err)
+	}
+	if err := h.ResizeContainerCapacity("container-3", 300, 0); err != nil {
+		t.Errorf("resize down container-3 failed: %v", err)
+	}
+}
+
+func TestClusterHandler_ReloadNamespaces(t *testing.T) {
+	h := NewClusterHandler(newTestNamespaceCluster(1200))
+
+	updated := newTestNamespaceCluster(2000)
+	ns := NewNamespace("ns-2", "ns-2")
+	updated.Namespaces[ns.UUID] = ns
+	updated.Nodes["node-1"].VMs["vnode-1"].Pods["pod-2"].Namespace = ns.UUID
+
+	diff := h.Reload(newTestNamespaceCluster(1200), updated)
+	expected := &TopologyDiff{Added: []string{"namespace[ns-2]"}, Changed: []string{"namespace[ns-1]", "pod[pod-2]"}}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	if h.pods["pod-2"].Namespace != "ns-2" {
+		t.Errorf("pod-2 should be moved to ns-2")
+	}
+	if _, err := h.ProvisionPod("pod-1", ""); err != nil {
+		t.Errorf("provision pod-1 within the updated quota failed: %v", err)
+	}
+
+	diff = h.Reload(updated, newTestCluster())
+	expected = &TopologyDiff{Removed: []string{"namespace[ns-1]", "namespace[ns-2]"},
+		Changed: []string{"pod[pod-1]", "pod[pod-2]", "pod[pod-3]"}}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	for id, pod := range h.pods {
+		if pod.Namespace != "" {
+			t.Errorf("%s should be in no namespace, but in %s", id, pod.Namespace)
+		}
+	}
+}
@@ -99,6 +99,11 @@ func (node *Node) createCommoditiesSold() ([]*proto.CommodityDTO, error) {
 	clusterComm, _ := CreateKeyCommodity(node.ClusterId, proto.CommodityDTO_CLUSTER)
 	result = append(result, clusterComm)
 
+	for _, key := range node.datastores {
+		datastoreComm, _ := CreateKeyCommodity(key, proto.CommodityDTO_DATASTORE)
+		result = append(result, datastoreComm)
+	}
+
 	return result, nil
 }
 
@@ -0,0 +1,268 @@
+package target
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// prefixes of the keys of the VMPM_ACCESS commodities between Pods and VNodes
+	accessLabel        = "label:"
+	accessTaint        = "taint:"
+	accessAffinity     = "affinity:"
+	accessAntiAffinity = "anti-affinity:"
+)
+
+// Taint keeps the Pods off the VNode, except the ones tolerating it; in the format of key=value, or key.
+type Taint struct {
+	Key   string
+	Value string
+}
+
+// Toleration tolerates the Taints of the Key; of any value if Value is empty.
+type Toleration struct {
+	Key   string
+	Value string
+}
+
+// ParseTaint parses a taint in the format of key=value, or key.
+func ParseTaint(s string) (Taint, error) {
+	key, value, err := parseKeyValue(s, false)
+	return Taint{Key: key, Value: value}, err
+}
+
+// ParseToleration parses a toleration in the format of key=value, or key for any value.
+func ParseToleration(s string) (Toleration, error) {
+	key, value, err := parseKeyValue(s, false)
+	return Toleration{Key: key, Value: value}, err
+}
+
+// ParseLabel parses a label, or a term of a selector, in the format of key=value.
+func ParseLabel(s string) (key, value string, err error) {
+	return parseKeyValue(s, true)
+}
+
+func parseKeyValue(s string, valueRequired bool) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	key := strings.TrimSpace(parts[0])
+	value := ""
+	if len(parts) == 2 {
+		value = strings.TrimSpace(parts[1])
+	}
+	if key == "" || (valueRequired && len(parts) != 2) {
+		return "", "", fmt.Errorf("invalid '%s', should be key=value", s)
+	}
+	return key, value, nil
+}
+
+func (t Taint) String() string {
+	if t.Value == "" {
+		return t.Key
+	}
+	return t.Key + "=" + t.Value
+}
+
+func (t Toleration) String() string {
+	return Taint(t).String()
+}
+
+func (t Toleration) tolerates(taint Taint) bool {
+	return t.Key == taint.Key && (t.Value == "" || t.Value == taint.Value)
+}
+
+// FormatLabels returns the labels, or the terms of a selector, as key=value in order.
+func FormatLabels(labels map[string]string) []string {
+	result := []string{}
+	for k, v := range labels {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// matches returns whether the labels have all the terms of the selector; an empty selector matches nothing.
+func matches(labels, selector map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for k, v := range selector {
+		if value, exist := labels[k]; !exist || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// PlacementError is the reason why a Pod cannot be placed on a VNode by the labels, affinity and taints.
+type PlacementError struct {
+	Entity string
+	Host   string
+	Reason string
+}
+
+func (e *PlacementError) Error() string {
+	return fmt.Sprintf("[%s] cannot be placed on [%s]: %s", e.Entity, e.Host, e.Reason)
+}
+
+// CheckPlacement checks the node selector, tolerations, affinity and anti-affinity of the Pod on the VNode;
+// the anti-affinity of the Pods on the VNode is checked too. The error is a *PlacementError.
+func (vnode *VNode) CheckPlacement(pod *Pod) error {
+	fail := func(format string, args ...interface{}) error {
+		return &PlacementError{
+			Entity: pod.Name,
+			Host:   vnode.Name,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+
+	for k, v := range pod.NodeSelector {
+		if value, exist := vnode.Labels[k]; !exist || value != v {
+			return fail("node selector %s=%s is not matched", k, v)
+		}
+	}
+
+	for _, taint := range vnode.Taints {
+		if !pod.tolerates(taint) {
+			return fail("taint %v is not tolerated", taint)
+		}
+	}
+
+	others := []*Pod{}
+	for _, p := range vnode.Pods {
+		if p.UUID != pod.UUID {
+			others = append(others, p)
+		}
+	}
+
+	if len(pod.Affinity) > 0 {
+		found := false
+		for _, p := range others {
+			if matches(p.Labels, pod.Affinity) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("no pod matches affinity %v", FormatLabels(pod.Affinity))
+		}
+	}
+
+	for _, p := range others {
+		if matches(p.Labels, pod.AntiAffinity) {
+			return fail("pod[%s] matches anti-affinity %v", p.Name, FormatLabels(pod.AntiAffinity))
+		}
+		if matches(pod.Labels, p.AntiAffinity) {
+			return fail("anti-affinity %v of pod[%s] is matched", FormatLabels(p.AntiAffinity), p.Name)
+		}
+	}
+	return nil
+}
+
+func (pod *Pod) tolerates(taint Taint) bool {
+	for _, t := range pod.Tolerations {
+		if t.tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAccessKeys sets the keys of the VMPM_ACCESS commodities sold by the VNodes and bought by the Pods,
+// so that the market places the Pods as CheckPlacement does:
+// a VNode sells label:<key=value> for each label, and a Pod buys the ones of its node selector;
+// a VNode sells taint:<taint> for each taint of the cluster which it does not have, and a Pod buys the ones
+// it does not tolerate; a VNode sells affinity:<selector> if one of its Pods matches the selector,
+// and anti-affinity:<selector> if none of them does, not counting the Pods with the same anti-affinity.
+// The anti-affinity between the replicas of a Pod is not seen by the market, but it is checked by MovePod.
+func (c *Cluster) setAccessKeys() {
+	var vnodes []*VNode
+	taints := make(map[Taint]bool)
+	affinities := make(map[string]map[string]string)
+	antiAffinities := make(map[string]map[string]string)
+	for _, node := range c.Nodes {
+		for _, vnode := range node.VMs {
+			vnodes = append(vnodes, vnode)
+			for _, taint := range vnode.Taints {
+				taints[taint] = true
+			}
+			for _, pod := range vnode.Pods {
+				if len(pod.Affinity) > 0 {
+					affinities[formatSelector(pod.Affinity)] = pod.Affinity
+				}
+				if len(pod.AntiAffinity) > 0 {
+					antiAffinities[formatSelector(pod.AntiAffinity)] = pod.AntiAffinity
+				}
+			}
+		}
+	}
+
+	for _, vnode := range vnodes {
+		keys := []string{}
+		for _, label := range FormatLabels(vnode.Labels) {
+			keys = append(keys, accessLabel+label)
+		}
+
+		tainted := make(map[Taint]bool)
+		for _, taint := range vnode.Taints {
+			tainted[taint] = true
+		}
+		for taint := range taints {
+			if !tainted[taint] {
+				keys = append(keys, accessTaint+taint.String())
+			}
+		}
+
+		for key, selector := range affinities {
+			for _, pod := range vnode.Pods {
+				if matches(pod.Labels, selector) {
+					keys = append(keys, accessAffinity+key)
+					break
+				}
+			}
+		}
+
+		for key, selector := range antiAffinities {
+			free := true
+			for _, pod := range vnode.Pods {
+				if matches(pod.Labels, selector) && formatSelector(pod.AntiAffinity) != key {
+					free = false
+					break
+				}
+			}
+			if free {
+				keys = append(keys, accessAntiAffinity+key)
+			}
+		}
+		sort.Strings(keys)
+		vnode.accessKeys = keys
+
+		for _, pod := range vnode.Pods {
+			pod.setAccessKeys(taints)
+		}
+	}
+}
+
+func (pod *Pod) setAccessKeys(taints map[Taint]bool) {
+	keys := []string{}
+	for _, label := range FormatLabels(pod.NodeSelector) {
+		keys = append(keys, accessLabel+label)
+	}
+	for taint := range taints {
+		if !pod.tolerates(taint) {
+			keys = append(keys, accessTaint+taint.String())
+		}
+	}
+	if len(pod.Affinity) > 0 {
+		keys = append(keys, accessAffinity+formatSelector(pod.Affinity))
+	}
+	if len(pod.AntiAffinity) > 0 {
+		keys = append(keys, accessAntiAffinity+formatSelector(pod.AntiAffinity))
+	}
+	sort.Strings(keys)
+	pod.accessKeys = keys
+}
+
+func formatSelector(selector map[string]string) string {
+	return strings.Join(FormatLabels(selector), ",")
+}
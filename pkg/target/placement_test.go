@@ -0,0 +1,187 @@
+package target
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func TestVNode_CheckPlacement(t *testing.T) {
+	db := NewPod("db-1", "db-1")
+	db.Labels = map[string]string{"app": "db"}
+	cache := NewPod("cache-1", "cache-1")
+	cache.Labels = map[string]string{"app": "cache"}
+	cache.AntiAffinity = map[string]string{"app": "cache"}
+
+	vnode := NewVNode("vnode-1", "vnode-1")
+	vnode.Labels = map[string]string{"zone": "a", "disk": "ssd"}
+	vnode.Taints = []Taint{{Key: "dedicated", Value: "db"}}
+	vnode.Pods = map[string]*Pod{db.UUID: db, cache.UUID: cache}
+
+	tolerant := []Toleration{{Key: "dedicated"}}
+	tests := []struct {
+		name string
+		pod  *Pod
+		ok   bool
+	}{
+		{"fit", &Pod{NodeSelector: map[string]string{"zone": "a", "disk": "ssd"}, Tolerations: tolerant}, true},
+		{"node selector", &Pod{NodeSelector: map[string]string{"zone": "b"}, Tolerations: tolerant}, false},
+		{"no toleration", &Pod{}, false},
+		{"toleration of another value", &Pod{Tolerations: []Toleration{{Key: "dedicated", Value: "web"}}}, false},
+		{"toleration of the value", &Pod{Tolerations: []Toleration{{Key: "dedicated", Value: "db"}}}, true},
+		{"affinity", &Pod{Affinity: map[string]string{"app": "db"}, Tolerations: tolerant}, true},
+		{"affinity not matched", &Pod{Affinity: map[string]string{"app": "web"}, Tolerations: tolerant}, false},
+		{"anti-affinity", &Pod{AntiAffinity: map[string]string{"app": "db"}, Tolerations: tolerant}, false},
+		{"anti-affinity of other pods", &Pod{Labels: map[string]string{"app": "cache"}, Tolerations: tolerant}, false},
+		{"anti-affinity not matched", &Pod{AntiAffinity: map[string]string{"app": "web"}, Tolerations: tolerant}, true},
+	}
+
+	for _, test := range tests {
+		test.pod.ObjectMeta = ObjectMeta{Name: "pod-x", UUID: "pod-x"}
+		err := vnode.CheckPlacement(test.pod)
+		if test.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		var reason *PlacementError
+		if !test.ok && !errors.As(err, &reason) {
+			t.Errorf("%s: should fail with a PlacementError, but got %v", test.name, err)
+		}
+	}
+
+	// the anti-affinity of a pod does not exclude itself
+	cache.Tolerations = tolerant
+	if err := vnode.CheckPlacement(cache); err != nil {
+		t.Errorf("pod already on the vnode should be placeable: %v", err)
+	}
+}
+
+func TestClusterHandler_MovePodPlacement(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+	h.vnodes["vnode-2"].Taints = []Taint{{Key: "gpu"}}
+
+	err := h.MovePod("pod-1", "vnode-2")
+	var reason *PlacementError
+	if !errors.As(err, &reason) {
+		t.Fatalf("move pod-1 to a tainted vnode should fail with a PlacementError, but got %v", err)
+	}
+	if reason.Entity != "pod-1" || reason.Host != "vnode-2" {
+		t.Errorf("wrong placement reason: %+v", reason)
+	}
+	if h.pods["pod-1"].ProviderID != "vnode-1" {
+		t.Errorf("pod-1 should stay on vnode-1")
+	}
+
+	h.pods["pod-1"].Tolerations = []Toleration{{Key: "gpu"}}
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod-1 with toleration failed: %v", err)
+	}
+
+	// a replica is not placed with the pods of its anti-affinity
+	pod3 := h.pods["pod-3"]
+	pod3.Labels = map[string]string{"app": "db"}
+	pod3.AntiAffinity = map[string]string{"app": "db"}
+	pod3.Tolerations = []Toleration{{Key: "gpu"}}
+	if _, err := h.ProvisionPod("pod-3", ""); !errors.As(err, &reason) {
+		t.Errorf("provision pod-3 beside itself should fail with a PlacementError, but got %v", err)
+	}
+	if _, err := h.ProvisionPod("pod-3", "vnode-1"); err != nil {
+		t.Fatalf("provision pod-3 on vnode-1 failed: %v", err)
+	}
+
+	// neither the replica of pod-3 nor pod-2 can be evicted to vnode-2
+	if err := h.SuspendVirtualMachine("vnode-1"); err == nil {
+		t.Errorf("suspend vnode-1 should fail without a vnode meeting the constraints")
+	}
+}
+
+// accessKeys returns the keys of the VMPM_ACCESS commodities sold by the entities, or bought by them
+func accessKeys(dtos []*proto.EntityDTO, bought bool) map[string][]string {
+	result := make(map[string][]string)
+	collect := func(id string, commodities []*proto.CommodityDTO) {
+		for _, comm := range commodities {
+			if comm.GetCommodityType() == proto.CommodityDTO_VMPM_ACCESS {
+				result[id] = append(result[id], comm.GetKey())
+			}
+		}
+	}
+	for _, dto := range dtos {
+		if bought {
+			for _, b := range dto.GetCommoditiesBought() {
+				collect(dto.GetId(), b.GetBought())
+			}
+		} else {
+			collect(dto.GetId(), dto.GetCommoditiesSold())
+		}
+	}
+	return result
+}
+
+func TestCluster_AccessKeys(t *testing.T) {
+	c := newTestCluster()
+	vnode1 := c.Nodes["node-1"].VMs["vnode-1"]
+	vnode2 := c.Nodes["node-1"].VMs["vnode-2"]
+	vnode1.Labels = map[string]string{"zone": "a"}
+	vnode2.Taints = []Taint{{Key: "gpu"}}
+
+	pod1 := vnode1.Pods["pod-1"]
+	pod2 := vnode1.Pods["pod-2"]
+	pod3 := vnode2.Pods["pod-3"]
+	pod1.Labels = map[string]string{"app": "web"}
+	pod2.NodeSelector = map[string]string{"zone": "a"}
+	pod2.Affinity = map[string]string{"app": "web"}
+	pod3.AntiAffinity = map[string]string{"app": "web"}
+	pod3.Tolerations = []Toleration{{Key: "gpu"}}
+
+	dtos, err := c.GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+
+	sold := accessKeys(dtos, false)
+	expected := map[string][]string{
+		"vnode-1": {"affinity:app=web", "label:zone=a", "taint:gpu"},
+		"vnode-2": {"anti-affinity:app=web"},
+	}
+	for id, keys := range expected {
+		if !reflect.DeepEqual(sold[id], keys) {
+			t.Errorf("wrong keys sold by %s: %v, expected %v", id, sold[id], keys)
+		}
+	}
+
+	bought := accessKeys(dtos, true)
+	expected = map[string][]string{
+		"pod-1": {"taint:gpu"},
+		"pod-2": {"affinity:app=web", "label:zone=a", "taint:gpu"},
+		"pod-3": {"anti-affinity:app=web"},
+	}
+	for id, keys := range expected {
+		if !reflect.DeepEqual(bought[id], keys) {
+			t.Errorf("wrong keys bought by %s: %v, expected %v", id, bought[id], keys)
+		}
+	}
+}
+
+func TestClusterHandler_ReloadPlacement(t *testing.T) {
+	h := NewClusterHandler(newTestCluster())
+
+	updated := newTestCluster()
+	updated.Nodes["node-1"].VMs["vnode-2"].Taints = []Taint{{Key: "gpu"}}
+	updated.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Labels = map[string]string{"app": "web"}
+
+	diff := h.Reload(newTestCluster(), updated)
+	expected := &TopologyDiff{Changed: []string{"pod[pod-1]", "vhost[vnode-2]"}}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	if taints := h.vnodes["vnode-2"].Taints; !reflect.DeepEqual(taints, []Taint{{Key: "gpu"}}) {
+		t.Errorf("wrong taints of vnode-2: %v", taints)
+	}
+	if labels := h.pods["pod-1"].Labels; !reflect.DeepEqual(labels, map[string]string{"app": "web"}) {
+		t.Errorf("wrong labels of pod-1: %v", labels)
+	}
+	if err := h.MovePod("pod-1", "vnode-2"); err == nil {
+		t.Errorf("move pod-1 to the tainted vnode-2 should fail after reload")
+	}
+}
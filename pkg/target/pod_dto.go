@@ -8,19 +8,56 @@ import (
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
+// Clone a Pod with new containers and applications; the new Pod is not placed on any VNode.
+func (pod *Pod) Clone(newName, newId string) *Pod {
+	result := NewPod(newName, newId)
+	result.ProviderID = emptyProvider
+	result.CPU = pod.CPU
+	result.Memory = pod.Memory
+	result.Namespace = pod.Namespace
+	result.Controller = pod.Controller
+	result.Volumes = copyVolumes(pod.Volumes)
+	pod.copyPlacement(result)
+
+	for _, container := range pod.Containers {
+		cid := fmt.Sprintf("%s-%s", pod.getContainerName(container), newId)
+
+		ct := container.Clone(cid, cid)
+		ct.ProviderID = newId
+		ct.GenerateApp()
+		result.Containers = append(result.Containers, ct)
+	}
+
+	return result
+}
+
 func (pod *Pod) BuildDTO(host *VNode) (*proto.EntityDTO, error) {
 	bought, _ := pod.createCommoditiesBought(host.ClusterId)
 	sold, _ := pod.createCommoditiesSold()
 	provider := builder.CreateProvider(proto.EntityDTO_PHYSICAL_MACHINE, host.UUID)
 
-	entity, err := builder.
+	podBuilder := builder.
 		NewEntityDTOBuilder(proto.EntityDTO_CONTAINER_POD, pod.UUID).
 		DisplayName(pod.Name).
 		Provider(provider).
 		BuysCommodities(bought).
 		SellsCommodities(sold).
-		WithPowerState(proto.EntityDTO_POWERED_ON).
-		Create()
+		ContainerPodData(pod.createContainerPodData()).
+		WithPowerState(proto.EntityDTO_POWERED_ON)
+
+	// the quotas of the Namespace are resold by the WorkloadController of the Pod
+	if pod.Controller != "" {
+		podBuilder.AggregatedBy(pod.Controller)
+	}
+	if pod.Namespace != "" {
+		quotaProvider := builder.CreateProvider(proto.EntityDTO_NAMESPACE, pod.Namespace)
+		if pod.Controller != "" {
+			quotaProvider = builder.CreateProvider(proto.EntityDTO_WORKLOAD_CONTROLLER, pod.Controller)
+		}
+		podBuilder.Provider(quotaProvider).BuysCommodities(createQuotaCommoditiesBought(pod.Namespace, pod.getQuotaUsage()))
+	}
+
+	entity, err := podBuilder.Create()
 
 	if err != nil {
 		msg := fmt.Errorf("Failed to build EntityDTO for pod(%v): %v",
@@ -44,6 +81,16 @@ func (pod *Pod) createCommoditiesBought(clusterId string) ([]*proto.CommodityDTO
 
 	clusterComm, _ := CreateKeyCommodity(clusterId, proto.CommodityDTO_CLUSTER)
 	result = append(result, clusterComm)
+
+	if size, _ := pod.getVolumeUsage(); size > 0 {
+		diskComm, _ := CreateResourceCommodityBought(&Resource{Used: size}, proto.CommodityDTO_VSTORAGE)
+		result = append(result, diskComm)
+	}
+
+	for _, key := range pod.accessKeys {
+		accessComm, _ := CreateKeyCommodityBought(key, proto.CommodityDTO_VMPM_ACCESS)
+		result = append(result, accessComm)
+	}
 	return result, nil
 }
 
@@ -66,7 +113,11 @@ func (pod *Pod) createContainerPodData() *proto.EntityDTO_ContainerPodData {
 	// Add IP address in ContainerPodData. Some pods (system pods and daemonset pods) may use the host IP as the pod IP,
 	// in which case the IP address will not be unique (in the k8s cluster) and hence not populated in ContainerPodData.
 	fullName := pod.Name
-	ns := "ns"
+	// the UUID of a Namespace is its name, see topology.ClusterBuilder.buildNamespaces()
+	ns := pod.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
 	return &proto.EntityDTO_ContainerPodData{
 		// Note the port needs to be set if needed
 		IpAddress: &fullName,
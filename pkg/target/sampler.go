@@ -0,0 +1,128 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+	"time"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// usageSample is the used amount of a commodity at a time.
+type usageSample struct {
+	time time.Time
+	used float64
+}
+
+// UsageSampler keeps the history of the used amount of each commodity sold and bought, in a rolling window.
+// The used amount of a commodity is reported as the average of the samples in the window, and the peak as the
+// maximum; a sample is taken in each performance discovery.
+type UsageSampler struct {
+	window time.Duration
+
+	// key=commodity, see sampleKey()
+	history map[string][]usageSample
+	mux     sync.Mutex
+}
+
+func NewUsageSampler(window time.Duration) (*UsageSampler, error) {
+	if window <= 0 {
+		err := fmt.Errorf("sampling window should be positive: %v", window)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	return &UsageSampler{
+		window:  window,
+		history: make(map[string][]usageSample),
+	}, nil
+}
+
+// sampleKey identifies a commodity sold by an entity, or bought from a provider if the provider is not empty.
+func sampleKey(entityId, providerId string, comm *proto.CommodityDTO) string {
+	return fmt.Sprintf("%s/%s/%v/%s", entityId, providerId, comm.GetCommodityType(), comm.GetKey())
+}
+
+// Sample records the used amount of the commodities of the DTOs, and drops the samples out of the window and the
+// history of the commodities not in the DTOs; then sets the used and peak of the commodities from the history.
+func (s *UsageSampler) Sample(dtos []*proto.EntityDTO) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := timeNow()
+	history := make(map[string][]usageSample, len(s.history))
+	for _, dto := range dtos {
+		id := dto.GetId()
+		for _, comm := range dto.GetCommoditiesSold() {
+			s.sample(history, sampleKey(id, "", comm), comm, now)
+		}
+		for _, bought := range dto.GetCommoditiesBought() {
+			for _, comm := range bought.GetBought() {
+				s.sample(history, sampleKey(id, bought.GetProviderId(), comm), comm, now)
+			}
+		}
+	}
+
+	glog.V(3).Infof("sampled %d commodities, %d are dropped.", len(history), len(s.history)-len(history))
+	s.history = history
+}
+
+func (s *UsageSampler) sample(history map[string][]usageSample, key string, comm *proto.CommodityDTO, now time.Time) {
+	if comm.Used == nil {
+		return
+	}
+
+	samples := history[key]
+	if samples == nil {
+		// the samples in the window
+		for _, sample := range s.history[key] {
+			if now.Sub(sample.time) < s.window {
+				samples = append(samples, sample)
+			}
+		}
+	}
+	samples = append(samples, usageSample{time: now, used: comm.GetUsed()})
+	history[key] = samples
+
+	sum := 0.0
+	peak := 0.0
+	for _, sample := range samples {
+		sum += sample.used
+		if sample.used > peak {
+			peak = sample.used
+		}
+	}
+	used := sum / float64(len(samples))
+	comm.Used = &used
+	comm.Peak = &peak
+}
+
+// SetSampler reports the used amount of the commodities as the average in the window of the sampler, and the
+// peak as the maximum; disabled if nil.
+func (h *ClusterHandler) SetSampler(s *UsageSampler) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.sampler = s
+}
+
+func (h *ClusterHandler) sample(dtos []*proto.EntityDTO) {
+	h.mux.RLock()
+	s := h.sampler
+	h.mux.RUnlock()
+
+	if s != nil {
+		s.Sample(dtos)
+	}
+}
+
+// DiscoverPerformanceDTOs builds the DTOs of all the entities, as GenerateClusterDTOs(), with the used amount and
+// peak of the commodities sampled; the other discoveries report the current usage, and take no sample.
+// The base of the incremental discovery is not changed.
+func (h *ClusterHandler) DiscoverPerformanceDTOs() ([]*proto.EntityDTO, error) {
+	dtos, err := h.GenerateClusterDTOs()
+	if err != nil {
+		return dtos, err
+	}
+	h.sample(dtos)
+	return dtos, nil
+}
@@ -0,0 +1,71 @@
+package target
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func TestClusterHandler_SampleUsage(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	if _, err := NewUsageSampler(0); err == nil {
+		t.Errorf("sampling window 0 should be invalid")
+	}
+	sampler, _ := NewUsageSampler(10 * time.Minute)
+	cluster := newTestCluster()
+	h := NewClusterHandler(cluster)
+	h.SetSampler(sampler)
+	container := cluster.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0]
+
+	// only the performance discovery takes samples
+	h.DiscoverDTOs()
+	h.DiscoverChangedDTOs()
+	if len(sampler.history) > 0 {
+		t.Errorf("no sample should be taken by the other discoveries, but got %d", len(sampler.history))
+	}
+
+	check := func(step string, used, peak float64) {
+		dtos, err := h.DiscoverPerformanceDTOs()
+		if err != nil {
+			t.Fatalf("failed to discover: %v", err)
+		}
+		cpu := getCommodities(findDTO(dtos, "container-1"), proto.CommodityDTO_VCPU, false)[""]
+		if cpu.GetUsed() != used || cpu.GetPeak() != peak {
+			t.Errorf("%s: VCPU of container-1 should be %v with peak %v, but got %v", step, used, peak, cpu)
+		}
+		bought := getCommodities(findDTO(dtos, "pod-1"), proto.CommodityDTO_VCPU, true)[""]
+		if bought.GetPeak() < bought.GetUsed() {
+			t.Errorf("%s: peak of VCPU bought by pod-1 should not be less than used: %v", step, bought)
+		}
+	}
+
+	check("first sample", 100, 100)
+
+	now = now.Add(time.Minute)
+	container.CPU.Used = 300
+	check("second sample", 200, 300)
+
+	// the first sample is out of the window
+	now = now.Add(9*time.Minute + 30*time.Second)
+	container.CPU.Used = 100
+	check("third sample", 200, 300)
+
+	now = now.Add(10 * time.Minute)
+	check("after the window", 100, 100)
+
+	// the history of a removed entity is dropped
+	if err := h.SuspendPod("pod-1"); err != nil {
+		t.Fatalf("failed to suspend pod-1: %v", err)
+	}
+	h.DiscoverPerformanceDTOs()
+	for key := range sampler.history {
+		if strings.HasPrefix(key, "container-1/") {
+			t.Errorf("history of container-1 should be dropped, but got %s", key)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// SetProxyVM makes the VNodes proxy VMs, to be replaced by the VMs of the same IP or UUID discovered by another
+// probe; the Pods carry the same property, so that they are stitched to the real VMs. Disabled if empty.
+func (c *Cluster) SetProxyVM(pType stitching.StitchingPropertyType) error {
+	if pType != "" && pType != stitching.UUID && pType != stitching.IP {
+		err := fmt.Errorf("stitching type %s is not supported", pType)
+		glog.Error(err.Error())
+		return err
+	}
+	c.stitchType = pType
+	return nil
+}
+
+// newStitchingManager returns a StitchingManager with the stitching value of each VNode, keyed by its UUID.
+func (c *Cluster) newStitchingManager() *stitching.StitchingManager {
+	m := stitching.NewStitchingManager(c.stitchType)
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			m.StoreStitchingValue(vhost.UUID, vhost.UUID, vhost.IP)
+		}
+	}
+	return m
+}
+
+// setProxyVMs sets the reconciliation metadata of the VNodes, so that their DTOs are built as proxy VMs;
+// the VNodes are not proxy VMs without the stitching type.
+func (c *Cluster) setProxyVMs() error {
+	var meta *proto.EntityDTO_ReplacementEntityMetaData
+	if c.stitchType != "" {
+		var err error
+		if meta, err = c.newStitchingManager().GenerateReconciliationMetaData(); err != nil {
+			glog.Errorf("failed to generate reconciliation metadata: %v", err)
+			return err
+		}
+	}
+
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			vhost.replacement = meta
+		}
+	}
+	return nil
+}
+
+// addStitchingProperties adds the reconciliation property to the VM DTOs, and the stitching property of its
+// VNode to each Pod DTO.
+func (c *Cluster) addStitchingProperties(dtos []*proto.EntityDTO) {
+	m := c.newStitchingManager()
+	podHosts := make(map[string]string)
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			for _, pod := range vhost.Pods {
+				podHosts[pod.UUID] = vhost.UUID
+			}
+		}
+	}
+
+	for _, dto := range dtos {
+		var property *proto.EntityDTO_EntityProperty
+		var err error
+		switch dto.GetEntityType() {
+		case proto.EntityDTO_VIRTUAL_MACHINE:
+			property, err = m.BuildDTOProperty(dto.GetId(), true)
+		case proto.EntityDTO_CONTAINER_POD:
+			property, err = m.BuildDTOProperty(podHosts[dto.GetId()], false)
+		default:
+			continue
+		}
+
+		if err != nil {
+			glog.Errorf("failed to add stitching property to %v[%s]: %v", dto.GetEntityType(), dto.GetId(), err)
+			continue
+		}
+		dto.EntityProperties = append(dto.EntityProperties, property)
+	}
+}
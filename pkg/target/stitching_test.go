@@ -0,0 +1,61 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func getProperty(dto *proto.EntityDTO, name string) string {
+	for _, property := range dto.GetEntityProperties() {
+		if property.GetName() == name {
+			return property.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestCluster_ProxyVMDTOs(t *testing.T) {
+	c := newTestCluster()
+	c.Nodes["node-1"].VMs["vnode-1"].IP = "10.0.0.1"
+	c.Nodes["node-1"].VMs["vnode-2"].IP = "10.0.0.2"
+
+	dtos, err := c.GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+	if vm := findDTO(dtos, "vnode-1"); len(vm.GetEntityProperties()) > 0 || vm.GetReplacementEntityData() != nil ||
+		vm.GetOrigin() == proto.EntityDTO_PROXY {
+		t.Errorf("vnode-1 should not be a proxy VM: %v", vm)
+	}
+
+	if err := c.SetProxyVM("MAC"); err == nil {
+		t.Errorf("stitching type MAC should be invalid")
+	}
+	for ptype, expected := range map[stitching.StitchingPropertyType][]string{
+		stitching.IP:   {"Proxy_VM_IP", "ipAddress", "10.0.0.2"},
+		stitching.UUID: {"Proxy_VM_UUID", "uuid", "vnode-2"},
+	} {
+		if err := c.SetProxyVM(ptype); err != nil {
+			t.Fatalf("failed to set proxy VM: %v", err)
+		}
+		dtos, err := c.DeepCopy().GenerateDTOs()
+		if err != nil {
+			t.Fatalf("failed to generate DTOs: %v", err)
+		}
+
+		vm := findDTO(dtos, "vnode-2")
+		if value := getProperty(vm, expected[0]); value != expected[2] || vm.GetReplacementEntityData() == nil ||
+			vm.GetOrigin() != proto.EntityDTO_PROXY {
+			t.Errorf("vnode-2 should be a proxy VM of %s, but got %v", expected[2], vm)
+		}
+		if value := getProperty(findDTO(dtos, "pod-3"), expected[1]); value != expected[2] {
+			t.Errorf("pod-3 should be stitched to %s, but got %s", expected[2], value)
+		}
+		if node := findDTO(dtos, "node-1"); len(node.GetEntityProperties()) > 0 {
+			t.Errorf("node-1 should have no stitching property: %v", node)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// getVolumeUsage returns the total size and IOPS of the volumes claimed by the Pod.
+func (pod *Pod) getVolumeUsage() (size, iops float64) {
+	for _, volume := range pod.Volumes {
+		size += volume.Size
+		iops += volume.IOPS
+	}
+	return
+}
+
+// getDiskIOPS returns the IOPS of the volumes on the disk of the VNode.
+func (vnode *VNode) getDiskIOPS() float64 {
+	result := 0.0
+	for _, pod := range vnode.Pods {
+		_, iops := pod.getVolumeUsage()
+		result += iops
+	}
+	return result
+}
+
+func (s *Storage) hasNode(nodeId string) bool {
+	for _, id := range s.Nodes {
+		if id == nodeId {
+			return true
+		}
+	}
+	return false
+}
+
+// setStorageUsage sets the usage of the Storages, and the datastores sold by the Nodes:
+// Storage.StorageAmount.Used = sum.VNode.Disk.Capacity; Storage.StorageAccess.Used = sum.Pod.volumes.IOPS.
+// VNode.Disk.Used = sum.Pod.volumes is set with the usage of the VNode.
+func (c *Cluster) setStorageUsage() {
+	for _, s := range c.Storages {
+		s.StorageAmount.Used = 0
+		s.StorageAccess.Used = 0
+	}
+
+	for _, host := range c.Nodes {
+		host.datastores = nil
+		for _, s := range c.Storages {
+			if s.hasNode(host.UUID) {
+				host.datastores = append(host.datastores, s.UUID)
+			}
+		}
+		sort.Strings(host.datastores)
+
+		for _, vhost := range host.VMs {
+			if s, exist := c.Storages[vhost.Storage]; exist {
+				s.StorageAmount.Used += vhost.Disk.Capacity
+				s.StorageAccess.Used += vhost.getDiskIOPS()
+			}
+		}
+	}
+}
+
+// setStorageUsageOf sets the usage of the Storage only, by the disks on it.
+func (c *Cluster) setStorageUsageOf(s *Storage) {
+	s.StorageAmount.Used = 0
+	s.StorageAccess.Used = 0
+	for _, host := range c.Nodes {
+		for _, vhost := range host.VMs {
+			if vhost.Storage == s.UUID {
+				s.StorageAmount.Used += vhost.Disk.Capacity
+				s.StorageAccess.Used += vhost.getDiskIOPS()
+			}
+		}
+	}
+}
+
+// admitDisk checks whether the Storage has room for more disk of the VNode. The usage should be up-to-date.
+func (s *Storage) admitDisk(vnode *VNode, required float64) error {
+	if available := s.StorageAmount.Capacity - s.StorageAmount.Used; required > available {
+		return &AdmissionError{
+			Entity:    vnode.Name,
+			Host:      s.Name,
+			Resource:  ResourceStorageAmount,
+			Required:  required,
+			Available: available,
+		}
+	}
+	return nil
+}
+
+// BuildDTO builds the Storage selling its amount and access to the VNodes, and DSPM_ACCESS to each attached Node.
+func (s *Storage) BuildDTO() (*proto.EntityDTO, error) {
+	var sold []*proto.CommodityDTO
+	amountComm, _ := CreateResourceCommodity(&(s.StorageAmount), proto.CommodityDTO_STORAGE_AMOUNT)
+	sold = append(sold, amountComm)
+
+	accessComm, _ := CreateResourceCommodity(&(s.StorageAccess), proto.CommodityDTO_STORAGE_ACCESS)
+	sold = append(sold, accessComm)
+
+	clusterComm, _ := CreateKeyCommodity(s.ClusterId, proto.CommodityDTO_STORAGE_CLUSTER)
+	sold = append(sold, clusterComm)
+
+	for _, nodeId := range s.Nodes {
+		dspmComm, _ := CreateKeyCommodity(nodeId, proto.CommodityDTO_DSPM_ACCESS)
+		sold = append(sold, dspmComm)
+	}
+
+	entity, err := builder.
+		NewEntityDTOBuilder(proto.EntityDTO_STORAGE, s.UUID).
+		DisplayName(s.Name).
+		SellsCommodities(sold).
+		WithPowerState(proto.EntityDTO_POWERED_ON).
+		Create()
+
+	if err != nil {
+		msg := fmt.Errorf("Failed to build EntityDTO for storage(%v): %v", s.Name, err.Error())
+		glog.Error(msg.Error())
+		return nil, msg
+	}
+
+	storageId := s.UUID
+	entity.EntityData = &proto.EntityDTO_StorageData_{StorageData: &proto.EntityDTO_StorageData{StorageId: &storageId}}
+	return entity, nil
+}
+
+// createStorageCommoditiesBought creates the commodities bought by the disk of the VNode from its Storage;
+// DSPM_ACCESS binds the disk to a Storage attached to the Node of the VNode.
+func (vnode *VNode) createStorageCommoditiesBought(clusterId string) []*proto.CommodityDTO {
+	amountComm, _ := builder.NewCommodityDTOBuilder(proto.CommodityDTO_STORAGE_AMOUNT).Used(vnode.Disk.Capacity).Create()
+	accessComm, _ := builder.NewCommodityDTOBuilder(proto.CommodityDTO_STORAGE_ACCESS).Used(vnode.getDiskIOPS()).Create()
+	clusterComm, _ := CreateKeyCommodityBought(clusterId, proto.CommodityDTO_STORAGE_CLUSTER)
+	dspmComm, _ := CreateKeyCommodityBought(vnode.ProviderID, proto.CommodityDTO_DSPM_ACCESS)
+
+	return []*proto.CommodityDTO{amountComm, accessComm, clusterComm, dspmComm}
+}
+
+// generateStorageDTOs builds the Storages in the filter.
+func (c *Cluster) generateStorageDTOs(filter entityFilter) []*proto.EntityDTO {
+	var result []*proto.EntityDTO
+	if len(c.Storages) < 1 {
+		return result
+	}
+
+	for _, s := range c.Storages {
+		if !filter.has(s.UUID) {
+			continue
+		}
+		dto, err := s.BuildDTO()
+		if err != nil {
+			continue
+		}
+		result = append(result, dto)
+	}
+
+	glog.V(3).Infof("There are %d storages, and %d storageDTOs.", len(c.Storages), len(result))
+	return result
+}
@@ -0,0 +1,214 @@
+package target
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// newTestStorageCluster attaches storage-1 to node-1, and storage-2 to both nodes;
+// the disks of vnode-1 (4096MB) and vnode-2 (2048MB) are on storage-1, and pod-1 claims a volume of 1024MB.
+func newTestStorageCluster() *Cluster {
+	c := newTestCluster()
+
+	newStorage := func(name string, capacity float64, nodes ...string) *Storage {
+		s := NewStorage(name, name)
+		s.StorageAmount.Capacity = capacity
+		s.StorageAccess.Capacity = 1000
+		s.ClusterId = c.UUID
+		s.Nodes = nodes
+		return s
+	}
+	c.Storages = map[string]*Storage{
+		"storage-1": newStorage("storage-1", 10000, "node-1"),
+		"storage-2": newStorage("storage-2", 5000, "node-1", "node-2"),
+	}
+
+	vnodes := c.Nodes["node-1"].VMs
+	vnodes["vnode-1"].Disk.Capacity = 4096
+	vnodes["vnode-1"].Storage = "storage-1"
+	vnodes["vnode-2"].Disk.Capacity = 2048
+	vnodes["vnode-2"].Storage = "storage-1"
+	vnodes["vnode-1"].Pods["pod-1"].Volumes = []Volume{{Name: "data", Size: 1024, IOPS: 100}}
+
+	c.SetResourceAmount()
+	return c
+}
+
+// getCommodities returns the commodities of the type, bought from any provider or sold; key=commodity key
+func getCommodities(dto *proto.EntityDTO, ctype proto.CommodityDTO_CommodityType, bought bool) map[string]*proto.CommodityDTO {
+	comms := dto.GetCommoditiesSold()
+	if bought {
+		comms = nil
+		for _, b := range dto.GetCommoditiesBought() {
+			comms = append(comms, b.GetBought()...)
+		}
+	}
+
+	result := make(map[string]*proto.CommodityDTO)
+	for _, comm := range comms {
+		if comm.GetCommodityType() == ctype {
+			result[comm.GetKey()] = comm
+		}
+	}
+	return result
+}
+
+func TestCluster_StorageDTOs(t *testing.T) {
+	dtos, err := newTestStorageCluster().GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+
+	storage := findDTO(dtos, "storage-1")
+	if storage == nil || storage.GetEntityType() != proto.EntityDTO_STORAGE {
+		t.Fatalf("wrong storage DTO: %v", storage)
+	}
+	if amount := getCommodities(storage, proto.CommodityDTO_STORAGE_AMOUNT, false)[""]; amount.GetUsed() != 6144 || amount.GetCapacity() != 10000 {
+		t.Errorf("wrong StorageAmount of storage-1: %v", amount)
+	}
+	if access := getCommodities(storage, proto.CommodityDTO_STORAGE_ACCESS, false)[""]; access.GetUsed() != 100 {
+		t.Errorf("wrong StorageAccess of storage-1: %v", access)
+	}
+	if dspm := getCommodities(storage, proto.CommodityDTO_DSPM_ACCESS, false); len(dspm) != 1 || dspm["node-1"] == nil {
+		t.Errorf("storage-1 should sell DSPM_ACCESS to node-1 only, but got %v", dspm)
+	}
+
+	node := findDTO(dtos, "node-1")
+	if datastores := getCommodities(node, proto.CommodityDTO_DATASTORE, false); len(datastores) != 2 {
+		t.Errorf("node-1 should sell DATASTORE of both storages, but got %v", datastores)
+	}
+
+	vnode := findDTO(dtos, "vnode-1")
+	if disk := getCommodities(vnode, proto.CommodityDTO_VSTORAGE, false)[""]; disk.GetUsed() != 1024 || disk.GetCapacity() != 4096 || !disk.GetResizable() {
+		t.Errorf("wrong VStorage of vnode-1: %v", disk)
+	}
+	var providers []string
+	for _, b := range vnode.GetCommoditiesBought() {
+		providers = append(providers, b.GetProviderId())
+	}
+	if !reflect.DeepEqual(providers, []string{"node-1", "storage-1"}) {
+		t.Errorf("wrong providers of vnode-1: %v", providers)
+	}
+	if dspm := getCommodities(vnode, proto.CommodityDTO_DSPM_ACCESS, true); dspm["node-1"] == nil {
+		t.Errorf("vnode-1 should buy DSPM_ACCESS of node-1, but got %v", dspm)
+	}
+	if datastore := getCommodities(vnode, proto.CommodityDTO_DATASTORE, true); datastore["storage-1"] == nil {
+		t.Errorf("vnode-1 should buy DATASTORE of storage-1, but got %v", datastore)
+	}
+
+	for id, expected := range map[string]float64{"pod-1": 1024, "pod-2": 0} {
+		disk := getCommodities(findDTO(dtos, id), proto.CommodityDTO_VSTORAGE, true)[""]
+		if disk.GetUsed() != expected {
+			t.Errorf("%s should buy VStorage of %v, but got %v", id, expected, disk)
+		}
+	}
+}
+
+func TestClusterHandler_MoveVirtualMachineStorage(t *testing.T) {
+	h := NewClusterHandler(newTestStorageCluster())
+
+	// storage-1 is not attached to node-2
+	var placement *PlacementError
+	if err := h.MoveVirtualMachine("vnode-2", "node-2"); !errors.As(err, &placement) {
+		t.Errorf("move vnode-2 to node-2 should fail with a PlacementError, but got %v", err)
+	}
+
+	if err := h.MoveVirtualMachineStorage("vnode-1", "storage-2"); err != nil {
+		t.Fatalf("move disk of vnode-1 failed: %v", err)
+	}
+	if s := h.cluster.Storages["storage-2"]; h.vnodes["vnode-1"].Storage != "storage-2" || s.StorageAmount.Used != 4096 {
+		t.Errorf("disk of vnode-1 is not moved to storage-2: %+v", s)
+	}
+
+	var reason *AdmissionError
+	if err := h.MoveVirtualMachineStorage("vnode-2", "storage-2"); !errors.As(err, &reason) {
+		t.Fatalf("move disk beyond the capacity should fail with an AdmissionError, but got %v", err)
+	}
+	if reason.Resource != ResourceStorageAmount || reason.Required != 2048 || reason.Available != 904 {
+		t.Errorf("wrong admission reason: %+v", reason)
+	}
+
+	// storage-2 is attached to node-2
+	if err := h.MoveVirtualMachine("vnode-1", "node-2"); err != nil {
+		t.Errorf("move vnode-1 to node-2 failed: %v", err)
+	}
+	if err := h.MoveVirtualMachineStorage("vnode-1", "storage-1"); !errors.As(err, &placement) {
+		t.Errorf("move disk to a storage not attached should fail with a PlacementError, but got %v", err)
+	}
+	if err := h.MoveVirtualMachineStorage("vnode-1", "storage-x"); err == nil {
+		t.Errorf("move disk to unknown storage should fail")
+	}
+}
+
+func TestClusterHandler_ResizeVirtualMachineDisk(t *testing.T) {
+	h := NewClusterHandler(newTestStorageCluster())
+
+	if err := h.ResizeVirtualMachineDisk("vnode-1", 512); err == nil {
+		t.Errorf("resize disk below the volumes should fail")
+	}
+
+	var reason *AdmissionError
+	if err := h.ResizeVirtualMachineDisk("vnode-1", 8192); !errors.As(err, &reason) {
+		t.Fatalf("resize disk beyond the storage should fail with an AdmissionError, but got %v", err)
+	}
+	if reason.Required != 4096 || reason.Available != 3856 {
+		t.Errorf("wrong admission reason: %+v", reason)
+	}
+
+	if err := h.ResizeVirtualMachineDisk("vnode-1", 2048); err != nil {
+		t.Fatalf("resize disk failed: %v", err)
+	}
+	if used := h.cluster.Storages["storage-1"].StorageAmount.Used; used != 4096 {
+		t.Errorf("storage-1 should have 4096 used, but got %v", used)
+	}
+}
+
+func TestClusterHandler_MovePodWithVolume(t *testing.T) {
+	c := newTestStorageCluster()
+	c.Nodes["node-1"].VMs["vnode-2"].Disk.Capacity = 512
+	h := NewClusterHandler(c)
+
+	var reason *AdmissionError
+	if err := h.MovePod("pod-1", "vnode-2"); !errors.As(err, &reason) || reason.Resource != ResourceVStorage {
+		t.Fatalf("move pod-1 to a small disk should fail for VStorage, but got %v", err)
+	}
+
+	if err := h.ResizeVirtualMachineDisk("vnode-2", 2048); err != nil {
+		t.Fatalf("resize disk failed: %v", err)
+	}
+	if err := h.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod-1 failed: %v", err)
+	}
+	h.cluster.SetResourceAmount()
+	if used := h.vnodes["vnode-2"].Disk.Used; used != 1024 {
+		t.Errorf("the volume should be moved with pod-1, but vnode-2 has %v used", used)
+	}
+}
+
+func TestClusterHandler_ReloadStorages(t *testing.T) {
+	h := NewClusterHandler(newTestStorageCluster())
+
+	updated := newTestStorageCluster()
+	delete(updated.Storages, "storage-2")
+	updated.Storages["storage-1"].StorageAmount.Capacity = 20000
+	updated.Nodes["node-1"].VMs["vnode-2"].Disk.Capacity = 1024
+	updated.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Volumes = nil
+
+	diff := h.Reload(newTestStorageCluster(), updated)
+	expected := &TopologyDiff{
+		Removed: []string{"storage[storage-2]"},
+		Changed: []string{"pod[pod-1]", "storage[storage-1]", "vhost[vnode-2]"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("wrong diff:\n%v\nexpected:\n%v", diff, expected)
+	}
+	if s := h.cluster.Storages["storage-1"]; s.StorageAmount.Capacity != 20000 || s.StorageAmount.Used != 5120 {
+		t.Errorf("storage-1 is not reloaded: %+v", s)
+	}
+	if len(h.pods["pod-1"].Volumes) != 0 || h.vnodes["vnode-1"].Disk.Used != 0 {
+		t.Errorf("volumes of pod-1 are not reloaded")
+	}
+}
@@ -0,0 +1,250 @@
+package target
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TraceRealtime    = "realtime"
+	TraceAccelerated = "accelerated"
+	TraceStep        = "step"
+)
+
+// TraceSample is the recorded usage of a container at Time from the start of the trace;
+// the units are the same as the Container, and a value is NaN if it is not recorded.
+type TraceSample struct {
+	Time time.Duration
+
+	CPU          float64
+	Memory       float64
+	QPS          float64
+	ResponseTime float64
+}
+
+// Trace is the recorded usage of the containers; the key is the name of the container template,
+// which applies to all the containers built from it, or the name of a single container.
+type Trace struct {
+	samples map[string][]TraceSample
+
+	// the distinct times of all the samples, in order
+	times []time.Duration
+}
+
+func NewTrace() *Trace {
+	return &Trace{
+		samples: make(map[string][]TraceSample),
+	}
+}
+
+// Add adds a sample of the container; the samples can be added in any order.
+func (t *Trace) Add(key string, sample TraceSample) {
+	t.samples[key] = append(t.samples[key], sample)
+	t.times = nil
+}
+
+// Keys returns the keys of the containers in the trace, in order.
+func (t *Trace) Keys() []string {
+	keys := []string{}
+	for key := range t.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of distinct times in the trace.
+func (t *Trace) Len() int {
+	t.sort()
+	return len(t.times)
+}
+
+func (t *Trace) sort() {
+	if t.times != nil {
+		return
+	}
+
+	seen := make(map[time.Duration]bool)
+	t.times = []time.Duration{}
+	for _, samples := range t.samples {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Time < samples[j].Time
+		})
+		for _, s := range samples {
+			if !seen[s.Time] {
+				seen[s.Time] = true
+				t.times = append(t.times, s.Time)
+			}
+		}
+	}
+	sort.Slice(t.times, func(i, j int) bool {
+		return t.times[i] < t.times[j]
+	})
+}
+
+// sample returns the last sample of the container at or before time at; false if there is none.
+func (t *Trace) sample(key string, at time.Duration) (TraceSample, bool) {
+	samples := t.samples[key]
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Time > at
+	})
+	if i == 0 {
+		return TraceSample{}, false
+	}
+	return samples[i-1], true
+}
+
+// TracePlayer replays a Trace in the discovery: in real time, accelerated by a speed,
+// or one time of the trace per discovery; the trace is repeated from the beginning after the end.
+// It is shared by the snapshots of a Cluster, so that the position is kept between the discoveries.
+type TracePlayer struct {
+	trace *Trace
+	mode  string
+	speed float64
+
+	// the length of the trace, including the interval after the last sample
+	length time.Duration
+
+	lock sync.Mutex
+	step int
+}
+
+func NewTracePlayer(trace *Trace, mode string, speed float64) (*TracePlayer, error) {
+	if trace.Len() < 1 {
+		return nil, fmt.Errorf("trace is empty")
+	}
+
+	switch mode {
+	case TraceRealtime:
+		speed = 1.0
+	case TraceAccelerated:
+		if speed <= 0 {
+			return nil, fmt.Errorf("speed of accelerated trace should be positive: %v", speed)
+		}
+	case TraceStep:
+	default:
+		return nil, fmt.Errorf("unknown trace mode[%s], should be one of %s, %s, %s", mode,
+			TraceRealtime, TraceAccelerated, TraceStep)
+	}
+
+	p := &TracePlayer{
+		trace: trace,
+		mode:  mode,
+		speed: speed,
+		step:  -1,
+	}
+
+	// the last sample lasts for the average interval
+	if n := len(trace.times); n > 1 {
+		last := trace.times[n-1]
+		p.length = last + (last-trace.times[0])/time.Duration(n-1)
+	}
+	return p, nil
+}
+
+// Next moves to the next time of the trace in the step mode; it is called once in each full discovery.
+func (p *TracePlayer) Next() {
+	if p.mode != TraceStep {
+		return
+	}
+
+	p.lock.Lock()
+	p.step++
+	p.lock.Unlock()
+}
+
+// position returns the time in the trace, after elapsed time from the start of the Cluster
+func (p *TracePlayer) position(elapsed time.Duration) time.Duration {
+	times := p.trace.times
+	if p.mode == TraceStep {
+		p.lock.Lock()
+		step := p.step
+		p.lock.Unlock()
+
+		if step < 0 {
+			step = 0
+		}
+		return times[step%len(times)]
+	}
+
+	at := time.Duration(float64(elapsed) * p.speed)
+	if p.length > 0 {
+		at = times[0] + at%p.length
+	}
+	return at
+}
+
+// checkKeys returns an error if a key of the trace refers to no container of the Cluster
+func (p *TracePlayer) checkKeys(c *Cluster) error {
+	known := make(map[string]bool)
+	c.forEachContainer(func(pod *Pod, container *Container) {
+		known[container.Name] = true
+		known[containerKey(pod, container)] = true
+	})
+
+	unknown := []string{}
+	for _, key := range p.trace.Keys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("trace has unknown containers: %v", unknown)
+	}
+	return nil
+}
+
+// containerKey returns the name of the template of the container, which is named <template>-<podId>
+func containerKey(pod *Pod, container *Container) string {
+	return strings.TrimSuffix(container.Name, "-"+pod.UUID)
+}
+
+// applyTrace sets the usage recorded in the trace at time at; the sample of the container itself
+// goes before the one of its template.
+func (c *Container) applyTrace(trace *Trace, key string, at time.Duration) {
+	sample, exist := trace.sample(c.Name, at)
+	if !exist {
+		if sample, exist = trace.sample(key, at); !exist {
+			return
+		}
+	}
+
+	c.saveBase()
+	if !math.IsNaN(sample.CPU) {
+		c.CPU.Used = limitUsage(sample.CPU, c.CPU.Capacity)
+	}
+	if !math.IsNaN(sample.Memory) {
+		c.Memory.Used = limitUsage(sample.Memory, c.Memory.Capacity)
+	}
+	if !math.IsNaN(sample.QPS) {
+		c.QPS.Used = limitUsage(sample.QPS, c.QPS.Capacity)
+	}
+	if !math.IsNaN(sample.ResponseTime) {
+		c.ResponseTime.Used = sample.ResponseTime
+	}
+}
+
+// SetTrace makes the Cluster replay the usage of the containers from the trace.
+func (c *Cluster) SetTrace(player *TracePlayer) error {
+	if err := player.checkKeys(c); err != nil {
+		return err
+	}
+	c.trace = player
+	return nil
+}
+
+func (c *Cluster) forEachContainer(f func(pod *Pod, container *Container)) {
+	for _, node := range c.Nodes {
+		for _, vnode := range node.VMs {
+			for _, pod := range vnode.Pods {
+				for _, container := range pod.Containers {
+					f(pod, container)
+				}
+			}
+		}
+	}
+}
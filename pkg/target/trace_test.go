@@ -0,0 +1,130 @@
+package target
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// containers of newTestCluster at 0, 5m, 10m; container-2 is only recorded at 5m
+func newTestTrace() *Trace {
+	nan := math.NaN()
+	trace := NewTrace()
+	trace.Add("container-1", TraceSample{Time: 5 * time.Minute, CPU: 200, Memory: nan, QPS: nan, ResponseTime: nan})
+	trace.Add("container-1", TraceSample{Time: 0, CPU: 150, Memory: 200 * 1024, QPS: nan, ResponseTime: 20})
+	trace.Add("container-1", TraceSample{Time: 10 * time.Minute, CPU: 900, Memory: nan, QPS: nan, ResponseTime: nan})
+	trace.Add("container-2", TraceSample{Time: 5 * time.Minute, CPU: 300, Memory: nan, QPS: nan, ResponseTime: nan})
+	return trace
+}
+
+func TestTracePlayer_Position(t *testing.T) {
+	player, err := NewTracePlayer(newTestTrace(), TraceAccelerated, 60)
+	if err != nil {
+		t.Fatalf("failed to create trace player: %v", err)
+	}
+
+	// 3 times, 5 minutes apart: the trace repeats every 15 minutes
+	for elapsed, expected := range map[time.Duration]time.Duration{
+		10 * time.Second: 10 * time.Minute,
+		16 * time.Second: time.Minute,
+	} {
+		if at := player.position(elapsed); at != expected {
+			t.Errorf("position after %v should be %v, but got %v", elapsed, expected, at)
+		}
+	}
+
+	player, err = NewTracePlayer(newTestTrace(), TraceStep, 0)
+	if err != nil {
+		t.Fatalf("failed to create trace player: %v", err)
+	}
+	for _, expected := range []time.Duration{0, 5 * time.Minute, 10 * time.Minute, 0} {
+		player.Next()
+		if at := player.position(time.Hour); at != expected {
+			t.Errorf("position of the step should be %v, but got %v", expected, at)
+		}
+	}
+}
+
+func TestTracePlayer_Failures(t *testing.T) {
+	if _, err := NewTracePlayer(NewTrace(), TraceRealtime, 0); err == nil {
+		t.Errorf("player of empty trace should fail")
+	}
+	if _, err := NewTracePlayer(newTestTrace(), "rewind", 0); err == nil {
+		t.Errorf("player of unknown mode should fail")
+	}
+	if _, err := NewTracePlayer(newTestTrace(), TraceAccelerated, 0); err == nil {
+		t.Errorf("accelerated player without speed should fail")
+	}
+
+	trace := newTestTrace()
+	trace.Add("container-x", TraceSample{})
+	player, _ := NewTracePlayer(trace, TraceStep, 0)
+	if err := newTestCluster().SetTrace(player); err == nil {
+		t.Errorf("trace of unknown container should fail")
+	}
+}
+
+func TestCluster_ReplayTrace(t *testing.T) {
+	cluster := newTestCluster()
+	player, _ := NewTracePlayer(newTestTrace(), TraceStep, 0)
+	if err := cluster.SetTrace(player); err != nil {
+		t.Fatalf("failed to set trace: %v", err)
+	}
+	pods := cluster.Nodes["node-1"].VMs["vnode-1"].Pods
+	c1, c2 := pods["pod-1"].Containers[0], pods["pod-2"].Containers[0]
+
+	// the values not recorded are the usage of the topology
+	expected := []struct {
+		cpu1, mem1, rt1, cpu2 float64
+	}{
+		{150, 200 * 1024, 20, 100},
+		{200, 100 * 1024, 0, 300},
+		// capped at the limit of container-1
+		{500, 100 * 1024, 0, 300},
+		// container-2 goes back to the topology before it is recorded
+		{150, 200 * 1024, 20, 100},
+	}
+	for i, e := range expected {
+		player.Next()
+		if _, err := cluster.DeepCopy().GenerateDTOs(); err != nil {
+			t.Fatalf("failed to generate DTOs: %v", err)
+		}
+		snapshot := cluster.DeepCopy()
+		snapshot.SetResourceAmount()
+		s1 := snapshot.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0]
+		s2 := snapshot.Nodes["node-1"].VMs["vnode-1"].Pods["pod-2"].Containers[0]
+		if s1.CPU.Used != e.cpu1 || s1.Memory.Used != e.mem1 || s2.CPU.Used != e.cpu2 {
+			t.Errorf("step[%d]: usage should be (%v, %v, %v), but got (%v, %v, %v)", i,
+				e.cpu1, e.mem1, e.cpu2, s1.CPU.Used, s1.Memory.Used, s2.CPU.Used)
+		}
+		if s1.App.ResponseTime.Used != e.rt1 {
+			t.Errorf("step[%d]: response time of app should be %v, but got %v", i, e.rt1, s1.App.ResponseTime.Used)
+		}
+	}
+
+	// the live cluster keeps the usage of the topology
+	if cpu, _, _, _ := c1.GetBaseUsage(); cpu != 100 || c2.CPU.Used != 100 {
+		t.Errorf("usage of the topology should be kept, but got %v and %v", cpu, c2.CPU.Used)
+	}
+}
+
+func TestClusterHandler_StepTraceByFullDiscovery(t *testing.T) {
+	cluster := newTestCluster()
+	player, _ := NewTracePlayer(newTestTrace(), TraceStep, 0)
+	if err := cluster.SetTrace(player); err != nil {
+		t.Fatalf("failed to set trace: %v", err)
+	}
+	h := NewClusterHandler(cluster)
+
+	if _, err := h.DiscoverDTOs(); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	h.DiscoverChangedDTOs()
+	h.DiscoverPerformanceDTOs()
+	if _, err := h.DiscoverDTOs(); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	if player.step != 1 {
+		t.Errorf("the trace should be at step 1 after 2 full discoveries, but got %d", player.step)
+	}
+}
@@ -3,6 +3,11 @@ package target
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/discovery/stitching"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
 const (
@@ -10,9 +15,13 @@ const (
 	KindContainer  = "container"
 	KindPod        = "pod"
 	KindVirtualApp = "service"
+	KindNamespace  = "namespace"
+	KindController = "controller"
+	KindSpec       = "containerSpec"
 	KindVNode      = "vhost"
 	KindNode       = "host"
 	KindSwitch     = "switch"
+	KindStorage    = "storage"
 	KindCluster    = "cluster"
 
 	emptyProvider = "None"
@@ -59,6 +68,15 @@ type Container struct {
 	ResponseTime Resource
 
 	App *Application
+
+	// capacity is not set by limit, and it follows the capacity of the Pod.
+	inheritCPU bool
+	inheritMem bool
+
+	// changes the usage over time, from the base usage in the topology
+	Profile *UsageProfile
+	base    *containerUsage
+	walk    *randomWalk
 }
 
 type Pod struct {
@@ -69,6 +87,32 @@ type Pod struct {
 	Memory Resource
 
 	Containers []*Container
+
+	// UUID of the Namespace of the Pod, empty if it is in no Namespace
+	Namespace string
+
+	// UUID of the WorkloadController of the Pod, empty if it is not a replica of any controller
+	Controller string
+
+	// persistent volumes claimed by the Pod, on the disk of its VNode
+	Volumes []Volume
+
+	// placement constraints, see placement.go
+	Labels       map[string]string
+	NodeSelector map[string]string
+	Affinity     map[string]string
+	AntiAffinity map[string]string
+	Tolerations  []Toleration
+
+	// keys of the VMPM_ACCESS commodities bought, set when the DTOs are generated
+	accessKeys []string
+}
+
+// Volume is a persistent volume claimed by a Pod; the size is in MB.
+type Volume struct {
+	Name string
+	Size float64
+	IOPS float64
 }
 
 type VirtualApp struct {
@@ -77,6 +121,26 @@ type VirtualApp struct {
 	Pods []*Pod
 }
 
+// Namespace limits the total limits and requests of its Pods by the quotas; no quota if the Capacity is 0.
+// The Pods refer to the Namespace by Pod.Namespace.
+type Namespace struct {
+	ObjectMeta
+
+	CPULimitQuota      Resource
+	MemoryLimitQuota   Resource
+	CPURequestQuota    Resource
+	MemoryRequestQuota Resource
+}
+
+// WorkloadController manages the replicas of a Pod, e.g., a Deployment; the Pods refer to it by Pod.Controller.
+// The same container of all the replicas is a ContainerSpec, see controller.go.
+type WorkloadController struct {
+	ObjectMeta
+
+	// one of the ControllerTypes
+	ControllerType string
+}
+
 // virtual machine
 type VNode struct {
 	ObjectMeta
@@ -87,8 +151,20 @@ type VNode struct {
 	ClusterId string
 	IP        string
 
+	// the disk holding the volumes of the Pods, in MB; it is on the Storage of the UUID, empty if none
+	Disk    Resource
+	Storage string
+
 	//a map for easy of move/deletion, key=pod.UUID
 	Pods map[string]*Pod
+
+	Labels map[string]string
+	Taints []Taint
+
+	// keys of the VMPM_ACCESS commodities sold, set when the DTOs are generated
+	accessKeys []string
+	// the metadata to be replaced by the real VM if it is a proxy VM, set when the DTOs are generated
+	replacement *proto.EntityDTO_ReplacementEntityMetaData
 }
 
 // physical machine
@@ -106,6 +182,9 @@ type Node struct {
 	//Map for easy of deletion
 	// key = vm.UUID
 	VMs map[string]*VNode
+
+	// keys of the DATASTORE commodities sold, set when the DTOs are generated
+	datastores []string
 }
 
 // network switch
@@ -121,11 +200,43 @@ type Switch struct {
 	PMs map[string]*Node
 }
 
+// Storage is a datastore attached to some Nodes; the disks of the VNodes on these Nodes can be placed on it.
+// StorageAmount is in MB, and StorageAccess is in IOPS. The VNodes refer to it by VNode.Storage.
+type Storage struct {
+	ObjectMeta
+
+	StorageAmount Resource
+	StorageAccess Resource
+
+	ClusterId string
+
+	// UUIDs of the attached Nodes, sorted
+	Nodes []string
+}
+
 type Cluster struct {
 	ObjectMeta
 	Switches map[string]*Switch
 	Nodes    map[string]*Node
 	Services []*VirtualApp
+
+	// key=namespace.UUID
+	Namespaces map[string]*Namespace
+
+	// key=controller.UUID
+	Controllers map[string]*WorkloadController
+
+	// key=storage.UUID
+	Storages map[string]*Storage
+
+	// the start of the UsageProfiles and the Trace
+	start time.Time
+	trace *TracePlayer
+	// the UsageProfiles run this times faster than the real time, see SetProfileSpeed()
+	profileSpeed float64
+
+	// the VNodes are proxy VMs stitched by this property, see SetProxyVM()
+	stitchType stitching.StitchingPropertyType
 }
 
 func NewContainer(name, id string) *Container {
@@ -135,6 +246,7 @@ func NewContainer(name, id string) *Container {
 			Name: name,
 			UUID: id,
 		},
+		walk: &randomWalk{},
 	}
 }
 
@@ -178,6 +290,16 @@ func NewSwitch(name, id string) *Switch {
 	}
 }
 
+func NewStorage(name, id string) *Storage {
+	return &Storage{
+		ObjectMeta: ObjectMeta{
+			Kind: KindStorage,
+			Name: name,
+			UUID: id,
+		},
+	}
+}
+
 func NewApplication(name, id string) *Application {
 	return &Application{
 		ObjectMeta: ObjectMeta{
@@ -198,6 +320,27 @@ func NewVirtualApp(name, id string) *VirtualApp {
 	}
 }
 
+func NewNamespace(name, id string) *Namespace {
+	return &Namespace{
+		ObjectMeta: ObjectMeta{
+			Kind: KindNamespace,
+			Name: name,
+			UUID: id,
+		},
+	}
+}
+
+func NewWorkloadController(name, id, controllerType string) *WorkloadController {
+	return &WorkloadController{
+		ObjectMeta: ObjectMeta{
+			Kind: KindController,
+			Name: name,
+			UUID: id,
+		},
+		ControllerType: controllerType,
+	}
+}
+
 func NewCluster(name, id string) *Cluster {
 	glog.V(2).Infof("VM: CPUOverHead=%d MHz, MemOverHead=%d MB;", defaultOverheadVMCPU, defaultOverheadVMMem/1024)
 	glog.V(2).Infof("PM: CPUOverHead=%d MHz, MemOverHead=%d MB;", defaultOverheadPMCPU, defaultOverheadPMMem/1024)
@@ -208,6 +351,8 @@ func NewCluster(name, id string) *Cluster {
 			Name: name,
 			UUID: id,
 		},
+		start:        timeNow(),
+		profileSpeed: 1,
 	}
 }
 
@@ -249,6 +394,39 @@ func (v *VNode) AddPod(pod *Pod) error {
 	return nil
 }
 
+func (s *VirtualApp) HasPod(podId string) bool {
+	for _, pod := range s.Pods {
+		if pod.UUID == podId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *VirtualApp) AddPod(pod *Pod) error {
+	if s.HasPod(pod.UUID) {
+		err := fmt.Errorf("VirtualApp[%s] add Pod[%s] failed: Pod already exists.", s.Name, pod.Name)
+		glog.Error(err.Error())
+		return err
+	}
+
+	s.Pods = append(s.Pods, pod)
+	return nil
+}
+
+func (s *VirtualApp) DeletePod(podId string) error {
+	for i, pod := range s.Pods {
+		if pod.UUID == podId {
+			s.Pods = append(s.Pods[:i], s.Pods[i+1:]...)
+			return nil
+		}
+	}
+
+	err := fmt.Errorf("VirtualApp[%s] delete Pod[%s] failed: Pod is not found.", s.Name, podId)
+	glog.Error(err.Error())
+	return err
+}
+
 func (n *Node) GetVMNames() string {
 	alist := []string{}
 	for _, vm := range n.VMs {
@@ -289,10 +467,34 @@ func (n *Node) AddVM(vnode *VNode) error {
 func (c *Container) SetCapacity(cpu, memory float64) error {
 	if cpu > 0 {
 		c.CPU.Capacity = cpu
+		c.inheritCPU = false
 	}
 
 	if memory > 0 {
 		c.Memory.Capacity = memory
+		c.inheritMem = false
+	}
+	return nil
+}
+
+// GetLimits returns the CPU and Memory limits of the Container; 0 if it has no limit, and follows the Pod.
+func (c *Container) GetLimits() (cpu, memory float64) {
+	if !c.inheritCPU {
+		cpu = c.CPU.Capacity
+	}
+	if !c.inheritMem {
+		memory = c.Memory.Capacity
+	}
+	return
+}
+
+func (v *VNode) SetCapacity(cpu, memory float64) error {
+	if cpu > 0 {
+		v.CPU.Capacity = cpu
+	}
+
+	if memory > 0 {
+		v.Memory.Capacity = memory
 	}
 	return nil
 }
@@ -0,0 +1,215 @@
+package target
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	ProfileConstant   = "constant"
+	ProfileSine       = "sine"
+	ProfileDiurnal    = "diurnal"
+	ProfileLinear     = "linear"
+	ProfileRandomWalk = "randomWalk"
+	ProfileSpike      = "spike"
+
+	defaultProfilePeriod   = 24 * time.Hour
+	defaultProfileInterval = 10 * time.Minute
+)
+
+// the time used to evaluate the UsageProfiles, changed in tests
+var timeNow = time.Now
+
+// UsageProfile changes the usage of a Container over time, by a factor of the usage in the topology.
+// The factor is 1 for constant; 1 + Amplitude*sin(2*Pi*(t+Phase)/Period) for sine or diurnal;
+// 1 + Growth*t/Period for linear; 1 plus the sum of the normal steps with deviation Step, one step
+// in each Interval, for randomWalk; and for spike, the Factor of a Spike during it, repeated in each Period.
+// The time t is from the start of the Cluster; the usage is kept in [0, capacity].
+type UsageProfile struct {
+	Type string
+
+	Period    time.Duration
+	Amplitude float64
+	Phase     time.Duration
+	Growth    float64
+
+	Step     float64
+	Interval time.Duration
+	Seed     int64
+
+	Spikes []Spike
+}
+
+type Spike struct {
+	// offset in the Period
+	Start    time.Duration
+	Duration time.Duration
+	Factor   float64
+}
+
+// the usage in the topology, before it is changed by the UsageProfile or the Trace
+type containerUsage struct {
+	cpu          float64
+	memory       float64
+	qps          float64
+	responseTime float64
+}
+
+// the state of the randomWalk: the sum of the steps so far. It is shared by the copies of the Container, e.g., in
+// the snapshots of the cluster, so that the steps are not drawn again from the start by each copy.
+type randomWalk struct {
+	mux  sync.Mutex
+	step int64
+	sum  float64
+}
+
+// Check checks the parameters of the profile, and sets the default Period and Interval if they are not set.
+func (p *UsageProfile) Check() error {
+	if p.Period == 0 {
+		p.Period = defaultProfilePeriod
+	}
+	if p.Interval == 0 {
+		p.Interval = defaultProfileInterval
+	}
+	if p.Period < 0 || p.Interval < 0 {
+		return fmt.Errorf("period and interval of profile should be positive")
+	}
+
+	switch p.Type {
+	case ProfileConstant, ProfileSine, ProfileDiurnal, ProfileLinear:
+	case ProfileRandomWalk:
+		if p.Step < 0 {
+			return fmt.Errorf("step of randomWalk profile should not be negative")
+		}
+	case ProfileSpike:
+		for _, spike := range p.Spikes {
+			if spike.Start < 0 || spike.Duration <= 0 || spike.Start+spike.Duration > p.Period {
+				return fmt.Errorf("spike[%v, %v] should be in the period %v", spike.Start, spike.Duration, p.Period)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown profile type[%s], should be one of %s, %s, %s, %s, %s, %s", p.Type,
+			ProfileConstant, ProfileSine, ProfileDiurnal, ProfileLinear, ProfileRandomWalk, ProfileSpike)
+	}
+	return nil
+}
+
+// factor evaluates the profile at time t; key and walk are the identity and the state of the randomWalk.
+func (p *UsageProfile) factor(t time.Duration, key string, walk *randomWalk) float64 {
+	result := 1.0
+	switch p.Type {
+	case ProfileSine, ProfileDiurnal:
+		result = 1.0 + p.Amplitude*math.Sin(2*math.Pi*float64(t+p.Phase)/float64(p.Period))
+	case ProfileLinear:
+		result = 1.0 + p.Growth*float64(t)/float64(p.Period)
+	case ProfileRandomWalk:
+		// the steps are drawn from the key, so the walk is the same however often it is evaluated
+		seed := uint64(p.Seed) ^ hashKey(key)
+		walk.mux.Lock()
+		for last := int64(t / p.Interval); walk.step < last; {
+			walk.step++
+			walk.sum += p.Step * normal(seed, uint64(walk.step))
+		}
+		result = 1.0 + walk.sum
+		walk.mux.Unlock()
+	case ProfileSpike:
+		offset := t % p.Period
+		for _, spike := range p.Spikes {
+			if offset >= spike.Start && offset < spike.Start+spike.Duration {
+				result = spike.Factor
+				break
+			}
+		}
+	}
+	return math.Max(0, result)
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// normal draws the i-th value of the standard normal distribution from the seed, by Box-Muller
+func normal(seed, i uint64) float64 {
+	u1 := uniform(splitmix64(seed + 2*i))
+	u2 := uniform(splitmix64(seed + 2*i + 1))
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+// uniform maps x into (0, 1]
+func uniform(x uint64) float64 {
+	return float64(x>>11+1) / float64(1<<53)
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// SetProfileSpeed makes the UsageProfiles of the Cluster run speed times faster than the real time,
+// e.g., 24 replays a diurnal profile in one hour.
+func (c *Cluster) SetProfileSpeed(speed float64) error {
+	if speed <= 0 {
+		err := fmt.Errorf("speed of usage profiles should be positive: %v", speed)
+		glog.Error(err.Error())
+		return err
+	}
+	c.profileSpeed = speed
+	return nil
+}
+
+// SetProfile makes the usage of the Container change over time; the current usage is the base of the profile.
+func (c *Container) SetProfile(profile *UsageProfile) {
+	c.saveBase()
+	c.Profile = profile
+	c.walk = &randomWalk{}
+}
+
+// saveBase keeps the current usage as the base, before it is changed over time
+func (c *Container) saveBase() {
+	if c.base == nil {
+		c.base = &containerUsage{cpu: c.CPU.Used, memory: c.Memory.Used, qps: c.QPS.Used, responseTime: c.ResponseTime.Used}
+	}
+}
+
+// GetBaseUsage returns the usage in the topology, before it is changed by the profile or the trace.
+func (c *Container) GetBaseUsage() (cpu, memory, qps, responseTime float64) {
+	if c.base == nil {
+		return c.CPU.Used, c.Memory.Used, c.QPS.Used, c.ResponseTime.Used
+	}
+	return c.base.cpu, c.base.memory, c.base.qps, c.base.responseTime
+}
+
+// applyProfile sets the usage at time t from the base, after the capacity is set
+func (c *Container) applyProfile(t time.Duration) {
+	if c.base == nil {
+		return
+	}
+
+	f := 1.0
+	if c.Profile != nil {
+		if c.walk == nil {
+			c.walk = &randomWalk{}
+		}
+		f = c.Profile.factor(t, c.UUID, c.walk)
+	}
+	c.CPU.Used = limitUsage(c.base.cpu*f, c.CPU.Capacity)
+	c.Memory.Used = limitUsage(c.base.memory*f, c.Memory.Capacity)
+	c.QPS.Used = limitUsage(c.base.qps*f, c.QPS.Capacity)
+	c.ResponseTime.Used = c.base.responseTime
+}
+
+// limitUsage keeps the usage under the capacity, if there is one
+func limitUsage(used, capacity float64) float64 {
+	if capacity > 0 {
+		return math.Min(used, capacity)
+	}
+	return used
+}
@@ -0,0 +1,197 @@
+package target
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newProfileContainer(profile *UsageProfile) *Container {
+	c := NewContainer("c", "c-1")
+	c.CPU = Resource{Capacity: 400, Used: 100}
+	c.Memory = Resource{Capacity: 1024 * 1024, Used: 100 * 1024}
+	c.QPS = Resource{Capacity: 100, Used: 20}
+	c.SetProfile(profile)
+	return c
+}
+
+func TestUsageProfile_Factor(t *testing.T) {
+	tests := []struct {
+		profile  UsageProfile
+		t        time.Duration
+		expected float64
+	}{
+		{UsageProfile{Type: ProfileConstant}, 5 * time.Hour, 1},
+		{UsageProfile{Type: ProfileSine, Amplitude: 0.5}, 6 * time.Hour, 1.5},
+		{UsageProfile{Type: ProfileDiurnal, Amplitude: 0.5, Phase: 6 * time.Hour}, 12 * time.Hour, 0.5},
+		{UsageProfile{Type: ProfileSine, Amplitude: 2}, 18 * time.Hour, 0},
+		{UsageProfile{Type: ProfileLinear, Growth: 1, Period: time.Hour}, 90 * time.Minute, 2.5},
+		{UsageProfile{Type: ProfileSpike, Period: time.Hour,
+			Spikes: []Spike{{Start: 10 * time.Minute, Duration: 5 * time.Minute, Factor: 3}}}, 72 * time.Minute, 3},
+		{UsageProfile{Type: ProfileSpike, Period: time.Hour,
+			Spikes: []Spike{{Start: 10 * time.Minute, Duration: 5 * time.Minute, Factor: 3}}}, 75 * time.Minute, 1},
+	}
+
+	for i, test := range tests {
+		if err := test.profile.Check(); err != nil {
+			t.Errorf("test[%d]: check failed: %v", i, err)
+			continue
+		}
+		f := test.profile.factor(test.t, "key", &randomWalk{})
+		if math.Abs(f-test.expected) > 1e-9 {
+			t.Errorf("test[%d]: factor of %s at %v should be %v, but got %v",
+				i, test.profile.Type, test.t, test.expected, f)
+		}
+	}
+}
+
+func TestUsageProfile_RandomWalk(t *testing.T) {
+	profile := &UsageProfile{Type: ProfileRandomWalk, Step: 0.05, Interval: time.Minute, Seed: 7}
+	if err := profile.Check(); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+
+	// evaluated step by step, or at once, the walk is the same
+	walk := &randomWalk{}
+	for m := 0; m <= 60; m++ {
+		profile.factor(time.Duration(m)*time.Minute, "key", walk)
+	}
+	once := profile.factor(time.Hour, "key", &randomWalk{})
+	if f := profile.factor(time.Hour, "key", walk); f != once {
+		t.Errorf("random walk should be deterministic, but got %v and %v", f, once)
+	}
+	if once == 1 {
+		t.Errorf("random walk should move away from 1 in 60 steps")
+	}
+
+	if other := profile.factor(time.Hour, "other", &randomWalk{}); other == once {
+		t.Errorf("random walks of different keys should be different")
+	}
+}
+
+func TestContainer_CloneRandomWalk(t *testing.T) {
+	profile := &UsageProfile{Type: ProfileRandomWalk, Step: 0.05, Interval: time.Minute, Seed: 7}
+	if err := profile.Check(); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	c := newProfileContainer(profile)
+	c.applyProfile(30 * time.Minute)
+
+	// the clone walks by its own Id from the start, not from the state of the original
+	clone := c.Clone("c", "c-2")
+	clone.applyProfile(time.Hour)
+	expected := NewContainer("c", "c-2")
+	expected.CPU = Resource{Capacity: 400, Used: 100}
+	expected.Memory = Resource{Capacity: 1024 * 1024, Used: 100 * 1024}
+	expected.QPS = Resource{Capacity: 100, Used: 20}
+	expected.SetProfile(profile)
+	expected.applyProfile(time.Hour)
+	if clone.CPU.Used != expected.CPU.Used {
+		t.Errorf("usage of the clone should be %v, but got %v", expected.CPU.Used, clone.CPU.Used)
+	}
+}
+
+func TestContainer_SnapshotRandomWalk(t *testing.T) {
+	profile := &UsageProfile{Type: ProfileRandomWalk, Step: 0.05, Interval: time.Minute, Seed: 7}
+	if err := profile.Check(); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	c := newProfileContainer(profile)
+
+	// the copies share the state of the walk, so the steps are drawn once
+	snapshot := c.deepCopy()
+	snapshot.applyProfile(time.Hour)
+	if c.walk.step != 60 {
+		t.Errorf("walk of the snapshot should be shared, but the original is at step %d", c.walk.step)
+	}
+	c.applyProfile(time.Hour)
+	if c.CPU.Used != snapshot.CPU.Used {
+		t.Errorf("usage of the original should be %v, but got %v", snapshot.CPU.Used, c.CPU.Used)
+	}
+}
+
+func TestUsageProfile_CheckFailures(t *testing.T) {
+	if err := (&UsageProfile{Type: "square"}).Check(); err == nil || !strings.Contains(err.Error(), ProfileDiurnal) {
+		t.Errorf("unknown profile type should list %s, but got %v", ProfileDiurnal, err)
+	}
+
+	profiles := []*UsageProfile{
+		{Type: "square"},
+		{Type: ProfileRandomWalk, Step: -1},
+		{Type: ProfileSpike, Period: time.Hour, Spikes: []Spike{{Start: 50 * time.Minute, Duration: 20 * time.Minute}}},
+		{Type: ProfileSine, Period: -time.Hour},
+	}
+
+	for _, p := range profiles {
+		if err := p.Check(); err == nil {
+			t.Errorf("check of profile %+v should fail", p)
+		}
+	}
+}
+
+func TestContainer_ApplyProfile(t *testing.T) {
+	c := newProfileContainer(&UsageProfile{Type: ProfileLinear, Growth: 1, Period: time.Hour})
+
+	c.applyProfile(30 * time.Minute)
+	if c.CPU.Used != 150 || c.Memory.Used != 150*1024 || c.QPS.Used != 30 {
+		t.Errorf("usage should be (150, 150MB, 30), but got (%v, %vMB, %v)", c.CPU.Used, c.Memory.Used/1024, c.QPS.Used)
+	}
+
+	// the usage is kept under the capacity, and the base is not changed
+	c.applyProfile(10 * time.Hour)
+	if c.CPU.Used != 400 || c.QPS.Used != 100 {
+		t.Errorf("usage should be capped at (400, 100), but got (%v, %v)", c.CPU.Used, c.QPS.Used)
+	}
+	if cpu, memory, qps, _ := c.GetBaseUsage(); cpu != 100 || memory != 100*1024 || qps != 20 {
+		t.Errorf("base usage should be (100, 100MB, 20), but got (%v, %vMB, %v)", cpu, memory/1024, qps)
+	}
+}
+
+func TestCluster_SetResourceAmountWithProfile(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	cluster := newTestCluster()
+	container := cluster.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0]
+	container.SetProfile(&UsageProfile{Type: ProfileSine, Amplitude: 0.5, Period: 4 * time.Hour})
+
+	now = now.Add(time.Hour)
+	cluster.SetResourceAmount()
+	if container.CPU.Used != 150 || container.App.CPU.Used != 150 {
+		t.Errorf("usage of container and app should be 150 after 1h, but got %v and %v",
+			container.CPU.Used, container.App.CPU.Used)
+	}
+}
+
+func TestCluster_SetProfileSpeed(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	cluster := newTestCluster()
+	if err := cluster.SetProfileSpeed(0); err == nil {
+		t.Errorf("speed 0 should be invalid")
+	}
+	container := cluster.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0]
+	container.SetProfile(&UsageProfile{Type: ProfileSine, Amplitude: 0.5, Period: 4 * time.Hour})
+
+	// 1h of the profile in 15m
+	if err := cluster.SetProfileSpeed(4); err != nil {
+		t.Fatalf("failed to set speed: %v", err)
+	}
+	now = now.Add(15 * time.Minute)
+	cluster.SetResourceAmount()
+	if container.CPU.Used != 150 {
+		t.Errorf("usage of container should be 150 after 15m at speed 4, but got %v", container.CPU.Used)
+	}
+
+	// the speed is kept by the snapshots
+	snapshot := cluster.DeepCopy()
+	now = now.Add(30 * time.Minute)
+	snapshot.SetResourceAmount()
+	if used := snapshot.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0].CPU.Used; used != 50 {
+		t.Errorf("usage of container should be 50 after 45m at speed 4, but got %v", used)
+	}
+}
@@ -9,20 +9,50 @@ import (
 )
 
 //  ---------- Virtual Machine Node ------------------
+
+// Clone a VNode with the same capacity; the new VNode has no Pod, and is not placed on any Node.
+func (vnode *VNode) Clone(newName, newId, newIP string) *VNode {
+	result := NewVNode(newName, newId)
+	result.ProviderID = emptyProvider
+	result.CPU.Capacity = vnode.CPU.Capacity
+	result.Memory.Capacity = vnode.Memory.Capacity
+	result.ClusterId = vnode.ClusterId
+	result.IP = newIP
+	result.Disk.Capacity = vnode.Disk.Capacity
+	result.Storage = vnode.Storage
+	result.Pods = make(map[string]*Pod)
+	result.Labels = copyLabels(vnode.Labels)
+	result.Taints = copyTaints(vnode.Taints)
+
+	return result
+}
+
 func (vnode *VNode) BuildDTO(pm *Node) (*proto.EntityDTO, error) {
 	sold, _ := vnode.createCommoditiesSold()
 	bought, _ := vnode.createCommoditiesBought()
 	provider := builder.CreateProvider(proto.EntityDTO_PHYSICAL_MACHINE, pm.UUID)
 
-	entity, err := builder.
+	vmBuilder := builder.
 		NewEntityDTOBuilder(proto.EntityDTO_VIRTUAL_MACHINE, vnode.UUID).
 		WithPowerState(proto.EntityDTO_POWERED_ON).
 		DisplayName(vnode.Name).
 		VirtualMachineData(vnode.getVMRData()).
 		Provider(provider).
 		BuysCommodities(bought).
-		SellsCommodities(sold).
-		Create()
+		SellsCommodities(sold)
+
+	// the disk is on a Storage
+	if vnode.Storage != "" {
+		storageProvider := builder.CreateProvider(proto.EntityDTO_STORAGE, vnode.Storage)
+		vmBuilder.Provider(storageProvider).BuysCommodities(vnode.createStorageCommoditiesBought(vnode.ClusterId))
+	}
+
+	// a proxy VM, to be replaced by the real VM
+	if vnode.replacement != nil {
+		vmBuilder.ReplacedBy(vnode.replacement)
+	}
+
+	entity, err := vmBuilder.Create()
 
 	if err != nil {
 		msg := fmt.Errorf("Failed to build EntityDTO for pod(%v): %v",
@@ -54,8 +84,15 @@ func (vnode *VNode) createCommoditiesBought() ([]*proto.CommodityDTO, error) {
 	cpuComm, _ := builder.NewCommodityDTOBuilder(proto.CommodityDTO_CPU).Used(vnode.CPU.Capacity).Create()
 	memComm, _ := builder.NewCommodityDTOBuilder(proto.CommodityDTO_MEM).Used(vnode.Memory.Capacity).Create()
 	clusterComm, _ := CreateKeyCommodityBought(vnode.ClusterId, proto.CommodityDTO_CLUSTER)
+	result := []*proto.CommodityDTO{cpuComm, memComm, clusterComm}
 
-	return []*proto.CommodityDTO{cpuComm, memComm, clusterComm}, nil
+	// the Node should be attached to the Storage of the disk
+	if vnode.Storage != "" {
+		datastoreComm, _ := CreateKeyCommodityBought(vnode.Storage, proto.CommodityDTO_DATASTORE)
+		result = append(result, datastoreComm)
+	}
+
+	return result, nil
 }
 
 func (vnode *VNode) createCommoditiesSold() ([]*proto.CommodityDTO, error) {
@@ -74,6 +111,16 @@ func (vnode *VNode) createCommoditiesSold() ([]*proto.CommodityDTO, error) {
 	clusterComm, _ := CreateKeyCommodity(vnode.ClusterId, proto.CommodityDTO_CLUSTER)
 	result = append(result, clusterComm)
 
+	if vnode.Disk.Capacity > 0 {
+		diskComm, _ := CreateResourceCommodityResize(&(vnode.Disk), proto.CommodityDTO_VSTORAGE, resizeable)
+		result = append(result, diskComm)
+	}
+
+	for _, key := range vnode.accessKeys {
+		accessComm, _ := CreateKeyCommodity(key, proto.CommodityDTO_VMPM_ACCESS)
+		result = append(result, accessComm)
+	}
+
 	return result, nil
 }
 
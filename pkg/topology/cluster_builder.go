@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/turbonomic/virtualCluster/pkg/target"
+	"sort"
+	"strings"
 )
 
 type ClusterBuilder struct {
@@ -12,12 +14,15 @@ type ClusterBuilder struct {
 
 	topology *TargetTopology
 
-	containers map[string]*target.Container
-	pods       map[string]*target.Pod
-	vnodes     map[string]*target.VNode
-	nodes      map[string]*target.Node
-	switches   map[string]*target.Switch
-	services   []*target.VirtualApp
+	containers  map[string]*target.Container
+	pods        map[string]*target.Pod
+	vnodes      map[string]*target.VNode
+	nodes       map[string]*target.Node
+	switches    map[string]*target.Switch
+	storages    map[string]*target.Storage
+	services    []*target.VirtualApp
+	namespaces  map[string]*target.Namespace
+	controllers map[string]*target.WorkloadController
 }
 
 func NewClusterBuilderfromTopology(clusterId, clusterName string, topo *TargetTopology) *ClusterBuilder {
@@ -44,6 +49,11 @@ func NewClusterBuilder(clusterId, clusterName, topoConf string) *ClusterBuilder
 	return NewClusterBuilderfromTopology(clusterId, clusterName, topo)
 }
 
+// Validate returns the problems of the loaded topology; entities with problems may be dropped from the cluster.
+func (b *ClusterBuilder) Validate() Diagnostics {
+	return b.topology.Validate()
+}
+
 func (b *ClusterBuilder) buildContainers() error {
 	containers := make(map[string]*target.Container)
 
@@ -56,6 +66,9 @@ func (b *ClusterBuilder) buildContainers() error {
 		container.ReqMemory = v.ReqMem
 		container.QPS = v.QPS
 		container.ResponseTime = v.ResponseTime
+		if v.Profile != nil {
+			container.SetProfile(v.Profile)
+		}
 
 		containers[k] = container
 		glog.V(4).Infof("container-%+v", container)
@@ -77,8 +90,12 @@ func (b *ClusterBuilder) buildPods() error {
 		containers := []*target.Container{}
 		for i, cname := range v.Containers {
 			if container, exist := allContainers[cname]; exist {
-				// generate a new container with different UUID
+				// generate a new container with different UUID;
+				// a container exported for only this pod is named <containerName>-<podId> already
 				newId := fmt.Sprintf("%s-%s", container.Name, pod.UUID)
+				if strings.HasSuffix(container.Name, "-"+pod.UUID) {
+					newId = container.Name
+				}
 				ct := container.Clone(newId, newId)
 				containers = append(containers, ct)
 			} else {
@@ -101,6 +118,12 @@ func (b *ClusterBuilder) buildPods() error {
 		}
 
 		pod.Containers = containers
+		pod.Labels = copyLabels(v.Labels)
+		pod.NodeSelector = copyLabels(v.NodeSelector)
+		pod.Affinity = copyLabels(v.Affinity)
+		pod.AntiAffinity = copyLabels(v.AntiAffinity)
+		pod.Tolerations = append([]target.Toleration(nil), v.Tolerations...)
+		pod.Volumes = append([]target.Volume(nil), v.Volumes...)
 		result[k] = pod
 		glog.V(4).Infof("pod--%+v", pod)
 	}
@@ -113,6 +136,10 @@ func assignVNode(node *target.VNode, tmp *vnodeTemplate) {
 	node.Memory.Capacity = tmp.Memory
 	node.CPU.Capacity = tmp.CPU
 	node.IP = tmp.IP
+	node.Labels = copyLabels(tmp.Labels)
+	node.Taints = append([]target.Taint(nil), tmp.Taints...)
+	node.Disk.Capacity = tmp.Disk
+	node.Storage = tmp.Storage
 }
 
 //Note: will set VNode resourceAmount in cluster.SetResourceAmount()
@@ -233,6 +260,33 @@ func (b *ClusterBuilder) buildSwitches() error {
 	return nil
 }
 
+// buildStorages builds the storages attached to the nodes; an unknown node is dropped.
+func (b *ClusterBuilder) buildStorages() error {
+	result := make(map[string]*target.Storage)
+
+	for k, v := range b.topology.StorageTemplateMap {
+		storage := target.NewStorage(k, k)
+		storage.StorageAmount.Capacity = v.Capacity
+		storage.StorageAccess.Capacity = v.IOPS
+		storage.ClusterId = b.clusterId
+
+		for i, nodeKey := range v.Nodes {
+			if node, exist := b.nodes[nodeKey]; exist {
+				storage.Nodes = append(storage.Nodes, node.UUID)
+			} else {
+				glog.Warningf("storage[%s]-%dth node[%s] does not exist.", k, i+1, nodeKey)
+			}
+		}
+		sort.Strings(storage.Nodes)
+
+		result[storage.UUID] = storage
+		glog.V(4).Infof("[storage] %+v", storage)
+	}
+
+	b.storages = result
+	return nil
+}
+
 func (b *ClusterBuilder) buildVirtualApp() error {
 	var result []*target.VirtualApp
 
@@ -265,6 +319,67 @@ func (b *ClusterBuilder) buildVirtualApp() error {
 	return nil
 }
 
+// buildNamespaces builds the namespaces with their quotas, and assigns the pods to them;
+// a pod in no namespace is in the default namespace of ContainerPodData.
+func (b *ClusterBuilder) buildNamespaces() error {
+	result := make(map[string]*target.Namespace)
+
+	for k, v := range b.topology.NamespaceTemplateMap {
+		ns := target.NewNamespace(k, k)
+		ns.CPULimitQuota.Capacity = v.LimitCPU
+		ns.MemoryLimitQuota.Capacity = v.LimitMem
+		ns.CPURequestQuota.Capacity = v.ReqCPU
+		ns.MemoryRequestQuota.Capacity = v.ReqMem
+
+		for i, podName := range v.Pods {
+			pod, exist := b.pods[podName]
+			if !exist {
+				glog.Warningf("namespace[%s]-%dth pod[%s] does not exist.", k, i+1, podName)
+				continue
+			}
+			if pod.Namespace != "" {
+				glog.Warningf("pod[%s] is already in namespace[%s], skip namespace[%s].", podName, pod.Namespace, k)
+				continue
+			}
+			pod.Namespace = ns.UUID
+		}
+
+		result[ns.UUID] = ns
+		glog.V(4).Infof("[namespace] %+v", ns)
+	}
+
+	b.namespaces = result
+	return nil
+}
+
+// buildControllers builds the workload controllers, and assigns the pods to them as replicas.
+func (b *ClusterBuilder) buildControllers() error {
+	result := make(map[string]*target.WorkloadController)
+
+	for k, v := range b.topology.ControllerTemplateMap {
+		controller := target.NewWorkloadController(k, k, v.Type)
+
+		for i, podName := range v.Pods {
+			pod, exist := b.pods[podName]
+			if !exist {
+				glog.Warningf("controller[%s]-%dth pod[%s] does not exist.", k, i+1, podName)
+				continue
+			}
+			if pod.Controller != "" {
+				glog.Warningf("pod[%s] is already controlled by controller[%s], skip controller[%s].", podName, pod.Controller, k)
+				continue
+			}
+			pod.Controller = controller.UUID
+		}
+
+		result[controller.UUID] = controller
+		glog.V(4).Infof("[controller] %+v", controller)
+	}
+
+	b.controllers = result
+	return nil
+}
+
 func (b *ClusterBuilder) GenerateCluster() (*target.Cluster, error) {
 	if b.topology == nil {
 		err := fmt.Errorf("need to set topology first.")
@@ -302,16 +417,37 @@ func (b *ClusterBuilder) GenerateCluster() (*target.Cluster, error) {
 		return nil, err
 	}
 
+	if err := b.buildStorages(); err != nil {
+		err := fmt.Errorf("Generate cluster failed: build storages failed: %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
 	if err := b.buildVirtualApp(); err != nil {
 		err := fmt.Errorf("Generate cluster failed: build virtualApp failed: %v", err)
 		glog.Error(err.Error())
 		return nil, err
 	}
 
+	if err := b.buildNamespaces(); err != nil {
+		err := fmt.Errorf("Generate cluster failed: build namespaces failed: %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	if err := b.buildControllers(); err != nil {
+		err := fmt.Errorf("Generate cluster failed: build controllers failed: %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
 	cluster := target.NewCluster(b.clusterName, b.clusterId)
 	cluster.Switches = b.switches
+	cluster.Storages = b.storages
 	cluster.Nodes = b.nodes
 	cluster.Services = b.services
+	cluster.Namespaces = b.namespaces
+	cluster.Controllers = b.controllers
 
 	cluster.CompleteBuild()
 	return cluster, nil
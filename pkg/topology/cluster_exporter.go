@@ -0,0 +1,201 @@
+package topology
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// NewTargetTopologyFromCluster converts the current state of a Cluster back into templates,
+// so that the Cluster built from them is the same as the given one.
+func NewTargetTopologyFromCluster(cluster *target.Cluster) *TargetTopology {
+	t := NewTargetTopology(cluster.UUID)
+
+	var pods []*target.Pod
+	for _, node := range cluster.Nodes {
+		vnodes := []string{}
+		for _, vnode := range node.VMs {
+			vnodes = append(vnodes, vnode.UUID)
+
+			podIds := []string{}
+			for _, pod := range vnode.Pods {
+				podIds = append(podIds, pod.UUID)
+				pods = append(pods, pod)
+			}
+			sort.Strings(podIds)
+
+			t.VNodeTemplateMap[vnode.UUID] = &vnodeTemplate{
+				Key:     vnode.UUID,
+				CPU:     vnode.CPU.Capacity,
+				Memory:  vnode.Memory.Capacity,
+				IP:      vnode.IP,
+				Pods:    podIds,
+				Labels:  copyLabels(vnode.Labels),
+				Taints:  append([]target.Taint(nil), vnode.Taints...),
+				Disk:    vnode.Disk.Capacity,
+				Storage: vnode.Storage,
+			}
+		}
+		sort.Strings(vnodes)
+
+		t.NodeTemplateMap[node.UUID] = &nodeTemplate{
+			Key:    node.UUID,
+			CPU:    node.CPU.Capacity,
+			Memory: node.Memory.Capacity,
+			IP:     node.IP,
+			VMs:    vnodes,
+		}
+	}
+
+	addContainers(t, pods)
+
+	for _, networkswitch := range cluster.Switches {
+		nodes := []string{}
+		for _, node := range networkswitch.PMs {
+			nodes = append(nodes, node.UUID)
+		}
+		sort.Strings(nodes)
+
+		t.SwitchTemplateMap[networkswitch.UUID] = &switchTemplate{
+			Key:               networkswitch.UUID,
+			NetworkThroughput: networkswitch.NetworkThroughput.Capacity,
+			PMs:               nodes,
+		}
+	}
+
+	for _, storage := range cluster.Storages {
+		t.StorageTemplateMap[storage.UUID] = &storageTemplate{
+			Key:      storage.UUID,
+			Capacity: storage.StorageAmount.Capacity,
+			IOPS:     storage.StorageAccess.Capacity,
+			Nodes:    append([]string{}, storage.Nodes...),
+		}
+	}
+
+	for _, service := range cluster.Services {
+		podIds := []string{}
+		for _, pod := range service.Pods {
+			podIds = append(podIds, pod.UUID)
+		}
+
+		t.ServiceTemplateMap[service.UUID] = &serviceTemplate{
+			Key:  service.UUID,
+			Pods: podIds,
+		}
+	}
+
+	for _, ns := range cluster.Namespaces {
+		t.NamespaceTemplateMap[ns.UUID] = &namespaceTemplate{
+			Key:      ns.UUID,
+			LimitCPU: ns.CPULimitQuota.Capacity,
+			LimitMem: ns.MemoryLimitQuota.Capacity,
+			ReqCPU:   ns.CPURequestQuota.Capacity,
+			ReqMem:   ns.MemoryRequestQuota.Capacity,
+			Pods:     []string{},
+		}
+	}
+	// the pods are sorted by addContainers()
+	for _, pod := range pods {
+		if ns, exist := t.NamespaceTemplateMap[pod.Namespace]; exist {
+			ns.Pods = append(ns.Pods, pod.UUID)
+		}
+	}
+
+	for _, controller := range cluster.Controllers {
+		t.ControllerTemplateMap[controller.UUID] = &controllerTemplate{
+			Key:  controller.UUID,
+			Type: controller.ControllerType,
+			Pods: []string{},
+		}
+	}
+	for _, pod := range pods {
+		if controller, exist := t.ControllerTemplateMap[pod.Controller]; exist {
+			controller.Pods = append(controller.Pods, pod.UUID)
+		}
+	}
+
+	return t
+}
+
+// addContainers adds the templates of the containers and the pods.
+// The containers of a pod are named <containerName>-<podId>, see ClusterBuilder.buildPods();
+// containers of the same name share one template, unless they are changed differently, e.g., by resize.
+func addContainers(t *TargetTopology, pods []*target.Pod) {
+	type podContainer struct {
+		container *target.Container
+		template  containerTemplate
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].UUID < pods[j].UUID
+	})
+
+	names := make(map[string][]*podContainer)
+	for _, pod := range pods {
+		for _, container := range pod.Containers {
+			name := strings.TrimSuffix(container.Name, "-"+pod.UUID)
+			cpu, memory := container.GetLimits()
+			usedCPU, usedMemory, usedQPS, usedResponseTime := container.GetBaseUsage()
+			names[name] = append(names[name], &podContainer{
+				container: container,
+				template: containerTemplate{
+					CPU:          target.Resource{Capacity: cpu, Used: usedCPU},
+					Memory:       target.Resource{Capacity: memory, Used: usedMemory},
+					ReqCPU:       container.ReqCPU,
+					ReqMem:       container.ReqMemory,
+					QPS:          target.Resource{Capacity: container.QPS.Capacity, Used: usedQPS},
+					ResponseTime: target.Resource{Capacity: container.ResponseTime.Capacity, Used: usedResponseTime},
+					Profile:      container.Profile,
+				},
+			})
+		}
+	}
+
+	keys := make(map[*target.Container]string)
+	for name, containers := range names {
+		shared := true
+		for _, c := range containers {
+			if c.template != containers[0].template {
+				shared = false
+				break
+			}
+		}
+
+		for _, c := range containers {
+			key := name
+			if !shared {
+				key = c.container.Name
+			}
+			keys[c.container] = key
+
+			template := c.template
+			template.Key = key
+			t.ContainerTemplateMap[key] = &template
+		}
+	}
+
+	for _, pod := range pods {
+		containers := []string{}
+		for _, container := range pod.Containers {
+			containers = append(containers, keys[container])
+		}
+
+		t.PodTemplateMap[pod.UUID] = &podTemplate{
+			Key:          pod.UUID,
+			Containers:   containers,
+			Labels:       copyLabels(pod.Labels),
+			NodeSelector: copyLabels(pod.NodeSelector),
+			Affinity:     copyLabels(pod.Affinity),
+			AntiAffinity: copyLabels(pod.AntiAffinity),
+			Tolerations:  append([]target.Toleration(nil), pod.Tolerations...),
+			Volumes:      append([]target.Volume(nil), pod.Volumes...),
+		}
+	}
+}
+
+// SaveCluster writes the current state of a Cluster into a topology file,
+// in the format selected by the file extension.
+func SaveCluster(cluster *target.Cluster, fname string) error {
+	return NewTargetTopologyFromCluster(cluster).SaveTopology(fname)
+}
@@ -0,0 +1,123 @@
+package topology
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	goproto "github.com/golang/protobuf/proto"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func buildTestCluster(t *testing.T, fname string) *target.Cluster {
+	builder := NewClusterBuilder("clusterId-1", "testCluster", fname)
+	if builder == nil {
+		t.Fatalf("load topology failed: %s", fname)
+	}
+
+	cluster, err := builder.GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to generate cluster: %v", err)
+	}
+	return cluster
+}
+
+// sortedDTOs returns the DTOs in order of Id, and the commodities bought in order of provider,
+// which is random in the EntityDTOBuilder
+func sortedDTOs(t *testing.T, cluster *target.Cluster) []*proto.EntityDTO {
+	dtos, err := cluster.GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+	sort.Slice(dtos, func(i, j int) bool {
+		return dtos[i].GetId() < dtos[j].GetId()
+	})
+	for _, dto := range dtos {
+		bought := dto.CommoditiesBought
+		sort.Slice(bought, func(i, j int) bool {
+			return bought[i].GetProviderId() < bought[j].GetProviderId()
+		})
+	}
+	return dtos
+}
+
+func sameDTOs(a, b []*proto.EntityDTO) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !goproto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSaveCluster(t *testing.T) {
+	handler := target.NewClusterHandler(buildTestCluster(t, testutil.MakeTestPath("conf/topology.conf")))
+
+	// change the cluster by actions
+	if err := handler.ResizeContainerCapacity("containerA-pod-2", 400, 512*1024); err != nil {
+		t.Fatalf("resize container failed: %v", err)
+	}
+	if err := handler.ResizeVirtualMachine("vnode-2", 6000, 0); err != nil {
+		t.Fatalf("resize vnode failed: %v", err)
+	}
+	if _, err := handler.ProvisionPod("pod-3", ""); err != nil {
+		t.Fatalf("provision pod failed: %v", err)
+	}
+	if _, err := handler.ProvisionVirtualMachine("vnode-1", ""); err != nil {
+		t.Fatalf("provision vnode failed: %v", err)
+	}
+	if err := handler.MovePod("pod-1", "vnode-2"); err != nil {
+		t.Fatalf("move pod failed: %v", err)
+	}
+	if err := handler.SuspendPod("pod-2"); err != nil {
+		t.Fatalf("suspend pod failed: %v", err)
+	}
+
+	cluster := handler.Snapshot()
+	expected := sortedDTOs(t, cluster)
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"exported.conf", "exported.yaml", "exported.json"} {
+		fname := filepath.Join(dir, name)
+		if err := SaveCluster(cluster, fname); err != nil {
+			t.Fatalf("save cluster to [%s] failed: %v", name, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if diagnostics := topo.Validate(); len(diagnostics) > 0 {
+			t.Errorf("exported topology[%s] should be valid, but got:\n%v", name, diagnostics.Error())
+		}
+
+		dtos := sortedDTOs(t, buildTestCluster(t, fname))
+		if !sameDTOs(expected, dtos) {
+			t.Errorf("cluster built from [%s] is different from the exported one:\n%v\n%v", name, expected, dtos)
+		}
+	}
+}
+
+func TestSaveClusterWithProfiles(t *testing.T) {
+	fname := testutil.MakeTestPath("conf/profile.topology.conf")
+	expected := loadTestTopology(t, fname)
+
+	// the usage is changed by the profiles in discovery, but the usage in the topology is kept
+	cluster := buildTestCluster(t, fname)
+	if _, err := cluster.GenerateDTOs(); err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+	cluster.SetResourceAmount()
+
+	exported := filepath.Join(testutil.TempDir(t), "exported.yaml")
+	if err := SaveCluster(cluster, exported); err != nil {
+		t.Fatalf("save cluster failed: %v", err)
+	}
+	topo := loadTestTopology(t, exported)
+	if !reflect.DeepEqual(expected.ContainerTemplateMap, topo.ContainerTemplateMap) {
+		t.Errorf("containers are changed after export:\n%+v\n%+v", expected.ContainerTemplateMap, topo.ContainerTemplateMap)
+	}
+}
@@ -0,0 +1,114 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func TestTargetTopology_LoadControllers(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/controller.topology.conf"))
+
+	db := expected.ControllerTemplateMap["db"]
+	if db == nil || db.Type != target.ControllerStatefulSet || !reflect.DeepEqual(db.Pods, []string{"pod-3", "pod-4"}) {
+		t.Errorf("wrong controller db: %+v", db)
+	}
+	if diagnostics := expected.Validate(); len(diagnostics) > 0 {
+		t.Errorf("controller topology should be valid, but got:\n%v", diagnostics.Error())
+	}
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"controller.yaml", "controller.json", "controller.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("controllers in topology[%s] are changed after save and load", name)
+		}
+	}
+
+	// the pods are assigned to the controllers, and exported back
+	cluster, err := NewClusterBuilderfromTopology("cluster-1", "testCluster", expected).GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to build cluster: %v", err)
+	}
+	if c := cluster.Controllers["frontend"]; c == nil || c.ControllerType != target.ControllerDeployment {
+		t.Errorf("wrong controller frontend: %+v", c)
+	}
+	dtos, err := cluster.GenerateDTOs()
+	if err != nil {
+		t.Fatalf("failed to generate DTOs: %v", err)
+	}
+	specs := make(map[string]bool)
+	for _, dto := range dtos {
+		if strings.Contains(dto.GetId(), "/") {
+			specs[dto.GetId()] = true
+		}
+	}
+	expectedSpecs := map[string]bool{"frontend/containerA": true, "frontend/containerB": true, "db/containerC": true}
+	if !reflect.DeepEqual(specs, expectedSpecs) {
+		t.Errorf("wrong containerSpecs: %v", specs)
+	}
+
+	exported := NewTargetTopologyFromCluster(cluster)
+	if !reflect.DeepEqual(exported.ControllerTemplateMap, expected.ControllerTemplateMap) {
+		t.Errorf("controllers are not exported: %+v", exported.ControllerTemplateMap)
+	}
+}
+
+func TestTargetTopology_ValidateControllers(t *testing.T) {
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+container, containerB, 200, 100, 150, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+pod, pod-2, containerA, containerB
+pod, pod-3, containerA
+namespace, ns-1, 0, 0, 0, 0, pod-1
+controller, deploy-1, Deployment, pod-1, pod-2, pod-3
+controller, deploy-2, replicaset, pod-3, pod-x
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1, pod-2, pod-3
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+`
+	fname := filepath.Join(testutil.TempDir(t), "controller.conf")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	topo := loadTestTopology(t, fname)
+	if ctype := topo.ControllerTemplateMap["deploy-2"].Type; ctype != target.ControllerReplicaSet {
+		t.Errorf("controller type should be case-insensitive, but got %s", ctype)
+	}
+	expected := []string{
+		"controller.conf:5: pod[pod-3] is in more than one controller: deploy-1, deploy-2",
+		"controller.conf:7: replicas of controller[deploy-1] are in different namespaces: pod[pod-1] in [ns-1], pod[pod-2] in []",
+		"controller.conf:7: replicas of controller[deploy-1] have different containers: pod[pod-1] [containerA], pod[pod-2] [containerA containerB]",
+		"controller.conf:7: replicas of controller[deploy-1] are in different namespaces: pod[pod-1] in [ns-1], pod[pod-3] in []",
+		"controller.conf:8: controller[deploy-2] refers to unknown pod[pod-x]",
+	}
+	diagnostics := topo.Validate()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(expected), len(diagnostics), diagnostics.Error())
+	}
+	for i, msg := range expected {
+		if !strings.HasSuffix(diagnostics[i].String(), msg) {
+			t.Errorf("problem %d should be [%s], but got [%v]", i, msg, diagnostics[i])
+		}
+	}
+
+	content = strings.Replace(content, "replicaset", "DaemonSet", 1)
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	topo = loadTestTopology(t, fname)
+	diagnostics = topo.Validate()
+	if last := diagnostics[len(diagnostics)-1].String(); !strings.HasSuffix(last, "controller.conf:8: invalid controller type 'DaemonSet', should be one of [Deployment StatefulSet ReplicaSet]") {
+		t.Errorf("unknown controller type should be reported, but got:\n%v", diagnostics.Error())
+	}
+}
@@ -0,0 +1,596 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	distributionFixed   = ""
+	distributionUniform = "uniform"
+	distributionNormal  = "normal"
+	distributionHotspot = "hotspot"
+
+	defaultVNodeIPs = "10.1.1.2"
+	defaultNodeIPs  = "200.1.1.2"
+)
+
+// GeneratorConf defines the templates of a cluster and their replicas, to generate a large topology.
+// Each replica of a node template gets new replicas of its vnodes, and so on down to the containers;
+// services, namespaces, controllers and switches get all the replicas of their pod and node templates.
+// The replicas of the pods of a controller share their containers, whose usage is not changed by the distribution.
+type GeneratorConf struct {
+	// seed of the random usage; the same seed generates the same topology
+	Seed int64 `yaml:"seed" json:"seed"`
+
+	// the IPs allocated to vnodes and nodes in order, e.g., 10.1.1.2-10.1.255.254
+	IPRanges ipRangesEntry `yaml:"ipRanges" json:"ipRanges"`
+
+	// the distribution of the usage of containers without their own distribution
+	Distribution *distributionEntry `yaml:"distribution,omitempty" json:"distribution,omitempty"`
+
+	Containers  []*genContainerEntry `yaml:"containers" json:"containers"`
+	Pods        []*podEntry          `yaml:"pods" json:"pods"`
+	Services    []*serviceEntry      `yaml:"services,omitempty" json:"services,omitempty"`
+	Namespaces  []*namespaceEntry    `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	Controllers []*controllerEntry   `yaml:"controllers,omitempty" json:"controllers,omitempty"`
+	VNodes      []*genVNodeEntry     `yaml:"vnodes" json:"vnodes"`
+	Nodes       []*genNodeEntry      `yaml:"nodes" json:"nodes"`
+	Switches    []*switchEntry       `yaml:"switches,omitempty" json:"switches,omitempty"`
+}
+
+type ipRangesEntry struct {
+	VNode string `yaml:"vnode" json:"vnode"`
+	Node  string `yaml:"node" json:"node"`
+}
+
+// distributionEntry changes the usage of each replica of a container by a random factor: in [1-spread, 1+spread]
+// for uniform; with mean 1 and standard deviation spread for normal; hotFactor for a hotRatio of the replicas,
+// and 1 for the others, for hotspot. The usage is kept in [0, limit].
+type distributionEntry struct {
+	Type      string  `yaml:"type" json:"type"`
+	Spread    float64 `yaml:"spread,omitempty" json:"spread,omitempty"`
+	HotRatio  float64 `yaml:"hotRatio,omitempty" json:"hotRatio,omitempty"`
+	HotFactor float64 `yaml:"hotFactor,omitempty" json:"hotFactor,omitempty"`
+}
+
+type genContainerEntry struct {
+	containerEntry `yaml:",inline"`
+	Distribution   *distributionEntry `yaml:"distribution,omitempty" json:"distribution,omitempty"`
+}
+
+type genVNodeEntry struct {
+	Name   string   `yaml:"name" json:"name"`
+	CPU    float64  `yaml:"cpu" json:"cpu"`
+	Memory float64  `yaml:"memory" json:"memory"`
+	Pods   []string `yaml:"pods" json:"pods"`
+
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Taints []string          `yaml:"taints,omitempty" json:"taints,omitempty"`
+}
+
+type genNodeEntry struct {
+	Name   string   `yaml:"name" json:"name"`
+	CPU    float64  `yaml:"cpu" json:"cpu"`
+	Memory float64  `yaml:"memory" json:"memory"`
+	VNodes []string `yaml:"vnodes" json:"vnodes"`
+
+	// number of replicas, 1 if not set
+	Num int `yaml:"num,omitempty" json:"num,omitempty"`
+}
+
+// NewGeneratorConf reads the templates from a YAML or JSON file.
+func NewGeneratorConf(fname string) (*GeneratorConf, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		glog.Errorf("failed to read file[%s]: %v", fname, err)
+		return nil, err
+	}
+
+	conf := &GeneratorConf{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(conf); err != nil {
+		err := fmt.Errorf("failed to parse file[%s]: %v", fname, err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	return conf, nil
+}
+
+// check checks the references between the templates, and the distributions
+func (c *GeneratorConf) check() error {
+	names := make(map[string]bool)
+	add := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s without name", kind)
+		}
+		if names[kind+"/"+name] {
+			return fmt.Errorf("%s[%s] already exists", kind, name)
+		}
+		names[kind+"/"+name] = true
+		return nil
+	}
+	refer := func(kind, name, memberKind string, members []string) error {
+		for _, member := range members {
+			if !names[memberKind+"/"+member] {
+				return fmt.Errorf("%s[%s] refers to unknown %s[%s]", kind, name, memberKind, member)
+			}
+		}
+		return nil
+	}
+
+	if err := c.Distribution.check(); err != nil {
+		return err
+	}
+	for _, e := range c.Containers {
+		if err := add("container", e.Name); err != nil {
+			return err
+		}
+		if err := e.Distribution.check(); err != nil {
+			return fmt.Errorf("container[%s]: %v", e.Name, err)
+		}
+		if _, err := e.newTemplate(e.Name); err != nil {
+			return err
+		}
+	}
+	for _, e := range c.Pods {
+		if err := add("pod", e.Name); err != nil {
+			return err
+		}
+		if len(e.Containers) < 1 {
+			return fmt.Errorf("pod[%s] has no container", e.Name)
+		}
+		if err := refer("pod", e.Name, "container", e.Containers); err != nil {
+			return err
+		}
+		if err := e.setPlacement(&podTemplate{}); err != nil {
+			return fmt.Errorf("pod[%s]: %v", e.Name, err)
+		}
+	}
+	for _, e := range c.VNodes {
+		if err := add("vnode", e.Name); err != nil {
+			return err
+		}
+		if err := refer("vnode", e.Name, "pod", e.Pods); err != nil {
+			return err
+		}
+		if _, err := parseTaints(e.Taints); err != nil {
+			return fmt.Errorf("vnode[%s]: %v", e.Name, err)
+		}
+	}
+	for _, e := range c.Nodes {
+		if err := add("node", e.Name); err != nil {
+			return err
+		}
+		if e.Num < 0 {
+			return fmt.Errorf("node[%s] has negative num %d", e.Name, e.Num)
+		}
+		if err := refer("node", e.Name, "vnode", e.VNodes); err != nil {
+			return err
+		}
+	}
+	for _, e := range c.Services {
+		if err := add("service", e.Name); err != nil {
+			return err
+		}
+		if err := refer("service", e.Name, "pod", e.Pods); err != nil {
+			return err
+		}
+	}
+	for _, e := range c.Namespaces {
+		if err := add("namespace", e.Name); err != nil {
+			return err
+		}
+		if err := refer("namespace", e.Name, "pod", e.Pods); err != nil {
+			return err
+		}
+	}
+	for _, e := range c.Controllers {
+		if err := add("controller", e.Name); err != nil {
+			return err
+		}
+		if _, err := target.ParseControllerType(e.Type); err != nil {
+			return fmt.Errorf("controller[%s] has %v", e.Name, err)
+		}
+		if err := refer("controller", e.Name, "pod", e.Pods); err != nil {
+			return err
+		}
+	}
+	for _, e := range c.Switches {
+		if err := add("switch", e.Name); err != nil {
+			return err
+		}
+		if err := refer("switch", e.Name, "node", e.Nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *distributionEntry) check() error {
+	if d == nil {
+		return nil
+	}
+	switch d.Type {
+	case distributionFixed, distributionUniform, distributionNormal:
+	case distributionHotspot:
+		if d.HotRatio < 0 || d.HotRatio > 1 {
+			return fmt.Errorf("hotRatio %v should be in [0, 1]", d.HotRatio)
+		}
+		if d.HotFactor <= 0 {
+			return fmt.Errorf("hotFactor %v of hotspot should be positive", d.HotFactor)
+		}
+	default:
+		return fmt.Errorf("unknown distribution type[%s], should be uniform, normal or hotspot", d.Type)
+	}
+	if d.Spread < 0 {
+		return fmt.Errorf("spread %v should not be negative", d.Spread)
+	}
+	return nil
+}
+
+// factor draws the factor of the usage of a replica
+func (d *distributionEntry) factor(r *rand.Rand) float64 {
+	if d == nil {
+		return 1.0
+	}
+	switch d.Type {
+	case distributionUniform:
+		return 1.0 + (2*r.Float64()-1)*d.Spread
+	case distributionNormal:
+		return 1.0 + r.NormFloat64()*d.Spread
+	case distributionHotspot:
+		if r.Float64() < d.HotRatio {
+			return d.HotFactor
+		}
+	}
+	return 1.0
+}
+
+// Generate generates the templates of all the replicas.
+func (c *GeneratorConf) Generate(clusterId string) (*TargetTopology, error) {
+	if err := c.check(); err != nil {
+		err := fmt.Errorf("invalid generator conf: %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	g, err := newGenerator(c, clusterId)
+	if err != nil {
+		glog.Errorf("failed to generate topology: %v", err)
+		return nil, err
+	}
+
+	if err := g.generate(); err != nil {
+		err := fmt.Errorf("failed to generate topology: %v", err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	g.topology.PrintTemplateInfo()
+	return g.topology, nil
+}
+
+type generator struct {
+	conf     *GeneratorConf
+	rand     *rand.Rand
+	topology *TargetTopology
+
+	vnodeIPs *ipRange
+	nodeIPs  *ipRange
+
+	// the number of replicas of each template, key=<kind>/<template>
+	counters map[string]int
+	// the replicas of each template, key=template
+	pods  map[string][]string
+	nodes map[string][]string
+
+	// the containers shared by the replicas of the pod templates of controllers, key=<pod>/<container>
+	shared map[string]string
+}
+
+func newGenerator(conf *GeneratorConf, clusterId string) (*generator, error) {
+	vnodeIPs, err := newIPRange(conf.IPRanges.VNode, defaultVNodeIPs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range of vnodes: %v", err)
+	}
+	nodeIPs, err := newIPRange(conf.IPRanges.Node, defaultNodeIPs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range of nodes: %v", err)
+	}
+
+	return &generator{
+		conf:     conf,
+		rand:     rand.New(rand.NewSource(conf.Seed)),
+		topology: NewTargetTopology(clusterId),
+		vnodeIPs: vnodeIPs,
+		nodeIPs:  nodeIPs,
+		counters: make(map[string]int),
+		pods:     make(map[string][]string),
+		nodes:    make(map[string][]string),
+		shared:   make(map[string]string),
+	}, nil
+}
+
+// the templates are generated in the order of the file, so that the random draws are repeatable
+func (g *generator) generate() error {
+	for _, e := range g.conf.Nodes {
+		num := e.Num
+		if num == 0 {
+			num = 1
+		}
+		for i := 0; i < num; i++ {
+			if _, err := g.generateNode(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, e := range g.conf.Services {
+		service := &serviceTemplate{Key: e.Name, Pods: []string{}}
+		for _, pod := range e.Pods {
+			service.Pods = append(service.Pods, g.pods[pod]...)
+		}
+		g.topology.ServiceTemplateMap[service.Key] = service
+	}
+
+	for _, e := range g.conf.Namespaces {
+		ns := &namespaceTemplate{
+			Key:      e.Name,
+			LimitCPU: e.Limits.CPU,
+			LimitMem: e.Limits.Memory * 1024.0,
+			ReqCPU:   e.Requests.CPU,
+			ReqMem:   e.Requests.Memory * 1024.0,
+			Pods:     []string{},
+		}
+		for _, pod := range e.Pods {
+			ns.Pods = append(ns.Pods, g.pods[pod]...)
+		}
+		g.topology.NamespaceTemplateMap[ns.Key] = ns
+	}
+
+	for _, e := range g.conf.Controllers {
+		ctype, _ := target.ParseControllerType(e.Type)
+		controller := &controllerTemplate{Key: e.Name, Type: ctype, Pods: []string{}}
+		for _, pod := range e.Pods {
+			controller.Pods = append(controller.Pods, g.pods[pod]...)
+		}
+		g.topology.ControllerTemplateMap[controller.Key] = controller
+	}
+
+	for _, e := range g.conf.Switches {
+		networkswitch := &switchTemplate{Key: e.Name, NetworkThroughput: e.NetworkThroughput, PMs: []string{}}
+		for _, node := range e.Nodes {
+			networkswitch.PMs = append(networkswitch.PMs, g.nodes[node]...)
+		}
+		g.topology.SwitchTemplateMap[networkswitch.Key] = networkswitch
+	}
+	return nil
+}
+
+func (g *generator) nextName(kind, template string) string {
+	key := kind + "/" + template
+	g.counters[key]++
+	return fmt.Sprintf("%s-%d", template, g.counters[key])
+}
+
+func (g *generator) generateNode(e *genNodeEntry) (string, error) {
+	name := g.nextName("node", e.Name)
+	vnodes := []string{}
+	for _, key := range e.VNodes {
+		vnode, err := g.generateVNode(g.findVNode(key))
+		if err != nil {
+			return "", err
+		}
+		vnodes = append(vnodes, vnode)
+	}
+
+	ip, err := g.nodeIPs.allocate()
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate IP for node[%s]: %v", name, err)
+	}
+
+	g.topology.NodeTemplateMap[name] = &nodeTemplate{
+		Key:    name,
+		CPU:    e.CPU,
+		Memory: e.Memory * 1024.0,
+		IP:     ip,
+		VMs:    vnodes,
+	}
+	g.nodes[e.Name] = append(g.nodes[e.Name], name)
+	return name, nil
+}
+
+func (g *generator) generateVNode(e *genVNodeEntry) (string, error) {
+	name := g.nextName("vnode", e.Name)
+	pods := []string{}
+	for _, key := range e.Pods {
+		pods = append(pods, g.generatePod(g.findPod(key)))
+	}
+
+	ip, err := g.vnodeIPs.allocate()
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate IP for vnode[%s]: %v", name, err)
+	}
+
+	// the taints are checked in GeneratorConf.check()
+	taints, _ := parseTaints(e.Taints)
+	g.topology.VNodeTemplateMap[name] = &vnodeTemplate{
+		Key:    name,
+		CPU:    e.CPU,
+		Memory: e.Memory * 1024.0,
+		IP:     ip,
+		Pods:   pods,
+		Labels: copyLabels(e.Labels),
+		Taints: taints,
+	}
+	return name, nil
+}
+
+func (g *generator) generatePod(e *podEntry) string {
+	name := g.nextName("pod", e.Name)
+	controlled := g.isControlled(e.Name)
+	containers := []string{}
+	for _, key := range e.Containers {
+		if !controlled {
+			containers = append(containers, g.generateContainer(g.findContainer(key)))
+			continue
+		}
+		// the replicas of a controller have the same containers, see validator.checkControllers()
+		if _, exist := g.shared[e.Name+"/"+key]; !exist {
+			g.shared[e.Name+"/"+key] = g.generateSharedContainer(g.findContainer(key))
+		}
+		containers = append(containers, g.shared[e.Name+"/"+key])
+	}
+
+	pod := &podTemplate{
+		Key:        name,
+		Containers: containers,
+	}
+	// the tolerations are checked in GeneratorConf.check()
+	e.setPlacement(pod)
+	g.topology.PodTemplateMap[name] = pod
+	g.pods[e.Name] = append(g.pods[e.Name], name)
+	return name
+}
+
+// generateContainer generates a container with its usage changed by the distribution
+func (g *generator) generateContainer(e *genContainerEntry) string {
+	name := g.nextName("container", e.Name)
+
+	distribution := e.Distribution
+	if distribution == nil {
+		distribution = g.conf.Distribution
+	}
+	factor := distribution.factor(g.rand)
+
+	// the profile is checked in GeneratorConf.check()
+	container, _ := e.newTemplate(name)
+	container.CPU.Used = scaleUsage(e.Usage.CPU, factor, e.Limits.CPU)
+	container.Memory.Used = scaleUsage(e.Usage.Memory, factor, e.Limits.Memory) * 1024.0
+	container.QPS.Used = scaleUsage(e.QPS.Used, factor, e.QPS.Limit)
+
+	g.topology.ContainerTemplateMap[name] = container
+	return name
+}
+
+// generateSharedContainer generates a container without changing its usage, for all the replicas of a pod
+func (g *generator) generateSharedContainer(e *genContainerEntry) string {
+	name := g.nextName("container", e.Name)
+	container, _ := e.newTemplate(name)
+	g.topology.ContainerTemplateMap[name] = container
+	return name
+}
+
+func (g *generator) isControlled(pod string) bool {
+	for _, e := range g.conf.Controllers {
+		for _, p := range e.Pods {
+			if p == pod {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scaleUsage changes the usage by the factor, rounded and kept in [0, limit]; no limit if it is 0
+func scaleUsage(used, factor, limit float64) float64 {
+	result := math.Max(0, math.Round(used*factor))
+	if limit > 0 {
+		result = math.Min(result, limit)
+	}
+	return result
+}
+
+// the templates are checked in GeneratorConf.check()
+func (g *generator) findContainer(name string) *genContainerEntry {
+	for _, e := range g.conf.Containers {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (g *generator) findPod(name string) *podEntry {
+	for _, e := range g.conf.Pods {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (g *generator) findVNode(name string) *genVNodeEntry {
+	for _, e := range g.conf.VNodes {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// ipRange allocates the IPs in order, skipping the network and broadcast addresses (x.x.x.0 and x.x.x.255).
+type ipRange struct {
+	next uint64
+	last uint64
+}
+
+// newIPRange parses a range as <first>-<last>, or <first> for all the IPs after it.
+func newIPRange(spec, defaultSpec string) (*ipRange, error) {
+	if spec == "" {
+		spec = defaultSpec
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	first, err := parseIPv4(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	last := uint32(math.MaxUint32)
+	if len(parts) > 1 {
+		if last, err = parseIPv4(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	if first > last {
+		return nil, fmt.Errorf("first IP is after the last one in range[%s]", spec)
+	}
+
+	return &ipRange{next: uint64(first), last: uint64(last)}, nil
+}
+
+func parseIPv4(s string) (uint32, error) {
+	ip := net.ParseIP(strings.TrimSpace(s)).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("invalid IPv4 address[%s]", s)
+	}
+	return binary.BigEndian.Uint32(ip), nil
+}
+
+func (r *ipRange) allocate() (string, error) {
+	for r.next <= r.last {
+		value := uint32(r.next)
+		r.next++
+		if last := value & 0xff; last == 0 || last == 0xff {
+			continue
+		}
+
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, value)
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("IP range is exhausted")
+}
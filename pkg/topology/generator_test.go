@@ -0,0 +1,134 @@
+package topology
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func loadTestGeneratorConf(t *testing.T) *GeneratorConf {
+	conf, err := NewGeneratorConf(testutil.MakeTestPath("conf/generator.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load generator conf: %v", err)
+	}
+	return conf
+}
+
+func TestGeneratorConf_Generate(t *testing.T) {
+	conf := loadTestGeneratorConf(t)
+	topo, err := conf.Generate("testCluster")
+	if err != nil {
+		t.Fatalf("failed to generate topology: %v", err)
+	}
+
+	// node1: 2 * (vnode1, vnode1, vnode2); node2: 3 * (vnode1, vnode2); vnode1 and vnode2 have 3 pods each;
+	// the 12 replicas of pod2 share one container
+	counts := map[string]int{
+		"node":      len(topo.NodeTemplateMap),
+		"vnode":     len(topo.VNodeTemplateMap),
+		"pod":       len(topo.PodTemplateMap),
+		"container": len(topo.ContainerTemplateMap),
+		"service-1": len(topo.ServiceTemplateMap["service1"].Pods),
+		"batch":     len(topo.NamespaceTemplateMap["batch"].Pods),
+		"frontend":  len(topo.ControllerTemplateMap["frontend"].Pods),
+		"switch-1":  len(topo.SwitchTemplateMap["switch1"].PMs),
+	}
+	expected := map[string]int{
+		"node":      5,
+		"vnode":     12,
+		"pod":       36,
+		"container": 35,
+		"service-1": 26,
+		"batch":     10,
+		"frontend":  12,
+		"switch-1":  5,
+	}
+	for k, v := range expected {
+		if counts[k] != v {
+			t.Errorf("number of %s should be %d, but got %d", k, v, counts[k])
+		}
+	}
+
+	if ip := topo.NodeTemplateMap["node2-1"].IP; ip != "200.1.1.4" {
+		t.Errorf("IP of node2-1 should be 200.1.1.4, but got %s", ip)
+	}
+	if diagnostics := topo.Validate(); len(diagnostics) > 0 {
+		t.Errorf("generated topology should be valid, but got:\n%v", diagnostics.Error())
+	}
+
+	// the same seed generates the same topology
+	again, err := loadTestGeneratorConf(t).Generate("testCluster")
+	if err != nil {
+		t.Fatalf("failed to generate topology: %v", err)
+	}
+	if !sameTemplates(topo, again) {
+		t.Errorf("topologies generated from the same seed are different")
+	}
+
+	conf = loadTestGeneratorConf(t)
+	conf.Seed += 1
+	other, err := conf.Generate("testCluster")
+	if err != nil {
+		t.Fatalf("failed to generate topology: %v", err)
+	}
+	if sameTemplates(topo, other) {
+		t.Errorf("topologies generated from different seeds are the same")
+	}
+}
+
+func TestGeneratorConf_GenerateHotspot(t *testing.T) {
+	conf := &GeneratorConf{
+		Distribution: &distributionEntry{Type: distributionHotspot, HotRatio: 1, HotFactor: 3},
+		Containers: []*genContainerEntry{
+			{containerEntry: containerEntry{
+				Name:   "c",
+				Limits: resourceEntry{CPU: 500, Memory: 0},
+				Usage:  resourceEntry{CPU: 200, Memory: 100},
+			}},
+		},
+		Pods:   []*podEntry{{Name: "p", Containers: []string{"c"}}},
+		VNodes: []*genVNodeEntry{{Name: "v", CPU: 1000, Memory: 1000, Pods: []string{"p"}}},
+		Nodes:  []*genNodeEntry{{Name: "n", CPU: 2000, Memory: 2000, VNodes: []string{"v"}, Num: 3}},
+	}
+
+	topo, err := conf.Generate("testCluster")
+	if err != nil {
+		t.Fatalf("failed to generate topology: %v", err)
+	}
+
+	for k, c := range topo.ContainerTemplateMap {
+		// CPU is kept under the limit; Memory has no limit
+		if c.CPU.Used != 500 || c.Memory.Used != 300*1024 {
+			t.Errorf("usage of hot container[%s] should be (500, 300MB), but got (%v, %vMB)",
+				k, c.CPU.Used, c.Memory.Used/1024)
+		}
+	}
+}
+
+func TestGeneratorConf_GenerateFailures(t *testing.T) {
+	tests := map[string]*GeneratorConf{
+		"pod[p] refers to unknown container[x]": {
+			Pods: []*podEntry{{Name: "p", Containers: []string{"x"}}},
+		},
+		"unknown distribution type[skewed]": {
+			Distribution: &distributionEntry{Type: "skewed"},
+		},
+		"hotFactor 0 of hotspot should be positive": {
+			Distribution: &distributionEntry{Type: distributionHotspot, HotRatio: 0.5},
+		},
+		"IP range is exhausted": {
+			IPRanges:   ipRangesEntry{Node: "10.0.0.254-10.0.1.1"},
+			Containers: []*genContainerEntry{{containerEntry: containerEntry{Name: "c"}}},
+			Pods:       []*podEntry{{Name: "p", Containers: []string{"c"}}},
+			Nodes:      []*genNodeEntry{{Name: "n", Num: 3}},
+		},
+	}
+
+	for msg, conf := range tests {
+		_, err := conf.Generate("testCluster")
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("generate should fail with [%s], but got: %v", msg, err)
+		}
+	}
+}
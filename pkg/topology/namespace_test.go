@@ -0,0 +1,82 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func TestTargetTopology_LoadNamespaces(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/namespace.topology.conf"))
+
+	web := expected.NamespaceTemplateMap["web"]
+	if web == nil || web.LimitCPU != 1000 || web.LimitMem != 2048*1024 || web.ReqCPU != 600 || web.ReqMem != 0 ||
+		!reflect.DeepEqual(web.Pods, []string{"pod-1", "pod-2"}) {
+		t.Errorf("wrong namespace web: %+v", web)
+	}
+	if diagnostics := expected.Validate(); len(diagnostics) > 0 {
+		t.Errorf("namespace topology should be valid, but got:\n%v", diagnostics.Error())
+	}
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"namespace.yaml", "namespace.json", "namespace.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("namespaces in topology[%s] are changed after save and load", name)
+		}
+	}
+
+	// the pods are assigned to the namespaces, and exported back
+	cluster, err := NewClusterBuilderfromTopology("cluster-1", "testCluster", expected).GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to build cluster: %v", err)
+	}
+	if ns := cluster.Namespaces["batch"]; ns == nil || ns.CPULimitQuota.Capacity != 400 {
+		t.Errorf("wrong namespace batch: %+v", ns)
+	}
+	exported := NewTargetTopologyFromCluster(cluster)
+	if !reflect.DeepEqual(exported.NamespaceTemplateMap, expected.NamespaceTemplateMap) {
+		t.Errorf("namespaces are not exported: %+v", exported.NamespaceTemplateMap)
+	}
+}
+
+func TestTargetTopology_ValidateNamespaces(t *testing.T) {
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+pod, pod-2, containerA
+namespace, ns-1, 300, 0, 0, 0, pod-1, pod-2
+namespace, ns-2, 0, 0, 0, 50, pod-2, pod-x
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1, pod-2
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+`
+	fname := filepath.Join(testutil.TempDir(t), "namespace.conf")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	topo := loadTestTopology(t, fname)
+	expected := []string{
+		"namespace.conf:3: pod[pod-2] is in more than one namespace: ns-1, ns-2",
+		"namespace.conf:4: CPU limits of the pods of namespace[ns-1] are more than its quota: 400.0 > 300.0",
+		"namespace.conf:5: namespace[ns-2] refers to unknown pod[pod-x]",
+		"namespace.conf:5: Memory requests of the pods of namespace[ns-2] are more than its quota: 100.0 > 50.0",
+	}
+	diagnostics := topo.Validate()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(expected), len(diagnostics), diagnostics.Error())
+	}
+	for i, msg := range expected {
+		if !strings.HasSuffix(diagnostics[i].String(), msg) {
+			t.Errorf("problem %d should be [%s], but got [%v]", i, msg, diagnostics[i])
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package topology
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// parseLabels parses the labels, or the terms of a selector, in the format of key=value.
+func parseLabels(name string, fields []string) (map[string]string, error) {
+	if len(fields) < 1 {
+		return nil, nil
+	}
+	result := make(map[string]string)
+	for _, field := range fields {
+		k, v, err := target.ParseLabel(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if _, exist := result[k]; exist {
+			return nil, fmt.Errorf("%s: duplicate key '%s'", name, k)
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// parseTaints parses the taints in the format of key=value, or key.
+func parseTaints(fields []string) ([]target.Taint, error) {
+	var result []target.Taint
+	for _, field := range fields {
+		taint, err := target.ParseTaint(field)
+		if err != nil {
+			return nil, fmt.Errorf("taints: %v", err)
+		}
+		result = append(result, taint)
+	}
+	return result, nil
+}
+
+// parseTolerations parses the tolerations in the format of key=value, or key for any value.
+func parseTolerations(fields []string) ([]target.Toleration, error) {
+	var result []target.Toleration
+	for _, field := range fields {
+		toleration, err := target.ParseToleration(field)
+		if err != nil {
+			return nil, fmt.Errorf("tolerations: %v", err)
+		}
+		result = append(result, toleration)
+	}
+	return result, nil
+}
+
+func formatTaints(taints []target.Taint) []string {
+	var result []string
+	for _, taint := range taints {
+		result = append(result, taint.String())
+	}
+	return result
+}
+
+func formatTolerations(tolerations []target.Toleration) []string {
+	var result []string
+	for _, toleration := range tolerations {
+		result = append(result, toleration.String())
+	}
+	return result
+}
+
+// copyLabels copies the labels; nil if there is none, so that no label and empty labels are the same.
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) < 1 {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+// setPlacement sets the labels and placement constraints of the pod template from the entry
+func (e *podEntry) setPlacement(pod *podTemplate) error {
+	tolerations, err := parseTolerations(e.Tolerations)
+	if err != nil {
+		return err
+	}
+	pod.Labels = copyLabels(e.Labels)
+	pod.NodeSelector = copyLabels(e.NodeSelector)
+	pod.Affinity = copyLabels(e.Affinity)
+	pod.AntiAffinity = copyLabels(e.AntiAffinity)
+	pod.Tolerations = tolerations
+	return nil
+}
+
+// the fields of the pod template set by each line of placement constraints
+var podSelectors = map[string]func(*podTemplate) *map[string]string{
+	"nodeSelector": func(p *podTemplate) *map[string]string { return &p.NodeSelector },
+	"affinity":     func(p *podTemplate) *map[string]string { return &p.Affinity },
+	"antiAffinity": func(p *podTemplate) *map[string]string { return &p.AntiAffinity },
+}
+
+// load the labels of a pod or a vnode from a line, by the one of the name; the pod or vnode should be defined before
+// labels, podId|vnodeId, key1=value1, key2=value2, ...
+func loadLabels(t *TargetTopology, input *InputLine) error {
+	_, isPod := t.PodTemplateMap[input.key]
+	_, isVNode := t.VNodeTemplateMap[input.key]
+	if isPod && isVNode {
+		return fmt.Errorf("labels of [%s] is ambiguous: both pod and vnode, use podLabels or vnodeLabels", input.key)
+	}
+	if !isPod && !isVNode {
+		return fmt.Errorf("labels of unknown pod or vnode[%s]", input.key)
+	}
+
+	if isPod {
+		return loadPodLabels(t, input)
+	}
+	return loadVNodeLabels(t, input)
+}
+
+// load the labels of a pod from a line; the pod should be defined before
+// podLabels, podId, key1=value1, key2=value2, ...
+func loadPodLabels(t *TargetTopology, input *InputLine) error {
+	pod, exist := t.PodTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("%s of unknown pod[%s]", input.command, input.key)
+	}
+	if pod.Labels != nil {
+		return fmt.Errorf("labels of pod[%s] already exist", input.key)
+	}
+
+	labels, err := parseRequiredLabels(input)
+	if err != nil {
+		return err
+	}
+	pod.Labels = labels
+	glog.V(4).Infof("[%s] pod %s: %v", input.command, input.key, labels)
+	return nil
+}
+
+// load the labels of a vnode from a line; the vnode should be defined before
+// vnodeLabels, vnodeId, key1=value1, key2=value2, ...
+func loadVNodeLabels(t *TargetTopology, input *InputLine) error {
+	vnode, exist := t.VNodeTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("%s of unknown vnode[%s]", input.command, input.key)
+	}
+	if vnode.Labels != nil {
+		return fmt.Errorf("labels of vnode[%s] already exist", input.key)
+	}
+
+	labels, err := parseRequiredLabels(input)
+	if err != nil {
+		return err
+	}
+	vnode.Labels = labels
+	glog.V(4).Infof("[%s] vnode %s: %v", input.command, input.key, labels)
+	return nil
+}
+
+func parseRequiredLabels(input *InputLine) (map[string]string, error) {
+	labels, err := parseLabels(input.command, input.GetRestOfFields())
+	if err != nil {
+		return nil, err
+	}
+	if labels == nil {
+		return nil, fmt.Errorf("missing label list of [%s]", input.key)
+	}
+	return labels, nil
+}
+
+// load the taints of a vnode from a line; the vnode should be defined before
+// taints, vnodeId, key1=value1, key2, ...
+func loadTaints(t *TargetTopology, input *InputLine) error {
+	vnode, exist := t.VNodeTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("taints of unknown vnode[%s]", input.key)
+	}
+	if vnode.Taints != nil {
+		return fmt.Errorf("taints of vnode[%s] already exist", input.key)
+	}
+	if input.RemainingFieldCount() < 1 {
+		return fmt.Errorf("missing taint list of vnode[%s]", input.key)
+	}
+
+	taints, err := parseTaints(input.GetRestOfFields())
+	if err != nil {
+		return err
+	}
+	vnode.Taints = taints
+	glog.V(4).Infof("[taints] %s: %v", input.key, taints)
+	return nil
+}
+
+// load the tolerations of a pod from a line; the pod should be defined before
+// tolerations, podId, key1=value1, key2, ...
+func loadTolerations(t *TargetTopology, input *InputLine) error {
+	pod, exist := t.PodTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("tolerations of unknown pod[%s]", input.key)
+	}
+	if pod.Tolerations != nil {
+		return fmt.Errorf("tolerations of pod[%s] already exist", input.key)
+	}
+	if input.RemainingFieldCount() < 1 {
+		return fmt.Errorf("missing toleration list of pod[%s]", input.key)
+	}
+
+	tolerations, err := parseTolerations(input.GetRestOfFields())
+	if err != nil {
+		return err
+	}
+	pod.Tolerations = tolerations
+	glog.V(4).Infof("[tolerations] %s: %v", input.key, tolerations)
+	return nil
+}
+
+// load a selector of a pod from a line; the pod should be defined before
+// nodeSelector|affinity|antiAffinity, podId, key1=value1, key2=value2, ...
+func loadPodSelector(t *TargetTopology, input *InputLine) error {
+	pod, exist := t.PodTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("%s of unknown pod[%s]", input.command, input.key)
+	}
+	field := podSelectors[input.command](pod)
+	if *field != nil {
+		return fmt.Errorf("%s of pod[%s] already exists", input.command, input.key)
+	}
+
+	selector, err := parseLabels(input.command, input.GetRestOfFields())
+	if err != nil {
+		return err
+	}
+	if selector == nil {
+		return fmt.Errorf("missing %s of pod[%s]", input.command, input.key)
+	}
+	*field = selector
+	glog.V(4).Infof("[%s] %s: %v", input.command, input.key, selector)
+	return nil
+}
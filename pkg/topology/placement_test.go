@@ -0,0 +1,169 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func TestTargetTopology_LoadPlacement(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/placement.topology.conf"))
+
+	pod := expected.PodTemplateMap["pod-2"]
+	if !reflect.DeepEqual(pod.NodeSelector, map[string]string{"zone": "a"}) ||
+		!reflect.DeepEqual(pod.Affinity, map[string]string{"app": "web"}) {
+		t.Errorf("wrong placement of pod-2: %+v", pod)
+	}
+	if tolerations := expected.PodTemplateMap["pod-3"].Tolerations; !reflect.DeepEqual(tolerations,
+		[]target.Toleration{{Key: "dedicated"}}) {
+		t.Errorf("wrong tolerations of pod-3: %v", tolerations)
+	}
+	vnode := expected.VNodeTemplateMap["vnode-2"]
+	if !reflect.DeepEqual(vnode.Taints, []target.Taint{{Key: "dedicated", Value: "batch"}}) ||
+		!reflect.DeepEqual(vnode.Labels, map[string]string{"zone": "b"}) {
+		t.Errorf("wrong placement of vnode-2: %+v", vnode)
+	}
+	if diagnostics := expected.Validate(); len(diagnostics) > 0 {
+		t.Errorf("placement topology should be valid, but got:\n%v", diagnostics.Error())
+	}
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"placement.yaml", "placement.json", "placement.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("placement in topology[%s] is changed after save and load", name)
+		}
+	}
+
+	// the constraints are kept by the cluster, and exported back
+	cluster, err := NewClusterBuilderfromTopology("cluster-1", "testCluster", expected).GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to build cluster: %v", err)
+	}
+	exported := NewTargetTopologyFromCluster(cluster)
+	if !reflect.DeepEqual(exported.PodTemplateMap["pod-2"].Affinity, pod.Affinity) ||
+		!reflect.DeepEqual(exported.VNodeTemplateMap["vnode-2"].Taints, vnode.Taints) {
+		t.Errorf("placement is not exported: %+v, %+v",
+			exported.PodTemplateMap["pod-2"], exported.VNodeTemplateMap["vnode-2"])
+	}
+}
+
+func TestTargetTopology_LoadPlacementFailures(t *testing.T) {
+	tests := map[string]string{
+		"labels, pod-x, app=web":                             "labels of unknown pod or vnode[pod-x]",
+		"podLabels, vnode-1, app=web":                        "podLabels of unknown pod[vnode-1]",
+		"vnodeLabels, pod-1, zone=a":                         "vnodeLabels of unknown vnode[pod-1]",
+		"vnodeLabels, vnode-1, zone":                         "vnodeLabels: invalid 'zone'",
+		"podLabels, pod-1, a=1\nlabels, pod-1, b=2":          "labels of pod[pod-1] already exist",
+		"labels, pod-1, app":                                 "labels: invalid 'app', should be key=value",
+		"labels, pod-1, app=web, app=api":                    "labels: duplicate key 'app'",
+		"labels, pod-1, a=1\nlabels, pod-1, b=2":             "labels of pod[pod-1] already exist",
+		"taints, pod-1, gpu":                                 "taints of unknown vnode[pod-1]",
+		"taints, vnode-1, =gpu":                              "taints: invalid '=gpu'",
+		"tolerations, vnode-1, gpu":                          "tolerations of unknown pod[vnode-1]",
+		"nodeSelector, pod-1, zone":                          "nodeSelector: invalid 'zone'",
+		"affinity, pod-x, app=web":                           "affinity of unknown pod[pod-x]",
+		"antiAffinity, pod-1, a=1\nantiAffinity, pod-1, a=2": "antiAffinity of pod[pod-1] already exists",
+	}
+
+	for line, msg := range tests {
+		content := "container, containerA, 200, 100, 150, 305, 200, 100, 120, 50\n" +
+			"pod, pod-1, containerA\n" +
+			"vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1\n" + line + "\n"
+		fname := filepath.Join(testutil.TempDir(t), "bad.conf")
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		output, _ := testutil.GetOutput(func() {
+			NewTargetTopology("testCluster").LoadTopology(fname)
+		})
+		if !strings.Contains(output, msg) {
+			t.Errorf("load [%s] should fail with [%s], but got:\n%s", line, msg, output)
+		}
+	}
+}
+
+func TestTargetTopology_SaveLabelsOfSameName(t *testing.T) {
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+pod, web, containerA
+vnode, web, 5200, 8192, 192.168.1.2, web
+node, node-1, 10400, 16384, 200.0.0.1, web
+podLabels, web, app=web
+vnodeLabels, web, zone=a
+`
+	dir := testutil.TempDir(t)
+	fname := filepath.Join(dir, "labels.conf")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	expected := loadTestTopology(t, fname)
+
+	// the labels of the pod and the vnode of the same name are saved and loaded back apart
+	saved := filepath.Join(dir, "saved.conf")
+	if err := expected.SaveTopology(saved); err != nil {
+		t.Fatalf("save topology failed: %v", err)
+	}
+	topo := loadTestTopology(t, saved)
+	if !reflect.DeepEqual(topo.PodTemplateMap["web"].Labels, map[string]string{"app": "web"}) ||
+		!reflect.DeepEqual(topo.VNodeTemplateMap["web"].Labels, map[string]string{"zone": "a"}) {
+		t.Errorf("wrong labels after save and load: %v, %v",
+			topo.PodTemplateMap["web"].Labels, topo.VNodeTemplateMap["web"].Labels)
+	}
+
+	// labels of the name are ambiguous
+	output, _ := testutil.GetOutput(func() {
+		ioutil.WriteFile(fname, []byte(content+"labels, web, tier=front\n"), 0644)
+		NewTargetTopology("testCluster").LoadTopology(fname)
+	})
+	if !strings.Contains(output, "labels of [web] is ambiguous") {
+		t.Errorf("labels of a pod and vnode of the same name should be ambiguous, but got:\n%s", output)
+	}
+}
+
+func TestTargetTopology_ValidatePlacement(t *testing.T) {
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+pod, pod-2, containerA
+pod, pod-3, containerA
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1, pod-2, pod-3
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+taints, vnode-1, gpu
+labels, pod-1, app=web
+tolerations, pod-1, gpu
+tolerations, pod-2, gpu
+antiAffinity, pod-2, app=web
+tolerations, pod-3, gpu=a100
+`
+	fname := filepath.Join(testutil.TempDir(t), "placement.conf")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	topo := loadTestTopology(t, fname)
+	// the anti-affinity is reported for both pods
+	expected := []string{
+		"placement.conf:2: [pod-1] cannot be placed on [vnode-1]: anti-affinity [app=web] of pod[pod-2] is matched",
+		"placement.conf:3: [pod-2] cannot be placed on [vnode-1]: pod[pod-1] matches anti-affinity [app=web]",
+		"placement.conf:4: [pod-3] cannot be placed on [vnode-1]: taint gpu is not tolerated",
+	}
+	diagnostics := topo.Validate()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(expected), len(diagnostics), diagnostics.Error())
+	}
+	for i, msg := range expected {
+		if !strings.HasSuffix(diagnostics[i].String(), msg) {
+			t.Errorf("problem %d should be [%s], but got [%v]", i, msg, diagnostics[i])
+		}
+	}
+}
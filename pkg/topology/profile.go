@@ -0,0 +1,218 @@
+package topology
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// profileEntry is the usage profile of a container; the durations are in the format of time.ParseDuration, e.g., 24h.
+type profileEntry struct {
+	Type      string       `yaml:"type" json:"type"`
+	Period    string       `yaml:"period,omitempty" json:"period,omitempty"`
+	Amplitude float64      `yaml:"amplitude,omitempty" json:"amplitude,omitempty"`
+	Phase     string       `yaml:"phase,omitempty" json:"phase,omitempty"`
+	Growth    float64      `yaml:"growth,omitempty" json:"growth,omitempty"`
+	Step      float64      `yaml:"step,omitempty" json:"step,omitempty"`
+	Interval  string       `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Seed      int64        `yaml:"seed,omitempty" json:"seed,omitempty"`
+	Spikes    []spikeEntry `yaml:"spikes,omitempty" json:"spikes,omitempty"`
+}
+
+type spikeEntry struct {
+	Start    string  `yaml:"start" json:"start"`
+	Duration string  `yaml:"duration" json:"duration"`
+	Factor   float64 `yaml:"factor" json:"factor"`
+}
+
+func (e *profileEntry) newProfile() (*target.UsageProfile, error) {
+	profile := &target.UsageProfile{
+		Type:      e.Type,
+		Amplitude: e.Amplitude,
+		Growth:    e.Growth,
+		Step:      e.Step,
+		Seed:      e.Seed,
+	}
+
+	var err error
+	if profile.Period, err = parseDuration("period", e.Period); err != nil {
+		return nil, err
+	}
+	if profile.Phase, err = parseDuration("phase", e.Phase); err != nil {
+		return nil, err
+	}
+	if profile.Interval, err = parseDuration("interval", e.Interval); err != nil {
+		return nil, err
+	}
+	for _, s := range e.Spikes {
+		spike := target.Spike{Factor: s.Factor}
+		if spike.Start, err = parseDuration("spike start", s.Start); err != nil {
+			return nil, err
+		}
+		if spike.Duration, err = parseDuration("spike duration", s.Duration); err != nil {
+			return nil, err
+		}
+		profile.Spikes = append(profile.Spikes, spike)
+	}
+
+	if err := profile.Check(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func newProfileEntry(profile *target.UsageProfile) *profileEntry {
+	if profile == nil {
+		return nil
+	}
+
+	e := &profileEntry{
+		Type:      profile.Type,
+		Period:    formatDuration(profile.Period),
+		Amplitude: profile.Amplitude,
+		Phase:     formatDuration(profile.Phase),
+		Growth:    profile.Growth,
+		Step:      profile.Step,
+		Interval:  formatDuration(profile.Interval),
+		Seed:      profile.Seed,
+	}
+	for _, spike := range profile.Spikes {
+		e.Spikes = append(e.Spikes, spikeEntry{
+			Start:    formatDuration(spike.Start),
+			Duration: formatDuration(spike.Duration),
+			Factor:   spike.Factor,
+		})
+	}
+	return e
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", name, value, err)
+	}
+	return d, nil
+}
+
+// formatDuration formats the duration without the zero units, e.g., 24h instead of 24h0m0s; empty if it is 0
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// load the profile of a container from a line; the container should be defined before
+// profile, containerId, type, <name>=<value>, ...
+// the names are the fields of profileEntry, and spike=<start>/<duration>/<factor> for each spike.
+func loadProfile(t *TargetTopology, input *InputLine) error {
+	container, exist := t.ContainerTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("profile of unknown container[%s]", input.key)
+	}
+	if container.Profile != nil {
+		return fmt.Errorf("profile of container[%s] already exists", input.key)
+	}
+
+	e := &profileEntry{Type: input.getString()}
+	if input.err != nil {
+		return input.err
+	}
+	for _, field := range input.GetRestOfFields() {
+		if err := e.set(field); err != nil {
+			return err
+		}
+	}
+
+	profile, err := e.newProfile()
+	if err != nil {
+		return err
+	}
+	container.Profile = profile
+	glog.V(4).Infof("[profile] %s: %+v", input.key, profile)
+	return nil
+}
+
+// set a parameter from <name>=<value>
+func (e *profileEntry) set(field string) error {
+	parts := strings.SplitN(field, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid profile parameter '%s', should be <name>=<value>", field)
+	}
+	name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+	var err error
+	switch name {
+	case "period":
+		e.Period = value
+	case "phase":
+		e.Phase = value
+	case "interval":
+		e.Interval = value
+	case "amplitude":
+		e.Amplitude, err = strconv.ParseFloat(value, 64)
+	case "growth":
+		e.Growth, err = strconv.ParseFloat(value, 64)
+	case "step":
+		e.Step, err = strconv.ParseFloat(value, 64)
+	case "seed":
+		e.Seed, err = strconv.ParseInt(value, 10, 64)
+	case "spike":
+		spike := strings.Split(value, "/")
+		if len(spike) != 3 {
+			return fmt.Errorf("invalid spike '%s', should be <start>/<duration>/<factor>", value)
+		}
+		s := spikeEntry{Start: spike[0], Duration: spike[1]}
+		s.Factor, err = strconv.ParseFloat(spike[2], 64)
+		e.Spikes = append(e.Spikes, s)
+	default:
+		return fmt.Errorf("unknown profile parameter '%s'", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value of profile parameter '%s': %v", field, err)
+	}
+	return nil
+}
+
+// fields are the parameters in the comma-separated format, the reverse of set()
+func (e *profileEntry) fields() []string {
+	result := []string{e.Type}
+	add := func(name, value string) {
+		if value != "" {
+			result = append(result, name+"="+value)
+		}
+	}
+	add("period", e.Period)
+	add("phase", e.Phase)
+	add("interval", e.Interval)
+	if e.Amplitude != 0 {
+		add("amplitude", formatFloats(e.Amplitude)[0])
+	}
+	if e.Growth != 0 {
+		add("growth", formatFloats(e.Growth)[0])
+	}
+	if e.Step != 0 {
+		add("step", formatFloats(e.Step)[0])
+	}
+	if e.Seed != 0 {
+		add("seed", strconv.FormatInt(e.Seed, 10))
+	}
+	for _, s := range e.Spikes {
+		add("spike", fmt.Sprintf("%s/%s/%s", s.Start, s.Duration, formatFloats(s.Factor)[0]))
+	}
+	return result
+}
@@ -0,0 +1,151 @@
+package topology
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/glog"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// load the disk of a vnode from a line; the vnode should be defined before; size in MB
+// disk, vnodeId, size, storageId
+func loadDisk(t *TargetTopology, input *InputLine) error {
+	vnode, exist := t.VNodeTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("disk of unknown vnode[%s]", input.key)
+	}
+	if vnode.Storage != "" {
+		return fmt.Errorf("disk of vnode[%s] already exists", input.key)
+	}
+
+	size := input.getFloat()
+	storage := input.getString()
+	if input.err != nil {
+		return input.err
+	}
+
+	vnode.Disk = size
+	vnode.Storage = storage
+	glog.V(4).Infof("[disk] %s: %v on %s", input.key, size, storage)
+	return nil
+}
+
+// load a volume of a pod from a line, one line per volume; the pod should be defined before; size in MB
+// volume, podId, name, size, iops
+func loadVolume(t *TargetTopology, input *InputLine) error {
+	pod, exist := t.PodTemplateMap[input.key]
+	if !exist {
+		return fmt.Errorf("volume of unknown pod[%s]", input.key)
+	}
+
+	volume := target.Volume{
+		Name: input.getString(),
+		Size: input.getFloat(),
+		IOPS: input.getFloat(),
+	}
+	if input.err != nil {
+		return input.err
+	}
+
+	volumes, err := appendVolume(pod.Volumes, volume)
+	if err != nil {
+		return fmt.Errorf("pod[%s]: %v", input.key, err)
+	}
+	pod.Volumes = volumes
+	glog.V(4).Infof("[volume] %s: %+v", input.key, volume)
+	return nil
+}
+
+// appendVolume appends the volume, whose name should be unique in the pod
+func appendVolume(volumes []target.Volume, volume target.Volume) ([]target.Volume, error) {
+	for _, v := range volumes {
+		if v.Name == volume.Name {
+			return nil, fmt.Errorf("volume[%s] already exists", volume.Name)
+		}
+	}
+	return append(volumes, volume), nil
+}
+
+func (e *storageEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.StorageTemplateMap[e.Name]; exist {
+		return fmt.Errorf("storage [%s] already exists", e.Name)
+	}
+	if len(e.Nodes) < 1 {
+		return fmt.Errorf("missing node list in storage declaration")
+	}
+
+	storage := &storageTemplate{
+		Key:      e.Name,
+		Capacity: e.Capacity,
+		IOPS:     e.IOPS,
+		Nodes:    e.Nodes,
+	}
+
+	t.StorageTemplateMap[e.Name] = storage
+	glog.V(4).Infof("[storage] %+v", storage)
+	return nil
+}
+
+// setVolumes sets the volumes of the pod template from the entry
+func (e *podEntry) setVolumes(pod *podTemplate) error {
+	var volumes []target.Volume
+	for _, v := range e.Volumes {
+		if v.Name == "" {
+			return fmt.Errorf("missing volume name")
+		}
+		var err error
+		volumes, err = appendVolume(volumes, target.Volume{Name: v.Name, Size: v.Size, IOPS: v.IOPS})
+		if err != nil {
+			return err
+		}
+	}
+	pod.Volumes = volumes
+	return nil
+}
+
+func newVolumeEntries(volumes []target.Volume) []volumeEntry {
+	var result []volumeEntry
+	for _, v := range volumes {
+		result = append(result, volumeEntry{Name: v.Name, Size: v.Size, IOPS: v.IOPS})
+	}
+	return result
+}
+
+func newDiskEntry(vnode *vnodeTemplate) *diskEntry {
+	if vnode.Storage == "" {
+		return nil
+	}
+	return &diskEntry{Size: vnode.Disk, Storage: vnode.Storage}
+}
+
+// marshalStorage writes the storages, the disks of the vnodes and the volumes of the pods, one section per type.
+func (f *topologyFile) marshalStorage(buf *bytes.Buffer) {
+	if len(f.Storages) > 0 {
+		buf.WriteString("\n# storage, <storageId>, <capacity>, <iops>, <nodeId1>, <nodeId2>, ...\n")
+		for _, e := range f.Storages {
+			writeLine(buf, "storage", e.Name, append(formatFloats(e.Capacity, e.IOPS), e.Nodes...)...)
+		}
+	}
+
+	header := "\n# disk, <vnodeId>, <size>, <storageId>\n"
+	for _, e := range f.VNodes {
+		if e.Disk != nil {
+			buf.WriteString(header)
+			header = ""
+			writeLine(buf, "disk", e.Name, append(formatFloats(e.Disk.Size), e.Disk.Storage)...)
+		}
+	}
+
+	header = "\n# volume, <podId>, <name>, <size>, <iops>\n"
+	for _, e := range f.Pods {
+		for _, v := range e.Volumes {
+			buf.WriteString(header)
+			header = ""
+			writeLine(buf, "volume", e.Name, append([]string{v.Name}, formatFloats(v.Size, v.IOPS)...)...)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func TestTargetTopology_LoadStorages(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/storage.topology.conf"))
+
+	storage := expected.StorageTemplateMap["storage-1"]
+	if storage == nil || storage.Capacity != 102400 || !reflect.DeepEqual(storage.Nodes, []string{"node-1", "node-2"}) {
+		t.Errorf("wrong storage-1: %+v", storage)
+	}
+	if vnode := expected.VNodeTemplateMap["vnode-1"]; vnode.Disk != 40960 || vnode.Storage != "storage-1" {
+		t.Errorf("wrong disk of vnode-1: %+v", vnode)
+	}
+	volumes := []target.Volume{{Name: "data", Size: 10240, IOPS: 300}, {Name: "log", Size: 1024, IOPS: 50}}
+	if pod := expected.PodTemplateMap["pod-3"]; !reflect.DeepEqual(pod.Volumes, volumes) {
+		t.Errorf("wrong volumes of pod-3: %+v", pod.Volumes)
+	}
+	if diagnostics := expected.Validate(); len(diagnostics) > 0 {
+		t.Errorf("storage topology should be valid, but got:\n%v", diagnostics.Error())
+	}
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"storage.yaml", "storage.json", "storage.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("storages in topology[%s] are changed after save and load", name)
+		}
+	}
+
+	// the disks and volumes are kept by the cluster, and exported back
+	cluster, err := NewClusterBuilderfromTopology("cluster-1", "testCluster", expected).GenerateCluster()
+	if err != nil {
+		t.Fatalf("failed to build cluster: %v", err)
+	}
+	if s := cluster.Storages["storage-1"]; s == nil || s.StorageAmount.Used != 61440 || s.StorageAccess.Used != 650 {
+		t.Errorf("wrong usage of storage-1: %+v", s)
+	}
+	exported := NewTargetTopologyFromCluster(cluster)
+	if !reflect.DeepEqual(exported.StorageTemplateMap, expected.StorageTemplateMap) ||
+		!reflect.DeepEqual(exported.VNodeTemplateMap["vnode-2"], expected.VNodeTemplateMap["vnode-2"]) ||
+		!reflect.DeepEqual(exported.PodTemplateMap["pod-3"].Volumes, volumes) {
+		t.Errorf("storages are not exported: %+v", exported.StorageTemplateMap)
+	}
+}
+
+func TestTargetTopology_ValidateStorages(t *testing.T) {
+	content := `container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+pod, pod-2, containerA
+volume, pod-1, data, 2048, 100
+volume, pod-1, data, 1024, 100
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1
+vnode, vnode-2, 5200, 8192, 192.168.1.3, pod-2
+disk, vnode-1, 1024, storage-1
+disk, vnode-2, 4096, storage-x
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1
+node, node-2, 10400, 16384, 200.0.0.2, vnode-2
+storage, storage-1, 512, 1000, node-2, node-x
+`
+	fname := filepath.Join(testutil.TempDir(t), "storage.conf")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	topo := loadTestTopology(t, fname)
+	expected := []string{
+		"storage.conf:5: pod[pod-1]: volume[data] already exists",
+		"storage.conf:8: volumes of the pods of vnode[vnode-1] are more than its disk: 2048.0 > 1024.0",
+		"storage.conf:8: storage[storage-1] of vnode[vnode-1] is not attached to node[node-1]",
+		"storage.conf:9: disk of vnode[vnode-2] refers to unknown storage[storage-x]",
+		"storage.conf:12: storage[storage-1] refers to unknown node[node-x]",
+		"storage.conf:12: disks on storage[storage-1] are more than its capacity: 1024.0 > 512.0",
+	}
+	diagnostics := topo.Validate()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(expected), len(diagnostics), diagnostics.Error())
+	}
+	for i, msg := range expected {
+		if !strings.HasSuffix(diagnostics[i].String(), msg) {
+			t.Errorf("problem %d should be [%s], but got [%v]", i, msg, diagnostics[i])
+		}
+	}
+}
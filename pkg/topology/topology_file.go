@@ -0,0 +1,785 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatComma = "comma"
+	formatYAML  = "yaml"
+	formatJSON  = "json"
+)
+
+// getFormat selects the topology format by the file extension:
+// .yaml/.yml and .json are the structured formats, others are the comma-separated format.
+func getFormat(fname string) string {
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".json":
+		return formatJSON
+	}
+	return formatComma
+}
+
+const fileHeader = `# topology of a virtual cluster; unit of CPU is MHz, unit of Memory and Storage is MB.
+`
+
+// topologyFile is the structured topology format, with the same units as the comma-separated format:
+// CPU in MHz, and Memory and Storage in MB.
+type topologyFile struct {
+	Containers  []*containerEntry  `yaml:"containers" json:"containers"`
+	Pods        []*podEntry        `yaml:"pods" json:"pods"`
+	Services    []*serviceEntry    `yaml:"services,omitempty" json:"services,omitempty"`
+	Namespaces  []*namespaceEntry  `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	Controllers []*controllerEntry `yaml:"controllers,omitempty" json:"controllers,omitempty"`
+	VNodes      []*vnodeEntry      `yaml:"vnodes" json:"vnodes"`
+	Nodes       []*nodeEntry       `yaml:"nodes" json:"nodes"`
+	Switches    []*switchEntry     `yaml:"switches,omitempty" json:"switches,omitempty"`
+	Storages    []*storageEntry    `yaml:"storages,omitempty" json:"storages,omitempty"`
+}
+
+type resourceEntry struct {
+	CPU    float64 `yaml:"cpu" json:"cpu"`
+	Memory float64 `yaml:"memory" json:"memory"`
+}
+
+type metricEntry struct {
+	Limit float64 `yaml:"limit" json:"limit"`
+	Used  float64 `yaml:"used" json:"used"`
+}
+
+type containerEntry struct {
+	Name         string        `yaml:"name" json:"name"`
+	Limits       resourceEntry `yaml:"limits" json:"limits"`
+	Requests     resourceEntry `yaml:"requests" json:"requests"`
+	Usage        resourceEntry `yaml:"usage" json:"usage"`
+	QPS          metricEntry   `yaml:"qps" json:"qps"`
+	ResponseTime metricEntry   `yaml:"responseTime" json:"responseTime"`
+	Profile      *profileEntry `yaml:"profile,omitempty" json:"profile,omitempty"`
+}
+
+type podEntry struct {
+	Name       string   `yaml:"name" json:"name"`
+	Containers []string `yaml:"containers" json:"containers"`
+
+	Labels       map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	NodeSelector map[string]string `yaml:"nodeSelector,omitempty" json:"nodeSelector,omitempty"`
+	Affinity     map[string]string `yaml:"affinity,omitempty" json:"affinity,omitempty"`
+	AntiAffinity map[string]string `yaml:"antiAffinity,omitempty" json:"antiAffinity,omitempty"`
+	// key=value, or key for any value
+	Tolerations []string `yaml:"tolerations,omitempty" json:"tolerations,omitempty"`
+
+	Volumes []volumeEntry `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+}
+
+// a persistent volume of a pod, on the disk of its vnode
+type volumeEntry struct {
+	Name string  `yaml:"name" json:"name"`
+	Size float64 `yaml:"size" json:"size"`
+	IOPS float64 `yaml:"iops" json:"iops"`
+}
+
+type serviceEntry struct {
+	Name string   `yaml:"name" json:"name"`
+	Pods []string `yaml:"pods" json:"pods"`
+}
+
+// the quotas of a namespace, 0 means no quota
+type namespaceEntry struct {
+	Name     string        `yaml:"name" json:"name"`
+	Limits   resourceEntry `yaml:"limits" json:"limits"`
+	Requests resourceEntry `yaml:"requests" json:"requests"`
+	Pods     []string      `yaml:"pods" json:"pods"`
+}
+
+// the type is one of target.ControllerTypes, case-insensitive
+type controllerEntry struct {
+	Name string   `yaml:"name" json:"name"`
+	Type string   `yaml:"type" json:"type"`
+	Pods []string `yaml:"pods" json:"pods"`
+}
+
+type vnodeEntry struct {
+	Name   string   `yaml:"name" json:"name"`
+	CPU    float64  `yaml:"cpu" json:"cpu"`
+	Memory float64  `yaml:"memory" json:"memory"`
+	IP     string   `yaml:"ip" json:"ip"`
+	Pods   []string `yaml:"pods" json:"pods"`
+
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// key=value, or key
+	Taints []string `yaml:"taints,omitempty" json:"taints,omitempty"`
+
+	Disk *diskEntry `yaml:"disk,omitempty" json:"disk,omitempty"`
+}
+
+// the disk of a vnode, on the storage
+type diskEntry struct {
+	Size    float64 `yaml:"size" json:"size"`
+	Storage string  `yaml:"storage" json:"storage"`
+}
+
+type nodeEntry struct {
+	Name   string   `yaml:"name" json:"name"`
+	CPU    float64  `yaml:"cpu" json:"cpu"`
+	Memory float64  `yaml:"memory" json:"memory"`
+	IP     string   `yaml:"ip" json:"ip"`
+	VNodes []string `yaml:"vnodes" json:"vnodes"`
+}
+
+type switchEntry struct {
+	Name              string   `yaml:"name" json:"name"`
+	NetworkThroughput float64  `yaml:"networkThroughput" json:"networkThroughput"`
+	Nodes             []string `yaml:"nodes" json:"nodes"`
+}
+
+// a storage attached to the nodes
+type storageEntry struct {
+	Name     string   `yaml:"name" json:"name"`
+	Capacity float64  `yaml:"capacity" json:"capacity"`
+	IOPS     float64  `yaml:"iops" json:"iops"`
+	Nodes    []string `yaml:"nodes" json:"nodes"`
+}
+
+// entryLines keeps the line number of each entry, key=section name
+type entryLines map[string][]int
+
+func (l entryLines) get(section string, i int) int {
+	if lines, exist := l[section]; exist && i < len(lines) {
+		return lines[i]
+	}
+	return 0
+}
+
+// parseTopologyFile parses a YAML or JSON topology; JSON is parsed as YAML, of which it is a subset.
+func parseTopologyFile(data []byte) (*topologyFile, entryLines, error) {
+	file := &topologyFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+
+	lines := make(entryLines)
+	if len(root.Content) < 1 {
+		return file, lines, nil
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		section := doc.Content[i].Value
+		for _, item := range doc.Content[i+1].Content {
+			lines[section] = append(lines[section], item.Line)
+		}
+	}
+
+	return file, lines, nil
+}
+
+func (t *TargetTopology) loadStructured(fname string) error {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		glog.Errorf("failed to open file[%s] for read: %v", fname, err)
+		return err
+	}
+
+	file, lines, err := parseTopologyFile(data)
+	if err != nil {
+		glog.Errorf("failed to parse file[%s]: %v", fname, err)
+		t.addDiagnostic(0, err.Error())
+		return err
+	}
+
+	report := func(section, kind string, i int, name string, err error) {
+		line := lines.get(section, i)
+		if err != nil {
+			glog.Errorf("parse [%s/%d] %s[%s] failed: %v", fname, line, kind, name, err)
+			t.addDiagnostic(line, err.Error())
+			return
+		}
+		t.setPosition(kind, name, line)
+	}
+
+	for i, e := range file.Containers {
+		report("containers", "container", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Pods {
+		report("pods", "pod", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Services {
+		report("services", "service", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Namespaces {
+		report("namespaces", "namespace", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Controllers {
+		report("controllers", "controller", i, e.Name, e.load(t))
+	}
+	for i, e := range file.VNodes {
+		report("vnodes", "vnode", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Nodes {
+		report("nodes", "node", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Switches {
+		report("switches", "switch", i, e.Name, e.load(t))
+	}
+	for i, e := range file.Storages {
+		report("storages", "storage", i, e.Name, e.load(t))
+	}
+
+	return nil
+}
+
+func (e *containerEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.ContainerTemplateMap[e.Name]; exist {
+		return fmt.Errorf("container[%s] already exists.", e.Name)
+	}
+
+	container, err := e.newTemplate(e.Name)
+	if err != nil {
+		return err
+	}
+	t.ContainerTemplateMap[e.Name] = container
+	glog.V(4).Infof("[container] %+v", container)
+	return nil
+}
+
+func (e *containerEntry) newTemplate(key string) (*containerTemplate, error) {
+	container := &containerTemplate{
+		Key: key,
+		CPU: target.Resource{
+			Capacity: e.Limits.CPU,
+			Used:     e.Usage.CPU,
+		},
+		ReqCPU: e.Requests.CPU,
+
+		// change the unit of Memory from MB to KB
+		Memory: target.Resource{
+			Capacity: e.Limits.Memory * 1024.0,
+			Used:     e.Usage.Memory * 1024.0,
+		},
+		ReqMem: e.Requests.Memory * 1024.0,
+
+		QPS: target.Resource{
+			Capacity: e.QPS.Limit,
+			Used:     e.QPS.Used,
+		},
+		ResponseTime: target.Resource{
+			Capacity: e.ResponseTime.Limit,
+			Used:     e.ResponseTime.Used,
+		},
+	}
+
+	if e.Profile != nil {
+		profile, err := e.Profile.newProfile()
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile of container[%s]: %v", e.Name, err)
+		}
+		container.Profile = profile
+	}
+	return container, nil
+}
+
+func (e *podEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.PodTemplateMap[e.Name]; exist {
+		return fmt.Errorf("Pod[%s] already exists", e.Name)
+	}
+	if len(e.Containers) < 1 {
+		return fmt.Errorf("missing container list in pod declaration")
+	}
+
+	pod := &podTemplate{
+		Key:        e.Name,
+		Containers: e.Containers,
+	}
+	if err := e.setPlacement(pod); err != nil {
+		return err
+	}
+	if err := e.setVolumes(pod); err != nil {
+		return err
+	}
+
+	t.PodTemplateMap[e.Name] = pod
+	glog.V(4).Infof("[pod] %+v", pod)
+	return nil
+}
+
+func (e *serviceEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.ServiceTemplateMap[e.Name]; exist {
+		return fmt.Errorf("service[%s] already exists", e.Name)
+	}
+
+	service := &serviceTemplate{
+		Key:  e.Name,
+		Pods: e.Pods,
+	}
+
+	t.ServiceTemplateMap[e.Name] = service
+	glog.V(4).Infof("[service] %+v", service)
+	return nil
+}
+
+func (e *namespaceEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.NamespaceTemplateMap[e.Name]; exist {
+		return fmt.Errorf("namespace[%s] already exists", e.Name)
+	}
+
+	ns := &namespaceTemplate{
+		Key:      e.Name,
+		LimitCPU: e.Limits.CPU,
+		LimitMem: e.Limits.Memory * 1024.0,
+		ReqCPU:   e.Requests.CPU,
+		ReqMem:   e.Requests.Memory * 1024.0,
+		Pods:     e.Pods,
+	}
+
+	t.NamespaceTemplateMap[e.Name] = ns
+	glog.V(4).Infof("[namespace] %+v", ns)
+	return nil
+}
+
+func (e *controllerEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.ControllerTemplateMap[e.Name]; exist {
+		return fmt.Errorf("controller[%s] already exists", e.Name)
+	}
+	ctype, err := target.ParseControllerType(e.Type)
+	if err != nil {
+		return err
+	}
+
+	controller := &controllerTemplate{
+		Key:  e.Name,
+		Type: ctype,
+		Pods: e.Pods,
+	}
+
+	t.ControllerTemplateMap[e.Name] = controller
+	glog.V(4).Infof("[controller] %+v", controller)
+	return nil
+}
+
+func (e *vnodeEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.VNodeTemplateMap[e.Name]; exist {
+		return fmt.Errorf("vnode [%s] already exists", e.Name)
+	}
+
+	taints, err := parseTaints(e.Taints)
+	if err != nil {
+		return err
+	}
+
+	vnode := &vnodeTemplate{
+		Key:    e.Name,
+		CPU:    e.CPU,
+		Memory: e.Memory * 1024.0,
+		IP:     e.IP,
+		Pods:   e.Pods,
+		Labels: copyLabels(e.Labels),
+		Taints: taints,
+	}
+	if e.Disk != nil {
+		if e.Disk.Storage == "" {
+			return fmt.Errorf("missing storage of the disk")
+		}
+		vnode.Disk = e.Disk.Size
+		vnode.Storage = e.Disk.Storage
+	}
+
+	t.VNodeTemplateMap[e.Name] = vnode
+	glog.V(4).Infof("[vnode] %+v", vnode)
+	return nil
+}
+
+func (e *nodeEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.NodeTemplateMap[e.Name]; exist {
+		return fmt.Errorf("node [%s] already exists", e.Name)
+	}
+
+	node := &nodeTemplate{
+		Key:    e.Name,
+		CPU:    e.CPU,
+		Memory: e.Memory * 1024,
+		IP:     e.IP,
+		VMs:    e.VNodes,
+	}
+
+	t.NodeTemplateMap[e.Name] = node
+	glog.V(4).Infof("[node] %+v", node)
+	return nil
+}
+
+func (e *switchEntry) load(t *TargetTopology) error {
+	if e.Name == "" {
+		return fmt.Errorf("missing key field")
+	}
+	if _, exist := t.SwitchTemplateMap[e.Name]; exist {
+		return fmt.Errorf("switch [%s] already exists", e.Name)
+	}
+	if len(e.Nodes) < 1 {
+		return fmt.Errorf("missing node list in switch declaration")
+	}
+
+	networkswitch := &switchTemplate{
+		Key:               e.Name,
+		NetworkThroughput: e.NetworkThroughput,
+		PMs:               e.Nodes,
+	}
+
+	t.SwitchTemplateMap[e.Name] = networkswitch
+	glog.V(4).Infof("[switch] %+v", networkswitch)
+	return nil
+}
+
+// newTopologyFile converts the templates into the structured format, sorted by key.
+func newTopologyFile(t *TargetTopology) *topologyFile {
+	file := &topologyFile{}
+
+	for _, k := range sortedKeys(t.ContainerTemplateMap) {
+		c := t.ContainerTemplateMap[k]
+		file.Containers = append(file.Containers, &containerEntry{
+			Name:         k,
+			Limits:       resourceEntry{CPU: c.CPU.Capacity, Memory: c.Memory.Capacity / 1024.0},
+			Requests:     resourceEntry{CPU: c.ReqCPU, Memory: c.ReqMem / 1024.0},
+			Usage:        resourceEntry{CPU: c.CPU.Used, Memory: c.Memory.Used / 1024.0},
+			QPS:          metricEntry{Limit: c.QPS.Capacity, Used: c.QPS.Used},
+			ResponseTime: metricEntry{Limit: c.ResponseTime.Capacity, Used: c.ResponseTime.Used},
+			Profile:      newProfileEntry(c.Profile),
+		})
+	}
+
+	for _, k := range sortedKeys(t.PodTemplateMap) {
+		p := t.PodTemplateMap[k]
+		file.Pods = append(file.Pods, &podEntry{
+			Name:         k,
+			Containers:   p.Containers,
+			Labels:       p.Labels,
+			NodeSelector: p.NodeSelector,
+			Affinity:     p.Affinity,
+			AntiAffinity: p.AntiAffinity,
+			Tolerations:  formatTolerations(p.Tolerations),
+			Volumes:      newVolumeEntries(p.Volumes),
+		})
+	}
+
+	for _, k := range sortedKeys(t.ServiceTemplateMap) {
+		file.Services = append(file.Services, &serviceEntry{Name: k, Pods: t.ServiceTemplateMap[k].Pods})
+	}
+
+	for _, k := range sortedKeys(t.NamespaceTemplateMap) {
+		ns := t.NamespaceTemplateMap[k]
+		file.Namespaces = append(file.Namespaces, &namespaceEntry{
+			Name:     k,
+			Limits:   resourceEntry{CPU: ns.LimitCPU, Memory: ns.LimitMem / 1024.0},
+			Requests: resourceEntry{CPU: ns.ReqCPU, Memory: ns.ReqMem / 1024.0},
+			Pods:     ns.Pods,
+		})
+	}
+
+	for _, k := range sortedKeys(t.ControllerTemplateMap) {
+		c := t.ControllerTemplateMap[k]
+		file.Controllers = append(file.Controllers, &controllerEntry{Name: k, Type: c.Type, Pods: c.Pods})
+	}
+
+	for _, k := range sortedKeys(t.VNodeTemplateMap) {
+		v := t.VNodeTemplateMap[k]
+		file.VNodes = append(file.VNodes, &vnodeEntry{
+			Name:   k,
+			CPU:    v.CPU,
+			Memory: v.Memory / 1024.0,
+			IP:     v.IP,
+			Pods:   v.Pods,
+			Labels: v.Labels,
+			Taints: formatTaints(v.Taints),
+			Disk:   newDiskEntry(v),
+		})
+	}
+
+	for _, k := range sortedKeys(t.NodeTemplateMap) {
+		n := t.NodeTemplateMap[k]
+		file.Nodes = append(file.Nodes, &nodeEntry{
+			Name:   k,
+			CPU:    n.CPU,
+			Memory: n.Memory / 1024.0,
+			IP:     n.IP,
+			VNodes: n.VMs,
+		})
+	}
+
+	for _, k := range sortedKeys(t.SwitchTemplateMap) {
+		s := t.SwitchTemplateMap[k]
+		file.Switches = append(file.Switches, &switchEntry{
+			Name:              k,
+			NetworkThroughput: s.NetworkThroughput,
+			Nodes:             s.PMs,
+		})
+	}
+
+	for _, k := range sortedKeys(t.StorageTemplateMap) {
+		s := t.StorageTemplateMap[k]
+		file.Storages = append(file.Storages, &storageEntry{
+			Name:     k,
+			Capacity: s.Capacity,
+			IOPS:     s.IOPS,
+			Nodes:    s.Nodes,
+		})
+	}
+
+	return file
+}
+
+// sortedKeys returns the keys of a template map in order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch templates := m.(type) {
+	case map[string]*containerTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*podTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*serviceTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*namespaceTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*controllerTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*vnodeTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*nodeTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*switchTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	case map[string]*storageTemplate:
+		for k := range templates {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SaveTopology writes the templates into a topology file, in the format selected by the file extension.
+func (t *TargetTopology) SaveTopology(fname string) error {
+	file := newTopologyFile(t)
+
+	var data []byte
+	var err error
+	switch getFormat(fname) {
+	case formatYAML:
+		var buf bytes.Buffer
+		buf.WriteString(fileHeader)
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(file); err == nil {
+			err = encoder.Close()
+		}
+		data = buf.Bytes()
+	case formatJSON:
+		data, err = json.MarshalIndent(file, "", "  ")
+		data = append(data, '\n')
+	default:
+		data = file.marshalLines()
+	}
+	if err != nil {
+		glog.Errorf("failed to save topology to file[%s]: %v", fname, err)
+		return err
+	}
+
+	if err := ioutil.WriteFile(fname, data, 0644); err != nil {
+		glog.Errorf("failed to write file[%s]: %v", fname, err)
+		return err
+	}
+	return nil
+}
+
+// marshalLines writes the topology in the comma-separated format, one section per entity type.
+func (f *topologyFile) marshalLines() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(fileHeader)
+
+	buf.WriteString("\n# container, <containerId>, <limitCPU>, <usedCPU>, <reqCPU>, <limitMem>, <usedMem>, <reqMem>, " +
+		"<limitQPS>, <usedQPS>, <limitResponseTime>, <usedResponseTime>\n")
+	for _, e := range f.Containers {
+		writeLine(&buf, "container", e.Name, formatFloats(e.Limits.CPU, e.Usage.CPU, e.Requests.CPU,
+			e.Limits.Memory, e.Usage.Memory, e.Requests.Memory,
+			e.QPS.Limit, e.QPS.Used, e.ResponseTime.Limit, e.ResponseTime.Used)...)
+	}
+
+	header := "\n# profile, <containerId>, <type>, <name>=<value>, ...\n"
+	for _, e := range f.Containers {
+		if e.Profile != nil {
+			buf.WriteString(header)
+			header = ""
+			writeLine(&buf, "profile", e.Name, e.Profile.fields()...)
+		}
+	}
+
+	buf.WriteString("\n# pod, <podId>, <containerId1>, <containerId2>, ...\n")
+	for _, e := range f.Pods {
+		writeLine(&buf, "pod", e.Name, e.Containers...)
+	}
+
+	if len(f.Services) > 0 {
+		buf.WriteString("\n# service, <serviceId>, <podId1>, <podId2>, ...\n")
+		for _, e := range f.Services {
+			writeLine(&buf, "service", e.Name, e.Pods...)
+		}
+	}
+
+	if len(f.Namespaces) > 0 {
+		buf.WriteString("\n# namespace, <namespaceId>, <limitCPU>, <limitMem>, <reqCPU>, <reqMem>, " +
+			"<podId1>, <podId2>, ...\n")
+		for _, e := range f.Namespaces {
+			fields := formatFloats(e.Limits.CPU, e.Limits.Memory, e.Requests.CPU, e.Requests.Memory)
+			writeLine(&buf, "namespace", e.Name, append(fields, e.Pods...)...)
+		}
+	}
+
+	if len(f.Controllers) > 0 {
+		buf.WriteString("\n# controller, <controllerId>, <type>, <podId1>, <podId2>, ...\n")
+		for _, e := range f.Controllers {
+			writeLine(&buf, "controller", e.Name, append([]string{e.Type}, e.Pods...)...)
+		}
+	}
+
+	buf.WriteString("\n# vnode, <vnodeId>, <cpu_capacity>, <mem_capacity>, <IP>, <podId1>, <podId2>, ...\n")
+	for _, e := range f.VNodes {
+		fields := append(formatFloats(e.CPU, e.Memory), e.IP)
+		writeLine(&buf, "vnode", e.Name, append(fields, e.Pods...)...)
+	}
+
+	f.marshalPlacement(&buf)
+
+	buf.WriteString("\n# node, <nodeId>, <cpu_capacity>, <mem_capacity>, <IP>, <vnodeId1>, <vnodeId2>, ...\n")
+	for _, e := range f.Nodes {
+		fields := append(formatFloats(e.CPU, e.Memory), e.IP)
+		writeLine(&buf, "node", e.Name, append(fields, e.VNodes...)...)
+	}
+
+	if len(f.Switches) > 0 {
+		buf.WriteString("\n# switch, <switchId>, <net_capacity>, <nodeId1>, <nodeId2>, ...\n")
+		for _, e := range f.Switches {
+			writeLine(&buf, "switch", e.Name, append(formatFloats(e.NetworkThroughput), e.Nodes...)...)
+		}
+	}
+
+	f.marshalStorage(&buf)
+
+	return buf.Bytes()
+}
+
+// marshalPlacement writes the labels and placement constraints of the pods and vnodes, one section per type.
+func (f *topologyFile) marshalPlacement(buf *bytes.Buffer) {
+	section := func(header string, lines [][]string) {
+		if len(lines) < 1 {
+			return
+		}
+		buf.WriteString(header)
+		for _, line := range lines {
+			writeLine(buf, line[0], line[1], line[2:]...)
+		}
+	}
+	collect := func(kind string, entries map[string][]string) [][]string {
+		var lines [][]string
+		for _, k := range sortedStrings(entries) {
+			if len(entries[k]) > 0 {
+				lines = append(lines, append([]string{kind, k}, entries[k]...))
+			}
+		}
+		return lines
+	}
+
+	podLabels := make(map[string][]string)
+	nodeSelectors := make(map[string][]string)
+	affinities := make(map[string][]string)
+	antiAffinities := make(map[string][]string)
+	tolerations := make(map[string][]string)
+	for _, e := range f.Pods {
+		podLabels[e.Name] = target.FormatLabels(e.Labels)
+		nodeSelectors[e.Name] = target.FormatLabels(e.NodeSelector)
+		affinities[e.Name] = target.FormatLabels(e.Affinity)
+		antiAffinities[e.Name] = target.FormatLabels(e.AntiAffinity)
+		tolerations[e.Name] = e.Tolerations
+	}
+	vnodeLabels := make(map[string][]string)
+	taints := make(map[string][]string)
+	for _, e := range f.VNodes {
+		vnodeLabels[e.Name] = target.FormatLabels(e.Labels)
+		taints[e.Name] = e.Taints
+	}
+
+	section("\n# vnodeLabels, <vnodeId>, <key>=<value>, ...\n", collect("vnodeLabels", vnodeLabels))
+	section("\n# taints, <vnodeId>, <key>[=<value>], ...\n", collect("taints", taints))
+	section("\n# podLabels, <podId>, <key>=<value>, ...\n", collect("podLabels", podLabels))
+	section("\n# nodeSelector, <podId>, <key>=<value>, ...\n", collect("nodeSelector", nodeSelectors))
+	section("\n# affinity, <podId>, <key>=<value>, ...\n", collect("affinity", affinities))
+	section("\n# antiAffinity, <podId>, <key>=<value>, ...\n", collect("antiAffinity", antiAffinities))
+	section("\n# tolerations, <podId>, <key>[=<value>], ...\n", collect("tolerations", tolerations))
+}
+
+func writeLine(buf *bytes.Buffer, kind, key string, fields ...string) {
+	buf.WriteString(strings.Join(append([]string{kind, key}, fields...), ", "))
+	buf.WriteString("\n")
+}
+
+// formatFloats formats the values with the fewest digits that are read back to the same values
+func formatFloats(values ...float64) []string {
+	var result []string
+	for _, v := range values {
+		result = append(result, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return result
+}
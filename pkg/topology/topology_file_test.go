@@ -0,0 +1,150 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func loadTestTopology(t *testing.T, fname string) *TargetTopology {
+	topo := NewTargetTopology("testCluster")
+	if err := topo.LoadTopology(fname); err != nil {
+		t.Fatalf("load topology[%s] failed: %v", fname, err)
+	}
+	return topo
+}
+
+// sameTemplates compares the templates, regardless of the file they are loaded from
+func sameTemplates(a, b *TargetTopology) bool {
+	return reflect.DeepEqual(a.ContainerTemplateMap, b.ContainerTemplateMap) &&
+		reflect.DeepEqual(a.PodTemplateMap, b.PodTemplateMap) &&
+		reflect.DeepEqual(a.ServiceTemplateMap, b.ServiceTemplateMap) &&
+		reflect.DeepEqual(a.NamespaceTemplateMap, b.NamespaceTemplateMap) &&
+		reflect.DeepEqual(a.ControllerTemplateMap, b.ControllerTemplateMap) &&
+		reflect.DeepEqual(a.VNodeTemplateMap, b.VNodeTemplateMap) &&
+		reflect.DeepEqual(a.NodeTemplateMap, b.NodeTemplateMap) &&
+		reflect.DeepEqual(a.SwitchTemplateMap, b.SwitchTemplateMap) &&
+		reflect.DeepEqual(a.StorageTemplateMap, b.StorageTemplateMap)
+}
+
+func TestTargetTopology_LoadStructuredTopology(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/topology.conf"))
+	topo := loadTestTopology(t, testutil.MakeTestPath("conf/topology.yaml"))
+
+	if !sameTemplates(expected, topo) {
+		t.Errorf("YAML topology is different from the comma-separated one:\n%+v\n%+v", expected, topo)
+	}
+}
+
+func TestTargetTopology_SaveTopology(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/topology.conf"))
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"topology.yaml", "topology.json", "topology.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("topology[%s] is changed after save and load:\n%+v\n%+v", name, expected, topo)
+		}
+	}
+}
+
+func TestTargetTopology_LoadStructuredTopologyFailures(t *testing.T) {
+	content := `containers:
+  - name: containerA
+    limits: {cpu: 200, memory: 305}
+  - name: containerA
+pods:
+  - name: pod-1
+    containers: [containerA]
+  - name: pod-2
+`
+	fname := filepath.Join(testutil.TempDir(t), "bad.yaml")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	output, _ := testutil.GetOutput(func() {
+		NewTargetTopology("testCluster").LoadTopology(fname)
+	})
+	for _, msg := range []string{
+		"/4] container[containerA] failed: container[containerA] already exists.",
+		"/8] pod[pod-2] failed: missing container list in pod declaration",
+	} {
+		if !strings.Contains(output, msg) {
+			t.Errorf("missing error [%s] in output:\n%s", msg, output)
+		}
+	}
+
+	// unknown fields are rejected
+	if err := ioutil.WriteFile(fname, []byte("containers:\n  - name: a\n    limit: {cpu: 1}\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := NewTargetTopology("testCluster").LoadTopology(fname); err == nil {
+		t.Errorf("load topology with unknown field should fail")
+	}
+}
+
+func TestTargetTopology_LoadProfiles(t *testing.T) {
+	expected := loadTestTopology(t, testutil.MakeTestPath("conf/profile.topology.conf"))
+
+	profile := expected.ContainerTemplateMap["containerC"].Profile
+	if profile == nil || profile.Type != target.ProfileSpike || len(profile.Spikes) != 2 ||
+		profile.Spikes[1] != (target.Spike{Start: 40 * time.Minute, Duration: 2 * time.Minute, Factor: 2.5}) {
+		t.Errorf("wrong profile of containerC: %+v", profile)
+	}
+	if profile := expected.ContainerTemplateMap["containerA"].Profile; profile == nil || profile.Phase != 6*time.Hour {
+		t.Errorf("wrong profile of containerA: %+v", profile)
+	}
+
+	dir := testutil.TempDir(t)
+	for _, name := range []string{"profile.yaml", "profile.json", "profile.conf"} {
+		fname := filepath.Join(dir, name)
+		if err := expected.SaveTopology(fname); err != nil {
+			t.Fatalf("save topology[%s] failed: %v", fname, err)
+		}
+
+		topo := loadTestTopology(t, fname)
+		if !sameTemplates(expected, topo) {
+			t.Errorf("profiles in topology[%s] are changed after save and load", name)
+		}
+	}
+}
+
+func TestTargetTopology_LoadProfileFailures(t *testing.T) {
+	tests := map[string]string{
+		"profile, containerX, sine":                              "profile of unknown container[containerX]",
+		"profile, containerA, square":                            "unknown profile type[square]",
+		"profile, containerA, sine, period=1d":                   "invalid period '1d'",
+		"profile, containerA, sine, width=3":                     "unknown profile parameter 'width'",
+		"profile, containerA, spike, period=1h, spike=50m/20m/2": "should be in the period",
+		"profile, containerA, spike, spike=1m/2m":                "should be <start>/<duration>/<factor>",
+		"profile, containerA, sine\nprofile, containerA, linear": "profile of container[containerA] already exists",
+		"profile, containerA, randomWalk, step=0.1, seed=x":      "invalid value of profile parameter 'seed=x'",
+	}
+
+	for line, msg := range tests {
+		content := "container, containerA, 200, 100, 150, 305, 200, 100, 120, 50, 500, 0\n" + line + "\n"
+		fname := filepath.Join(testutil.TempDir(t), "bad.conf")
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		output, _ := testutil.GetOutput(func() {
+			NewTargetTopology("testCluster").LoadTopology(fname)
+		})
+		if !strings.Contains(output, msg) {
+			t.Errorf("load [%s] should fail with [%s], but got:\n%s", line, msg, output)
+		}
+	}
+}
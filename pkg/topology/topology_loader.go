@@ -20,6 +20,24 @@ type serviceTemplate struct {
 	Pods []string
 }
 
+// namespace, with the quotas of the limits and requests of its pods; 0 means no quota
+type namespaceTemplate struct {
+	Key string
+
+	LimitCPU float64
+	LimitMem float64
+	ReqCPU   float64
+	ReqMem   float64
+	Pods     []string
+}
+
+// workload controller, managing its pods as replicas
+type controllerTemplate struct {
+	Key  string
+	Type string
+	Pods []string
+}
+
 type containerTemplate struct {
 	Key    string
 	CPU    target.Resource
@@ -29,11 +47,24 @@ type containerTemplate struct {
 
 	QPS          target.Resource
 	ResponseTime target.Resource
+
+	// optional, changes the usage over time
+	Profile *target.UsageProfile
 }
 
 type podTemplate struct {
 	Key        string
 	Containers []string
+
+	// optional placement constraints, see target.VNode.CheckPlacement()
+	Labels       map[string]string
+	NodeSelector map[string]string
+	Affinity     map[string]string
+	AntiAffinity map[string]string
+	Tolerations  []target.Toleration
+
+	// optional persistent volumes, on the disk of the vnode
+	Volumes []target.Volume
 }
 
 // virtual machine
@@ -44,6 +75,13 @@ type vnodeTemplate struct {
 	Memory float64
 	IP     string
 	Pods   []string
+
+	Labels map[string]string
+	Taints []target.Taint
+
+	// optional disk in MB, on the storage
+	Disk    float64
+	Storage string
 }
 
 // physical machine
@@ -64,12 +102,27 @@ type switchTemplate struct {
 	PMs               []string
 }
 
+// storage, attached to the nodes; capacity in MB
+type storageTemplate struct {
+	Key string
+
+	Capacity float64
+	IOPS     float64
+	Nodes    []string
+}
+
 type TargetTopology struct {
 	ClusterId string
 
 	//serviceTemplate map
 	ServiceTemplateMap map[string]*serviceTemplate
 
+	//namespaceTemplate map
+	NamespaceTemplateMap map[string]*namespaceTemplate
+
+	//controllerTemplate map
+	ControllerTemplateMap map[string]*controllerTemplate
+
 	// containerTemplate map
 	ContainerTemplateMap map[string]*containerTemplate
 
@@ -84,24 +137,39 @@ type TargetTopology struct {
 
 	//switch map
 	SwitchTemplateMap map[string]*switchTemplate
+
+	//storage map
+	StorageTemplateMap map[string]*storageTemplate
+
+	// the loaded file, and the line of each template, key=<kind>/<key>
+	fname     string
+	positions map[string]int
+
+	// errors found while loading the file
+	diagnostics Diagnostics
 }
 
 func NewTargetTopology(clusterId string) *TargetTopology {
 	topo := &TargetTopology{
-		ClusterId:            clusterId,
-		ContainerTemplateMap: make(map[string]*containerTemplate),
-		PodTemplateMap:       make(map[string]*podTemplate),
-		VNodeTemplateMap:     make(map[string]*vnodeTemplate),
-		NodeTemplateMap:      make(map[string]*nodeTemplate),
-		SwitchTemplateMap:    make(map[string]*switchTemplate),
-		ServiceTemplateMap:   make(map[string]*serviceTemplate),
+		ClusterId:             clusterId,
+		ContainerTemplateMap:  make(map[string]*containerTemplate),
+		PodTemplateMap:        make(map[string]*podTemplate),
+		VNodeTemplateMap:      make(map[string]*vnodeTemplate),
+		NodeTemplateMap:       make(map[string]*nodeTemplate),
+		SwitchTemplateMap:     make(map[string]*switchTemplate),
+		StorageTemplateMap:    make(map[string]*storageTemplate),
+		ServiceTemplateMap:    make(map[string]*serviceTemplate),
+		NamespaceTemplateMap:  make(map[string]*namespaceTemplate),
+		ControllerTemplateMap: make(map[string]*controllerTemplate),
+		positions:             make(map[string]int),
 	}
 
 	return topo
 }
 
 // load containerTemplate from a line
-// fields: containerName, req_cpu, used_cpu, req_memory, used_mem, qpsLimit, qpsUsed, responseTimeCap, responseTimeUsed
+// fields: containerName, limit_cpu, used_cpu, req_cpu, limit_memory, used_mem, req_mem, qpsLimit, qpsUsed,
+// and the optional pair: responseTimeCap, responseTimeUsed
 func loadContainer(t *TargetTopology, input *InputLine) error {
 	if _, exist := t.ContainerTemplateMap[input.key]; exist {
 		return fmt.Errorf("container[%s] already exists.", input.key)
@@ -121,8 +189,12 @@ func loadContainer(t *TargetTopology, input *InputLine) error {
 	usedQPS := input.getFloat()
 
 	// ResponseTime
-	limitResponseTime := input.getFloat()
-	usedResponseTime := input.getFloat()
+	limitResponseTime := 0.0
+	usedResponseTime := 0.0
+	if input.RemainingFieldCount() > 0 {
+		limitResponseTime = input.getFloat()
+		usedResponseTime = input.getFloat()
+	}
 
 	container := &containerTemplate{
 		Key: input.key,
@@ -178,7 +250,7 @@ func loadPod(t *TargetTopology, input *InputLine) error {
 	return nil
 }
 
-// load vnodeTemplate from a line
+// load vnodeTemplate from a line; an idle vnode has no pod
 // vnode.key, cpu, memory, IP, pod1, pod2, ...
 func loadVNode(t *TargetTopology, input *InputLine) error {
 	if _, exist := t.VNodeTemplateMap[input.key]; exist {
@@ -191,10 +263,6 @@ func loadVNode(t *TargetTopology, input *InputLine) error {
 	mem := input.getFloat()
 	ip := input.getString()
 
-	if input.RemainingFieldCount() < 1 {
-		return fmt.Errorf("missing pod list in vnode declaration")
-	}
-
 	vnode := &vnodeTemplate{
 		Key:    input.key,
 		CPU:    cpu,
@@ -208,7 +276,7 @@ func loadVNode(t *TargetTopology, input *InputLine) error {
 	return nil
 }
 
-// load nodeTemplate from a line
+// load nodeTemplate from a line; an empty node has no vnode
 // node.key, cpu, memory, IP, vnode1, vnode2, ...
 func loadNode(t *TargetTopology, input *InputLine) error {
 	if _, exist := t.NodeTemplateMap[input.key]; exist {
@@ -221,10 +289,6 @@ func loadNode(t *TargetTopology, input *InputLine) error {
 	mem := input.getFloat()
 	ip := input.getString()
 
-	if input.RemainingFieldCount() < 1 {
-		return fmt.Errorf("missing vnode list in node declaration")
-	}
-
 	node := &nodeTemplate{
 		Key:    input.key,
 		CPU:    cpu,
@@ -264,7 +328,38 @@ func loadSwitch(t *TargetTopology, input *InputLine) error {
 	return nil
 }
 
-// load serviceTemplate from a line
+// load storageTemplate from a line; capacity in MB
+// storage.key, capacity, iops, node1, node2, ...
+func loadStorage(t *TargetTopology, input *InputLine) error {
+	if _, exist := t.StorageTemplateMap[input.key]; exist {
+		err := fmt.Errorf("storage [%s] already exists", input.key)
+		glog.Error(err.Error())
+		return err
+	}
+
+	capacity := input.getFloat()
+	iops := input.getFloat()
+	if input.err != nil {
+		return input.err
+	}
+
+	if input.RemainingFieldCount() < 1 {
+		return fmt.Errorf("missing node list in storage declaration")
+	}
+
+	storage := &storageTemplate{
+		Key:      input.key,
+		Capacity: capacity,
+		IOPS:     iops,
+		Nodes:    input.GetRestOfFields(),
+	}
+
+	t.StorageTemplateMap[input.key] = storage
+	glog.V(4).Infof("[storage] %+v", storage)
+	return nil
+}
+
+// load serviceTemplate from a line; a service has no pod if all its pods are suspended
 // service-key, pod1, pod2, ...
 func loadService(t *TargetTopology, input *InputLine) error {
 	if _, exist := t.ServiceTemplateMap[input.key]; exist {
@@ -273,10 +368,6 @@ func loadService(t *TargetTopology, input *InputLine) error {
 		return err
 	}
 
-	if input.RemainingFieldCount() < 1 {
-		return fmt.Errorf("missing pod list in service declaration")
-	}
-
 	service := &serviceTemplate{
 		Key:  input.key,
 		Pods: input.GetRestOfFields(),
@@ -287,6 +378,65 @@ func loadService(t *TargetTopology, input *InputLine) error {
 	return nil
 }
 
+// load namespaceTemplate from a line; memory in MB, and 0 means no quota
+// namespace-key, limitCPU, limitMem, reqCPU, reqMem, pod1, pod2, ...
+func loadNamespace(t *TargetTopology, input *InputLine) error {
+	if _, exist := t.NamespaceTemplateMap[input.key]; exist {
+		err := fmt.Errorf("namespace[%s] already exists", input.key)
+		glog.Error(err.Error())
+		return err
+	}
+
+	limitCPU := input.getFloat()
+	limitMem := input.getFloat()
+	reqCPU := input.getFloat()
+	reqMem := input.getFloat()
+
+	ns := &namespaceTemplate{
+		Key:      input.key,
+		LimitCPU: limitCPU,
+		LimitMem: limitMem * 1024.0,
+		ReqCPU:   reqCPU,
+		ReqMem:   reqMem * 1024.0,
+		Pods:     input.GetRestOfFields(),
+	}
+
+	if input.err == nil {
+		t.NamespaceTemplateMap[input.key] = ns
+		glog.V(4).Infof("[namespace] %+v", ns)
+	}
+	return input.err
+}
+
+// load controllerTemplate from a line; the type is one of target.ControllerTypes
+// controller-key, type, pod1, pod2, ...
+func loadController(t *TargetTopology, input *InputLine) error {
+	if _, exist := t.ControllerTemplateMap[input.key]; exist {
+		err := fmt.Errorf("controller[%s] already exists", input.key)
+		glog.Error(err.Error())
+		return err
+	}
+
+	ctype, err := target.ParseControllerType(input.getString())
+	if input.err != nil {
+		return input.err
+	}
+	if err != nil {
+		glog.Error(err.Error())
+		return err
+	}
+
+	controller := &controllerTemplate{
+		Key:  input.key,
+		Type: ctype,
+		Pods: input.GetRestOfFields(),
+	}
+
+	t.ControllerTemplateMap[input.key] = controller
+	glog.V(4).Infof("[controller] %+v", controller)
+	return nil
+}
+
 type InputLine struct {
 	err        error
 	line       string // original line
@@ -366,13 +516,29 @@ func noop(_ *TargetTopology, _ *InputLine) error {
 }
 
 var loadHandlers = map[string]HandlerFunction{
-	"container": loadContainer,
-	"pod":       loadPod,
-	"vnode":     loadVNode,
-	"node":      loadNode,
-	"switch":    loadSwitch,
-	"service":   loadService,
-	"comment":   noop,
+	"container":  loadContainer,
+	"pod":        loadPod,
+	"vnode":      loadVNode,
+	"node":       loadNode,
+	"switch":     loadSwitch,
+	"storage":    loadStorage,
+	"service":    loadService,
+	"namespace":  loadNamespace,
+	"controller": loadController,
+	"profile":    loadProfile,
+
+	"labels":       loadLabels,
+	"podLabels":    loadPodLabels,
+	"vnodeLabels":  loadVNodeLabels,
+	"taints":       loadTaints,
+	"tolerations":  loadTolerations,
+	"nodeSelector": loadPodSelector,
+	"affinity":     loadPodSelector,
+	"antiAffinity": loadPodSelector,
+	"disk":         loadDisk,
+	"volume":       loadVolume,
+
+	"comment": noop,
 }
 
 func (t *TargetTopology) parseLine(lineNum int, input *InputLine) error {
@@ -429,10 +595,38 @@ func (t *TargetTopology) PrintTemplateInfo() {
 	glog.V(1).Infof("vnodeTemplate.num=%d", len(t.VNodeTemplateMap))
 	glog.V(1).Infof("nodeTemplate.num=%d", len(t.NodeTemplateMap))
 	glog.V(1).Infof("switchTemplate.num=%d", len(t.SwitchTemplateMap))
+	glog.V(1).Infof("storageTemplate.num=%d", len(t.StorageTemplateMap))
 	glog.V(1).Infof("serviceTemplate.num=%d", len(t.ServiceTemplateMap))
+	glog.V(1).Infof("namespaceTemplate.num=%d", len(t.NamespaceTemplateMap))
+	glog.V(1).Infof("controllerTemplate.num=%d", len(t.ControllerTemplateMap))
 }
 
+// LoadTopology loads the templates from a topology file,
+// in the comma-separated format or the structured format selected by the file extension.
 func (t *TargetTopology) LoadTopology(fname string) error {
+	t.fname = fname
+	var err error
+	if getFormat(fname) == formatComma {
+		err = t.loadLines(fname)
+	} else {
+		err = t.loadStructured(fname)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := t.CheckTemplateEmpty(); err != nil {
+		err := fmt.Errorf("Template checked failed: %v", err)
+		glog.Error(err.Error())
+		return err
+	}
+
+	t.PrintTemplateInfo()
+
+	return nil
+}
+
+func (t *TargetTopology) loadLines(fname string) error {
 	file, err := os.Open(fname)
 	if err != nil {
 		glog.Errorf("failed to open file[%s] for read: %v", fname, err)
@@ -450,6 +644,9 @@ func (t *TargetTopology) LoadTopology(fname string) error {
 		}
 		if err != nil {
 			glog.Errorf("parse [%s/%d] line[%s] failed: %v", fname, lineNum, input.line, err)
+			t.addDiagnostic(lineNum, err.Error())
+		} else if input.command != "comment" {
+			t.setPosition(input.command, input.key, lineNum)
 		}
 	}
 
@@ -458,13 +655,5 @@ func (t *TargetTopology) LoadTopology(fname string) error {
 		return err
 	}
 
-	if err := t.CheckTemplateEmpty(); err != nil {
-		err := fmt.Errorf("Template checked failed: %v", err)
-		glog.Error(err.Error())
-		return err
-	}
-
-	t.PrintTemplateInfo()
-
 	return nil
 }
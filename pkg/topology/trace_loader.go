@@ -0,0 +1,227 @@
+package topology
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"gopkg.in/yaml.v3"
+)
+
+// the columns of a trace in CSV; time and container are required, the others are optional
+var traceColumns = []string{"time", "container", "cpu", "memory", "qps", "responseTime"}
+
+// traceRecord is one row of a trace: the usage of a container at a time; the units are the same as the topology.
+// The time is in RFC3339 (e.g., 2017-10-25T16:30:00Z), or the seconds since epoch.
+type traceRecord struct {
+	Time         string   `yaml:"time" json:"time"`
+	Container    string   `yaml:"container" json:"container"`
+	CPU          *float64 `yaml:"cpu,omitempty" json:"cpu,omitempty"`
+	Memory       *float64 `yaml:"memory,omitempty" json:"memory,omitempty"`
+	QPS          *float64 `yaml:"qps,omitempty" json:"qps,omitempty"`
+	ResponseTime *float64 `yaml:"responseTime,omitempty" json:"responseTime,omitempty"`
+}
+
+// LoadTrace loads the recorded usage of the containers from a CSV file, or a list of records in .yaml/.json;
+// the times are relative to the first one of the trace.
+func LoadTrace(fname string) (*target.Trace, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		err = fmt.Errorf("failed to read trace[%s]: %v", fname, err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	var records []*traceRecord
+	if getFormat(fname) == formatComma {
+		records, err = parseTraceCSV(data)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&records); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to parse trace[%s]: %v", fname, err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+
+	trace, err := newTrace(records)
+	if err != nil {
+		err = fmt.Errorf("invalid trace[%s]: %v", fname, err)
+		glog.Error(err.Error())
+		return nil, err
+	}
+	glog.V(2).Infof("loaded %d records of %d containers from trace[%s]", len(records), len(trace.Keys()), fname)
+	return trace, nil
+}
+
+// parseTraceCSV parses the trace line by line, so that the errors tell the line number; a record does not span lines.
+func parseTraceCSV(data []byte) ([]*traceRecord, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	next := func() ([]string, error) {
+		for scanner.Scan() {
+			lineNum++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			reader := csv.NewReader(strings.NewReader(text))
+			reader.TrimLeadingSpace = true
+			row, err := reader.Read()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			return row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	header, err := next()
+	if err != nil {
+		return nil, fmt.Errorf("missing header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range header {
+		if !contains(traceColumns, strings.TrimSpace(name)) {
+			return nil, fmt.Errorf("unknown column '%s', should be in %v", name, traceColumns)
+		}
+	}
+	for _, name := range traceColumns[:2] {
+		if _, exist := columns[name]; !exist {
+			return nil, fmt.Errorf("missing column '%s'", name)
+		}
+	}
+
+	var records []*traceRecord
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line := lineNum
+		if len(row) != len(header) {
+			return nil, fmt.Errorf("line %d: wrong number of fields", line)
+		}
+
+		field := func(name string) string {
+			if i, exist := columns[name]; exist {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		value := func(name string) (*float64, error) {
+			s := field(name)
+			if s == "" {
+				return nil, nil
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s '%s'", line, name, s)
+			}
+			return &v, nil
+		}
+
+		record := &traceRecord{Time: field("time"), Container: field("container")}
+		for name, v := range map[string]**float64{
+			"cpu":          &record.CPU,
+			"memory":       &record.Memory,
+			"qps":          &record.QPS,
+			"responseTime": &record.ResponseTime,
+		} {
+			if *v, err = value(name); err != nil {
+				return nil, err
+			}
+		}
+		if record.Time == "" || record.Container == "" {
+			return nil, fmt.Errorf("line %d: time and container should not be empty", line)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func newTrace(records []*traceRecord) (*target.Trace, error) {
+	if len(records) < 1 {
+		return nil, fmt.Errorf("no record")
+	}
+
+	times := make([]time.Time, len(records))
+	var first time.Time
+	for i, r := range records {
+		t, err := parseTraceTime(r.Time)
+		if err != nil {
+			return nil, fmt.Errorf("record[%d] of container[%s]: %v", i+1, r.Container, err)
+		}
+		if i == 0 || t.Before(first) {
+			first = t
+		}
+		times[i] = t
+	}
+
+	trace := target.NewTrace()
+	for i, r := range records {
+		if r.Container == "" {
+			return nil, fmt.Errorf("record[%d]: container should not be empty", i+1)
+		}
+
+		sample := target.TraceSample{
+			Time:         times[i].Sub(first),
+			CPU:          recordValue(r.CPU, 1),
+			Memory:       recordValue(r.Memory, 1024), // MB to KB
+			QPS:          recordValue(r.QPS, 1),
+			ResponseTime: recordValue(r.ResponseTime, 1),
+		}
+		trace.Add(r.Container, sample)
+	}
+	return trace, nil
+}
+
+func parseTraceTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s', should be RFC3339 or seconds since epoch", s)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// recordValue returns the value in the unit of the Container; NaN if it is not recorded
+func recordValue(v *float64, unit float64) float64 {
+	if v == nil {
+		return math.NaN()
+	}
+	return *v * unit
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
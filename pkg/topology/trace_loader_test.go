@@ -0,0 +1,97 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+func TestLoadTrace(t *testing.T) {
+	trace, err := LoadTrace(testutil.MakeTestPath("conf/trace.csv"))
+	if err != nil {
+		t.Fatalf("failed to load trace: %v", err)
+	}
+	if keys := strings.Join(trace.Keys(), ","); keys != "containerA,containerB,containerC-pod-3" {
+		t.Errorf("wrong containers in trace: %s", keys)
+	}
+	if trace.Len() != 4 {
+		t.Errorf("trace should have 4 times, but got %d", trace.Len())
+	}
+
+	// the same trace in JSON, with the times in seconds since epoch
+	content := `[
+  {"time": 1508947200, "container": "containerA", "cpu": 100, "memory": 200},
+  {"time": "1508947500", "container": "containerA", "qps": 80}
+]`
+	fname := filepath.Join(testutil.TempDir(t), "trace.json")
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	trace, err = LoadTrace(fname)
+	if err != nil {
+		t.Fatalf("failed to load trace: %v", err)
+	}
+	if trace.Len() != 2 {
+		t.Errorf("trace should have 2 times, but got %d", trace.Len())
+	}
+
+	// replayed on the cluster of the topology
+	cluster := buildTestCluster(t, testutil.MakeTestPath("conf/topology.conf"))
+	player, err := target.NewTracePlayer(trace, target.TraceStep, 0)
+	if err != nil {
+		t.Fatalf("failed to create trace player: %v", err)
+	}
+	if err := cluster.SetTrace(player); err != nil {
+		t.Fatalf("failed to set trace: %v", err)
+	}
+	// QPS is not recorded at first, and is the one of the topology
+	for i, expected := range []float64{50, 80} {
+		player.Next()
+		if _, err := cluster.GenerateDTOs(); err != nil {
+			t.Fatalf("failed to generate DTOs: %v", err)
+		}
+		container := cluster.Nodes["node-1"].VMs["vnode-1"].Pods["pod-1"].Containers[0]
+		if container.QPS.Used != expected {
+			t.Errorf("step[%d]: QPS of %s should be %v, but got %v", i, container.Name, expected, container.QPS.Used)
+		}
+	}
+}
+
+func TestLoadTraceFailures(t *testing.T) {
+	tests := map[string]string{
+		"time, container, cpu\n2017-10-25T16:00:00Z, a, x\n":            "line 2: invalid cpu 'x'",
+		"# trace\ntime, container, cpu\n\n2017-10-25T16:00:00Z, a, x\n": "line 4: invalid cpu 'x'",
+		"time, container, disk\n":                                       "unknown column 'disk'",
+		"container, cpu\na, 1\n":                                        "missing column 'time'",
+		"time, container, cpu\nyesterday, a, 1\n":                       "invalid time 'yesterday'",
+		"time, container, cpu\n2017-10-25T16:00:00Z, , 1\n":             "time and container should not be empty",
+		"time, container, cpu\n":                                        "no record",
+		"time, container, cpu\n2017-10-25T16:00:00Z, a, 1, 2\n":         "wrong number of fields",
+	}
+
+	for content, msg := range tests {
+		fname := filepath.Join(testutil.TempDir(t), "trace.csv")
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := LoadTrace(fname); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("load trace [%s] should fail with [%s], but got: %v", content, msg, err)
+		}
+	}
+}
+
+func TestParseTraceTime(t *testing.T) {
+	if _, err := parseTraceTime("1508947200.5"); err != nil {
+		t.Errorf("failed to parse seconds: %v", err)
+	}
+	t1, _ := parseTraceTime("2017-10-25T16:00:00Z")
+	t2, _ := parseTraceTime("1508947500")
+	if d := t2.Sub(t1); d != 5*time.Minute {
+		t.Errorf("times should be 5 minutes apart, but got %v", d)
+	}
+}
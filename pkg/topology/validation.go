@@ -0,0 +1,532 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/turbonomic/virtualCluster/pkg/target"
+)
+
+// Diagnostic is a problem found in a topology file, at the line of the entity. It is critical if the line fails to
+// parse, or the entity refers to an unknown entity or is duplicated: the cluster cannot be built as the file says.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Message  string
+	Critical bool
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// Diagnostics are all the problems found in a topology file.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	var lines []string
+	for _, diag := range d {
+		lines = append(lines, diag.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (t *TargetTopology) addDiagnostic(line int, format string, args ...interface{}) {
+	t.diagnostics = append(t.diagnostics, &Diagnostic{
+		File:     t.fname,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+		Critical: true,
+	})
+}
+
+func (t *TargetTopology) setPosition(kind, key string, line int) {
+	t.positions[kind+"/"+key] = line
+}
+
+func (t *TargetTopology) getPosition(kind, key string) int {
+	return t.positions[kind+"/"+key]
+}
+
+// Validate returns the errors found while loading the topology, and the problems of the templates:
+// unknown references, entities in more than one or no host, requests greater than limits,
+// usage greater than capacity, limits and requests greater than the namespace quotas, replicas of a controller
+// which differ in their namespace or containers, duplicate IPs, pods violating their placement constraints,
+// and disks on storages not attached to their nodes, or beyond the capacity.
+// The result is in order of line.
+func (t *TargetTopology) Validate() Diagnostics {
+	v := &validator{
+		topology:    t,
+		diagnostics: append(Diagnostics{}, t.diagnostics...),
+	}
+
+	v.checkPods()
+	v.checkVNodes()
+	v.checkNodes()
+	v.checkSwitches()
+	v.checkServices()
+	v.checkNamespaces()
+	v.checkControllers()
+	v.checkContainers()
+	v.checkUsage()
+	v.checkIPs()
+	v.checkPlacement()
+	v.checkStorages()
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return v.diagnostics[i].Line < v.diagnostics[j].Line
+	})
+	return v.diagnostics
+}
+
+type validator struct {
+	topology    *TargetTopology
+	diagnostics Diagnostics
+}
+
+func (v *validator) report(kind, key, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, &Diagnostic{
+		File:    v.topology.fname,
+		Line:    v.topology.getPosition(kind, key),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// reportCritical reports an unknown reference or a duplicate
+func (v *validator) reportCritical(kind, key, format string, args ...interface{}) {
+	v.report(kind, key, format, args...)
+	v.diagnostics[len(v.diagnostics)-1].Critical = true
+}
+
+// checkMembers checks that the members of each host exist, and that each member is in exactly one host;
+// members: key=host, value=keys of the members.
+func (v *validator) checkMembers(hostKind, memberKind string, members map[string][]string,
+	exist func(string) bool, all []string, required bool) {
+	hosts := make(map[string][]string)
+	for _, host := range sortedStrings(members) {
+		for _, member := range members[host] {
+			if !exist(member) {
+				v.reportCritical(hostKind, host, "%s[%s] refers to unknown %s[%s]", hostKind, host, memberKind, member)
+				continue
+			}
+			hosts[member] = append(hosts[member], host)
+		}
+	}
+
+	for _, member := range all {
+		if len(hosts[member]) > 1 {
+			v.reportCritical(memberKind, member, "%s[%s] is in more than one %s: %s",
+				memberKind, member, hostKind, strings.Join(hosts[member], ", "))
+		} else if len(hosts[member]) < 1 && required {
+			v.report(memberKind, member, "%s[%s] is not in any %s", memberKind, member, hostKind)
+		}
+	}
+}
+
+func (v *validator) checkPods() {
+	t := v.topology
+
+	// a container can be used by many pods
+	for _, k := range sortedKeys(t.PodTemplateMap) {
+		for _, container := range t.PodTemplateMap[k].Containers {
+			if _, exist := t.ContainerTemplateMap[container]; !exist {
+				v.reportCritical("pod", k, "pod[%s] refers to unknown container[%s]", k, container)
+			}
+		}
+	}
+}
+
+func (v *validator) checkVNodes() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, vnode := range t.VNodeTemplateMap {
+		members[k] = vnode.Pods
+	}
+	exist := func(key string) bool {
+		_, exist := t.PodTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("vnode", "pod", members, exist, sortedKeys(t.PodTemplateMap), true)
+}
+
+func (v *validator) checkNodes() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, node := range t.NodeTemplateMap {
+		members[k] = node.VMs
+	}
+	exist := func(key string) bool {
+		_, exist := t.VNodeTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("node", "vnode", members, exist, sortedKeys(t.VNodeTemplateMap), true)
+}
+
+func (v *validator) checkSwitches() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, networkswitch := range t.SwitchTemplateMap {
+		members[k] = networkswitch.PMs
+	}
+	exist := func(key string) bool {
+		_, exist := t.NodeTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("switch", "node", members, exist, sortedKeys(t.NodeTemplateMap), false)
+}
+
+func (v *validator) checkServices() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, service := range t.ServiceTemplateMap {
+		members[k] = service.Pods
+	}
+	exist := func(key string) bool {
+		_, exist := t.PodTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("service", "pod", members, exist, sortedKeys(t.PodTemplateMap), false)
+}
+
+// checkNamespaces checks the members of the namespaces, and the limits and requests of their pods
+// against the quotas; no quota if it is 0.
+func (v *validator) checkNamespaces() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, ns := range t.NamespaceTemplateMap {
+		members[k] = ns.Pods
+	}
+	exist := func(key string) bool {
+		_, exist := t.PodTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("namespace", "pod", members, exist, sortedKeys(t.PodTemplateMap), false)
+
+	for _, k := range sortedKeys(t.NamespaceTemplateMap) {
+		ns := t.NamespaceTemplateMap[k]
+		var limitCPU, limitMem, reqCPU, reqMem float64
+		for _, key := range ns.Pods {
+			pod, exist := t.PodTemplateMap[key]
+			if !exist {
+				continue
+			}
+			for _, ckey := range pod.Containers {
+				if c, exist := t.ContainerTemplateMap[ckey]; exist {
+					limitCPU += c.CPU.Capacity
+					limitMem += c.Memory.Capacity
+					reqCPU += c.ReqCPU
+					reqMem += c.ReqMem
+				}
+			}
+		}
+
+		// in MB as in the file
+		quotas := []struct {
+			name        string
+			used, quota float64
+		}{
+			{"CPU limits", limitCPU, ns.LimitCPU},
+			{"Memory limits", limitMem / 1024.0, ns.LimitMem / 1024.0},
+			{"CPU requests", reqCPU, ns.ReqCPU},
+			{"Memory requests", reqMem / 1024.0, ns.ReqMem / 1024.0},
+		}
+		for _, q := range quotas {
+			if q.quota > 0 && q.used > q.quota {
+				v.report("namespace", k, "%s of the pods of namespace[%s] are more than its quota: %.1f > %.1f",
+					q.name, k, q.used, q.quota)
+			}
+		}
+	}
+}
+
+// checkControllers checks the members of the controllers; the replicas of a controller should be in the same
+// namespace, and have the same containers, which make its containerSpecs.
+func (v *validator) checkControllers() {
+	t := v.topology
+	members := make(map[string][]string)
+	for k, controller := range t.ControllerTemplateMap {
+		members[k] = controller.Pods
+	}
+	exist := func(key string) bool {
+		_, exist := t.PodTemplateMap[key]
+		return exist
+	}
+	v.checkMembers("controller", "pod", members, exist, sortedKeys(t.PodTemplateMap), false)
+
+	namespaces := make(map[string]string)
+	for k, ns := range t.NamespaceTemplateMap {
+		for _, pod := range ns.Pods {
+			namespaces[pod] = k
+		}
+	}
+
+	for _, k := range sortedKeys(t.ControllerTemplateMap) {
+		var first *podTemplate
+		firstKey := ""
+		for _, key := range t.ControllerTemplateMap[k].Pods {
+			pod, exist := t.PodTemplateMap[key]
+			if !exist {
+				continue
+			}
+			if first == nil {
+				first, firstKey = pod, key
+				continue
+			}
+			if namespaces[key] != namespaces[firstKey] {
+				v.report("controller", k, "replicas of controller[%s] are in different namespaces: pod[%s] in [%s], pod[%s] in [%s]",
+					k, firstKey, namespaces[firstKey], key, namespaces[key])
+			}
+			if !sameStrings(pod.Containers, first.Containers) {
+				v.report("controller", k, "replicas of controller[%s] have different containers: pod[%s] %v, pod[%s] %v",
+					k, firstKey, first.Containers, key, pod.Containers)
+			}
+		}
+	}
+}
+
+// sameStrings compares two lists regardless of the order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkContainers checks the requests and usage of containers against their limits; no limit if it is 0.
+func (v *validator) checkContainers() {
+	t := v.topology
+	for _, k := range sortedKeys(t.ContainerTemplateMap) {
+		c := t.ContainerTemplateMap[k]
+		if c.CPU.Capacity > 0 {
+			if c.ReqCPU > c.CPU.Capacity {
+				v.report("container", k, "container[%s] requests more CPU than its limit: %.1f > %.1f",
+					k, c.ReqCPU, c.CPU.Capacity)
+			}
+			if c.CPU.Used > c.CPU.Capacity {
+				v.report("container", k, "container[%s] uses more CPU than its limit: %.1f > %.1f",
+					k, c.CPU.Used, c.CPU.Capacity)
+			}
+		}
+
+		// in MB as in the file
+		if c.Memory.Capacity > 0 {
+			if c.ReqMem > c.Memory.Capacity {
+				v.report("container", k, "container[%s] requests more Memory than its limit: %.1f > %.1f",
+					k, c.ReqMem/1024.0, c.Memory.Capacity/1024.0)
+			}
+			if c.Memory.Used > c.Memory.Capacity {
+				v.report("container", k, "container[%s] uses more Memory than its limit: %.1f > %.1f",
+					k, c.Memory.Used/1024.0, c.Memory.Capacity/1024.0)
+			}
+		}
+
+		if c.QPS.Capacity > 0 && c.QPS.Used > c.QPS.Capacity {
+			v.report("container", k, "container[%s] uses more QPS than its limit: %.1f > %.1f",
+				k, c.QPS.Used, c.QPS.Capacity)
+		}
+	}
+}
+
+// checkUsage checks the usage of the containers on each vnode and node against the capacity of the host.
+func (v *validator) checkUsage() {
+	t := v.topology
+
+	type usage struct {
+		cpu    float64
+		memory float64
+	}
+
+	podUsage := func(key string) usage {
+		result := usage{}
+		if pod, exist := t.PodTemplateMap[key]; exist {
+			for _, ckey := range pod.Containers {
+				if c, exist := t.ContainerTemplateMap[ckey]; exist {
+					result.cpu += c.CPU.Used
+					result.memory += c.Memory.Used
+				}
+			}
+		}
+		return result
+	}
+
+	vnodeUsage := make(map[string]usage)
+	for _, k := range sortedKeys(t.VNodeTemplateMap) {
+		vnode := t.VNodeTemplateMap[k]
+		used := usage{}
+		for _, pod := range vnode.Pods {
+			u := podUsage(pod)
+			used.cpu += u.cpu
+			used.memory += u.memory
+		}
+		vnodeUsage[k] = used
+
+		if used.cpu > vnode.CPU {
+			v.report("vnode", k, "CPU used by the pods of vnode[%s] is more than its capacity: %.1f > %.1f",
+				k, used.cpu, vnode.CPU)
+		}
+		if used.memory > vnode.Memory {
+			v.report("vnode", k, "Memory used by the pods of vnode[%s] is more than its capacity: %.1f > %.1f",
+				k, used.memory/1024.0, vnode.Memory/1024.0)
+		}
+	}
+
+	for _, k := range sortedKeys(t.NodeTemplateMap) {
+		node := t.NodeTemplateMap[k]
+		used := usage{}
+		for _, vnode := range node.VMs {
+			used.cpu += vnodeUsage[vnode].cpu
+			used.memory += vnodeUsage[vnode].memory
+		}
+
+		if used.cpu > node.CPU {
+			v.report("node", k, "CPU used by the pods of node[%s] is more than its capacity: %.1f > %.1f",
+				k, used.cpu, node.CPU)
+		}
+		if used.memory > node.Memory {
+			v.report("node", k, "Memory used by the pods of node[%s] is more than its capacity: %.1f > %.1f",
+				k, used.memory/1024.0, node.Memory/1024.0)
+		}
+	}
+}
+
+// checkIPs checks that the IPs of vnodes and nodes are unique.
+func (v *validator) checkIPs() {
+	t := v.topology
+	owners := make(map[string]string)
+
+	check := func(kind, key, ip string) {
+		if ip == "" {
+			return
+		}
+		owner := fmt.Sprintf("%s[%s]", kind, key)
+		if other, exist := owners[ip]; exist {
+			v.reportCritical(kind, key, "IP[%s] of %s is already used by %s", ip, owner, other)
+			return
+		}
+		owners[ip] = owner
+	}
+
+	for _, k := range sortedKeys(t.NodeTemplateMap) {
+		check("node", k, t.NodeTemplateMap[k].IP)
+	}
+	for _, k := range sortedKeys(t.VNodeTemplateMap) {
+		check("vnode", k, t.VNodeTemplateMap[k].IP)
+	}
+}
+
+// checkPlacement checks the node selector, tolerations, affinity and anti-affinity of the pods on each vnode
+func (v *validator) checkPlacement() {
+	t := v.topology
+	for _, k := range sortedKeys(t.VNodeTemplateMap) {
+		tmp := t.VNodeTemplateMap[k]
+		vnode := target.NewVNode(k, k)
+		vnode.Labels = tmp.Labels
+		vnode.Taints = tmp.Taints
+		vnode.Pods = make(map[string]*target.Pod)
+
+		var pods []*target.Pod
+		for _, key := range tmp.Pods {
+			p, exist := t.PodTemplateMap[key]
+			if !exist {
+				continue
+			}
+			pod := target.NewPod(key, key)
+			pod.Labels = p.Labels
+			pod.NodeSelector = p.NodeSelector
+			pod.Affinity = p.Affinity
+			pod.AntiAffinity = p.AntiAffinity
+			pod.Tolerations = p.Tolerations
+			vnode.Pods[key] = pod
+			pods = append(pods, pod)
+		}
+
+		for _, pod := range pods {
+			if err := vnode.CheckPlacement(pod); err != nil {
+				v.report("pod", pod.UUID, "%v", err)
+			}
+		}
+	}
+}
+
+// checkStorages checks the nodes of the storages, and the disks of the vnodes: the storage of a disk should be
+// attached to the node of the vnode, the volumes of the pods should fit in the disk, and the disks in the storage.
+func (v *validator) checkStorages() {
+	t := v.topology
+
+	for _, k := range sortedKeys(t.StorageTemplateMap) {
+		for _, node := range t.StorageTemplateMap[k].Nodes {
+			if _, exist := t.NodeTemplateMap[node]; !exist {
+				v.reportCritical("storage", k, "storage[%s] refers to unknown node[%s]", k, node)
+			}
+		}
+	}
+
+	hosts := make(map[string]string)
+	for _, k := range sortedKeys(t.NodeTemplateMap) {
+		for _, vnode := range t.NodeTemplateMap[k].VMs {
+			hosts[vnode] = k
+		}
+	}
+
+	used := make(map[string]float64)
+	for _, k := range sortedKeys(t.VNodeTemplateMap) {
+		vnode := t.VNodeTemplateMap[k]
+		// the disk line of the comma-separated format, or the vnode
+		kind := "disk"
+		if t.getPosition(kind, k) == 0 {
+			kind = "vnode"
+		}
+
+		volumes := 0.0
+		for _, key := range vnode.Pods {
+			if pod, exist := t.PodTemplateMap[key]; exist {
+				for _, volume := range pod.Volumes {
+					volumes += volume.Size
+				}
+			}
+		}
+		if volumes > vnode.Disk {
+			v.report(kind, k, "volumes of the pods of vnode[%s] are more than its disk: %.1f > %.1f",
+				k, volumes, vnode.Disk)
+		}
+
+		if vnode.Storage == "" {
+			continue
+		}
+		storage, exist := t.StorageTemplateMap[vnode.Storage]
+		if !exist {
+			v.reportCritical(kind, k, "disk of vnode[%s] refers to unknown storage[%s]", k, vnode.Storage)
+			continue
+		}
+		if node, exist := hosts[k]; exist && !contains(storage.Nodes, node) {
+			v.report(kind, k, "storage[%s] of vnode[%s] is not attached to node[%s]", vnode.Storage, k, node)
+		}
+		used[vnode.Storage] += vnode.Disk
+	}
+
+	for _, k := range sortedKeys(t.StorageTemplateMap) {
+		if storage := t.StorageTemplateMap[k]; used[k] > storage.Capacity {
+			v.report("storage", k, "disks on storage[%s] are more than its capacity: %.1f > %.1f",
+				k, used[k], storage.Capacity)
+		}
+	}
+}
+
+func sortedStrings(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package topology
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/turbonomic/virtualCluster/pkg/util"
+)
+
+const invalidTopology = `# problems are found at the line of the entity
+container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+container, containerB, 200, 300, 250, 305, 200, 100, 120, 50
+pod, pod-1, containerA
+pod, pod-2, containerA, containerX
+pod, pod-3, containerB
+pod, pod-4, containerA
+vnode, vnode-1, 5200, 8192, 192.168.1.2, pod-1, pod-2
+vnode, vnode-2, 100, 8192, 192.168.1.2, pod-1, pod-3
+vnode, vnode-3, 5200, 8192, 192.168.1.4
+node, node-1, 10400, 16384, 200.0.0.1, vnode-1, vnode-2, vnode-x
+container, containerA, 200, 100, 150, 305, 200, 100, 120, 50
+`
+
+func TestTargetTopology_Validate(t *testing.T) {
+	fname := filepath.Join(testutil.TempDir(t), "invalid.conf")
+	if err := ioutil.WriteFile(fname, []byte(invalidTopology), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	topo := NewTargetTopology("testCluster")
+	if err := topo.LoadTopology(fname); err != nil {
+		t.Fatalf("load topology failed: %v", err)
+	}
+
+	expected := []struct {
+		line    int
+		message string
+	}{
+		{3, "container[containerB] requests more CPU than its limit: 250.0 > 200.0"},
+		{3, "container[containerB] uses more CPU than its limit: 300.0 > 200.0"},
+		{4, "pod[pod-1] is in more than one vnode: vnode-1, vnode-2"},
+		{5, "pod[pod-2] refers to unknown container[containerX]"},
+		{7, "pod[pod-4] is not in any vnode"},
+		{9, "CPU used by the pods of vnode[vnode-2] is more than its capacity: 400.0 > 100.0"},
+		{9, "IP[192.168.1.2] of vnode[vnode-2] is already used by vnode[vnode-1]"},
+		{10, "vnode[vnode-3] is not in any node"},
+		{11, "node[node-1] refers to unknown vnode[vnode-x]"},
+		{12, "container[containerA] already exists."},
+	}
+
+	diagnostics := topo.Validate()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(expected), len(diagnostics), diagnostics.Error())
+	}
+	for i, e := range expected {
+		d := diagnostics[i]
+		if d.File != fname || d.Line != e.line || d.Message != e.message {
+			t.Errorf("problem %d should be [%d: %s], but got [%v]", i, e.line, e.message, d)
+		}
+	}
+}
+
+func TestTargetTopology_ValidateValid(t *testing.T) {
+	for _, name := range []string{"conf/topology.conf", "conf/topology.yaml"} {
+		topo := loadTestTopology(t, testutil.MakeTestPath(name))
+		if diagnostics := topo.Validate(); len(diagnostics) > 0 {
+			t.Errorf("topology[%s] should be valid, but got:\n%v", name, diagnostics.Error())
+		}
+	}
+}
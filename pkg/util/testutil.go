@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"github.com/golang/glog"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
+	"testing"
 )
 
 // Normalize a local path to point to an absolute path
@@ -14,6 +16,18 @@ func MakeTestPath(localPath string) string {
 	return path.Join(os.Getenv("PWD"), "../../", localPath)
 }
 
+// Create a temporary directory which is removed when the test completes, as T.TempDir() of Go 1.15
+func TempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "vcluster-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
 // Capture output generated by glog
 func GetOutput(fn func()) (string, error) {
 	old := os.Stderr